@@ -203,6 +203,7 @@ var (
 		"{{TYPE}}":        true,
 		EnvironmentToken:  false,
 		"{{DESCRIPTION}}": false,
+		"{{NAMESPACE}}":   false,
 	}
 	schemaPathTemplateTokens = map[string]bool{
 		DBNameToken:      true,
@@ -50,6 +50,11 @@ func TestValidateRepositoryFilePathTemplate(t *testing.T) {
 			"{{ENV_NAME}}/{{DB_NAME}}_{{TYPE}}_{{VERSION}}_{{DESCRIPTION}}.sql",
 			TenantModeDisabled,
 			"",
+		}, {
+			"OK with namespace",
+			"{{VERSION}}_{{NAMESPACE}}##{{DB_NAME}}_{{TYPE}}.sql",
+			TenantModeTenant,
+			"",
 		}, {
 			"Missing {{VERSION}}",
 			"{{DB_NAME}}_{{TYPE}}.sql",
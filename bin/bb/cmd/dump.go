@@ -28,6 +28,7 @@ func newDumpCmd() *cobra.Command {
 		sslCA   string // server-ca.pem
 		sslCert string // client-cert.pem
 		sslKey  string // client-key.pem
+		sslMode string // disable, require or verify-full
 
 		// Dump options.
 		schemaOnly bool
@@ -40,6 +41,7 @@ func newDumpCmd() *cobra.Command {
 				SslCA:   sslCA,
 				SslCert: sslCert,
 				SslKey:  sslKey,
+				SslMode: db.SslMode(sslMode),
 			}
 			out := cmd.OutOrStdout()
 			if file != "" {
@@ -65,6 +67,7 @@ func newDumpCmd() *cobra.Command {
 	dumpCmd.Flags().StringVar(&sslCA, "ssl-ca", "", "CA file in PEM format.")
 	dumpCmd.Flags().StringVar(&sslCert, "ssl-cert", "", "X509 cert in PEM format.")
 	dumpCmd.Flags().StringVar(&sslKey, "ssl-key", "", "X509 key in PEM format.")
+	dumpCmd.Flags().StringVar(&sslMode, "ssl-mode", "", "SSL mode, one of disable, require and verify-full.")
 
 	dumpCmd.Flags().BoolVar(&schemaOnly, "schema-only", false, "Schema only dump.")
 
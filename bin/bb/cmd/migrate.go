@@ -29,11 +29,14 @@ func newMigrateCmd() *cobra.Command {
 		commandList  []string
 		description  string
 		issueID      string
+		// allowDestructive allows the DROP and TRUNCATE statements, and the DELETE statements without WHERE.
+		allowDestructive bool
 
 		// SSL flags.
 		sslCA   string // server-ca.pem
 		sslCert string // client-cert.pem
 		sslKey  string // client-key.pem
+		sslMode string // disable, require or verify-full
 	)
 	migrateCmd := &cobra.Command{
 		Use:   "migrate",
@@ -43,6 +46,7 @@ func newMigrateCmd() *cobra.Command {
 				SslCA:   sslCA,
 				SslCert: sslCert,
 				SslKey:  sslKey,
+				SslMode: db.SslMode(sslMode),
 			}
 
 			var sqlReaders []io.Reader
@@ -62,7 +66,7 @@ func newMigrateCmd() *cobra.Command {
 			}
 
 			sqlReader := io.MultiReader(sqlReaders...)
-			return migrateDatabase(context.Background(), databaseType, username, password, hostname, port, database, description, issueID, false /*createDatabase*/, sqlReader, tlsCfg)
+			return migrateDatabase(context.Background(), databaseType, username, password, hostname, port, database, description, issueID, false /*createDatabase*/, allowDestructive, sqlReader, tlsCfg)
 		}}
 
 	migrateCmd.Flags().StringVar(&databaseType, "type", "mysql", "Database type. (mysql or pg).")
@@ -75,15 +79,17 @@ func newMigrateCmd() *cobra.Command {
 	migrateCmd.Flags().StringSliceVarP(&commandList, "command", "c", []string{}, "SQL command to execute.")
 	migrateCmd.Flags().StringVar(&description, "description", "", "Description of migration.")
 	migrateCmd.Flags().StringVar(&issueID, "issue-id", "", "Issue ID of migration.")
+	migrateCmd.Flags().BoolVar(&allowDestructive, "allow-destructive", false, "Allow DROP, TRUNCATE and DELETE without WHERE statements.")
 	// tls flags for SSL connection.
 	migrateCmd.Flags().StringVar(&sslCA, "ssl-ca", "", "CA file in PEM format.")
 	migrateCmd.Flags().StringVar(&sslCert, "ssl-cert", "", "X509 cert in PEM format.")
 	migrateCmd.Flags().StringVar(&sslKey, "ssl-key", "", "X509 key in PEM format.")
+	migrateCmd.Flags().StringVar(&sslMode, "ssl-mode", "", "SSL mode, one of disable, require and verify-full.")
 
 	return migrateCmd
 }
 
-func migrateDatabase(ctx context.Context, databaseType, username, password, hostname, port, database, description, issueID string, createDatabase bool, sqlReader io.Reader, tlsCfg db.TLSConfig) error {
+func migrateDatabase(ctx context.Context, databaseType, username, password, hostname, port, database, description, issueID string, createDatabase, allowDestructive bool, sqlReader io.Reader, tlsCfg db.TLSConfig) error {
 	var dbType db.Type
 	switch databaseType {
 	case "mysql":
@@ -125,15 +131,16 @@ func migrateDatabase(ctx context.Context, databaseType, username, password, host
 	}
 	// TODO(d): support semantic versioning.
 	if _, _, err := driver.ExecuteMigration(ctx, &db.MigrationInfo{
-		ReleaseVersion: version,
-		Version:        common.DefaultMigrationVersion(),
-		Database:       database,
-		Source:         db.LIBRARY,
-		Type:           db.Migrate,
-		Description:    description,
-		Creator:        migrationCreator,
-		IssueID:        issueID,
-		CreateDatabase: createDatabase,
+		ReleaseVersion:   version,
+		Version:          common.DefaultMigrationVersion(),
+		Database:         database,
+		Source:           db.LIBRARY,
+		Type:             db.Migrate,
+		Description:      description,
+		Creator:          migrationCreator,
+		IssueID:          issueID,
+		CreateDatabase:   createDatabase,
+		AllowDestructive: allowDestructive,
 	}, buf.String()); err != nil {
 		return fmt.Errorf("failed to migrate database, got error: %w", err)
 	}
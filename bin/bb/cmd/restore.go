@@ -25,6 +25,7 @@ func newRestoreCmd() *cobra.Command {
 		sslCA   string // server-ca.pem
 		sslCert string // client-cert.pem
 		sslKey  string // client-key.pem
+		sslMode string // disable, require or verify-full
 	)
 	restoreCmd := &cobra.Command{
 		Use:   "restore",
@@ -34,6 +35,7 @@ func newRestoreCmd() *cobra.Command {
 				SslCA:   sslCA,
 				SslCert: sslCert,
 				SslKey:  sslKey,
+				SslMode: db.SslMode(sslMode),
 			}
 			return restoreDatabase(context.Background(), databaseType, username, password, hostname, port, database, file, tlsCfg)
 		},
@@ -56,6 +58,7 @@ func newRestoreCmd() *cobra.Command {
 	restoreCmd.Flags().StringVar(&sslCA, "ssl-ca", "", "CA file in PEM format.")
 	restoreCmd.Flags().StringVar(&sslCert, "ssl-cert", "", "X509 cert in PEM format.")
 	restoreCmd.Flags().StringVar(&sslKey, "ssl-key", "", "X509 key in PEM format.")
+	restoreCmd.Flags().StringVar(&sslMode, "ssl-mode", "", "SSL mode, one of disable, require and verify-full.")
 
 	return restoreCmd
 }
@@ -25,16 +25,24 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	// Register cassandra driver.
+	_ "github.com/bytebase/bytebase/plugin/db/cassandra"
 	// Register clickhouse driver.
 	_ "github.com/bytebase/bytebase/plugin/db/clickhouse"
+	// Register mongodb driver.
+	_ "github.com/bytebase/bytebase/plugin/db/mongodb"
 	// Register mysql driver.
 	_ "github.com/bytebase/bytebase/plugin/db/mysql"
 	// Register postgres driver.
 	_ "github.com/bytebase/bytebase/plugin/db/pg"
+	// Register mssql driver.
+	_ "github.com/bytebase/bytebase/plugin/db/mssql"
 	// Register snowflake driver.
 	_ "github.com/bytebase/bytebase/plugin/db/snowflake"
 	// Register sqlite driver.
 	_ "github.com/bytebase/bytebase/plugin/db/sqlite"
+	// Register trino driver.
+	_ "github.com/bytebase/bytebase/plugin/db/trino"
 
 	// Register pingcap parser driver.
 	_ "github.com/pingcap/tidb/types/parser_driver"
@@ -385,6 +393,7 @@ func (m *Main) newExternalDB() (*store.DB, error) {
 		SslCA:   q.Get("sslrootcert"),
 		SslKey:  q.Get("sslkey"),
 		SslCert: q.Get("sslcert"),
+		SslMode: dbdriver.SslMode(q.Get("sslmode")),
 	}
 
 	db := store.NewDB(m.l, connCfg, m.profile.demoDataDir, readonly, version, m.profile.mode)
@@ -31,6 +31,8 @@ const (
 	MigrationBaselineMissing Code = 204
 	MigrationPending         Code = 205
 	MigrationFailed          Code = 206
+	// MigrationSchemaAccessDenied means the user has no privilege to check whether the migration schema exists.
+	MigrationSchemaAccessDenied Code = 207
 
 	// 301 task error
 	TaskTimingNotAllowed Code = 301
@@ -69,6 +71,11 @@ func (e *Error) Error() string {
 	return e.Err.Error()
 }
 
+// Unwrap returns the embedded error, so that errors.Is and errors.As can match it.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 // ErrorCode unwraps an application error and returns its code.
 // Non-application errors always return EINTERNAL.
 func ErrorCode(err error) Code {
@@ -7,8 +7,10 @@ require (
 	github.com/VictoriaMetrics/fastcache v1.6.0
 	github.com/blang/semver/v4 v4.0.0
 	github.com/casbin/casbin/v2 v2.40.6
+	github.com/denisenkom/go-mssqldb v0.12.3
 	github.com/github/gh-ost v1.1.4
 	github.com/go-sql-driver/mysql v1.6.0
+	github.com/gocql/gocql v1.7.0
 	github.com/golang-jwt/jwt/v4 v4.0.0
 	github.com/google/go-cmp v0.5.6
 	github.com/google/jsonapi v1.0.0
@@ -23,12 +25,12 @@ require (
 	github.com/qiangmzsx/string-adapter/v2 v2.1.0
 	github.com/snowflakedb/gosnowflake v1.6.3
 	github.com/spf13/cobra v1.2.0
-	github.com/stretchr/testify v1.7.0
+	github.com/stretchr/testify v1.7.1
+	github.com/trinodb/trino-go-client v0.309.0
 	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8
+	go.mongodb.org/mongo-driver v1.13.4
 	go.uber.org/zap v1.19.1
-	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
-	golang.org/x/net v0.0.0-20220127200216-cd36cc0744dd // indirect
-	golang.org/x/sys v0.0.0-20220224003255-dbe011f71a99 // indirect
+	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d
 )
 
 // copied from pingcap/tidb
@@ -0,0 +1,1067 @@
+package cassandra
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytebase/bytebase/common"
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/bytebase/bytebase/plugin/db/util"
+	"github.com/gocql/gocql"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMigrationKeyspace is the keyspace of the migration history if DriverConfig.MigrationHistorySchema is empty.
+	defaultMigrationKeyspace = "bytebase"
+	// migrationHistoryTable is the table of the migration history in the migration keyspace.
+	migrationHistoryTable = "bytebase_migration_history"
+)
+
+var (
+	systemKeyspaces = map[string]bool{
+		"system":                true,
+		"system_auth":           true,
+		"system_distributed":    true,
+		"system_schema":         true,
+		"system_traces":         true,
+		"system_views":          true,
+		"system_virtual_schema": true,
+	}
+
+	// useStatementRegexp matches the USE statement switching the keyspace, e.g. `USE ks` and `USE "Ks"`.
+	useStatementRegexp = regexp.MustCompile(`(?is)^USE\s+("(?:[^"]|"")+"|\w+)$`)
+
+	_ db.Driver                = (*Driver)(nil)
+	_ db.StatementListExecutor = (*Driver)(nil)
+)
+
+func init() {
+	db.Register(db.Cassandra, newDriver)
+}
+
+// Driver is the Cassandra driver, which also supports ScyllaDB.
+//
+// The keyspaces are the databases. The migration history is recorded in the bytebase_migration_history table of the migration keyspace,
+// which is DriverConfig.MigrationHistorySchema or "bytebase" by default.
+// Cassandra has no multi-statement transactions, so the migration history is recorded on a best-effort basis like ClickHouse.
+// The PENDING record is written before the statements run, and it's updated to DONE or FAILED afterward by a separate write.
+// Neither the statements of a migration nor the history writes are atomic: a failed migration leaves the statements before
+// the failing one applied, and a crash in between leaves a PENDING record behind.
+// The duplicate version check reads the history before writing the PENDING record, so the concurrent migrations of the same
+// namespace aren't guarded against each other.
+type Driver struct {
+	l             *zap.Logger
+	driverConfig  db.DriverConfig
+	connectionCtx db.ConnectionContext
+	dbType        db.Type
+
+	// cluster is the config of the session, and its keyspace is the current keyspace.
+	cluster *gocql.ClusterConfig
+	session *gocql.Session
+
+	// migrationSetup caches whether the migration schema has been set up by this driver instance.
+	migrationSetup bool
+}
+
+func newDriver(config db.DriverConfig) db.Driver {
+	return &Driver{
+		l:            config.Logger,
+		driverConfig: config,
+	}
+}
+
+// Open opens a Cassandra driver. The host can be a comma-separated list of the contact points.
+func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.ConnectionConfig, connCtx db.ConnectionContext) (db.Driver, error) {
+	cluster := gocql.NewCluster(strings.Split(config.Host, ",")...)
+	if config.Port != "" {
+		port, err := strconv.Atoi(config.Port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q, error: %w", config.Port, err)
+		}
+		cluster.Port = port
+	}
+	cluster.Keyspace = config.Database
+	if config.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: config.Username,
+			Password: config.Password,
+		}
+	}
+	tlsConfig, err := config.TLSConfig.GetSslConfig()
+	if err != nil {
+		return nil, fmt.Errorf("sql: tls config error: %v", err)
+	}
+	if tlsConfig != nil {
+		cluster.SslOpts = &gocql.SslOptions{
+			Config:                 tlsConfig,
+			EnableHostVerification: !tlsConfig.InsecureSkipVerify,
+		}
+	}
+	if config.ConnectTimeout > 0 {
+		cluster.ConnectTimeout = config.ConnectTimeout
+	}
+
+	driver.l.Debug("Opening Cassandra driver",
+		zap.String("host", config.Host),
+		zap.Int("port", cluster.Port),
+		zap.String("environment", connCtx.EnvironmentName),
+		zap.String("database", connCtx.InstanceName),
+	)
+
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return nil, err
+	}
+	driver.dbType = dbType
+	driver.cluster = cluster
+	driver.session = session
+	driver.connectionCtx = connCtx
+
+	return driver, nil
+}
+
+// Close closes the driver.
+func (driver *Driver) Close(ctx context.Context) error {
+	driver.migrationSetup = false
+	if driver.session != nil {
+		driver.session.Close()
+		driver.session = nil
+	}
+	return nil
+}
+
+// Ping pings the database.
+func (driver *Driver) Ping(ctx context.Context) error {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Ping", driver.dbType, nil)
+	err := driver.session.Query("SELECT release_version FROM system.local").WithContext(ctx).Exec()
+	span.End(err)
+	return err
+}
+
+// Stats returns the zero value, since Cassandra doesn't use the connection pool of database/sql.
+func (*Driver) Stats() sql.DBStats {
+	return sql.DBStats{}
+}
+
+// GetDbConnection returns an error, since Cassandra doesn't support database/sql.
+func (*Driver) GetDbConnection(ctx context.Context, database string) (*sql.DB, error) {
+	return nil, fmt.Errorf("database/sql connection isn't supported for %s", db.Cassandra)
+}
+
+// GetVersion gets the version.
+func (driver *Driver) GetVersion(ctx context.Context) (string, error) {
+	query := "SELECT release_version FROM system.local"
+	var version string
+	if err := driver.session.Query(query).WithContext(ctx).Scan(&version); err != nil {
+		return "", util.FormatErrorWithQuery(err, query)
+	}
+	return version, nil
+}
+
+// useKeyspace switches the session to the keyspace, which reopens the session if the keyspace is changed.
+func (driver *Driver) useKeyspace(keyspace string) error {
+	if driver.cluster.Keyspace == keyspace {
+		return nil
+	}
+	cluster := *driver.cluster
+	cluster.Keyspace = keyspace
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return fmt.Errorf("failed to use keyspace %q, error: %w", keyspace, err)
+	}
+	driver.session.Close()
+	driver.cluster = &cluster
+	driver.session = session
+	return nil
+}
+
+// SyncSchema syncs the schema of the keyspaces. The users aren't synced.
+func (driver *Driver) SyncSchema(ctx context.Context, _ ...string) ([]*db.User, []*db.Schema, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "SyncSchema", driver.dbType, nil)
+	schemaList, err := driver.syncSchema(ctx)
+	span.End(err)
+	return nil, schemaList, err
+}
+
+func (driver *Driver) syncSchema(ctx context.Context) ([]*db.Schema, error) {
+	keyspaceList, err := driver.getKeyspaceList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tableList, err := driver.getTableList(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	// keyspace -> tableList map
+	tableMap := make(map[string][]db.Table)
+	for _, table := range tableList {
+		if table.name == migrationHistoryTable && table.keyspace == driver.migrationKeyspace() {
+			continue
+		}
+		var columnList []db.Column
+		for i, column := range table.columnList {
+			columnList = append(columnList, db.Column{
+				Name:     column.name,
+				Position: i + 1,
+				// The primary key columns can't be null.
+				Nullable: column.kind != "partition_key" && column.kind != "clustering",
+				Type:     column.dataType,
+			})
+		}
+		tableMap[table.keyspace] = append(tableMap[table.keyspace], db.Table{
+			Name:       table.name,
+			Type:       "BASE TABLE",
+			Comment:    table.comment,
+			ColumnList: columnList,
+		})
+	}
+
+	var schemaList []*db.Schema
+	for _, keyspace := range keyspaceList {
+		// Skip our internal "bytebase" keyspace.
+		if keyspace == defaultMigrationKeyspace {
+			continue
+		}
+		schemaList = append(schemaList, &db.Schema{
+			Name:      keyspace,
+			TableList: tableMap[keyspace],
+		})
+	}
+	return schemaList, nil
+}
+
+// getKeyspaceList returns the user keyspaces in order.
+func (driver *Driver) getKeyspaceList(ctx context.Context) ([]string, error) {
+	query := "SELECT keyspace_name FROM system_schema.keyspaces"
+	iter := driver.session.Query(query).WithContext(ctx).Iter()
+	var keyspaceList []string
+	var keyspace string
+	for iter.Scan(&keyspace) {
+		if systemKeyspaces[keyspace] {
+			continue
+		}
+		keyspaceList = append(keyspaceList, keyspace)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	sort.Strings(keyspaceList)
+	return keyspaceList, nil
+}
+
+// tableSchema is the schema of a table.
+type tableSchema struct {
+	keyspace string
+	name     string
+	comment  string
+	// columnList is in the order of the partition key columns, the clustering columns, and the other columns by name.
+	columnList []*columnSchema
+}
+
+// columnSchema is the schema of a column.
+type columnSchema struct {
+	name string
+	// kind is partition_key, clustering, static or regular.
+	kind string
+	// position is the position in the partition key or the clustering columns, and it's -1 for the other columns.
+	position int
+	// clusteringOrder is asc or desc for the clustering columns, and none for the other columns.
+	clusteringOrder string
+	dataType        string
+}
+
+// columnKindOrder is the order of the column kinds in the table.
+var columnKindOrder = map[string]int{
+	"partition_key": 0,
+	"clustering":    1,
+	"static":        2,
+	"regular":       3,
+}
+
+// getTableList returns the tables of the keyspace in order, or the tables of all user keyspaces if the keyspace is empty.
+func (driver *Driver) getTableList(ctx context.Context, keyspace string) ([]*tableSchema, error) {
+	var where string
+	var args []interface{}
+	if keyspace != "" {
+		where = " WHERE keyspace_name = ?"
+		args = append(args, keyspace)
+	}
+
+	query := "SELECT keyspace_name, table_name, comment FROM system_schema.tables" + where
+	iter := driver.session.Query(query, args...).WithContext(ctx).Iter()
+	// keyspace/table -> table map
+	tableMap := make(map[string]*tableSchema)
+	var tableList []*tableSchema
+	for {
+		table := &tableSchema{}
+		if !iter.Scan(&table.keyspace, &table.name, &table.comment) {
+			break
+		}
+		if systemKeyspaces[table.keyspace] {
+			continue
+		}
+		tableMap[fmt.Sprintf("%s/%s", table.keyspace, table.name)] = table
+		tableList = append(tableList, table)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+
+	query = "SELECT keyspace_name, table_name, column_name, kind, position, clustering_order, type FROM system_schema.columns" + where
+	iter = driver.session.Query(query, args...).WithContext(ctx).Iter()
+	for {
+		var keyspaceName, tableName string
+		column := &columnSchema{}
+		if !iter.Scan(&keyspaceName, &tableName, &column.name, &column.kind, &column.position, &column.clusteringOrder, &column.dataType) {
+			break
+		}
+		// The columns of the materialized views don't belong to any table.
+		if table, ok := tableMap[fmt.Sprintf("%s/%s", keyspaceName, tableName)]; ok {
+			table.columnList = append(table.columnList, column)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+
+	sort.Slice(tableList, func(i, j int) bool {
+		if tableList[i].keyspace != tableList[j].keyspace {
+			return tableList[i].keyspace < tableList[j].keyspace
+		}
+		return tableList[i].name < tableList[j].name
+	})
+	for _, table := range tableList {
+		sortColumnList(table.columnList)
+	}
+	return tableList, nil
+}
+
+// sortColumnList sorts the columns in the order of the partition key columns, the clustering columns, and the other columns by name.
+func sortColumnList(columnList []*columnSchema) {
+	sort.SliceStable(columnList, func(i, j int) bool {
+		a, b := columnList[i], columnList[j]
+		if columnKindOrder[a.kind] != columnKindOrder[b.kind] {
+			return columnKindOrder[a.kind] < columnKindOrder[b.kind]
+		}
+		if a.position != b.position {
+			return a.position < b.position
+		}
+		return a.name < b.name
+	})
+}
+
+// createStatement returns the CREATE TABLE statement of the table without the keyspace.
+func (t *tableSchema) createStatement() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (\n", quoteIdentifier(t.name))
+	var partitionKeyList, clusteringList, clusteringOrderList []string
+	for _, column := range t.columnList {
+		fmt.Fprintf(&b, "  %s %s", quoteIdentifier(column.name), column.dataType)
+		if column.kind == "static" {
+			b.WriteString(" STATIC")
+		}
+		b.WriteString(",\n")
+		switch column.kind {
+		case "partition_key":
+			partitionKeyList = append(partitionKeyList, quoteIdentifier(column.name))
+		case "clustering":
+			clusteringList = append(clusteringList, quoteIdentifier(column.name))
+			clusteringOrderList = append(clusteringOrderList, fmt.Sprintf("%s %s", quoteIdentifier(column.name), strings.ToUpper(column.clusteringOrder)))
+		}
+	}
+	primaryKeyList := append([]string{fmt.Sprintf("(%s)", strings.Join(partitionKeyList, ", "))}, clusteringList...)
+	fmt.Fprintf(&b, "  PRIMARY KEY (%s)\n)", strings.Join(primaryKeyList, ", "))
+	if len(clusteringOrderList) > 0 {
+		fmt.Fprintf(&b, " WITH CLUSTERING ORDER BY (%s)", strings.Join(clusteringOrderList, ", "))
+	}
+	if t.comment != "" {
+		if len(clusteringOrderList) > 0 {
+			b.WriteString(" AND")
+		} else {
+			b.WriteString(" WITH")
+		}
+		fmt.Fprintf(&b, " comment = '%s'", strings.ReplaceAll(t.comment, "'", "''"))
+	}
+	b.WriteString(";\n")
+	return b.String()
+}
+
+// quoteIdentifier quotes the identifier, which keeps its case.
+func quoteIdentifier(name string) string {
+	return fmt.Sprintf(`"%s"`, strings.ReplaceAll(name, `"`, `""`))
+}
+
+// parseUseStatement returns the keyspace of the USE statement, and false if the statement isn't a USE statement.
+// The unquoted keyspace is case-insensitive and lower-cased.
+func parseUseStatement(statement string) (string, bool) {
+	matches := useStatementRegexp.FindStringSubmatch(strings.TrimSpace(statement))
+	if matches == nil {
+		return "", false
+	}
+	keyspace := matches[1]
+	if strings.HasPrefix(keyspace, `"`) {
+		return strings.ReplaceAll(keyspace[1:len(keyspace)-1], `""`, `"`), true
+	}
+	return strings.ToLower(keyspace), true
+}
+
+// Execute executes the CQL statements one by one, where the USE statements switch the keyspace.
+// Cassandra doesn't report the rows affected, so the result is always empty.
+func (driver *Driver) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Execute", driver.dbType, nil)
+	result, err := driver.execute(ctx, statement)
+	span.End(err)
+	return result, err
+}
+
+func (driver *Driver) execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	statementList, err := db.SplitStatements(statement, db.Cassandra)
+	if err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return driver.ExecuteStatementList(ctx, statementList)
+}
+
+// GetType returns the database type.
+func (driver *Driver) GetType() db.Type {
+	return driver.dbType
+}
+
+// ExecuteStatementList executes the statements in order without a transaction, so the statements before the failed one stay applied.
+func (driver *Driver) ExecuteStatementList(ctx context.Context, statementList []string) (db.ExecuteResult, error) {
+	for i, statement := range statementList {
+		var err error
+		if keyspace, ok := parseUseStatement(statement); ok {
+			err = driver.useKeyspace(keyspace)
+		} else {
+			err = driver.session.Query(statement).WithContext(ctx).Exec()
+		}
+		if err != nil {
+			return db.ExecuteResult{}, &db.StatementError{Index: i, Statement: statement, Committed: i > 0, Err: err}
+		}
+	}
+	return db.ExecuteResult{}, nil
+}
+
+// ExecuteBatch executes the statements one by one, since Cassandra doesn't support transactions.
+// The statements before the failing one take effect.
+func (driver *Driver) ExecuteBatch(ctx context.Context, statementList []string) error {
+	for i, statement := range statementList {
+		if err := driver.session.Query(statement).WithContext(ctx).Exec(); err != nil {
+			return &db.BatchError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// Query queries a SELECT statement.
+func (driver *Driver) Query(ctx context.Context, statement string, limit int) ([]interface{}, error) {
+	if fields := strings.Fields(statement); len(fields) == 0 || !strings.EqualFold(fields[0], "SELECT") {
+		return nil, fmt.Errorf("only the SELECT statement can be queried for %s", db.Cassandra)
+	}
+	iter := driver.session.Query(statement).WithContext(ctx).Iter()
+	rowData, err := iter.RowData()
+	if err != nil {
+		iter.Close()
+		return nil, err
+	}
+	// The tuple columns are expanded into the elements by RowData.
+	columnTypeNames := []string{}
+	for _, column := range iter.Columns() {
+		if tuple, ok := column.TypeInfo.(gocql.TupleTypeInfo); ok {
+			for _, elem := range tuple.Elems {
+				columnTypeNames = append(columnTypeNames, elem.Type().String())
+			}
+			continue
+		}
+		columnTypeNames = append(columnTypeNames, column.TypeInfo.Type().String())
+	}
+
+	data := []interface{}{}
+	for (limit <= 0 || len(data) < limit) && iter.Scan(rowData.Values...) {
+		rowValues := []interface{}{}
+		for _, value := range rowData.Values {
+			rowValues = append(rowValues, reflect.ValueOf(value).Elem().Interface())
+		}
+		data = append(data, rowValues)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, util.FormatErrorWithQuery(err, statement)
+	}
+	return []interface{}{rowData.Columns, columnTypeNames, data}, nil
+}
+
+// BeginTx returns an error, since Cassandra doesn't support transactions.
+func (*Driver) BeginTx(ctx context.Context) (db.Tx, error) {
+	return nil, fmt.Errorf("transaction isn't supported for %s", db.Cassandra)
+}
+
+// migrationKeyspace returns the keyspace of the migration history.
+func (driver *Driver) migrationKeyspace() string {
+	if driver.driverConfig.MigrationHistorySchema != "" {
+		return driver.driverConfig.MigrationHistorySchema
+	}
+	return defaultMigrationKeyspace
+}
+
+// migrationHistoryTableName returns the qualified name of the migration history table.
+func (driver *Driver) migrationHistoryTableName() string {
+	return fmt.Sprintf("%s.%s", quoteIdentifier(driver.migrationKeyspace()), migrationHistoryTable)
+}
+
+// NeedsSetupMigration returns whether it needs to setup migration.
+func (driver *Driver) NeedsSetupMigration(ctx context.Context) (bool, error) {
+	if driver.migrationSetup {
+		return false, nil
+	}
+	query := "SELECT table_name FROM system_schema.tables WHERE keyspace_name = ? AND table_name = ?"
+	var table string
+	if err := driver.session.Query(query, driver.migrationKeyspace(), migrationHistoryTable).WithContext(ctx).Scan(&table); err != nil {
+		if err == gocql.ErrNotFound {
+			return true, nil
+		}
+		return false, util.FormatErrorWithQuery(err, query)
+	}
+	return false, nil
+}
+
+// SetupMigrationIfNeeded sets up migration if needed.
+// The migration keyspace is created with SimpleStrategy and replication factor 1 if it doesn't exist,
+// so create it beforehand for the other replication settings.
+func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
+	setup, err := driver.NeedsSetupMigration(ctx)
+	if err != nil {
+		return err
+	}
+
+	if setup {
+		driver.l.Info("Bytebase migration schema not found, creating schema...",
+			zap.String("environment", driver.connectionCtx.EnvironmentName),
+			zap.String("database", driver.connectionCtx.InstanceName),
+		)
+		statementList := []string{
+			fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %s WITH replication = {'class': 'SimpleStrategy', 'replication_factor': 1}", quoteIdentifier(driver.migrationKeyspace())),
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	namespace text,
+	id bigint,
+	created_by text,
+	created_ts bigint,
+	updated_by text,
+	updated_ts bigint,
+	release_version text,
+	sequence int,
+	source text,
+	type text,
+	status text,
+	version text,
+	description text,
+	statement text,
+	"schema" text,
+	schema_prev text,
+	execution_duration_ns bigint,
+	issue_id text,
+	payload text,
+	PRIMARY KEY (namespace, id)
+)`, driver.migrationHistoryTableName()),
+		}
+		for _, statement := range statementList {
+			if err := driver.session.Query(statement).WithContext(ctx).Exec(); err != nil {
+				driver.l.Error("Failed to initialize migration schema.",
+					zap.Error(err),
+					zap.String("environment", driver.connectionCtx.EnvironmentName),
+					zap.String("database", driver.connectionCtx.InstanceName),
+				)
+				return util.FormatErrorWithQuery(err, statement)
+			}
+		}
+		driver.l.Info("Successfully created migration schema.",
+			zap.String("environment", driver.connectionCtx.EnvironmentName),
+			zap.String("database", driver.connectionCtx.InstanceName),
+		)
+	}
+
+	driver.migrationSetup = true
+	return nil
+}
+
+// ExecuteMigration will execute the migration.
+// The semantic versions and running the migration as a role aren't supported.
+func (driver *Driver) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "ExecuteMigration", driver.dbType, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := driver.executeMigration(ctx, m, statement)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+func (driver *Driver) executeMigration(ctx context.Context, m *db.MigrationInfo, statement string) (migrationHistoryID int64, updatedSchema string, resErr error) {
+	if err := driver.driverConfig.ValidateStatement(driver.dbType, statement, m); err != nil {
+		return -1, "", err
+	}
+	if m.UseSemanticVersion {
+		return -1, "", fmt.Errorf("semantic versioning isn't supported for %s", db.Cassandra)
+	}
+	if m.RunAsRole != "" {
+		return -1, "", fmt.Errorf("running the migration as role %q isn't supported by the driver", m.RunAsRole)
+	}
+	payload, err := util.BuildMigrationPayload(m, statement)
+	if err != nil {
+		return -1, "", err
+	}
+
+	var prevSchemaBuf bytes.Buffer
+	// Don't record schema if the keyspace hasn't exist yet.
+	if !m.CreateDatabase {
+		if err := driver.Dump(ctx, m.Database, &prevSchemaBuf, true /* schemaOnly */); err != nil {
+			return -1, "", err
+		}
+	}
+
+	// Phase 1 - Precheck before executing migration
+	// Phase 2 - Record migration history as PENDING
+	insertedID, err := driver.beginMigration(ctx, m, prevSchemaBuf.String(), statement, payload)
+	if err != nil {
+		return -1, "", err
+	}
+
+	startedNs := time.Now().UnixNano()
+	defer func() {
+		if err := driver.endMigration(ctx, m.Namespace, insertedID, time.Now().UnixNano()-startedNs, updatedSchema, payload, resErr); err != nil {
+			driver.l.Error("Failed to update migration history record",
+				zap.Error(err),
+				zap.Int64("migration_id", insertedID),
+			)
+		}
+	}()
+
+	// Phase 3 - Executing migration
+	// Baseline migration type could has non-empty sql but will not execute, except for CreateDatabase.
+	if statement != "" && (m.Type != db.Baseline || m.CreateDatabase) {
+		// Switch to the target keyspace only if we're NOT creating this target keyspace.
+		if !m.CreateDatabase {
+			if err := driver.useKeyspace(m.Database); err != nil {
+				return -1, "", err
+			}
+		}
+		if _, err := driver.execute(ctx, statement); err != nil {
+			return -1, "", err
+		}
+	}
+
+	// Phase 4 - Dump the schema after migration
+	var afterSchemaBuf bytes.Buffer
+	if err := driver.Dump(ctx, m.Database, &afterSchemaBuf, true /* schemaOnly */); err != nil {
+		return -1, "", err
+	}
+	return insertedID, afterSchemaBuf.String(), nil
+}
+
+// beginMigration checks before executing migration and inserts a migration history record with pending status.
+func (driver *Driver) beginMigration(ctx context.Context, m *db.MigrationInfo, prevSchema string, statement string, payload string) (int64, error) {
+	historyList, err := driver.findMigrationHistoryList(ctx, &db.MigrationHistoryFind{
+		Database: &m.Namespace,
+	})
+	if err != nil {
+		return -1, err
+	}
+	// Check if the same migration version has already been applied.
+	for _, history := range historyList {
+		if history.Version != m.Version {
+			continue
+		}
+		switch history.Status {
+		case db.Done:
+			if !m.Force {
+				return -1, common.Errorf(common.MigrationAlreadyApplied,
+					fmt.Errorf("%w, database %q has already applied version %s", db.ErrMigrationAlreadyApplied, m.Database, m.Version))
+			}
+			// The forced re-run reuses the applied record, and endMigration updates it as DONE or FAILED again.
+			query := fmt.Sprintf(`UPDATE %s SET updated_by = ?, updated_ts = ?, payload = ? WHERE namespace = ? AND id = ?`, driver.migrationHistoryTableName())
+			if err := driver.session.Query(query, m.Creator, time.Now().Unix(), payload, m.Namespace, int64(history.ID)).WithContext(ctx).Exec(); err != nil {
+				return -1, util.FormatErrorWithQuery(err, query)
+			}
+			return int64(history.ID), nil
+		case db.Pending:
+			return -1, common.Errorf(common.MigrationPending,
+				fmt.Errorf("database %q version %s migration is already in progress", m.Database, m.Version))
+		case db.Failed:
+			return -1, common.Errorf(common.MigrationFailed,
+				fmt.Errorf("database %q version %s migration has failed, please check your database to make sure things are fine and then start a new migration using a new version ", m.Database, m.Version))
+		}
+	}
+	// Check if there is any higher version already been applied since the last baseline or branch.
+	// The history list is the most recent first.
+	largestSequence := 0
+	for _, history := range historyList {
+		if history.Sequence > largestSequence {
+			largestSequence = history.Sequence
+		}
+	}
+	for _, history := range historyList {
+		if db.CompareVersion(history.Version, m.Version) >= 0 {
+			return -1, common.Errorf(common.MigrationOutOfOrder, fmt.Errorf("database %q has already applied version %s which >= %s", m.Database, history.Version, m.Version))
+		}
+		if history.Type == db.Baseline || history.Type == db.Branch {
+			break
+		}
+	}
+
+	// The id is the creation time in nanoseconds, since Cassandra doesn't generate sequential ids.
+	now := time.Now()
+	insertedID := now.UnixNano()
+	query := fmt.Sprintf(`INSERT INTO %s (
+		namespace,
+		id,
+		created_by,
+		created_ts,
+		updated_by,
+		updated_ts,
+		release_version,
+		sequence,
+		source,
+		type,
+		status,
+		version,
+		description,
+		statement,
+		"schema",
+		schema_prev,
+		execution_duration_ns,
+		issue_id,
+		payload
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, driver.migrationHistoryTableName())
+	if err := driver.session.Query(query,
+		m.Namespace,
+		insertedID,
+		m.Creator,
+		now.Unix(),
+		m.Creator,
+		now.Unix(),
+		m.ReleaseVersion,
+		largestSequence+1,
+		string(m.Source),
+		string(m.Type),
+		string(db.Pending),
+		m.Version,
+		m.Description,
+		statement,
+		prevSchema,
+		prevSchema,
+		int64(0),
+		m.IssueID,
+		payload,
+	).WithContext(ctx).Exec(); err != nil {
+		return -1, util.FormatErrorWithQuery(err, query)
+	}
+	return insertedID, nil
+}
+
+// endMigration updates the migration history record to DONE, or FAILED with the error message in the payload if migrationErr isn't nil.
+func (driver *Driver) endMigration(ctx context.Context, namespace string, id int64, migrationDurationNs int64, updatedSchema string, payload string, migrationErr error) error {
+	if migrationErr == nil {
+		query := fmt.Sprintf(`UPDATE %s SET status = ?, execution_duration_ns = ?, "schema" = ?, updated_ts = ? WHERE namespace = ? AND id = ?`, driver.migrationHistoryTableName())
+		if err := driver.session.Query(query, string(db.Done), migrationDurationNs, updatedSchema, time.Now().Unix(), namespace, id).WithContext(ctx).Exec(); err != nil {
+			return util.FormatErrorWithQuery(err, query)
+		}
+		return nil
+	}
+	payload, err := util.SetMigrationPayloadError(payload, migrationErr.Error())
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`UPDATE %s SET status = ?, execution_duration_ns = ?, payload = ?, updated_ts = ? WHERE namespace = ? AND id = ?`, driver.migrationHistoryTableName())
+	if err := driver.session.Query(query, string(db.Failed), migrationDurationNs, payload, time.Now().Unix(), namespace, id).WithContext(ctx).Exec(); err != nil {
+		return util.FormatErrorWithQuery(err, query)
+	}
+	return nil
+}
+
+// Rollback will revert the applied migration version by executing its rollback statement as migration m with ExecuteMigration.
+// The reverted migration is marked as rolled back by m.Version in its payload, and it can't be rolled back again.
+func (driver *Driver) Rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Rollback", driver.dbType, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := driver.rollback(ctx, m, version)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+func (driver *Driver) rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	list, err := driver.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{
+		Database: &m.Namespace,
+		Version:  &version,
+	})
+	if err != nil {
+		return -1, "", fmt.Errorf("failed to find migration history of version %s, error %w", version, err)
+	}
+	if len(list) == 0 {
+		return -1, "", common.Errorf(common.NotFound, fmt.Errorf("database %q has never applied version %s", m.Database, version))
+	}
+	history := list[0]
+	if history.Type == db.Baseline {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s is a baseline, which can't be rolled back", m.Database, version))
+	}
+	if history.Status != db.Done {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration is %s, only the applied migration can be rolled back", m.Database, version, history.Status))
+	}
+	var miPayload db.MigrationInfoPayload
+	if history.Payload != "" {
+		if err := json.Unmarshal([]byte(history.Payload), &miPayload); err != nil {
+			return -1, "", fmt.Errorf("failed to unmarshal migration history payload of version %s, error %w", version, err)
+		}
+	}
+	if miPayload.RolledBackBy != "" {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration has already been rolled back by version %s", m.Database, version, miPayload.RolledBackBy))
+	}
+	if miPayload.RollbackStatement == "" {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration doesn't have a rollback statement", m.Database, version))
+	}
+
+	mi := *m
+	mi.Type = db.Migrate
+	mi.RollbackStatement = ""
+	if mi.Description == "" {
+		mi.Description = fmt.Sprintf("Rollback %s version %s", m.Database, version)
+	}
+	migrationHistoryID, updatedSchema, err := driver.ExecuteMigration(ctx, &mi, miPayload.RollbackStatement)
+	if err != nil {
+		return -1, "", err
+	}
+
+	miPayload.RolledBackBy = mi.Version
+	payloadBytes, err := json.Marshal(miPayload)
+	if err != nil {
+		return -1, "", fmt.Errorf("failed to marshal migration info payload, error %w", err)
+	}
+	query := fmt.Sprintf("UPDATE %s SET payload = ? WHERE namespace = ? AND id = ?", driver.migrationHistoryTableName())
+	if err := driver.session.Query(query, string(payloadBytes), history.Namespace, int64(history.ID)).WithContext(ctx).Exec(); err != nil {
+		return -1, "", fmt.Errorf("database %q version %s migration has been rolled back by version %s, but failed to mark it as rolled back, error %w", m.Database, version, mi.Version, err)
+	}
+	return migrationHistoryID, updatedSchema, nil
+}
+
+// FindMigrationHistoryList finds the migration history, most recent first.
+// Only the namespace is filtered by Cassandra, and the other conditions are filtered by the driver, which avoids ALLOW FILTERING.
+func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.MigrationHistoryFind) ([]*db.MigrationHistory, error) {
+	historyList, err := driver.findMigrationHistoryList(ctx, find)
+	if err != nil {
+		return nil, err
+	}
+	var filteredList []*db.MigrationHistory
+	for _, history := range historyList {
+		if v := find.ID; v != nil && history.ID != *v {
+			continue
+		}
+		if v := find.Version; v != nil && history.Version != *v {
+			continue
+		}
+		if v := find.Source; v != nil && history.Source != *v {
+			continue
+		}
+		if v := find.Status; v != nil && history.Status != *v {
+			continue
+		}
+		if v := find.IssueID; v != nil && history.IssueID != *v {
+			continue
+		}
+		if v := find.Limit; v != nil && len(filteredList) >= *v {
+			break
+		}
+		filteredList = append(filteredList, history)
+	}
+	return filteredList, nil
+}
+
+// findMigrationHistoryList returns the migration history of the namespace of find, or all namespaces if it's nil, most recent first.
+func (driver *Driver) findMigrationHistoryList(ctx context.Context, find *db.MigrationHistoryFind) ([]*db.MigrationHistory, error) {
+	// CQL can't select the empty string literal, so the statement column is left out instead.
+	statementColumn := "statement,\n\t\t"
+	if find.OmitStatement {
+		statementColumn = ""
+	}
+	query := fmt.Sprintf(`SELECT
+		id,
+		created_by,
+		created_ts,
+		updated_by,
+		updated_ts,
+		release_version,
+		namespace,
+		sequence,
+		source,
+		type,
+		status,
+		version,
+		description,
+		%s"schema",
+		schema_prev,
+		execution_duration_ns,
+		issue_id,
+		payload
+	FROM %s`, statementColumn, driver.migrationHistoryTableName())
+	var args []interface{}
+	if v := find.Database; v != nil {
+		query += " WHERE namespace = ?"
+		args = append(args, *v)
+	}
+	iter := driver.session.Query(query, args...).WithContext(ctx).Iter()
+	var historyList []*db.MigrationHistory
+	for {
+		var history db.MigrationHistory
+		var id int64
+		var source, migrationType, status string
+		dest := []interface{}{
+			&id,
+			&history.Creator,
+			&history.CreatedTs,
+			&history.Updater,
+			&history.UpdatedTs,
+			&history.ReleaseVersion,
+			&history.Namespace,
+			&history.Sequence,
+			&source,
+			&migrationType,
+			&status,
+			&history.Version,
+			&history.Description,
+		}
+		if !find.OmitStatement {
+			dest = append(dest, &history.Statement)
+		}
+		dest = append(dest,
+			&history.Schema,
+			&history.SchemaPrev,
+			&history.ExecutionDurationNs,
+			&history.IssueID,
+			&history.Payload,
+		)
+		if !iter.Scan(dest...) {
+			break
+		}
+		history.ID = int(id)
+		history.Source, history.Type, history.Status = db.MigrationSource(source), db.MigrationType(migrationType), db.MigrationStatus(status)
+		if history.Payload != "" {
+			var miPayload db.MigrationInfoPayload
+			if err := json.Unmarshal([]byte(history.Payload), &miPayload); err != nil {
+				iter.Close()
+				return nil, fmt.Errorf("failed to unmarshal migration history payload of version %s, error %w", history.Version, err)
+			}
+			history.ErrorMessage, history.Environment, history.Labels = miPayload.ErrorMessage, miPayload.Environment, miPayload.Labels
+			history.RolledBackBy = miPayload.RolledBackBy
+		}
+		historyList = append(historyList, &history)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	// The id is the creation time in nanoseconds.
+	sort.Slice(historyList, func(i, j int) bool {
+		return historyList[i].ID > historyList[j].ID
+	})
+	return historyList, nil
+}
+
+// Dump and restore
+const (
+	keyspaceHeaderFmt = "" +
+		"--\n" +
+		"-- Cassandra keyspace structure for `%s`\n" +
+		"--\n"
+	tableStmtFmt = "" +
+		"--\n" +
+		"-- Table structure for `%s`\n" +
+		"--\n" +
+		"%s\n"
+)
+
+// Dump dumps the schema of the keyspace, or all user keyspaces if database is empty.
+// schemaOnly isn't supported yet and true by default.
+func (driver *Driver) Dump(ctx context.Context, database string, out io.Writer, schemaOnly bool) error {
+	keyspaceList, err := driver.getKeyspaceList(ctx)
+	if err != nil {
+		return err
+	}
+
+	var dumpableKeyspaceList []string
+	if database != "" {
+		exist := false
+		for _, keyspace := range keyspaceList {
+			if keyspace == database {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			return common.Errorf(common.NotFound, fmt.Errorf("keyspace %s not found", database))
+		}
+		dumpableKeyspaceList = []string{database}
+	} else {
+		dumpableKeyspaceList = keyspaceList
+	}
+
+	for _, keyspace := range dumpableKeyspaceList {
+		// Include "CREATE KEYSPACE" and "USE" if dumping multiple keyspaces.
+		if len(dumpableKeyspaceList) > 1 {
+			stmt, err := driver.getKeyspaceStmt(ctx, keyspace)
+			if err != nil {
+				return fmt.Errorf("failed to get keyspace %q: %s", keyspace, err)
+			}
+			if _, err := io.WriteString(out, fmt.Sprintf(keyspaceHeaderFmt, keyspace)+stmt); err != nil {
+				return err
+			}
+		}
+
+		tableList, err := driver.getTableList(ctx, keyspace)
+		if err != nil {
+			return fmt.Errorf("failed to get tables of keyspace %q: %s", keyspace, err)
+		}
+		for _, table := range tableList {
+			if table.name == migrationHistoryTable && table.keyspace == driver.migrationKeyspace() {
+				continue
+			}
+			if _, err := io.WriteString(out, fmt.Sprintf(tableStmtFmt, table.name, table.createStatement())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getKeyspaceStmt gets the create and use statements of a keyspace.
+func (driver *Driver) getKeyspaceStmt(ctx context.Context, keyspace string) (string, error) {
+	query := "SELECT durable_writes, replication FROM system_schema.keyspaces WHERE keyspace_name = ?"
+	var durableWrites bool
+	var replication map[string]string
+	if err := driver.session.Query(query, keyspace).WithContext(ctx).Scan(&durableWrites, &replication); err != nil {
+		return "", util.FormatErrorWithQuery(err, query)
+	}
+	var optionList []string
+	for key, value := range replication {
+		optionList = append(optionList, fmt.Sprintf("'%s': '%s'", key, value))
+	}
+	sort.Strings(optionList)
+	return fmt.Sprintf("CREATE KEYSPACE %s WITH replication = {%s} AND durable_writes = %t;\nUSE %s;\n\n",
+		quoteIdentifier(keyspace), strings.Join(optionList, ", "), durableWrites, quoteIdentifier(keyspace)), nil
+}
+
+// Restore applies the statements one by one without a transaction, so the statements before a failure stay applied.
+func (driver *Driver) Restore(ctx context.Context, sc *bufio.Scanner) error {
+	f := func(stmt string) error {
+		if _, err := driver.ExecuteStatementList(ctx, []string{stmt}); err != nil {
+			return err
+		}
+		return nil
+	}
+	return db.ApplyStatements(sc, db.Cassandra, f)
+}
@@ -0,0 +1,63 @@
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUseStatement(t *testing.T) {
+	tests := []struct {
+		statement string
+		keyspace  string
+		ok        bool
+	}{
+		{statement: "USE ks", keyspace: "ks", ok: true},
+		{statement: "use KS", keyspace: "ks", ok: true},
+		{statement: `USE "Ks"`, keyspace: "Ks", ok: true},
+		{statement: `USE "a""b"`, keyspace: `a"b`, ok: true},
+		{statement: "  USE\nks  ", keyspace: "ks", ok: true},
+		{statement: "SELECT * FROM users", ok: false},
+		{statement: "USER ks", ok: false},
+	}
+	for _, test := range tests {
+		keyspace, ok := parseUseStatement(test.statement)
+		require.Equal(t, test.ok, ok, test.statement)
+		require.Equal(t, test.keyspace, keyspace, test.statement)
+	}
+}
+
+func TestCreateStatement(t *testing.T) {
+	table := &tableSchema{
+		keyspace: "ks",
+		name:     "events",
+		comment:  "user's events",
+		columnList: []*columnSchema{
+			{name: "payload", kind: "regular", position: -1, clusteringOrder: "none", dataType: "text"},
+			{name: "ts", kind: "clustering", position: 0, clusteringOrder: "desc", dataType: "timestamp"},
+			{name: "bucket", kind: "partition_key", position: 1, clusteringOrder: "none", dataType: "int"},
+			{name: "owner", kind: "static", position: -1, clusteringOrder: "none", dataType: "text"},
+			{name: "user_id", kind: "partition_key", position: 0, clusteringOrder: "none", dataType: "uuid"},
+			{name: "Kind", kind: "regular", position: -1, clusteringOrder: "none", dataType: "text"},
+		},
+	}
+	sortColumnList(table.columnList)
+	require.Equal(t, "CREATE TABLE \"events\" (\n"+
+		"  \"user_id\" uuid,\n"+
+		"  \"bucket\" int,\n"+
+		"  \"ts\" timestamp,\n"+
+		"  \"owner\" text STATIC,\n"+
+		"  \"Kind\" text,\n"+
+		"  \"payload\" text,\n"+
+		"  PRIMARY KEY ((\"user_id\", \"bucket\"), \"ts\")\n"+
+		") WITH CLUSTERING ORDER BY (\"ts\" DESC) AND comment = 'user''s events';\n", table.createStatement())
+
+	table = &tableSchema{
+		keyspace: "ks",
+		name:     "users",
+		columnList: []*columnSchema{
+			{name: "id", kind: "partition_key", position: 0, clusteringOrder: "none", dataType: "uuid"},
+		},
+	}
+	require.Equal(t, "CREATE TABLE \"users\" (\n  \"id\" uuid,\n  PRIMARY KEY ((\"id\"))\n);\n", table.createStatement())
+}
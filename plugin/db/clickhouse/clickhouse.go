@@ -38,17 +38,27 @@ func init() {
 }
 
 // Driver is the ClickHouse driver.
+//
+// ClickHouse has no real transactions, so the migration history is recorded on a best-effort basis.
+// The PENDING record is inserted before the statement runs, and it's marked as DONE or FAILED afterward with an
+// asynchronous mutation (ALTER TABLE ... UPDATE). The migration statement and the history writes aren't atomic:
+// a crash in between leaves a PENDING record behind, and a half-applied migration isn't rolled back.
 type Driver struct {
 	l             *zap.Logger
+	driverConfig  db.DriverConfig
 	connectionCtx db.ConnectionContext
 	dbType        db.Type
 
 	db *sql.DB
+
+	// migrationSetup caches whether the migration schema has been set up by this driver instance.
+	migrationSetup bool
 }
 
 func newDriver(config db.DriverConfig) db.Driver {
 	return &Driver{
-		l: config.Logger,
+		l:            config.Logger,
+		driverConfig: config,
 	}
 }
 
@@ -85,6 +95,7 @@ func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.Connec
 		zap.String("database", connCtx.InstanceName),
 	)
 
+	driver.driverConfig.ApplyConnectionPool(conn)
 	driver.dbType = dbType
 	driver.db = conn
 	driver.connectionCtx = connCtx
@@ -94,12 +105,27 @@ func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.Connec
 
 // Close closes the driver.
 func (driver *Driver) Close(ctx context.Context) error {
-	return driver.db.Close()
+	driver.migrationSetup = false
+	if driver.db != nil {
+		return driver.db.Close()
+	}
+	return nil
 }
 
 // Ping pings the database.
 func (driver *Driver) Ping(ctx context.Context) error {
-	return driver.db.PingContext(ctx)
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Ping", driver.dbType, nil)
+	err := driver.db.PingContext(ctx)
+	span.End(err)
+	return err
+}
+
+// Stats returns the statistics of the connection pool.
+func (driver *Driver) Stats() sql.DBStats {
+	if driver.db == nil {
+		return sql.DBStats{}
+	}
+	return driver.db.Stats()
 }
 
 // GetDbConnection gets a database connection.
@@ -125,7 +151,14 @@ func (driver *Driver) GetVersion(ctx context.Context) (string, error) {
 }
 
 // SyncSchema syncs the schema.
-func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema, error) {
+func (driver *Driver) SyncSchema(ctx context.Context, _ ...string) ([]*db.User, []*db.Schema, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "SyncSchema", driver.dbType, nil)
+	userList, schemaList, err := driver.syncSchema(ctx)
+	span.End(err)
+	return userList, schemaList, err
+}
+
+func (driver *Driver) syncSchema(ctx context.Context) ([]*db.User, []*db.Schema, error) {
 	excludedDatabaseList := []string{
 		// Skip our internal "bytebase" database
 		"'bytebase'",
@@ -187,6 +220,48 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 		}
 	}
 
+	// Query row count and data size of the active parts.
+	// system.tables only reports total_rows and total_bytes for some engines, so we prefer the statistics from system.parts.
+	partWhere := fmt.Sprintf("active AND LOWER(database) NOT IN (%s)", strings.Join(excludedDatabaseList, ", "))
+	query = `
+			SELECT
+				database,
+				table,
+				SUM(rows),
+				SUM(bytes_on_disk)
+			FROM system.parts
+			WHERE ` + partWhere + `
+			GROUP BY database, table`
+	partRows, err := driver.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer partRows.Close()
+
+	// dbName/tableName -> part statistics map
+	type partStat struct {
+		rowCount int64
+		dataSize int64
+	}
+	partStatMap := make(map[string]partStat)
+	for partRows.Next() {
+		var dbName, tableName string
+		var rowCount, dataSize uint64
+		if err := partRows.Scan(
+			&dbName,
+			&tableName,
+			&rowCount,
+			&dataSize,
+		); err != nil {
+			return nil, nil, err
+		}
+		key := fmt.Sprintf("%s/%s", dbName, tableName)
+		partStatMap[key] = partStat{rowCount: int64(rowCount), dataSize: int64(dataSize)}
+	}
+	if err := partRows.Err(); err != nil {
+		return nil, nil, err
+	}
+
 	// Query table info
 	tableWhere := fmt.Sprintf("LOWER(database) NOT IN (%s)", strings.Join(excludedDatabaseList, ", "))
 	query = `
@@ -246,6 +321,12 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 			table.DataSize = totalBytes
 			table.UpdatedTs = lastUpdatedTime.Unix()
 			key := fmt.Sprintf("%s/%s", dbName, name)
+			if stat, ok := partStatMap[key]; ok {
+				table.RowCount = stat.rowCount
+				// The rows of the active parts are exact, while total_rows is NULL for some engines such as Distributed.
+				table.IsRowCountExact = true
+				table.DataSize = stat.dataSize
+			}
 			table.ColumnList = columnMap[key]
 			tableMap[dbName] = append(tableMap[dbName], table)
 		}
@@ -337,29 +418,44 @@ func (driver *Driver) getUserList(ctx context.Context) ([]*db.User, error) {
 }
 
 // Execute executes a SQL statement.
-func (driver *Driver) Execute(ctx context.Context, statement string) error {
+func (driver *Driver) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Execute", driver.dbType, nil)
+	result, err := driver.execute(ctx, statement)
+	span.End(err)
+	return result, err
+}
+
+func (driver *Driver) execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	var result db.ExecuteResult
 	tx, err := driver.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return result, err
 	}
 	defer tx.Rollback()
 
 	f := func(stmt string) error {
-		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		sqlResult, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
 			return err
 		}
+		result.Add(sqlResult)
 		return nil
 	}
 	sc := bufio.NewScanner(strings.NewReader(statement))
 	if err := util.ApplyMultiStatements(sc, f); err != nil {
-		return err
+		return db.ExecuteResult{}, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return err
+		return db.ExecuteResult{}, err
 	}
 
-	return err
+	return result, nil
+}
+
+// ExecuteBatch executes the statements in a single transaction.
+func (driver *Driver) ExecuteBatch(ctx context.Context, statementList []string) error {
+	return util.ExecuteBatch(ctx, driver.db, statementList)
 }
 
 // Query queries a SQL statement.
@@ -367,8 +463,16 @@ func (driver *Driver) Query(ctx context.Context, statement string, limit int) ([
 	return util.Query(ctx, driver.l, driver.db, statement, limit)
 }
 
+// BeginTx begins a transaction.
+func (driver *Driver) BeginTx(ctx context.Context) (db.Tx, error) {
+	return util.BeginTx(ctx, driver.db)
+}
+
 // NeedsSetupMigration returns whether it needs to setup migration.
 func (driver *Driver) NeedsSetupMigration(ctx context.Context) (bool, error) {
+	if driver.migrationSetup {
+		return false, nil
+	}
 	const query = `
 		SELECT
 			1
@@ -390,7 +494,7 @@ func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
 			zap.String("environment", driver.connectionCtx.EnvironmentName),
 			zap.String("database", driver.connectionCtx.InstanceName),
 		)
-		if err := driver.Execute(ctx, migrationSchema); err != nil {
+		if _, err := driver.Execute(ctx, migrationSchema); err != nil {
 			driver.l.Error("Failed to initialize migration schema.",
 				zap.Error(err),
 				zap.String("environment", driver.connectionCtx.EnvironmentName),
@@ -404,38 +508,41 @@ func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
 		)
 	}
 
+	driver.migrationSetup = true
 	return nil
 }
 
-// FindLargestVersionSinceBaseline will find the largest version since last baseline or branch.
-func (driver Driver) FindLargestVersionSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) (*string, error) {
+// FindVersionListSinceBaseline will find the stored versions since last baseline or branch.
+func (driver Driver) FindVersionListSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) ([]string, error) {
 	largestBaselineSequence, err := driver.FindLargestSequence(ctx, tx, namespace, true /* baseline */)
 	if err != nil {
 		return nil, err
 	}
-	const getLargestVersionSinceLastBaselineQuery = `
-		SELECT MAX(version) FROM bytebase.migration_history
+	const getVersionListSinceLastBaselineQuery = `
+		SELECT version FROM bytebase.migration_history
 		WHERE namespace = $1 AND sequence >= $2
 	`
-	row, err := tx.QueryContext(ctx, getLargestVersionSinceLastBaselineQuery,
+	rows, err := tx.QueryContext(ctx, getVersionListSinceLastBaselineQuery,
 		namespace, largestBaselineSequence,
 	)
 	if err != nil {
-		return nil, util.FormatErrorWithQuery(err, getLargestVersionSinceLastBaselineQuery)
+		return nil, util.FormatErrorWithQuery(err, getVersionListSinceLastBaselineQuery)
 	}
-	defer row.Close()
+	defer rows.Close()
 
-	var version sql.NullString
-	row.Next()
-	if err := row.Scan(&version); err != nil {
-		return nil, err
+	var versionList []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versionList = append(versionList, version)
 	}
-
-	if version.Valid {
-		return &version.String, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil, nil
+	return versionList, nil
 }
 
 // FindLargestSequence will return the largest sequence number.
@@ -556,23 +663,52 @@ func (Driver) UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDura
 	return err
 }
 
-// UpdateHistoryAsFailed will update the migration record as failed.
-func (Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, insertedID int64) error {
+// UpdateHistoryAsFailed will update the migration record as failed with the payload recording the error message.
+func (Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, payload string, insertedID int64) error {
 	const updateHistoryAsFailedQuery = `
 		ALTER TABLE
 			bytebase.migration_history
 		UPDATE
 			status = 'FAILED',
-			execution_duration_ns = $1
+			execution_duration_ns = $1,
+			payload = $2
+		WHERE id = $3
+	`
+	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, payload, insertedID)
+	return err
+}
+
+// UpdateHistoryPayload will replace the payload of the migration record.
+func (Driver) UpdateHistoryPayload(ctx context.Context, tx *sql.Tx, payload string, id int64) error {
+	const updateHistoryPayloadQuery = `
+		ALTER TABLE
+			bytebase.migration_history
+		UPDATE
+			payload = $1
 		WHERE id = $2
 	`
-	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, insertedID)
+	_, err := tx.ExecContext(ctx, updateHistoryPayloadQuery, payload, id)
 	return err
 }
 
 // ExecuteMigration will execute the migration.
 func (driver *Driver) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
-	return util.ExecuteMigration(ctx, driver.l, driver, m, statement)
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "ExecuteMigration", driver.dbType, db.MigrationSpanAttributes(m))
+	if err := driver.driverConfig.ValidateStatement(driver.dbType, statement, m); err != nil {
+		span.End(err)
+		return -1, "", err
+	}
+	migrationHistoryID, updatedSchema, err := util.ExecuteMigration(ctx, driver.l, driver, m, statement)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+// Rollback will revert the applied migration version.
+func (driver *Driver) Rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Rollback", driver.dbType, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := util.Rollback(ctx, driver.l, driver, m, version)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
 }
 
 // FindMigrationHistoryList finds the migration history.
@@ -592,7 +728,7 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 		status,
 		version,
 		description,
-		statement,
+		` + util.MigrationHistoryStatementColumn(find) + `,
 		` + "`schema`," + `
 		schema_prev,
 		execution_duration_ns,
@@ -617,6 +753,12 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 	if v := find.Source; v != nil {
 		paramNames, params = append(paramNames, "source"), append(params, *v)
 	}
+	if v := find.Status; v != nil {
+		paramNames, params = append(paramNames, "status"), append(params, *v)
+	}
+	if v := find.IssueID; v != nil {
+		paramNames, params = append(paramNames, "issue_id"), append(params, *v)
+	}
 	var query = baseQuery +
 		db.FormatParamNameInNumberedPosition(paramNames) +
 		`ORDER BY created_ts DESC`
@@ -871,13 +1013,13 @@ func (driver *Driver) Restore(ctx context.Context, sc *bufio.Scanner) (err error
 	defer txn.Rollback()
 
 	f := func(stmt string) error {
-		if _, err := txn.Exec(stmt); err != nil {
+		if _, err := txn.ExecContext(ctx, stmt); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	if err := util.ApplyMultiStatements(sc, f); err != nil {
+	if err := db.ApplyStatements(sc, db.ClickHouse, f); err != nil {
 		return err
 	}
 
@@ -0,0 +1,267 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FindDestructiveStatement returns the first destructive statement in the SQL text, or an empty string if there is none.
+// The destructive statements are the DROP and TRUNCATE statements, the ALTER TABLE statements dropping or truncating columns or partitions,
+// and the DELETE and UPDATE statements without a top-level WHERE, including the ones after a WITH clause and in its common table expressions.
+// The statements in a routine body aren't checked.
+// For MongoDB, the destructive commands are drop, dropDatabase, dropIndexes, and delete with an empty filter.
+// The text is split by SplitStatements, and it returns an error if the text can't be split.
+func FindDestructiveStatement(text string, dbType Type) (string, error) {
+	statementList, err := SplitStatements(text, dbType)
+	if err != nil {
+		return "", err
+	}
+	for _, statement := range statementList {
+		if dbType == MongoDB {
+			destructive, err := isDestructiveMongoDBCommand(statement)
+			if err != nil {
+				return "", err
+			}
+			if destructive {
+				return statement, nil
+			}
+			continue
+		}
+		if isDestructiveStatement(statementWordList(statement, dbType)) {
+			return statement, nil
+		}
+	}
+	return "", nil
+}
+
+// nonDestructiveAlterTableDrops are the words following DROP in ALTER TABLE that don't drop any data,
+// e.g. DROP CONSTRAINT and ALTER COLUMN c DROP NOT NULL. The other words such as COLUMN and PARTITION drop data,
+// and so does a column name, since COLUMN is optional in MySQL.
+var nonDestructiveAlterTableDrops = map[string]bool{
+	"CONSTRAINT": true,
+	"INDEX":      true,
+	"KEY":        true,
+	"PRIMARY":    true,
+	"FOREIGN":    true,
+	"CHECK":      true,
+	"DEFAULT":    true,
+	"NOT":        true,
+	"IDENTITY":   true,
+	"EXPRESSION": true,
+	"PERIOD":     true,
+	"SYSTEM":     true,
+}
+
+// isDestructiveStatement returns whether the statement with the word list returned by statementWordList is destructive.
+func isDestructiveStatement(wordList []string) bool {
+	if len(wordList) == 0 {
+		return false
+	}
+	switch wordList[0] {
+	case "DROP", "TRUNCATE":
+		return true
+	case "ALTER":
+		return len(wordList) > 1 && wordList[1] == "TABLE" && isDestructiveAlterTable(wordList[2:])
+	case "DELETE", "UPDATE":
+		// The WHERE in the subqueries doesn't limit the rows, including the MySQL multi-table DELETE without WHERE.
+		return !hasTopLevelWord(wordList[1:], "WHERE")
+	case "WITH":
+		return isDestructiveWith(wordList[1:])
+	}
+	return false
+}
+
+// isDestructiveAlterTable returns whether the clauses of the ALTER TABLE statement drop or truncate data.
+func isDestructiveAlterTable(wordList []string) bool {
+	depth := 0
+	for i, word := range wordList {
+		switch word {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case "TRUNCATE":
+			if depth == 0 {
+				return true
+			}
+		case "DROP":
+			if depth == 0 && (i+1 == len(wordList) || !nonDestructiveAlterTableDrops[wordList[i+1]]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDestructiveWith returns whether the statement after WITH is destructive, which is the case
+// if either the statement following the common table expressions or any of them is destructive,
+// e.g. WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d in Postgres.
+func isDestructiveWith(wordList []string) bool {
+	depth, start := 0, 0
+	afterGroup := false
+	for i, word := range wordList {
+		switch word {
+		case "(":
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				if isDestructiveStatement(wordList[start:i]) {
+					return true
+				}
+				afterGroup = true
+			}
+		default:
+			if depth != 0 || !afterGroup {
+				continue
+			}
+			// The name of the next common table expression is followed by AS or its column list.
+			if i+1 < len(wordList) && (wordList[i+1] == "AS" || wordList[i+1] == "(") {
+				afterGroup = false
+				continue
+			}
+			return isDestructiveStatement(wordList[i:])
+		}
+	}
+	return false
+}
+
+// hasTopLevelWord returns whether the word is in the word list outside of the parentheses.
+func hasTopLevelWord(wordList []string, target string) bool {
+	depth := 0
+	for _, word := range wordList {
+		switch word {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case target:
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDestructiveMongoDBCommand returns whether the MongoDB command document is destructive.
+// The command name is the first key of the document.
+func isDestructiveMongoDBCommand(command string) (bool, error) {
+	decoder := json.NewDecoder(strings.NewReader(command))
+	// Skip the "{" token.
+	if _, err := decoder.Token(); err != nil {
+		return false, fmt.Errorf("invalid MongoDB command %s, error: %w", command, err)
+	}
+	token, err := decoder.Token()
+	if err != nil {
+		return false, fmt.Errorf("invalid MongoDB command %s, error: %w", command, err)
+	}
+	name, ok := token.(string)
+	if !ok {
+		// It's the empty document.
+		return false, nil
+	}
+	switch name {
+	case "drop", "dropDatabase", "dropIndexes":
+		return true, nil
+	case "delete":
+		var deleteCommand struct {
+			Deletes []struct {
+				Q map[string]json.RawMessage `json:"q"`
+			} `json:"deletes"`
+		}
+		if err := json.Unmarshal([]byte(command), &deleteCommand); err != nil {
+			return false, fmt.Errorf("invalid MongoDB command %s, error: %w", command, err)
+		}
+		for _, d := range deleteCommand.Deletes {
+			if len(d.Q) == 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// checkDestructiveStatement returns an error wrapping ErrDestructiveStatement if the statement is destructive and m doesn't allow it.
+func checkDestructiveStatement(dbType Type, statement string, m *MigrationInfo) error {
+	if m.AllowDestructive {
+		return nil
+	}
+	destructive, err := FindDestructiveStatement(statement, dbType)
+	if err != nil {
+		return fmt.Errorf("failed to check destructive statements, error: %w", err)
+	}
+	if destructive != "" {
+		return fmt.Errorf("%w %q isn't allowed without AllowDestructive", ErrDestructiveStatement, destructive)
+	}
+	return nil
+}
+
+// statementWordList returns the upper-cased words and the parentheses of the statement outside of the string literals, quoted identifiers and comments,
+// following the same quoting and comment rules as ApplyStatements.
+func statementWordList(statement string, dbType Type) []string {
+	s := newStatementSplitter(dbType)
+	var wordList []string
+	for i := 0; i < len(statement); {
+		rest := statement[i:]
+		switch {
+		case s.isLineComment(rest):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				return wordList
+			}
+			i += end + 1
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				return wordList
+			}
+			i += end + 4
+		case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`':
+			quote := rest[0]
+			j := 1
+			for j < len(rest) {
+				if s.isMySQL && quote != '`' && rest[j] == '\\' {
+					j += 2
+					continue
+				}
+				if rest[j] == quote {
+					if j+1 < len(rest) && rest[j+1] == quote {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			i += j + 1
+		case s.isPostgres && rest[0] == '$':
+			if tag, ok := s.dollarQuoteTag(statement, i); ok {
+				end := strings.Index(rest[len(tag):], tag)
+				if end < 0 {
+					return wordList
+				}
+				i += len(tag) + end + len(tag)
+			} else {
+				i++
+			}
+		case isIdentifierChar(rest[0]):
+			j := 1
+			for j < len(rest) && (isIdentifierChar(rest[j]) || rest[j] == '$') {
+				j++
+			}
+			wordList = append(wordList, strings.ToUpper(rest[:j]))
+			i += j
+		case rest[0] == '(' || rest[0] == ')':
+			wordList = append(wordList, rest[:1])
+			i++
+		default:
+			i++
+		}
+	}
+	return wordList
+}
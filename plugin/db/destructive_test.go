@@ -0,0 +1,130 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDestructiveStatement(t *testing.T) {
+	tests := []struct {
+		text   string
+		dbType Type
+		want   string
+	}{
+		{
+			text:   "CREATE TABLE t (id INT); INSERT INTO t VALUES (1);",
+			dbType: MySQL,
+			want:   "",
+		},
+		{
+			text:   "CREATE TABLE t (id INT);\n-- cleanup\ndrop table old_t;",
+			dbType: MySQL,
+			want:   "-- cleanup\ndrop table old_t",
+		},
+		{
+			text:   "TRUNCATE t",
+			dbType: Postgres,
+			want:   "TRUNCATE t",
+		},
+		{
+			text:   "DELETE FROM t WHERE id = 1; DELETE FROM t",
+			dbType: MySQL,
+			want:   "DELETE FROM t",
+		},
+		{
+			// WHERE in a string literal or a comment doesn't count.
+			text:   "DELETE FROM t /* WHERE id = 1 */; SELECT 'WHERE'",
+			dbType: MySQL,
+			want:   "DELETE FROM t /* WHERE id = 1 */",
+		},
+		{
+			text:   `DELETE FROM "where"`,
+			dbType: Postgres,
+			want:   `DELETE FROM "where"`,
+		},
+		{
+			// The statements in a routine body aren't checked.
+			text:   "CREATE FUNCTION f() RETURNS void AS $$ DELETE FROM t; $$ LANGUAGE SQL",
+			dbType: Postgres,
+			want:   "",
+		},
+		{
+			text:   "ALTER TABLE t DROP COLUMN c",
+			dbType: MySQL,
+			want:   "ALTER TABLE t DROP COLUMN c",
+		},
+		{
+			// COLUMN is optional in MySQL.
+			text:   "ALTER TABLE t ADD COLUMN d INT, DROP `c`",
+			dbType: MySQL,
+			want:   "ALTER TABLE t ADD COLUMN d INT, DROP `c`",
+		},
+		{
+			text:   "ALTER TABLE t DROP PARTITION p0",
+			dbType: MySQL,
+			want:   "ALTER TABLE t DROP PARTITION p0",
+		},
+		{
+			text:   "ALTER TABLE t DROP INDEX idx, DROP FOREIGN KEY fk; ALTER TABLE t ALTER COLUMN c DROP NOT NULL, ALTER COLUMN d DROP DEFAULT",
+			dbType: Postgres,
+			want:   "",
+		},
+		{
+			text:   "WITH old AS (SELECT id FROM t WHERE created_ts < 0) DELETE FROM t USING old",
+			dbType: Postgres,
+			want:   "WITH old AS (SELECT id FROM t WHERE created_ts < 0) DELETE FROM t USING old",
+		},
+		{
+			text:   "WITH RECURSIVE a(id) AS (SELECT 1), b AS (SELECT 2) DELETE FROM t WHERE id IN (SELECT id FROM a)",
+			dbType: Postgres,
+			want:   "",
+		},
+		{
+			// The data-modifying common table expressions are checked too.
+			text:   "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d",
+			dbType: Postgres,
+			want:   "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d",
+		},
+		{
+			// WHERE in a subquery doesn't count.
+			text:   "UPDATE t SET a = (SELECT MAX(a) FROM s WHERE s.id = 1)",
+			dbType: MySQL,
+			want:   "UPDATE t SET a = (SELECT MAX(a) FROM s WHERE s.id = 1)",
+		},
+		{
+			text:   "UPDATE t SET a = 1 WHERE id = 1",
+			dbType: MySQL,
+			want:   "",
+		},
+		{
+			text:   "DELETE t1, t2 FROM t1 INNER JOIN t2 ON t1.id = t2.id WHERE t1.id = 1; DELETE t1 FROM t1 INNER JOIN (SELECT id FROM t2 WHERE a = 1) s ON t1.id = s.id",
+			dbType: MySQL,
+			want:   "DELETE t1 FROM t1 INNER JOIN (SELECT id FROM t2 WHERE a = 1) s ON t1.id = s.id",
+		},
+		{
+			text:   `{"createIndexes": "t", "indexes": [{"key": {"a": 1}, "name": "drop"}]} {"delete": "t", "deletes": [{"q": {"a": 1}, "limit": 0}]}`,
+			dbType: MongoDB,
+			want:   "",
+		},
+		{
+			text:   `{"insert": "t", "documents": [{"a": 1}]}` + "\n" + `{"dropIndexes": "t", "index": "a_1"}`,
+			dbType: MongoDB,
+			want:   `{"dropIndexes": "t", "index": "a_1"}`,
+		},
+		{
+			text:   `{"delete": "t", "deletes": [{"q": {"a": 1}, "limit": 0}, {"q": {}, "limit": 0}]}`,
+			dbType: MongoDB,
+			want:   `{"delete": "t", "deletes": [{"q": {"a": 1}, "limit": 0}, {"q": {}, "limit": 0}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := FindDestructiveStatement(test.text, test.dbType)
+		require.NoError(t, err)
+		require.Equal(t, test.want, got, test.text)
+	}
+
+	_, err := FindDestructiveStatement("DROP TABLE 't", MySQL)
+	require.Error(t, err)
+}
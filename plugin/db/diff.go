@@ -0,0 +1,297 @@
+package db
+
+import (
+	"sort"
+)
+
+// SchemaChangeType is the type of a schema change.
+type SchemaChangeType string
+
+const (
+	// SchemaChangeAdd is the schema change type for ADD.
+	SchemaChangeAdd SchemaChangeType = "ADD"
+	// SchemaChangeDrop is the schema change type for DROP.
+	SchemaChangeDrop SchemaChangeType = "DROP"
+	// SchemaChangeModify is the schema change type for MODIFY.
+	SchemaChangeModify SchemaChangeType = "MODIFY"
+)
+
+// TableChange is a table level change.
+// OldTable is nil for SchemaChangeAdd and NewTable is nil for SchemaChangeDrop.
+// A modified table only reports the changes of its own attributes (engine, collation, comment),
+// the column, index and foreign key changes are reported in ColumnChangeList, IndexChangeList and ForeignKeyChangeList.
+type TableChange struct {
+	Type     SchemaChangeType
+	Name     string
+	OldTable *Table
+	NewTable *Table
+}
+
+// ColumnChange is a column change of a table existing in both schemas.
+// OldColumn is nil for SchemaChangeAdd and NewColumn is nil for SchemaChangeDrop.
+type ColumnChange struct {
+	Type      SchemaChangeType
+	TableName string
+	Name      string
+	OldColumn *Column
+	NewColumn *Column
+}
+
+// IndexChange is an index change of a table existing in both schemas.
+// The index list contains one entry per index column ordered by position,
+// OldIndexList is empty for SchemaChangeAdd and NewIndexList is empty for SchemaChangeDrop.
+type IndexChange struct {
+	Type         SchemaChangeType
+	TableName    string
+	Name         string
+	OldIndexList []Index
+	NewIndexList []Index
+}
+
+// ForeignKeyChange is a foreign key change of a table existing in both schemas.
+// OldForeignKey is nil for SchemaChangeAdd and NewForeignKey is nil for SchemaChangeDrop.
+type ForeignKeyChange struct {
+	Type          SchemaChangeType
+	TableName     string
+	Name          string
+	OldForeignKey *ForeignKey
+	NewForeignKey *ForeignKey
+}
+
+// SchemaDiff is the difference between two schemas.
+// The changes are sorted by table name, then by column position or index or foreign key name, so the diff is deterministic.
+type SchemaDiff struct {
+	TableChangeList      []TableChange
+	ColumnChangeList     []ColumnChange
+	IndexChangeList      []IndexChange
+	ForeignKeyChangeList []ForeignKeyChange
+}
+
+// IsEmpty returns whether there is no change.
+func (d *SchemaDiff) IsEmpty() bool {
+	return len(d.TableChangeList) == 0 && len(d.ColumnChangeList) == 0 && len(d.IndexChangeList) == 0 && len(d.ForeignKeyChangeList) == 0
+}
+
+// DiffSchema returns the changes to transform the old schema into the new schema.
+// A nil schema is treated as an empty schema. Stats such as the row count, sizes and timestamps are ignored.
+func DiffSchema(oldSchema, newSchema *Schema) *SchemaDiff {
+	oldTableMap := make(map[string]*Table)
+	if oldSchema != nil {
+		for i := range oldSchema.TableList {
+			oldTableMap[oldSchema.TableList[i].Name] = &oldSchema.TableList[i]
+		}
+	}
+	newTableMap := make(map[string]*Table)
+	if newSchema != nil {
+		for i := range newSchema.TableList {
+			newTableMap[newSchema.TableList[i].Name] = &newSchema.TableList[i]
+		}
+	}
+
+	diff := &SchemaDiff{}
+	for _, name := range sortedTableNames(oldTableMap, newTableMap) {
+		oldTable, newTable := oldTableMap[name], newTableMap[name]
+		switch {
+		case oldTable == nil:
+			diff.TableChangeList = append(diff.TableChangeList, TableChange{Type: SchemaChangeAdd, Name: name, NewTable: newTable})
+		case newTable == nil:
+			diff.TableChangeList = append(diff.TableChangeList, TableChange{Type: SchemaChangeDrop, Name: name, OldTable: oldTable})
+		default:
+			if oldTable.Engine != newTable.Engine || oldTable.Collation != newTable.Collation || oldTable.Comment != newTable.Comment {
+				diff.TableChangeList = append(diff.TableChangeList, TableChange{Type: SchemaChangeModify, Name: name, OldTable: oldTable, NewTable: newTable})
+			}
+			diff.ColumnChangeList = append(diff.ColumnChangeList, diffColumnList(name, oldTable.ColumnList, newTable.ColumnList)...)
+			diff.IndexChangeList = append(diff.IndexChangeList, diffIndexList(name, oldTable.IndexList, newTable.IndexList)...)
+			diff.ForeignKeyChangeList = append(diff.ForeignKeyChangeList, diffForeignKeyList(name, oldTable.ForeignKeyList, newTable.ForeignKeyList)...)
+		}
+	}
+	return diff
+}
+
+func sortedTableNames(oldTableMap, newTableMap map[string]*Table) []string {
+	var nameList []string
+	for name := range oldTableMap {
+		nameList = append(nameList, name)
+	}
+	for name := range newTableMap {
+		if _, ok := oldTableMap[name]; !ok {
+			nameList = append(nameList, name)
+		}
+	}
+	sort.Strings(nameList)
+	return nameList
+}
+
+// diffColumnList returns the column changes sorted by the dropped columns first, then by the position in the new table.
+func diffColumnList(tableName string, oldColumnList, newColumnList []Column) []ColumnChange {
+	oldColumnMap := make(map[string]*Column)
+	for i := range oldColumnList {
+		oldColumnMap[oldColumnList[i].Name] = &oldColumnList[i]
+	}
+	newColumnMap := make(map[string]*Column)
+	for i := range newColumnList {
+		newColumnMap[newColumnList[i].Name] = &newColumnList[i]
+	}
+
+	var dropList []ColumnChange
+	for i := range oldColumnList {
+		column := &oldColumnList[i]
+		if _, ok := newColumnMap[column.Name]; !ok {
+			dropList = append(dropList, ColumnChange{Type: SchemaChangeDrop, TableName: tableName, Name: column.Name, OldColumn: column})
+		}
+	}
+	sort.SliceStable(dropList, func(i, j int) bool {
+		return dropList[i].OldColumn.Position < dropList[j].OldColumn.Position
+	})
+
+	var changeList []ColumnChange
+	for i := range newColumnList {
+		column := &newColumnList[i]
+		oldColumn, ok := oldColumnMap[column.Name]
+		if !ok {
+			changeList = append(changeList, ColumnChange{Type: SchemaChangeAdd, TableName: tableName, Name: column.Name, NewColumn: column})
+		} else if !equalColumn(oldColumn, column) {
+			changeList = append(changeList, ColumnChange{Type: SchemaChangeModify, TableName: tableName, Name: column.Name, OldColumn: oldColumn, NewColumn: column})
+		}
+	}
+	sort.SliceStable(changeList, func(i, j int) bool {
+		return changeList[i].NewColumn.Position < changeList[j].NewColumn.Position
+	})
+
+	return append(dropList, changeList...)
+}
+
+// equalColumn returns whether the column definitions are the same. The position is ignored.
+func equalColumn(a, b *Column) bool {
+	if (a.Default == nil) != (b.Default == nil) {
+		return false
+	}
+	if a.Default != nil && *a.Default != *b.Default {
+		return false
+	}
+	return a.Type == b.Type &&
+		a.Nullable == b.Nullable &&
+		a.CharacterSet == b.CharacterSet &&
+		a.Collation == b.Collation &&
+		a.Comment == b.Comment
+}
+
+// diffIndexList returns the index changes sorted by the index name.
+func diffIndexList(tableName string, oldIndexList, newIndexList []Index) []IndexChange {
+	oldIndexMap := groupIndexList(oldIndexList)
+	newIndexMap := groupIndexList(newIndexList)
+
+	var nameList []string
+	for name := range oldIndexMap {
+		nameList = append(nameList, name)
+	}
+	for name := range newIndexMap {
+		if _, ok := oldIndexMap[name]; !ok {
+			nameList = append(nameList, name)
+		}
+	}
+	sort.Strings(nameList)
+
+	var changeList []IndexChange
+	for _, name := range nameList {
+		oldIndex, inOld := oldIndexMap[name]
+		newIndex, inNew := newIndexMap[name]
+		switch {
+		case !inOld:
+			changeList = append(changeList, IndexChange{Type: SchemaChangeAdd, TableName: tableName, Name: name, NewIndexList: newIndex})
+		case !inNew:
+			changeList = append(changeList, IndexChange{Type: SchemaChangeDrop, TableName: tableName, Name: name, OldIndexList: oldIndex})
+		case !equalIndex(oldIndex, newIndex):
+			changeList = append(changeList, IndexChange{Type: SchemaChangeModify, TableName: tableName, Name: name, OldIndexList: oldIndex, NewIndexList: newIndex})
+		}
+	}
+	return changeList
+}
+
+// groupIndexList groups the index entries by the index name and sorts each group by the position.
+func groupIndexList(indexList []Index) map[string][]Index {
+	indexMap := make(map[string][]Index)
+	for _, index := range indexList {
+		indexMap[index.Name] = append(indexMap[index.Name], index)
+	}
+	for _, list := range indexMap {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Position < list[j].Position
+		})
+	}
+	return indexMap
+}
+
+func equalIndex(a, b []Index) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Expression != b[i].Expression ||
+			a[i].Type != b[i].Type ||
+			a[i].Unique != b[i].Unique ||
+			a[i].Visible != b[i].Visible ||
+			a[i].Comment != b[i].Comment {
+			return false
+		}
+	}
+	return true
+}
+
+// diffForeignKeyList returns the foreign key changes sorted by the foreign key name.
+func diffForeignKeyList(tableName string, oldForeignKeyList, newForeignKeyList []ForeignKey) []ForeignKeyChange {
+	oldForeignKeyMap := make(map[string]*ForeignKey)
+	for i := range oldForeignKeyList {
+		oldForeignKeyMap[oldForeignKeyList[i].Name] = &oldForeignKeyList[i]
+	}
+	newForeignKeyMap := make(map[string]*ForeignKey)
+	for i := range newForeignKeyList {
+		newForeignKeyMap[newForeignKeyList[i].Name] = &newForeignKeyList[i]
+	}
+
+	var nameList []string
+	for name := range oldForeignKeyMap {
+		nameList = append(nameList, name)
+	}
+	for name := range newForeignKeyMap {
+		if _, ok := oldForeignKeyMap[name]; !ok {
+			nameList = append(nameList, name)
+		}
+	}
+	sort.Strings(nameList)
+
+	var changeList []ForeignKeyChange
+	for _, name := range nameList {
+		oldForeignKey, newForeignKey := oldForeignKeyMap[name], newForeignKeyMap[name]
+		switch {
+		case oldForeignKey == nil:
+			changeList = append(changeList, ForeignKeyChange{Type: SchemaChangeAdd, TableName: tableName, Name: name, NewForeignKey: newForeignKey})
+		case newForeignKey == nil:
+			changeList = append(changeList, ForeignKeyChange{Type: SchemaChangeDrop, TableName: tableName, Name: name, OldForeignKey: oldForeignKey})
+		case !equalForeignKey(oldForeignKey, newForeignKey):
+			changeList = append(changeList, ForeignKeyChange{Type: SchemaChangeModify, TableName: tableName, Name: name, OldForeignKey: oldForeignKey, NewForeignKey: newForeignKey})
+		}
+	}
+	return changeList
+}
+
+func equalForeignKey(a, b *ForeignKey) bool {
+	return equalStringList(a.ColumnList, b.ColumnList) &&
+		a.ReferencedSchema == b.ReferencedSchema &&
+		a.ReferencedTable == b.ReferencedTable &&
+		equalStringList(a.ReferencedColumnList, b.ReferencedColumnList) &&
+		a.OnDelete == b.OnDelete &&
+		a.OnUpdate == b.OnUpdate
+}
+
+func equalStringList(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
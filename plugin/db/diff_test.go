@@ -0,0 +1,110 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSchema(t *testing.T) {
+	defaultZero := "0"
+	defaultOne := "1"
+	oldSchema := &Schema{
+		Name: "db1",
+		TableList: []Table{
+			{
+				Name:   "t_drop",
+				Engine: "InnoDB",
+			},
+			{
+				Name:    "t_keep",
+				Engine:  "InnoDB",
+				Comment: "old comment",
+				ColumnList: []Column{
+					{Name: "id", Position: 1, Type: "int"},
+					{Name: "name", Position: 2, Type: "varchar(64)", Nullable: true},
+					{Name: "deleted", Position: 3, Type: "tinyint"},
+					{Name: "count", Position: 4, Type: "int", Default: &defaultZero},
+				},
+				IndexList: []Index{
+					{Name: "PRIMARY", Expression: "id", Position: 1, Type: "BTREE", Unique: true, Visible: true},
+					{Name: "idx_name", Expression: "name", Position: 1, Type: "BTREE", Visible: true},
+					{Name: "idx_drop", Expression: "deleted", Position: 1, Type: "BTREE", Visible: true},
+				},
+				// Stats changes are ignored.
+				RowCount: 10,
+			},
+		},
+	}
+	newSchema := &Schema{
+		Name: "db1",
+		TableList: []Table{
+			{
+				Name:    "t_keep",
+				Engine:  "InnoDB",
+				Comment: "new comment",
+				ColumnList: []Column{
+					{Name: "id", Position: 1, Type: "int"},
+					{Name: "name", Position: 2, Type: "varchar(128)", Nullable: true},
+					{Name: "count", Position: 3, Type: "int", Default: &defaultOne},
+					{Name: "created_ts", Position: 4, Type: "bigint"},
+				},
+				IndexList: []Index{
+					// The index columns are out of order on purpose.
+					{Name: "idx_name", Expression: "id", Position: 2, Type: "BTREE", Visible: true},
+					{Name: "idx_name", Expression: "name", Position: 1, Type: "BTREE", Visible: true},
+					{Name: "PRIMARY", Expression: "id", Position: 1, Type: "BTREE", Unique: true, Visible: true},
+					{Name: "idx_add", Expression: "created_ts", Position: 1, Type: "BTREE", Visible: true},
+				},
+				RowCount: 20,
+			},
+			{
+				Name:   "t_add",
+				Engine: "InnoDB",
+			},
+		},
+	}
+
+	diff := DiffSchema(oldSchema, newSchema)
+
+	var tableChangeList []string
+	for _, change := range diff.TableChangeList {
+		tableChangeList = append(tableChangeList, string(change.Type)+" "+change.Name)
+	}
+	require.Equal(t, []string{"ADD t_add", "DROP t_drop", "MODIFY t_keep"}, tableChangeList)
+
+	var columnChangeList []string
+	for _, change := range diff.ColumnChangeList {
+		columnChangeList = append(columnChangeList, string(change.Type)+" "+change.TableName+"."+change.Name)
+	}
+	require.Equal(t, []string{"DROP t_keep.deleted", "MODIFY t_keep.name", "MODIFY t_keep.count", "ADD t_keep.created_ts"}, columnChangeList)
+
+	var indexChangeList []string
+	for _, change := range diff.IndexChangeList {
+		indexChangeList = append(indexChangeList, string(change.Type)+" "+change.TableName+"."+change.Name)
+	}
+	require.Equal(t, []string{"ADD t_keep.idx_add", "DROP t_keep.idx_drop", "MODIFY t_keep.idx_name"}, indexChangeList)
+	require.Equal(t, "name", diff.IndexChangeList[2].NewIndexList[0].Expression)
+	require.Equal(t, "id", diff.IndexChangeList[2].NewIndexList[1].Expression)
+}
+
+func TestDiffSchemaEmpty(t *testing.T) {
+	schema := &Schema{
+		Name: "db1",
+		TableList: []Table{
+			{
+				Name:       "t1",
+				ColumnList: []Column{{Name: "id", Position: 1, Type: "int"}},
+				IndexList:  []Index{{Name: "PRIMARY", Expression: "id", Position: 1, Unique: true}},
+			},
+		},
+	}
+	require.True(t, DiffSchema(schema, schema).IsEmpty())
+	require.True(t, DiffSchema(nil, nil).IsEmpty())
+
+	diff := DiffSchema(nil, schema)
+	require.Len(t, diff.TableChangeList, 1)
+	require.Equal(t, SchemaChangeAdd, diff.TableChangeList[0].Type)
+	require.Empty(t, diff.ColumnChangeList)
+	require.Empty(t, diff.IndexChangeList)
+}
@@ -1,15 +1,24 @@
+// Package db is the plugin of the database drivers, where each database type registers its Driver by Register and is opened by Open.
+//
+// The features only some of the databases support are the optional interfaces, e.g. DryRunner and MigrationLocker,
+// and the callers should check whether a Driver implements one with a type assertion.
 package db
 
 import (
 	"bufio"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/blang/semver/v4"
 	"github.com/bytebase/bytebase/plugin/vcs"
 	"go.uber.org/zap"
 )
@@ -18,18 +27,32 @@ import (
 type Type string
 
 const (
+	// Cassandra is the database type for CASSANDRA, which also supports ScyllaDB.
+	Cassandra Type = "CASSANDRA"
 	// ClickHouse is the database type for CLICKHOUSE.
 	ClickHouse Type = "CLICKHOUSE"
+	// CockroachDB is the database type for COCKROACHDB.
+	CockroachDB Type = "COCKROACHDB"
+	// MariaDB is the database type for MARIADB.
+	MariaDB Type = "MARIADB"
+	// MongoDB is the database type for MONGODB, whose statements are the database commands in MongoDB Extended JSON.
+	MongoDB Type = "MONGODB"
 	// MySQL is the database type for MYSQL.
 	MySQL Type = "MYSQL"
 	// Postgres is the database type for POSTGRES.
 	Postgres Type = "POSTGRES"
+	// Redshift is the database type for REDSHIFT.
+	Redshift Type = "REDSHIFT"
 	// Snowflake is the database type for SNOWFLAKE.
 	Snowflake Type = "SNOWFLAKE"
 	// SQLite is the database type for SQLite.
 	SQLite Type = "SQLITE"
+	// SQLServer is the database type for SQLSERVER.
+	SQLServer Type = "SQLSERVER"
 	// TiDB is the database type for TiDB.
 	TiDB Type = "TIDB"
+	// Trino is the database type for TRINO.
+	Trino Type = "TRINO"
 )
 
 // User is the database user.
@@ -59,7 +82,7 @@ type Index struct {
 	Unique bool
 	// Visible isn't supported for Postgres, SQLite.
 	Visible bool
-	// Comment isn't supported for SQLite.
+	// Comment isn't supported for SQLite, SQLServer.
 	Comment string
 }
 
@@ -71,12 +94,52 @@ type Column struct {
 	// Nullable isn't supported for ClickHouse.
 	Nullable bool
 	Type     string
-	// CharacterSet isn't supported for Postgres, ClickHouse, SQLite.
+	// CharacterSet isn't supported for Postgres, ClickHouse, SQLite, SQLServer.
 	CharacterSet string
 	// Collation isn't supported for ClickHouse, SQLite.
 	Collation string
 	// Comment isn't supported for SQLite.
 	Comment string
+	// Extra is the additional information of the column such as auto_increment and DEFAULT_GENERATED,
+	// which is only supported for MySQL, TiDB, MariaDB.
+	Extra string
+}
+
+// ForeignKey is the database table foreign key.
+type ForeignKey struct {
+	Name string
+	// ColumnList and ReferencedColumnList are in the constraint column order, so ColumnList[i] references ReferencedColumnList[i].
+	ColumnList           []string
+	ReferencedSchema     string
+	ReferencedTable      string
+	ReferencedColumnList []string
+	// OnDelete and OnUpdate are the referential actions, e.g. CASCADE, SET NULL, RESTRICT.
+	OnDelete string
+	OnUpdate string
+}
+
+// Trigger is the database table trigger.
+type Trigger struct {
+	Name string
+	// Event is the triggering event, e.g. INSERT, UPDATE, DELETE.
+	Event string
+	// Timing is when the trigger activates, e.g. BEFORE, AFTER.
+	Timing    string
+	Statement string
+}
+
+// Partition is the database table partition.
+type Partition struct {
+	Name string
+	// Method is the partitioning method, e.g. RANGE, LIST, HASH, KEY.
+	Method string
+	// Expression is the partitioning expression or column list.
+	Expression string
+	// Value is the partition value, e.g. the upper bound of a RANGE partition, or the values of a LIST partition.
+	// It's empty for HASH and KEY partitions.
+	Value string
+	// RowCount is the approximate row count, including all its subpartitions.
+	RowCount int64
 }
 
 // Table is the database table.
@@ -87,49 +150,176 @@ type Table struct {
 	// UpdatedTs isn't supported for SQLite.
 	UpdatedTs int64
 	Type      string
-	// Engine isn't supported for Postgres, Snowflake, SQLite.
+	// Engine isn't supported for Postgres, Snowflake, SQLite, SQLServer.
 	Engine string
-	// Collation isn't supported for Postgres, ClickHouse, Snowflake, SQLite.
+	// Collation isn't supported for Postgres, ClickHouse, Snowflake, SQLite, SQLServer.
 	Collation string
-	RowCount  int64
+	// RowCount is the row count from the statistics of the database, which is an estimate unless IsRowCountExact is set,
+	// e.g. it's sampled for the InnoDB tables of MySQL and it's updated by ANALYZE and VACUUM for Postgres.
+	// Use RowCounter to count the rows exactly.
+	RowCount int64
+	// IsRowCountExact is whether RowCount is exact, which is the MyISAM tables of MySQL, MariaDB,
+	// the MergeTree tables of ClickHouse, the tables of Snowflake, and the collections of MongoDB.
+	IsRowCountExact bool
 	// DataSize isn't supported for SQLite.
 	DataSize int64
 	// IndexSize isn't supported for ClickHouse, Snowflake, SQLite.
 	IndexSize int64
-	// DataFree isn't supported for Postgres, ClickHouse, Snowflake, SQLite.
+	// DataFree isn't supported for Postgres, ClickHouse, Snowflake, SQLite, SQLServer.
 	DataFree int64
-	// CreateOptions isn't supported for Postgres, ClickHouse, Snowflake, SQLite.
+	// CreateOptions isn't supported for Postgres, ClickHouse, Snowflake, SQLite, SQLServer.
 	CreateOptions string
 	// Comment isn't supported for SQLite.
 	Comment    string
 	ColumnList []Column
 	// IndexList isn't supported for ClickHouse, Snowflake.
 	IndexList []Index
+	// ForeignKeyList isn't supported for Postgres, ClickHouse, Snowflake, SQLite, SQLServer.
+	ForeignKeyList []ForeignKey
+	// TriggerList isn't supported for Postgres, ClickHouse, Snowflake, SQLite, SQLServer.
+	TriggerList []Trigger
+	// PartitionList is empty for the tables without partitions.
+	// PartitionList isn't supported for Postgres, ClickHouse, Snowflake, SQLite, SQLServer.
+	PartitionList []Partition
+	// SystemVersioned is whether the table keeps the history of its rows, which is only supported for MariaDB.
+	SystemVersioned bool
+	// DistributionStyle, DistributionKey and SortKeyList are only supported for Redshift.
+	// DistributionStyle is e.g. AUTO(ALL), EVEN, ALL or KEY(column), and DistributionKey is the column of the KEY distribution.
+	DistributionStyle string
+	DistributionKey   string
+	// SortKeyList is the sort key columns in order.
+	SortKeyList []string
+}
+
+// Sequence is the database sequence.
+type Sequence struct {
+	Name       string
+	StartValue int64
+	MinValue   int64
+	MaxValue   int64
+	Increment  int64
+	CacheSize  int64
+	Cycle      bool
+}
+
+// Routine is the database stored procedure or function.
+type Routine struct {
+	Name       string
+	Definition string
+	// Language is the language of the routine body, e.g. SQL.
+	Language  string
+	CreatedTs int64
+	UpdatedTs int64
+}
+
+// ServerInfo is the database server information.
+type ServerInfo struct {
+	Version string
+	// CharacterSet and Collation are the server default character set and collation for the new databases.
+	CharacterSet string
+	Collation    string
+	// CollationList is the names of the available collations in alphabetical order.
+	CollationList []string
+	// SQLMode is the global SQL mode.
+	SQLMode string
 }
 
 // Schema is the database schema.
 type Schema struct {
 	Name string
-	// CharacterSet isn't supported for ClickHouse, Snowflake.
+	// CharacterSet isn't supported for ClickHouse, Snowflake, SQLServer.
 	CharacterSet string
 	// Collation isn't supported for ClickHouse, Snowflake.
 	Collation string
 	UserList  []User
 	TableList []Table
 	ViewList  []View
+	// ProcedureList and FunctionList aren't supported for Postgres, ClickHouse, Snowflake, SQLite, SQLServer.
+	ProcedureList []Routine
+	FunctionList  []Routine
+	// SequenceList is only synced for MariaDB.
+	SequenceList []Sequence
 }
 
 var (
 	driversMu sync.RWMutex
-	drivers   = make(map[Type]driverFunc)
+	drivers   = make(map[Type]DriverFunc)
 )
 
 // DriverConfig is the driver configuration.
 type DriverConfig struct {
 	Logger *zap.Logger
+
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime tune the connection pool of the driver.
+	// Zero values keep the database/sql defaults.
+	// Drivers that reopen the connection pool when switching databases (e.g. Postgres, SQLite) apply them to every new pool.
+	// A transaction holds a single connection until it finishes, so MaxOpenConns also caps the concurrent transactions,
+	// including the migration history transaction used by ExecuteMigration.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// MigrationHistorySchema and MigrationHistoryTable are the schema and the table name of the migration history.
+	// Empty values default to the "bytebase" schema and the "migration_history" table.
+	// For MySQL, TiDB and MariaDB, the schema is the database.
+	// For Postgres and CockroachDB, they're the schema and the table in the "bytebase" database,
+	// and the table is found by the search path if MigrationHistorySchema is empty.
+	// For Cassandra, MigrationHistorySchema is the keyspace of the "bytebase_migration_history" table, and MigrationHistoryTable isn't supported.
+	// For MongoDB, MigrationHistorySchema is the database of the "bytebase_migration_history" collection, and MigrationHistoryTable isn't supported.
+	// For Trino, MigrationHistorySchema is the catalog.schema of the "bytebase_migration_history" table, and MigrationHistoryTable isn't supported.
+	MigrationHistorySchema string
+	MigrationHistoryTable  string
+
+	// StatementTimeout is the optional timeout of each Execute, ExecuteBatch and Query call, including the statement executed by ExecuteMigration.
+	// The timed out calls return an error wrapping ErrStatementTimeout.
+	// The MySQL, TiDB and MariaDB drivers enforce it, and also limit the reads on the server by max_execution_time except for MariaDB.
+	// Note MySQL commits each DDL statement implicitly, so the DDL statements before the timed out one stay applied,
+	// and the timed out DDL statement may still complete on the server after the client gives up.
+	StatementTimeout time.Duration
+
+	// Tracer is the optional tracer creating the spans around Ping, SyncSchema, Execute and ExecuteMigration.
+	// No spans are created if it's nil.
+	Tracer Tracer
+
+	// MigrationLockTimeout is how long ExecuteMigration and Rollback wait for the migration lock held by another runner, see MigrationLocker.
+	// Zero waits until the context is done, and a negative value fails immediately.
+	// The timed out calls return an error wrapping ErrMigrationLocked.
+	MigrationLockTimeout time.Duration
+
+	// StatementValidator is the optional hook to enforce the policies on the migration statements, e.g. no DROP TABLE without a ticket.
+	// ExecuteMigration calls it after the destructive statement check, and a non-nil error is returned as is,
+	// without executing the statement or recording the migration history.
+	// No validation is done if it's nil.
+	StatementValidator func(statement string, info *MigrationInfo) error
 }
 
-type driverFunc func(DriverConfig) Driver
+// ApplyConnectionPool applies the connection pool settings to sqldb.
+func (c DriverConfig) ApplyConnectionPool(sqldb *sql.DB) {
+	if c.MaxOpenConns > 0 {
+		sqldb.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		sqldb.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		sqldb.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+}
+
+// ValidateStatement validates the migration statement before ExecuteMigration executes it.
+// It rejects the destructive statements unless info.AllowDestructive is set, and then calls the StatementValidator if it's set.
+func (c DriverConfig) ValidateStatement(dbType Type, statement string, info *MigrationInfo) error {
+	if err := checkDestructiveStatement(dbType, statement, info); err != nil {
+		return err
+	}
+	if c.StatementValidator == nil {
+		return nil
+	}
+	return c.StatementValidator(statement, info)
+}
+
+// DriverFunc creates a driver with the driver configuration.
+type DriverFunc func(DriverConfig) Driver
 
 // MigrationSource is the migration engine.
 type MigrationSource string
@@ -216,6 +406,20 @@ func (e MigrationStatus) String() string {
 // MigrationInfoPayload is the API message for migration info payload.
 type MigrationInfoPayload struct {
 	VCSPushEvent *vcs.PushEvent `json:"pushEvent,omitempty"`
+	// StatementChecksum is the hex encoded SHA-256 checksum of the migration statement.
+	StatementChecksum string `json:"statementChecksum,omitempty"`
+	// RollbackStatement is the statement to revert the migration.
+	RollbackStatement string `json:"rollbackStatement,omitempty"`
+	// Role is the role the migration statement is executed as, see MigrationInfo.RunAsRole.
+	Role string `json:"role,omitempty"`
+	// ErrorMessage is the error of the failed migration.
+	ErrorMessage string `json:"errorMessage,omitempty"`
+	// Environment is the environment of the migration, see MigrationInfo.Environment.
+	Environment string `json:"environment,omitempty"`
+	// Labels are the labels of the migration, see MigrationInfo.Labels.
+	Labels map[string]string `json:"labels,omitempty"`
+	// RolledBackBy is the version of the migration reverting this migration by Driver.Rollback.
+	RolledBackBy string `json:"rolledBackBy,omitempty"`
 }
 
 // MigrationInfo is the API message for migration info.
@@ -229,10 +433,31 @@ type MigrationInfo struct {
 	Type           MigrationType
 	Status         MigrationStatus
 	Description    string
+	// RawDescription is the description as it appears in the file path, before it's humanized into Description.
+	// It's empty if the description isn't derived from the file path.
+	RawDescription string
 	Creator        string
 	IssueID        string
 	Payload        string
 	CreateDatabase bool
+	// RollbackStatement is the optional statement to revert the migration.
+	// It's recorded in the payload of the migration history, and Driver.Rollback executes it to revert the migration.
+	RollbackStatement string
+	// AllowDestructive is whether the statement can contain the destructive statements found by FindDestructiveStatement.
+	// ExecuteMigration returns an error wrapping ErrDestructiveStatement for them if it's false.
+	AllowDestructive bool
+	// Force re-executes the version even if it has already been applied, e.g. an intentional re-run after fixing the database manually.
+	// The applied migration history is updated with the new result instead of inserting another one.
+	// Otherwise ExecuteMigration returns an error wrapping ErrMigrationAlreadyApplied, and RunMigrations skips the version.
+	Force bool
+	// RunAsRole is the optional role to execute the statement as, which the login user should be able to assume,
+	// e.g. a DDL-privileged role under the least-privilege policies.
+	// ExecuteMigration switches to the role for the statement and restores the role afterward, and records the role in the migration history payload.
+	// It's only supported by the drivers implementing RoleExecutor.
+	RunAsRole string
+	// Labels are the optional labels of the migration for audit, e.g. {"change_request": "CR-123"}.
+	// They're recorded in the migration history payload together with Environment.
+	Labels map[string]string
 	// UseSemanticVersion is whether version is a semantic version.
 	// When UseSemanticVersion is set, version should be set to the format specified in Semantic Versioning 2.0.0 (https://semver.org/).
 	// For example, for setting non-semantic version "hello", the values should be Version = "hello", UseSemanticVersion = false, SemanticVersionSuffix = "".
@@ -243,22 +468,110 @@ type MigrationInfo struct {
 	SemanticVersionSuffix string
 }
 
-// ParseMigrationInfo matches filePath against filePathTemplate
+// SemanticVersion returns the MAJOR, MINOR and PATCH of the version in Semantic Versioning 2.0.0 (https://semver.org/).
+func (m MigrationInfo) SemanticVersion() (major, minor, patch int, err error) {
+	v, err := semver.Make(m.Version)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid semantic version %q: %w", m.Version, err)
+	}
+	return int(v.Major), int(v.Minor), int(v.Patch), nil
+}
+
+// CompareVersion compares two migration versions, and returns -1 if a < b, 0 if a == b and 1 if a > b.
+// If both versions are dotted numeric versions such as "1.2.10", they are compared numerically segment by segment,
+// and a missing segment counts as 0, e.g. "1.2.10" > "1.2.9" and "1.2" == "1.2.0".
+// Otherwise, they are compared lexically, e.g. "20220101_v2" > "20220101_v10".
+func CompareVersion(a, b string) int {
+	aSegments, aOK := parseNumericVersion(a)
+	bSegments, bOK := parseNumericVersion(b)
+	if !aOK || !bOK {
+		return strings.Compare(a, b)
+	}
+	for i := 0; i < len(aSegments) || i < len(bSegments); i++ {
+		var x, y int
+		if i < len(aSegments) {
+			x = aSegments[i]
+		}
+		if i < len(bSegments) {
+			y = bSegments[i]
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseNumericVersion parses the dotted numeric version into segments.
+func parseNumericVersion(version string) ([]int, bool) {
+	var segments []int
+	for _, s := range strings.Split(version, ".") {
+		if s == "" {
+			return nil, false
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		segments = append(segments, n)
+	}
+	return segments, true
+}
+
+// ParseConfig is the config of ParseMigrationInfoWithConfig.
+// The separators between the fields are specified by the file path template, e.g. "{{VERSION}}__{{DB_NAME}}__{{DESCRIPTION}}.sql".
+type ParseConfig struct {
+	// DescriptionSeparator is the separator between the words of the description, which is replaced with a space.
+	// Empty value means "_".
+	DescriptionSeparator string
+	// KeepRawDescription is whether to use the description as it appears in the file path without humanizing it.
+	KeepRawDescription bool
+	// KeepDescriptionCase is whether to keep the case of the description when humanizing it, which only replaces the separators with spaces,
+	// e.g. "add_API_key" is "add API key" instead of "Add API key". It's ignored if KeepRawDescription is set.
+	KeepDescriptionCase bool
+	// VersionPattern is the optional pattern the version must match, e.g. `^\d{14}$`. Nil means no validation.
+	VersionPattern *regexp.Regexp
+}
+
+// ParseMigrationInfo matches filePath against filePathTemplate up to the end of filePath.
 // If filePath matches, then it will derive MigrationInfo from the filePath.
 // Both filePath and filePathTemplate are the full file path (including the base directory) of the repository.
+// The namespace is the {{NAMESPACE}} segment if the template contains it, e.g. "{{VERSION}}_{{NAMESPACE}}##{{DB_NAME}}_{{DESCRIPTION}}.sql",
+// so that the databases of the same logical schema share the migration history. Otherwise, the namespace is the database name.
 func ParseMigrationInfo(filePath string, filePathTemplate string) (*MigrationInfo, error) {
+	return ParseMigrationInfoWithConfig(filePath, filePathTemplate, ParseConfig{})
+}
+
+// ParseMigrationInfoWithConfig is ParseMigrationInfo with the config of how to derive the description.
+func ParseMigrationInfoWithConfig(filePath string, filePathTemplate string, cfg ParseConfig) (*MigrationInfo, error) {
+	descriptionSeparator := cfg.DescriptionSeparator
+	if descriptionSeparator == "" {
+		descriptionSeparator = "_"
+	}
+
 	placeholderList := []string{
 		"ENV_NAME",
 		"VERSION",
 		"DB_NAME",
+		"NAMESPACE",
 		"TYPE",
 		"DESCRIPTION",
 	}
 	filePathRegex := filePathTemplate
 	for _, placeholder := range placeholderList {
-		filePathRegex = strings.ReplaceAll(filePathRegex, fmt.Sprintf("{{%s}}", placeholder), fmt.Sprintf("(?P<%s>[a-zA-Z0-9+-=/_#?!$. ]+)", placeholder))
+		// The fields other than the description match as few characters as possible, so the separators in the description,
+		// e.g. "_" in "1.2_db1_create_user_table.sql", stay in the description.
+		quantifier := "+?"
+		if placeholder == "DESCRIPTION" {
+			quantifier = "+"
+		}
+		filePathRegex = strings.ReplaceAll(filePathRegex, fmt.Sprintf("{{%s}}", placeholder), fmt.Sprintf("(?P<%s>[a-zA-Z0-9+-=/_#?!$. ]%s)", placeholder, quantifier))
 	}
-	myRegex, err := regexp.Compile(filePathRegex)
+	// Match up to the end of the file path, otherwise the last field of the template would be a single character.
+	myRegex, err := regexp.Compile(filePathRegex + "$")
 	if err != nil {
 		return nil, fmt.Errorf("invalid file path template: %q", filePathTemplate)
 	}
@@ -280,9 +593,11 @@ func ParseMigrationInfo(filePath string, filePathTemplate string) (*MigrationInf
 			case "VERSION":
 				mi.Version = matchList[index]
 			case "DB_NAME":
-				mi.Namespace = matchList[index]
 				mi.Database = matchList[index]
+			case "NAMESPACE":
+				mi.Namespace = matchList[index]
 			case "TYPE":
+				// The type is only derived from the {{TYPE}} segment as a whole, so a description containing "baseline" won't change the type.
 				switch matchList[index] {
 				case "baseline":
 					mi.Type = Baseline
@@ -294,7 +609,7 @@ func ParseMigrationInfo(filePath string, filePathTemplate string) (*MigrationInf
 					return nil, fmt.Errorf("file path %q contains invalid migration type %q, must be 'baseline', 'migrate' or 'data'", filePath, matchList[index])
 				}
 			case "DESCRIPTION":
-				mi.Description = matchList[index]
+				mi.RawDescription = matchList[index]
 			}
 		}
 	}
@@ -302,11 +617,29 @@ func ParseMigrationInfo(filePath string, filePathTemplate string) (*MigrationInf
 	if mi.Version == "" {
 		return nil, fmt.Errorf("file path %q does not contain {{VERSION}}, configured file path template %q", filePath, filePathTemplate)
 	}
-	if mi.Namespace == "" {
+	if cfg.VersionPattern != nil && !cfg.VersionPattern.MatchString(mi.Version) {
+		return nil, fmt.Errorf("file path %q contains version %q not matching the pattern %q", filePath, mi.Version, cfg.VersionPattern.String())
+	}
+	if mi.Database == "" {
 		return nil, fmt.Errorf("file path %q does not contain {{DB_NAME}}, configured file path template %q", filePath, filePathTemplate)
 	}
+	if mi.Namespace == "" {
+		mi.Namespace = mi.Database
+	}
 
-	if mi.Description == "" {
+	if mi.RawDescription != "" {
+		// Replace the separator with space
+		mi.Description = strings.TrimSpace(strings.ReplaceAll(mi.RawDescription, descriptionSeparator, " "))
+		if mi.Description == "" {
+			return nil, fmt.Errorf("file path %q contains an empty description %q, configured file path template %q", filePath, mi.RawDescription, filePathTemplate)
+		}
+		if cfg.KeepRawDescription {
+			mi.Description = mi.RawDescription
+		} else if !cfg.KeepDescriptionCase {
+			// Capitalize first letter
+			mi.Description = strings.ToUpper(mi.Description[:1]) + mi.Description[1:]
+		}
+	} else {
 		switch mi.Type {
 		case Baseline:
 			mi.Description = fmt.Sprintf("Create %s baseline", mi.Database)
@@ -315,16 +648,90 @@ func ParseMigrationInfo(filePath string, filePathTemplate string) (*MigrationInf
 		default:
 			mi.Description = fmt.Sprintf("Create %s schema migration", mi.Database)
 		}
-	} else {
-		// Replace _ with space
-		mi.Description = strings.ReplaceAll(mi.Description, "_", " ")
-		// Capitalize first letter
-		mi.Description = strings.ToUpper(mi.Description[:1]) + mi.Description[1:]
 	}
 
 	return mi, nil
 }
 
+// FilePath returns the file path of the migration following filePathTemplate, which is the reverse of ParseMigrationInfo.
+// The description is the RawDescription, or the Description with the spaces replaced with "_" if it's empty.
+// The type is "baseline", "data" or "migrate", and the namespace is omitted by the templates without {{NAMESPACE}}.
+// It returns an error if a field in the template is empty, or if ParseMigrationInfo can't parse the same fields back from the file path,
+// e.g. the database name contains the separator of the template.
+func (m MigrationInfo) FilePath(filePathTemplate string) (string, error) {
+	description := m.fileDescription()
+	typeName := "migrate"
+	switch m.Type {
+	case Baseline:
+		typeName = "baseline"
+	case Data:
+		typeName = "data"
+	}
+	fieldList := []struct {
+		placeholder string
+		value       string
+	}{
+		{"ENV_NAME", m.Environment},
+		{"VERSION", m.Version},
+		{"DB_NAME", m.Database},
+		{"NAMESPACE", m.Namespace},
+		{"TYPE", typeName},
+		{"DESCRIPTION", description},
+	}
+	filePath := filePathTemplate
+	for _, field := range fieldList {
+		placeholder := fmt.Sprintf("{{%s}}", field.placeholder)
+		if !strings.Contains(filePath, placeholder) {
+			continue
+		}
+		if field.value == "" {
+			return "", fmt.Errorf("migration version %q has an empty value for %s in file path template %q", m.Version, placeholder, filePathTemplate)
+		}
+		filePath = strings.ReplaceAll(filePath, placeholder, field.value)
+	}
+
+	mi, err := ParseMigrationInfo(filePath, filePathTemplate)
+	if err != nil {
+		return "", err
+	}
+	parsedFieldList := map[string]string{
+		"ENV_NAME":    mi.Environment,
+		"VERSION":     mi.Version,
+		"DB_NAME":     mi.Database,
+		"NAMESPACE":   mi.Namespace,
+		"TYPE":        strings.ToLower(string(mi.Type)),
+		"DESCRIPTION": mi.RawDescription,
+	}
+	for _, field := range fieldList {
+		if strings.Contains(filePathTemplate, fmt.Sprintf("{{%s}}", field.placeholder)) && parsedFieldList[field.placeholder] != field.value {
+			return "", fmt.Errorf("file path %q can't be parsed back to migration version %q with file path template %q", filePath, m.Version, filePathTemplate)
+		}
+	}
+	return filePath, nil
+}
+
+// Filename returns the file name of the migration in the form of "{{VERSION}}_{{DB_NAME}}[_baseline][_{{DESCRIPTION}}].sql",
+// where "_baseline" is only for the baseline migrations and the description is the same as FilePath.
+func (m *MigrationInfo) Filename() string {
+	segmentList := []string{m.Version, m.Database}
+	if m.Type == Baseline {
+		segmentList = append(segmentList, "baseline")
+	}
+	if description := m.fileDescription(); description != "" {
+		segmentList = append(segmentList, description)
+	}
+	return strings.Join(segmentList, "_") + ".sql"
+}
+
+// fileDescription returns the description in the file path, which is the RawDescription,
+// or the Description with the spaces replaced with "_" if it's empty.
+func (m MigrationInfo) fileDescription() string {
+	if m.RawDescription != "" {
+		return m.RawDescription
+	}
+	return strings.ReplaceAll(m.Description, " ", "_")
+}
+
 // MigrationHistory is the API message for migration history.
 type MigrationHistory struct {
 	ID int
@@ -350,6 +757,15 @@ type MigrationHistory struct {
 	Payload               string
 	UseSemanticVersion    bool
 	SemanticVersionSuffix string
+	// ErrorMessage is the error of the FAILED migration, which is recorded in the payload.
+	// It's empty for the migrations failed before the error was recorded.
+	ErrorMessage string
+	// Environment and Labels are the ones of the MigrationInfo recorded in the payload.
+	Environment string
+	Labels      map[string]string
+	// RolledBackBy is the version of the migration reverting this migration, which is recorded in the payload.
+	// It's empty if the migration hasn't been rolled back.
+	RolledBackBy string
 }
 
 // MigrationHistoryFind is the API message for finding migration histories.
@@ -359,8 +775,12 @@ type MigrationHistoryFind struct {
 	Database *string
 	Source   *MigrationSource
 	Version  *string
+	Status   *MigrationStatus
+	IssueID  *string
 	// If specified, then it will only fetch "Limit" most recent migration histories
 	Limit *int
+	// OmitStatement is whether to leave the statements of the migration histories empty, which can be huge, e.g. for listing the applied versions.
+	OmitStatement bool
 }
 
 // ConnectionConfig is the configuration for connections.
@@ -371,8 +791,44 @@ type ConnectionConfig struct {
 	Password  string
 	Database  string
 	TLSConfig TLSConfig
-	// ReadOnly is only supported for Postgres at the moment.
+	// ReadOnly is only supported for Postgres.
 	ReadOnly bool
+	// ConnectTimeout is the optional timeout for opening the driver and pinging the database in Open.
+	// Zero means no timeout other than the deadline of the context.
+	ConnectTimeout time.Duration
+	// Options are the optional engine-specific settings without a typed field, see connectionOptionKeys for the recognized keys.
+	// Open returns an error for the keys not recognized by the database type.
+	Options map[string]string
+
+	// The fields below are only supported for MySQL, TiDB, MariaDB, and ignored by the other drivers.
+
+	// Socket is the optional Unix socket path to connect to, Host and Port should be empty or localhost when it's set.
+	Socket string
+	// Charset and Collation are the optional connection character set and collation, the server defaults are used if they're empty.
+	// They're different from the database character set and collation in Schema.
+	Charset   string
+	Collation string
+	// SQLMode is the optional session sql_mode set on each connection, e.g. "STRICT_TRANS_TABLES,NO_ZERO_DATE".
+	// The server default is used if it's empty.
+	SQLMode string
+	// ReadReplicaHost and ReadReplicaPort are the optional read replica to serve SyncSchema and Query.
+	// The rest of the connection config is shared with the primary.
+	ReadReplicaHost string
+	ReadReplicaPort string
+}
+
+// connectionOptionKeys are the recognized keys of ConnectionConfig.Options for each database type.
+var connectionOptionKeys = map[Type]map[string]bool{
+	// warehouse and role are the default warehouse and role of the session.
+	Snowflake: {
+		"warehouse": true,
+		"role":      true,
+	},
+}
+
+// isConnectionOption returns whether the key is a recognized ConnectionConfig.Options key for the database type.
+func isConnectionOption(dbType Type, key string) bool {
+	return connectionOptionKeys[dbType][key]
 }
 
 // ConnectionContext is the context for connection.
@@ -383,32 +839,69 @@ type ConnectionContext struct {
 }
 
 // Driver is the interface for database driver.
+// The MySQL, TiDB, MariaDB drivers are safe for concurrent use by multiple goroutines after Open, except for Close.
+// The other drivers don't guarantee it, so the callers should not share them across goroutines.
 type Driver interface {
 	// A driver might support multiple engines (e.g. MySQL driver can support both MySQL and TiDB),
 	// So we pass the dbType to tell the exact engine.
 	Open(ctx context.Context, dbType Type, config ConnectionConfig, connCtx ConnectionContext) (Driver, error)
 	// Remember to call Close to avoid connection leak
+	// Close is idempotent, and closing a closed driver returns nil.
 	Close(ctx context.Context) error
 	Ping(ctx context.Context) error
+	// Stats returns the statistics of the connection pool, e.g. the open, in use and idle connections and the wait count.
+	// It returns the zero value if the connection pool isn't open.
+	// For the drivers reopening the connection pool when switching databases, it's the statistics of the current pool only.
+	Stats() sql.DBStats
 	GetDbConnection(ctx context.Context, database string) (*sql.DB, error)
 	GetVersion(ctx context.Context) (string, error)
-	SyncSchema(ctx context.Context) ([]*User, []*Schema, error)
+	// SyncSchema syncs the schema of the databases in the optional database list, or all user databases if it's empty.
+	// Only the MySQL, TiDB and MariaDB drivers filter by the database list, and the other drivers always sync all user databases.
+	SyncSchema(ctx context.Context, databaseList ...string) ([]*User, []*Schema, error)
 	// Execute will execute the statement. For CREATE DATABASE statement, some types of databases such as Postgres
 	// will not use transactions to execute the statement but will still use transactions to execute the rest of statements.
-	Execute(ctx context.Context, statement string) error
+	// It returns the rows affected and the last insert id where the database supports them, see ExecuteResult.
+	Execute(ctx context.Context, statement string) (ExecuteResult, error)
+	// ExecuteBatch executes the statements one by one in a single transaction, and rolls back on the first failure.
+	// The returned error is a *BatchError with the index of the failing statement.
+	// Postgres, SQLite and SQLServer send all the statements in a single round trip, and the others send them one by one.
+	// Like BeginTx, MySQL, TiDB, MariaDB and Snowflake commit the transaction implicitly before and after each DDL statement,
+	// so the batch containing DDL statements isn't atomic there. ClickHouse, Cassandra, MongoDB and Trino don't support transactions,
+	// so the statements before the failing one take effect.
+	// Each statement is executed as is, so the special statements handled by Execute such as creating or switching databases aren't supported.
+	// Use SplitStatements to split a SQL text into the statement list.
+	ExecuteBatch(ctx context.Context, statementList []string) error
 	// Used for execute readonly SELECT statement
 	// limit is the maximum row count returned. No limit enforced if limit <= 0
+	// The result is driver-neutral: [column names ([]string), column type names ([]string), rows ([]interface{} of []interface{})].
+	// The query is canceled when ctx is done.
 	Query(ctx context.Context, statement string, limit int) ([]interface{}, error)
+	// BeginTx begins a transaction to run several Execute and Query calls atomically, which is rolled back if ctx is done before Commit.
+	// The transaction holds a connection of the pool until Commit or Rollback, and it doesn't switch the database by the special statements handled by Execute.
+	// MySQL, TiDB, MariaDB and Snowflake commit the transaction implicitly before and after each DDL statement, so only the DML statements are atomic there.
+	// ClickHouse doesn't support transactions, and the statements take effect as they're executed.
+	// Cassandra, MongoDB and Trino don't support transactions either, and BeginTx returns an error.
+	// DriverConfig.StatementTimeout doesn't apply to the statements in the transaction, use the context deadline instead.
+	BeginTx(ctx context.Context) (Tx, error)
 
 	// Migration related
 	// Check whether we need to setup migration (e.g. creating/upgrading the migration related tables)
+	// Once SetupMigrationIfNeeded succeeds, the driver caches the result and returns false without querying the database.
+	// The cache is per Driver instance only and is invalidated on Close.
 	NeedsSetupMigration(ctx context.Context) (bool, error)
 	// Create or upgrade migration related tables
 	SetupMigrationIfNeeded(ctx context.Context) error
 	// Execute migration will apply the statement and record the migration history, the schema after migration on success.
 	// The migration type is determined by m.Type. Note, it can also perform data migration (DML) in addition to schema migration (DDL).
+	// A Baseline migration doesn't execute the statement unless m.CreateDatabase is set, so a Baseline migration with an empty statement
+	// records the current schema as the version without executing anything.
 	// It returns the migration history id and the schema after migration on success.
 	ExecuteMigration(ctx context.Context, m *MigrationInfo, statement string) (int64, string, error)
+	// Rollback reverts the applied migration version by executing its rollback statement, which is recorded as migration m.
+	// Only the applied migration with a rollback statement can be rolled back, and baseline migrations can't be rolled back.
+	// The reverted migration is marked by MigrationHistory.RolledBackBy, and it can't be rolled back again.
+	// It returns the migration history id and the schema after rollback on success.
+	Rollback(ctx context.Context, m *MigrationInfo, version string) (int64, string, error)
 	// Find the migration history list and return most recent item first.
 	FindMigrationHistoryList(ctx context.Context, find *MigrationHistoryFind) ([]*MigrationHistory, error)
 
@@ -416,13 +909,280 @@ type Driver interface {
 	// Dump the database, if dbName is empty, then dump all databases.
 	Dump(ctx context.Context, database string, out io.Writer, schemaOnly bool) error
 	// Restore the database from sc.
+	// The statements are split by ApplyStatements and applied in a single transaction, and canceling ctx aborts the restore and rolls back the transaction.
+	// Cassandra, MongoDB and Trino apply the statements one by one without a transaction, so the statements before a failure stay applied.
 	Restore(ctx context.Context, sc *bufio.Scanner) error
 }
 
+var (
+	// ErrAuthFailed means the credentials are rejected by the database.
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrConnRefused means the database can't be reached, e.g. the host is unreachable or nothing listens on the port.
+	ErrConnRefused = errors.New("connection refused")
+	// ErrDatabaseNotFound means the database to connect to doesn't exist.
+	ErrDatabaseNotFound = errors.New("database not found")
+	// ErrTLSRequired means the database only accepts connections using TLS.
+	ErrTLSRequired = errors.New("TLS required")
+
+	// ErrStatementTimeout means the statement doesn't finish within DriverConfig.StatementTimeout.
+	ErrStatementTimeout = errors.New("statement timeout")
+
+	// ErrMigrationLocked means the migration lock is held by another runner after DriverConfig.MigrationLockTimeout.
+	ErrMigrationLocked = errors.New("migration locked")
+
+	// ErrDestructiveStatement means the migration contains a destructive statement, which isn't allowed by MigrationInfo.AllowDestructive.
+	ErrDestructiveStatement = errors.New("destructive statement")
+
+	// ErrMigrationAlreadyApplied means the migration version has already been applied, which isn't re-executed without MigrationInfo.Force.
+	ErrMigrationAlreadyApplied = errors.New("migration already applied")
+)
+
+// ConnectionError is the error of connecting to the database, which is returned by Open and Ping.
+// Kind is one of ErrAuthFailed, ErrConnRefused, ErrDatabaseNotFound and ErrTLSRequired, so the callers can check the kind
+// with errors.Is, or get the ConnectionError with errors.As.
+// Only the MySQL, TiDB and MariaDB drivers classify the connection errors.
+type ConnectionError struct {
+	Kind error
+	Err  error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("%v, error: %v", e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns whether the target is the kind of the error.
+func (e *ConnectionError) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Tx is a transaction started by Driver.BeginTx.
+type Tx interface {
+	// Execute executes the statement in the transaction.
+	Execute(ctx context.Context, statement string) (ExecuteResult, error)
+	// Query executes the SELECT statement in the transaction, and returns the result in the same format as Driver.Query.
+	Query(ctx context.Context, statement string, limit int) ([]interface{}, error)
+	Commit() error
+	// Rollback aborts the transaction, and it's a no-op after Commit, so it can be deferred.
+	Rollback() error
+}
+
+// ExecuteResult is the result of Driver.Execute.
+type ExecuteResult struct {
+	// RowsAffected is the number of rows affected by the DML statements, and it's 0 for DDL statements.
+	// Postgres and SQLite execute the statements in a single call and report the rows affected by the last statement only.
+	RowsAffected int64
+	// LastInsertID is the last auto-generated id, only supported for MySQL, TiDB, MariaDB, SQLite. It's 0 if there's none.
+	LastInsertID int64
+}
+
+// Add adds the result of an executed statement. The values unsupported by the database driver are ignored.
+func (r *ExecuteResult) Add(result sql.Result) {
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected > 0 {
+		r.RowsAffected += rowsAffected
+	}
+	if lastInsertID, err := result.LastInsertId(); err == nil && lastInsertID > 0 {
+		r.LastInsertID = lastInsertID
+	}
+}
+
+// BatchError is the error of Driver.ExecuteBatch.
+type BatchError struct {
+	// Index is the index of the failing statement.
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("failed to execute statement #%d, error: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// DryRunStatementResult is the dry run result of a single statement.
+type DryRunStatementResult struct {
+	Statement string
+	// Error is the reason why the statement is invalid, it's empty if the statement is valid.
+	Error string
+	// EstimatedAffectedRows is the estimated number of rows affected by the DML statement, or -1 if it's unknown, e.g. for DDL.
+	EstimatedAffectedRows int64
+}
+
+// DryRunResult is the dry run result of the statements.
+type DryRunResult struct {
+	StatementResultList []DryRunStatementResult
+}
+
+// Valid returns whether all statements are valid.
+func (r *DryRunResult) Valid() bool {
+	for _, result := range r.StatementResultList {
+		if result.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// DryRunner is the optional interface implemented by the drivers supporting dry run.
+type DryRunner interface {
+	// DryRun validates the statement without any side effects and without recording the migration history.
+	DryRun(ctx context.Context, statement string) (*DryRunResult, error)
+}
+
+// RoleExecutor is the optional interface implemented by the drivers executing the statements as a role.
+type RoleExecutor interface {
+	StatementListExecutor
+	// ExecuteStatementListAsRole executes the statements like ExecuteStatementList, but as the role, by SET ROLE.
+	// The role of the connection is restored afterward, and the statements aren't executed if the role can't be set.
+	ExecuteStatementListAsRole(ctx context.Context, role string, statementList []string) (ExecuteResult, error)
+}
+
+// MigrationLocker is the optional interface implemented by the drivers serializing the concurrent migrations of a namespace with an advisory lock.
+type MigrationLocker interface {
+	// WithMigrationLock calls fn holding the migration lock of the namespace, and releases the lock after fn returns.
+	// It waits for the lock held by another runner according to DriverConfig.MigrationLockTimeout.
+	// ExecuteMigration and Rollback hold the lock while applying the migration, so that the concurrent runners don't interleave the migration history writes.
+	// The lock is held on a dedicated connection, so DriverConfig.MaxOpenConns should be at least 2 for MySQL, TiDB, MariaDB.
+	WithMigrationLock(ctx context.Context, namespace string, fn func(ctx context.Context) error) error
+}
+
+// DDLAlgorithm is the algorithm to execute an ALTER TABLE statement in MySQL.
+type DDLAlgorithm string
+
+const (
+	// DDLAlgorithmInstant only changes the metadata.
+	DDLAlgorithmInstant DDLAlgorithm = "INSTANT"
+	// DDLAlgorithmInplace changes or rebuilds the table in place, which usually allows the concurrent DML.
+	DDLAlgorithmInplace DDLAlgorithm = "INPLACE"
+	// DDLAlgorithmCopy copies the rows into a new table, which blocks the concurrent writes.
+	DDLAlgorithmCopy DDLAlgorithm = "COPY"
+)
+
+// StatementAnalysis is the analysis of how a statement affects the concurrent workload.
+type StatementAnalysis struct {
+	Statement string
+	// Algorithm is the algorithm of the ALTER TABLE, CREATE INDEX and DROP INDEX statements, and it's empty for the other statements.
+	Algorithm DDLAlgorithm
+	// LockFree is whether the concurrent reads and writes on the table are allowed while the statement executes.
+	// The row locks taken by DML aren't counted.
+	LockFree bool
+	// EstimatedAffectedRows is the estimated number of the rows modified by the DML statement, or scanned or copied by the DDL statement.
+	// It's 0 for the INSTANT DDL, and -1 if it's unknown.
+	EstimatedAffectedRows int64
+}
+
+// StatementAnalyzer is the optional interface implemented by the drivers analyzing the statements.
+type StatementAnalyzer interface {
+	// AnalyzeStatement analyzes a single statement without executing it, e.g. to schedule the risky migrations off-peak.
+	// The DDL algorithm is derived by heuristics from the statement and the server version, and it may differ from the actual one,
+	// e.g. changing a column type is always reported as COPY.
+	AnalyzeStatement(ctx context.Context, statement string) (*StatementAnalysis, error)
+}
+
+// RowCounter is the optional interface implemented by the drivers counting the rows of a table exactly.
+type RowCounter interface {
+	// CountRows returns the exact row count of the table by SELECT COUNT(*), which scans the table and can be slow for a large table.
+	// The schema is the database for MySQL, TiDB, MariaDB, and the schema in the connected database for Postgres, CockroachDB, Redshift.
+	CountRows(ctx context.Context, schema string, table string) (int64, error)
+}
+
+// IncrementalSchemaSyncer is the optional interface implemented by the drivers supporting incremental schema sync.
+type IncrementalSchemaSyncer interface {
+	// SyncSchemaSince is SyncSchema only returning the tables created or updated at or after the since timestamp in seconds.
+	// The other objects such as views and routines are returned in full. The dropped tables aren't reported,
+	// and the callers should merge the result into their cached schema.
+	// It's a best-effort optimization, because the table update time isn't reliable for some storage engines,
+	// e.g. it's not maintained for the InnoDB tables in the system tablespace, and it's lost on restart before MySQL 8.0.
+	SyncSchemaSince(ctx context.Context, since int64, databaseList ...string) ([]*User, []*Schema, error)
+}
+
+// SchemaStreamer is the optional interface implemented by the drivers syncing the schemas one database at a time.
+type SchemaStreamer interface {
+	// SyncSchemaStream is SyncSchema calling fn on each schema as it's synced instead of returning all the schemas,
+	// so that the memory is bounded by the largest database. It doesn't sync the users.
+	// An error returned by fn aborts the sync and is returned.
+	SyncSchemaStream(ctx context.Context, fn func(*Schema) error, databaseList ...string) error
+}
+
+// PreparedExecutor is the optional interface implemented by the drivers executing the parameterized statements with the cached prepared statements.
+type PreparedExecutor interface {
+	// ExecutePrepared executes a single parameterized statement with the args, e.g. "UPDATE t SET a = ? WHERE id = ?".
+	// The prepared statements are cached by the statement text and reused across the calls, and the least recently used ones are evicted.
+	ExecutePrepared(ctx context.Context, statement string, args ...interface{}) (ExecuteResult, error)
+}
+
+// ServerInfoProvider is the optional interface implemented by the drivers providing the server information.
+type ServerInfoProvider interface {
+	// ServerInfo returns the server version, the default character set and collation, the available collations and the SQL mode.
+	ServerInfo(ctx context.Context) (*ServerInfo, error)
+}
+
+// ImportFormat is the format of the rows imported by BulkImporter.
+type ImportFormat string
+
+const (
+	// ImportFormatCSV is the comma-separated values, where a value can be enclosed in double quotes with the double quote doubled.
+	// All the values are imported as strings, and there's no NULL.
+	ImportFormatCSV ImportFormat = "CSV"
+	// ImportFormatTSV is the tab-separated values, where a backslash escapes the special characters, e.g. "\t", "\n" and "\\",
+	// and "\N" is NULL.
+	ImportFormatTSV ImportFormat = "TSV"
+)
+
+// BulkImporter is the optional interface implemented by the drivers supporting bulk import.
+type BulkImporter interface {
+	// BulkImport imports the rows in the format into the columns of the table, where each line is a row and there's no header line.
+	// The table can be qualified as "database.table" for MySQL, TiDB, MariaDB and "schema.table" for Postgres and CockroachDB.
+	// MySQL, TiDB, MariaDB use LOAD DATA LOCAL INFILE, which requires local_infile to be enabled on the server.
+	// Postgres and CockroachDB use COPY FROM STDIN in a transaction, so the rows are imported all or nothing.
+	BulkImport(ctx context.Context, table string, columns []string, rows io.Reader, format ImportFormat) error
+}
+
+// StatementListExecutor is the optional interface implemented by the drivers executing a migration statement by statement.
+type StatementListExecutor interface {
+	// GetType returns the database type, which decides how to split the statements by SplitStatements.
+	GetType() Type
+	// ExecuteStatementList executes the statements in order, and returns a *StatementError if a statement fails.
+	// The statements are executed in one transaction, so a failure rolls back all the statements for the databases with transactional DDL.
+	// MySQL, TiDB, MariaDB commit the transaction implicitly for the DDL statements, so the statements before the failed one may stay applied.
+	// Cassandra, MongoDB and Trino don't support transactions, so the statements before the failed one stay applied.
+	ExecuteStatementList(ctx context.Context, statementList []string) (ExecuteResult, error)
+}
+
+// StatementError is the error of a statement executed by StatementListExecutor.ExecuteStatementList.
+type StatementError struct {
+	// Index is the 0-based index of the failed statement in the statement list.
+	Index     int
+	Statement string
+	// Committed is whether the statements before the failed one are committed, and can't be rolled back.
+	Committed bool
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	if e.Committed {
+		return fmt.Sprintf("failed to execute statement #%d %q, the previous statements are committed and not rolled back, error: %v", e.Index+1, e.Statement, e.Err)
+	}
+	return fmt.Sprintf("failed to execute statement #%d %q, error: %v", e.Index+1, e.Statement, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
 // Register makes a database driver available by the provided type.
 // If Register is called twice with the same name or if driver is nil,
 // it panics.
-func Register(dbType Type, f driverFunc) {
+// Drivers outside this package can register themselves in init() as well, so the type string must be unique among all drivers.
+func Register(dbType Type, f DriverFunc) {
 	driversMu.Lock()
 	defer driversMu.Unlock()
 	if f == nil {
@@ -434,6 +1194,20 @@ func Register(dbType Type, f driverFunc) {
 	drivers[dbType] = f
 }
 
+// RegisteredTypes returns the sorted database types of the registered drivers.
+func RegisteredTypes() []Type {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	var list []Type
+	for dbType := range drivers {
+		list = append(list, dbType)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i] < list[j]
+	})
+	return list
+}
+
 // Open opens a database specified by its database driver type and connection config
 func Open(ctx context.Context, dbType Type, driverConfig DriverConfig, connectionConfig ConnectionConfig, connCtx ConnectionContext) (Driver, error) {
 	driversMu.RLock()
@@ -443,12 +1217,36 @@ func Open(ctx context.Context, dbType Type, driverConfig DriverConfig, connectio
 		return nil, fmt.Errorf("db: unknown driver %v", dbType)
 	}
 
-	driver, err := f(driverConfig).Open(ctx, dbType, connectionConfig, connCtx)
+	for key := range connectionConfig.Options {
+		if !isConnectionOption(dbType, key) {
+			return nil, fmt.Errorf("connection option %q isn't supported for %s", key, dbType)
+		}
+	}
+	if (driverConfig.MigrationHistorySchema != "" || driverConfig.MigrationHistoryTable != "") && dbType != MySQL && dbType != TiDB && dbType != MariaDB &&
+		dbType != Postgres && dbType != CockroachDB &&
+		((dbType != Cassandra && dbType != MongoDB && dbType != Trino) || driverConfig.MigrationHistoryTable != "") {
+		return nil, fmt.Errorf("configuring the migration history table isn't supported for %s", dbType)
+	}
+
+	// The drivers log with the logger, so a nil logger is replaced by a no-op one.
+	if driverConfig.Logger == nil {
+		driverConfig.Logger = zap.NewNop()
+	}
+
+	// Only opening and pinging are bounded by the connect timeout, the driver isn't bound to the context afterwards.
+	openCtx := ctx
+	if connectionConfig.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		openCtx, cancel = context.WithTimeout(ctx, connectionConfig.ConnectTimeout)
+		defer cancel()
+	}
+
+	driver, err := f(driverConfig).Open(openCtx, dbType, connectionConfig, connCtx)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := driver.Ping(ctx); err != nil {
+	if err := driver.Ping(openCtx); err != nil {
 		driver.Close(ctx)
 		return nil, err
 	}
@@ -456,6 +1254,45 @@ func Open(ctx context.Context, dbType Type, driverConfig DriverConfig, connectio
 	return driver, nil
 }
 
+// TestConnection tests connecting to the database by opening the driver, pinging the database and closing the driver.
+// The connection errors are returned as *ConnectionError where the database type supports it.
+func TestConnection(ctx context.Context, dbType Type, driverConfig DriverConfig, connectionConfig ConnectionConfig) error {
+	driver, err := Open(ctx, dbType, driverConfig, connectionConfig, ConnectionContext{})
+	if err != nil {
+		return err
+	}
+	return driver.Close(ctx)
+}
+
+// OpenWithRetry opens a database like Open, and retries at most retries times with the interval in between if it fails,
+// e.g. the database isn't ready yet right after provisioning. It stops retrying once ctx is done.
+// The error of the last attempt is returned if all attempts fail.
+func OpenWithRetry(ctx context.Context, dbType Type, driverConfig DriverConfig, connectionConfig ConnectionConfig, connCtx ConnectionContext, retries int, interval time.Duration) (Driver, error) {
+	for attempt := 0; ; attempt++ {
+		driver, err := Open(ctx, dbType, driverConfig, connectionConfig, connCtx)
+		if err == nil {
+			return driver, nil
+		}
+		if attempt >= retries {
+			return nil, fmt.Errorf("failed to open %s after %d attempts, error: %w", dbType, attempt+1, err)
+		}
+		if driverConfig.Logger != nil {
+			driverConfig.Logger.Debug("Failed to open database, retrying...",
+				zap.String("type", string(dbType)),
+				zap.Int("attempt", attempt+1),
+				zap.Error(err),
+			)
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to open %s, error: %w, last error: %v", dbType, ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
 // FormatParamNameInQuestionMark formats the param name in question mark.
 // For example, it will be WHERE hello = ? AND world = ?.
 func FormatParamNameInQuestionMark(paramNames []string) string {
@@ -483,3 +1320,18 @@ func FormatParamNameInNumberedPosition(paramNames []string) string {
 	}
 	return fmt.Sprintf("WHERE %s ", strings.Join(parts, " AND "))
 }
+
+// FormatParamNameInAtSignPosition formats the param name in at sign ordinal positions.
+// For example, it will be WHERE hello=@p1 AND world=@p2.
+func FormatParamNameInAtSignPosition(paramNames []string) string {
+	if len(paramNames) == 0 {
+		return ""
+	}
+	var parts []string
+	for i, param := range paramNames {
+		idx := fmt.Sprintf("@p%d", i+1)
+		param = param + "=" + idx
+		parts = append(parts, param)
+	}
+	return fmt.Sprintf("WHERE %s ", strings.Join(parts, " AND "))
+}
@@ -1,7 +1,12 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -79,14 +84,15 @@ func TestParseMigrationInfo(t *testing.T) {
 			filePath:         "db1__001foo__create_t1",
 			filePathTemplate: "{{DB_NAME}}__{{VERSION}}__{{DESCRIPTION}}",
 			want: MigrationInfo{
-				Version:     "001foo",
-				Namespace:   "db1",
-				Database:    "db1",
-				Environment: "",
-				Source:      VCS,
-				Type:        Migrate,
-				Description: "Create t1",
-				Creator:     "",
+				Version:        "001foo",
+				Namespace:      "db1",
+				Database:       "db1",
+				Environment:    "",
+				Source:         VCS,
+				Type:           Migrate,
+				Description:    "Create t1",
+				RawDescription: "create_t1",
+				Creator:        "",
 			},
 			wantErr: "",
 		},
@@ -124,14 +130,15 @@ func TestParseMigrationInfo(t *testing.T) {
 			filePath:         "db1__001foo__baseline__create_t1",
 			filePathTemplate: "{{DB_NAME}}__{{VERSION}}__{{TYPE}}__{{DESCRIPTION}}",
 			want: MigrationInfo{
-				Version:     "001foo",
-				Namespace:   "db1",
-				Database:    "db1",
-				Environment: "",
-				Source:      VCS,
-				Type:        Baseline,
-				Description: "Create t1",
-				Creator:     "",
+				Version:        "001foo",
+				Namespace:      "db1",
+				Database:       "db1",
+				Environment:    "",
+				Source:         VCS,
+				Type:           Baseline,
+				Description:    "Create t1",
+				RawDescription: "create_t1",
+				Creator:        "",
 			},
 			wantErr: "",
 		},
@@ -139,14 +146,15 @@ func TestParseMigrationInfo(t *testing.T) {
 			filePath:         "db_shop1__001foo__baseline__create_t1",
 			filePathTemplate: "{{DB_NAME}}__{{VERSION}}__{{TYPE}}__{{DESCRIPTION}}",
 			want: MigrationInfo{
-				Version:     "001foo",
-				Namespace:   "db_shop1",
-				Database:    "db_shop1",
-				Environment: "",
-				Source:      VCS,
-				Type:        Baseline,
-				Description: "Create t1",
-				Creator:     "",
+				Version:        "001foo",
+				Namespace:      "db_shop1",
+				Database:       "db_shop1",
+				Environment:    "",
+				Source:         VCS,
+				Type:           Baseline,
+				Description:    "Create t1",
+				RawDescription: "create_t1",
+				Creator:        "",
 			},
 			wantErr: "",
 		},
@@ -169,14 +177,31 @@ func TestParseMigrationInfo(t *testing.T) {
 			filePath:         "db_shop1__001foo__data__fix_customer_info",
 			filePathTemplate: "{{DB_NAME}}__{{VERSION}}__{{TYPE}}__{{DESCRIPTION}}",
 			want: MigrationInfo{
-				Version:     "001foo",
-				Namespace:   "db_shop1",
-				Database:    "db_shop1",
-				Environment: "",
-				Source:      VCS,
-				Type:        Data,
-				Description: "Fix customer info",
-				Creator:     "",
+				Version:        "001foo",
+				Namespace:      "db_shop1",
+				Database:       "db_shop1",
+				Environment:    "",
+				Source:         VCS,
+				Type:           Data,
+				Description:    "Fix customer info",
+				RawDescription: "fix_customer_info",
+				Creator:        "",
+			},
+			wantErr: "",
+		},
+		{
+			filePath:         "db1__001foo__migrate__add_baseline_column",
+			filePathTemplate: "{{DB_NAME}}__{{VERSION}}__{{TYPE}}__{{DESCRIPTION}}",
+			want: MigrationInfo{
+				Version:        "001foo",
+				Namespace:      "db1",
+				Database:       "db1",
+				Environment:    "",
+				Source:         VCS,
+				Type:           Migrate,
+				Description:    "Add baseline column",
+				RawDescription: "add_baseline_column",
+				Creator:        "",
 			},
 			wantErr: "",
 		},
@@ -194,6 +219,53 @@ func TestParseMigrationInfo(t *testing.T) {
 			},
 			wantErr: "does not match file path template",
 		},
+		{
+			filePath:         "202312_mydb_",
+			filePathTemplate: "{{VERSION}}_{{DB_NAME}}_{{DESCRIPTION}}",
+			wantErr:          "does not match file path template",
+		},
+		{
+			filePath:         "202312_mydb__create",
+			filePathTemplate: "{{VERSION}}_{{DB_NAME}}__{{DESCRIPTION}}",
+			want: MigrationInfo{
+				Version:        "202312",
+				Namespace:      "mydb",
+				Database:       "mydb",
+				Environment:    "",
+				Source:         VCS,
+				Type:           Migrate,
+				Description:    "Create",
+				RawDescription: "create",
+				Creator:        "",
+			},
+			wantErr: "",
+		},
+		{
+			filePath:         "1.0_app##tenant1__add_column",
+			filePathTemplate: "{{VERSION}}_{{NAMESPACE}}##{{DB_NAME}}__{{DESCRIPTION}}",
+			want: MigrationInfo{
+				Version:        "1.0",
+				Namespace:      "app",
+				Database:       "tenant1",
+				Environment:    "",
+				Source:         VCS,
+				Type:           Migrate,
+				Description:    "Add column",
+				RawDescription: "add_column",
+				Creator:        "",
+			},
+			wantErr: "",
+		},
+		{
+			filePath:         "_",
+			filePathTemplate: "{{VERSION}}_{{DB_NAME}}_{{DESCRIPTION}}",
+			wantErr:          "does not match file path template",
+		},
+		{
+			filePath:         "202312_mydb___",
+			filePathTemplate: "{{VERSION}}_{{DB_NAME}}__{{DESCRIPTION}}",
+			wantErr:          "contains an empty description",
+		},
 	}
 
 	for _, tc := range tests {
@@ -206,3 +278,312 @@ func TestParseMigrationInfo(t *testing.T) {
 		require.Equal(t, tc.want, *mi)
 	}
 }
+
+func TestParseMigrationInfoWithConfig(t *testing.T) {
+	mi, err := ParseMigrationInfoWithConfig("20220101__db1__add-user-table.sql", "{{VERSION}}__{{DB_NAME}}__{{DESCRIPTION}}.sql", ParseConfig{DescriptionSeparator: "-"})
+	require.NoError(t, err)
+	require.Equal(t, "20220101", mi.Version)
+	require.Equal(t, "db1", mi.Database)
+	require.Equal(t, "Add user table", mi.Description)
+	require.Equal(t, "add-user-table", mi.RawDescription)
+
+	mi, err = ParseMigrationInfoWithConfig("20220101__db1__add-user-table.sql", "{{VERSION}}__{{DB_NAME}}__{{DESCRIPTION}}.sql", ParseConfig{KeepRawDescription: true})
+	require.NoError(t, err)
+	require.Equal(t, "add-user-table", mi.Description)
+
+	mi, err = ParseMigrationInfoWithConfig("20220101__db1__add_API_key_column.sql", "{{VERSION}}__{{DB_NAME}}__{{DESCRIPTION}}.sql", ParseConfig{KeepDescriptionCase: true})
+	require.NoError(t, err)
+	require.Equal(t, "add API key column", mi.Description)
+
+	versionPattern := regexp.MustCompile(`^\d+$`)
+	mi, err = ParseMigrationInfoWithConfig("20220101__db1.sql", "{{VERSION}}__{{DB_NAME}}.sql", ParseConfig{VersionPattern: versionPattern})
+	require.NoError(t, err)
+	require.Equal(t, "20220101", mi.Version)
+	_, err = ParseMigrationInfoWithConfig("verison1__db1.sql", "{{VERSION}}__{{DB_NAME}}.sql", ParseConfig{VersionPattern: versionPattern})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `contains version "verison1" not matching the pattern`)
+}
+
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "1.2.9", b: "1.2.10", want: -1},
+		{a: "1.2.10", b: "1.2.9", want: 1},
+		{a: "1.2", b: "1.2.0", want: 0},
+		{a: "2", b: "10", want: -1},
+		{a: "1.10.0", b: "1.9.99", want: 1},
+		// Non-numeric versions are compared lexically.
+		{a: "001foo", b: "002bar", want: -1},
+		{a: "v2", b: "v10", want: 1},
+		{a: "1.2.x", b: "1.2.10", want: 1},
+		{a: "1..2", b: "1.2", want: -1},
+	}
+
+	for _, tc := range tests {
+		require.Equal(t, tc.want, CompareVersion(tc.a, tc.b), "CompareVersion(%q, %q)", tc.a, tc.b)
+	}
+}
+
+func TestMigrationInfoSemanticVersion(t *testing.T) {
+	major, minor, patch, err := MigrationInfo{Version: "1.2.10"}.SemanticVersion()
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 10}, []int{major, minor, patch})
+
+	_, _, _, err = MigrationInfo{Version: "001foo"}.SemanticVersion()
+	require.Error(t, err)
+}
+
+func TestRegisteredTypes(t *testing.T) {
+	newDriver := func(DriverConfig) Driver { return nil }
+	Register("TEST_B", newDriver)
+	Register("TEST_A", newDriver)
+	defer func() {
+		driversMu.Lock()
+		defer driversMu.Unlock()
+		delete(drivers, "TEST_A")
+		delete(drivers, "TEST_B")
+	}()
+
+	require.Equal(t, []Type{"TEST_A", "TEST_B"}, RegisteredTypes())
+	require.Panics(t, func() { Register("TEST_A", newDriver) })
+}
+
+// retryDriver is a driver failing to ping until the number of failures is reached.
+type retryDriver struct {
+	Driver
+	attempts *int
+	failures int
+	closes   int
+}
+
+func (d *retryDriver) Open(context.Context, Type, ConnectionConfig, ConnectionContext) (Driver, error) {
+	*d.attempts++
+	return d, nil
+}
+
+func (d *retryDriver) Ping(context.Context) error {
+	if *d.attempts <= d.failures {
+		return fmt.Errorf("not ready")
+	}
+	return nil
+}
+
+func (d *retryDriver) Close(context.Context) error {
+	d.closes++
+	return nil
+}
+
+func TestOpenWithRetry(t *testing.T) {
+	tests := []struct {
+		failures     int
+		retries      int
+		wantAttempts int
+		wantErr      string
+	}{
+		{failures: 0, retries: 0, wantAttempts: 1},
+		{failures: 2, retries: 3, wantAttempts: 3},
+		{failures: 3, retries: 2, wantAttempts: 3, wantErr: "after 3 attempts"},
+	}
+	for i, tc := range tests {
+		dbType := Type(fmt.Sprintf("TEST_RETRY_%d", i))
+		attempts := 0
+		failures := tc.failures
+		Register(dbType, func(DriverConfig) Driver { return &retryDriver{attempts: &attempts, failures: failures} })
+
+		_, err := OpenWithRetry(context.Background(), dbType, DriverConfig{}, ConnectionConfig{}, ConnectionContext{}, tc.retries, time.Millisecond)
+		if tc.wantErr != "" {
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.wantErr)
+		} else {
+			require.NoError(t, err)
+		}
+		require.Equal(t, tc.wantAttempts, attempts)
+
+		driversMu.Lock()
+		delete(drivers, dbType)
+		driversMu.Unlock()
+	}
+
+	// Retrying stops once the context is done.
+	Register("TEST_RETRY_CANCEL", func(DriverConfig) Driver { return &retryDriver{attempts: new(int), failures: 100} })
+	defer func() {
+		driversMu.Lock()
+		defer driversMu.Unlock()
+		delete(drivers, "TEST_RETRY_CANCEL")
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := OpenWithRetry(ctx, "TEST_RETRY_CANCEL", DriverConfig{}, ConnectionConfig{}, ConnectionContext{}, 100, time.Hour)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBatchError(t *testing.T) {
+	cause := fmt.Errorf("syntax error")
+	var err error = &BatchError{Index: 2, Err: cause}
+	require.Equal(t, "failed to execute statement #2, error: syntax error", err.Error())
+	require.ErrorIs(t, err, cause)
+
+	var batchErr *BatchError
+	require.True(t, errors.As(fmt.Errorf("wrapped: %w", err), &batchErr))
+	require.Equal(t, 2, batchErr.Index)
+}
+
+func TestConnectionError(t *testing.T) {
+	cause := fmt.Errorf("Access denied for user 'root'")
+	var err error = &ConnectionError{Kind: ErrAuthFailed, Err: cause}
+	require.Equal(t, "authentication failed, error: Access denied for user 'root'", err.Error())
+	require.ErrorIs(t, err, ErrAuthFailed)
+	require.ErrorIs(t, err, cause)
+	require.False(t, errors.Is(err, ErrConnRefused))
+
+	var connErr *ConnectionError
+	require.True(t, errors.As(fmt.Errorf("wrapped: %w", err), &connErr))
+	require.Equal(t, ErrAuthFailed, connErr.Kind)
+}
+
+func TestStatementError(t *testing.T) {
+	cause := fmt.Errorf("relation \"t\" does not exist")
+	var err error = &StatementError{Index: 4, Statement: "DROP TABLE t", Err: cause}
+	require.Equal(t, `failed to execute statement #5 "DROP TABLE t", error: relation "t" does not exist`, err.Error())
+	require.ErrorIs(t, err, cause)
+
+	err = &StatementError{Index: 1, Statement: "INSERT INTO t VALUES (1)", Committed: true, Err: cause}
+	require.Equal(t, `failed to execute statement #2 "INSERT INTO t VALUES (1)", the previous statements are committed and not rolled back, error: relation "t" does not exist`, err.Error())
+}
+
+func TestTestConnection(t *testing.T) {
+	for _, failures := range []int{0, 1} {
+		dbType := Type(fmt.Sprintf("TEST_CONNECTION_%d", failures))
+		driver := &retryDriver{attempts: new(int), failures: failures}
+		Register(dbType, func(DriverConfig) Driver { return driver })
+
+		err := TestConnection(context.Background(), dbType, DriverConfig{}, ConnectionConfig{})
+		if failures > 0 {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+		}
+		// The driver is closed whether the ping succeeds or not.
+		require.Equal(t, 1, driver.closes)
+
+		driversMu.Lock()
+		delete(drivers, dbType)
+		driversMu.Unlock()
+	}
+}
+
+func TestValidateStatement(t *testing.T) {
+	info := &MigrationInfo{Database: "db1", Version: "001"}
+	require.NoError(t, DriverConfig{}.ValidateStatement(MySQL, "CREATE TABLE t (id INT)", info))
+	require.ErrorIs(t, DriverConfig{}.ValidateStatement(MySQL, "DROP TABLE t", info), ErrDestructiveStatement)
+	info.AllowDestructive = true
+	require.NoError(t, DriverConfig{}.ValidateStatement(MySQL, "DROP TABLE t", info))
+
+	errRejected := errors.New("DROP TABLE requires a ticket")
+	config := DriverConfig{
+		StatementValidator: func(statement string, m *MigrationInfo) error {
+			require.Equal(t, info, m)
+			if regexp.MustCompile(`(?i)\bDROP\s+TABLE\b`).MatchString(statement) {
+				return errRejected
+			}
+			return nil
+		},
+	}
+	require.NoError(t, config.ValidateStatement(MySQL, "CREATE TABLE t (id INT)", info))
+	require.Equal(t, errRejected, config.ValidateStatement(MySQL, "drop table t", info))
+}
+
+func TestMigrationInfoFilePath(t *testing.T) {
+	tests := []struct {
+		mi               MigrationInfo
+		filePathTemplate string
+		want             string
+		wantErr          string
+	}{
+		{
+			mi:               MigrationInfo{Version: "1.2", Database: "db1", Type: Migrate, Description: "Create user table"},
+			filePathTemplate: "{{VERSION}}__{{DB_NAME}}__{{DESCRIPTION}}.sql",
+			want:             "1.2__db1__Create_user_table.sql",
+		},
+		{
+			mi:               MigrationInfo{Version: "1.2", Database: "db1", Environment: "prod", Type: Baseline, Description: "Create db1 baseline"},
+			filePathTemplate: "{{ENV_NAME}}/{{VERSION}}__{{DB_NAME}}__{{TYPE}}.sql",
+			want:             "prod/1.2__db1__baseline.sql",
+		},
+		{
+			mi:               MigrationInfo{Version: "1.2", Database: "db1", Namespace: "tenant", Type: Data, RawDescription: "add-rows"},
+			filePathTemplate: "{{VERSION}}_{{NAMESPACE}}##{{DB_NAME}}##{{TYPE}}##{{DESCRIPTION}}.sql",
+			want:             "1.2_tenant##db1##data##add-rows.sql",
+		},
+		{
+			mi:               MigrationInfo{Version: "1.2", Database: "db1", Type: Migrate},
+			filePathTemplate: "{{VERSION}}__{{DB_NAME}}__{{DESCRIPTION}}.sql",
+			wantErr:          "has an empty value for {{DESCRIPTION}}",
+		},
+		{
+			mi:               MigrationInfo{Version: "1.2", Database: "my_db", Type: Migrate, Description: "Create table"},
+			filePathTemplate: "{{VERSION}}_{{DB_NAME}}_{{DESCRIPTION}}.sql",
+			wantErr:          "can't be parsed back",
+		},
+	}
+	for _, tc := range tests {
+		filePath, err := tc.mi.FilePath(tc.filePathTemplate)
+		if tc.wantErr != "" {
+			require.Contains(t, err.Error(), tc.wantErr)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tc.want, filePath)
+
+		mi, err := ParseMigrationInfo(filePath, tc.filePathTemplate)
+		require.NoError(t, err)
+		require.Equal(t, tc.mi.Version, mi.Version)
+		require.Equal(t, tc.mi.Database, mi.Database)
+		require.Equal(t, tc.mi.Type, mi.Type)
+	}
+}
+
+func TestMigrationInfoFilename(t *testing.T) {
+	tests := []struct {
+		mi               MigrationInfo
+		want             string
+		filePathTemplate string
+	}{
+		{
+			mi:               MigrationInfo{Version: "1.2", Database: "db1", Type: Migrate, Description: "Create user table"},
+			want:             "1.2_db1_Create_user_table.sql",
+			filePathTemplate: "{{VERSION}}_{{DB_NAME}}_{{DESCRIPTION}}.sql",
+		},
+		{
+			mi:               MigrationInfo{Version: "1.2", Database: "db1", Type: Baseline, Description: "Initial schema"},
+			want:             "1.2_db1_baseline_Initial_schema.sql",
+			filePathTemplate: "{{VERSION}}_{{DB_NAME}}_{{TYPE}}_{{DESCRIPTION}}.sql",
+		},
+		{
+			mi:               MigrationInfo{Version: "1.2", Database: "db1", Type: Baseline},
+			want:             "1.2_db1_baseline.sql",
+			filePathTemplate: "{{VERSION}}_{{DB_NAME}}_{{TYPE}}.sql",
+		},
+		{
+			mi:               MigrationInfo{Version: "1.2", Database: "db1", Type: Migrate, RawDescription: "add-rows"},
+			want:             "1.2_db1_add-rows.sql",
+			filePathTemplate: "{{VERSION}}_{{DB_NAME}}_{{DESCRIPTION}}.sql",
+		},
+	}
+	for _, tc := range tests {
+		filename := tc.mi.Filename()
+		require.Equal(t, tc.want, filename)
+
+		mi, err := ParseMigrationInfoWithConfig(filename, tc.filePathTemplate, ParseConfig{KeepDescriptionCase: true})
+		require.NoError(t, err)
+		require.Equal(t, tc.mi.Version, mi.Version)
+		require.Equal(t, tc.mi.Database, mi.Database)
+		require.Equal(t, tc.mi.Type, mi.Type)
+		if tc.mi.Description != "" {
+			require.Equal(t, tc.mi.Description, mi.Description)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// numericRegex matches the numeric literals.
+var numericRegex = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// GenerateMigration generates the DDL statements to apply the schema diff for the database type.
+// It also returns the warnings for the potentially destructive statements, e.g. dropping a table or a column,
+// changing a column type or making a column NOT NULL, which should be reviewed before applying.
+// Only MySQL, TiDB, MariaDB are supported.
+func GenerateMigration(diff *SchemaDiff, dbType Type) ([]string, []string, error) {
+	switch dbType {
+	case MySQL, TiDB, MariaDB:
+		g := &mysqlGenerator{dbType: dbType}
+		g.generate(diff)
+		return g.statementList, g.warningList, nil
+	}
+	return nil, nil, fmt.Errorf("generating migration isn't supported for %s", dbType)
+}
+
+// GenerateCreateTable generates the CREATE TABLE statement of the synced table for the database type,
+// including the columns, indexes and foreign keys. Only MySQL, TiDB, MariaDB are supported.
+func GenerateCreateTable(table *Table, dbType Type) (string, error) {
+	switch dbType {
+	case MySQL, TiDB, MariaDB:
+		return mysqlCreateTableStatement(table, table.ForeignKeyList, dbType), nil
+	}
+	return "", fmt.Errorf("generating CREATE TABLE isn't supported for %s", dbType)
+}
+
+type mysqlGenerator struct {
+	dbType        Type
+	statementList []string
+	warningList   []string
+}
+
+func (g *mysqlGenerator) addStatement(format string, a ...interface{}) {
+	g.statementList = append(g.statementList, fmt.Sprintf(format, a...))
+}
+
+func (g *mysqlGenerator) addWarning(format string, a ...interface{}) {
+	g.warningList = append(g.warningList, fmt.Sprintf(format, a...))
+}
+
+// generate generates the statements in the order of dropping foreign keys, dropping tables, creating tables, altering tables,
+// dropping indexes, changing columns, adding indexes, and then adding the foreign keys,
+// so an index is dropped before its columns and added after its columns, and a foreign key is dropped before
+// and added after the tables, the columns and the indexes it references.
+func (g *mysqlGenerator) generate(diff *SchemaDiff) {
+	for _, change := range diff.ForeignKeyChangeList {
+		if change.Type == SchemaChangeDrop || change.Type == SchemaChangeModify {
+			g.addStatement("ALTER TABLE %s DROP FOREIGN KEY %s;", quoteMySQLIdentifier(change.TableName), quoteMySQLIdentifier(change.Name))
+		}
+	}
+	for _, change := range diff.TableChangeList {
+		if change.Type == SchemaChangeDrop {
+			g.addStatement("DROP TABLE %s;", quoteMySQLIdentifier(change.Name))
+			g.addWarning("Dropping table %q deletes all its data.", change.Name)
+		}
+	}
+	for _, change := range diff.TableChangeList {
+		if change.Type == SchemaChangeAdd {
+			g.addStatement("%s", mysqlCreateTableStatement(change.NewTable, nil /* foreignKeyList */, g.dbType))
+		}
+	}
+	for _, change := range diff.TableChangeList {
+		if change.Type == SchemaChangeModify {
+			var optionList []string
+			if change.OldTable.Engine != change.NewTable.Engine {
+				optionList = append(optionList, fmt.Sprintf("ENGINE=%s", change.NewTable.Engine))
+				g.addWarning("Changing the engine of table %q rebuilds the table.", change.Name)
+			}
+			if change.OldTable.Collation != change.NewTable.Collation {
+				optionList = append(optionList, fmt.Sprintf("COLLATE=%s", change.NewTable.Collation))
+			}
+			if change.OldTable.Comment != change.NewTable.Comment {
+				optionList = append(optionList, fmt.Sprintf("COMMENT=%s", quoteMySQLString(change.NewTable.Comment)))
+			}
+			g.addStatement("ALTER TABLE %s %s;", quoteMySQLIdentifier(change.Name), strings.Join(optionList, ", "))
+		}
+	}
+
+	for _, change := range diff.IndexChangeList {
+		if change.Type == SchemaChangeDrop || change.Type == SchemaChangeModify {
+			g.addStatement("ALTER TABLE %s %s;", quoteMySQLIdentifier(change.TableName), mysqlDropIndexClause(change.Name))
+		}
+	}
+
+	for _, change := range diff.ColumnChangeList {
+		table := quoteMySQLIdentifier(change.TableName)
+		switch change.Type {
+		case SchemaChangeDrop:
+			g.addStatement("ALTER TABLE %s DROP COLUMN %s;", table, quoteMySQLIdentifier(change.Name))
+			g.addWarning("Dropping column %q.%q deletes all its data.", change.TableName, change.Name)
+		case SchemaChangeAdd:
+			g.addStatement("ALTER TABLE %s ADD COLUMN %s;", table, mysqlColumnDefinition(change.NewColumn, g.dbType))
+			if !change.NewColumn.Nullable && change.NewColumn.Default == nil {
+				g.addWarning("Adding NOT NULL column %q.%q without a default value may fail on a non-empty table.", change.TableName, change.Name)
+			}
+		case SchemaChangeModify:
+			g.addStatement("ALTER TABLE %s MODIFY COLUMN %s;", table, mysqlColumnDefinition(change.NewColumn, g.dbType))
+			if change.OldColumn.Type != change.NewColumn.Type {
+				g.addWarning("Changing the type of column %q.%q from %s to %s may truncate or fail to convert the data.", change.TableName, change.Name, change.OldColumn.Type, change.NewColumn.Type)
+			}
+			if change.OldColumn.Nullable && !change.NewColumn.Nullable {
+				g.addWarning("Changing column %q.%q to NOT NULL fails if it contains NULL values.", change.TableName, change.Name)
+			}
+		}
+	}
+
+	for _, change := range diff.IndexChangeList {
+		if change.Type == SchemaChangeAdd || change.Type == SchemaChangeModify {
+			g.addStatement("ALTER TABLE %s ADD %s;", quoteMySQLIdentifier(change.TableName), mysqlIndexDefinition(change.Name, change.NewIndexList, g.dbType))
+		}
+	}
+
+	for _, change := range diff.TableChangeList {
+		if change.Type == SchemaChangeAdd {
+			for i := range change.NewTable.ForeignKeyList {
+				g.addStatement("ALTER TABLE %s ADD %s;", quoteMySQLIdentifier(change.Name), mysqlForeignKeyDefinition(&change.NewTable.ForeignKeyList[i]))
+			}
+		}
+	}
+	for _, change := range diff.ForeignKeyChangeList {
+		if change.Type == SchemaChangeAdd || change.Type == SchemaChangeModify {
+			g.addStatement("ALTER TABLE %s ADD %s;", quoteMySQLIdentifier(change.TableName), mysqlForeignKeyDefinition(change.NewForeignKey))
+		}
+	}
+}
+
+func mysqlCreateTableStatement(table *Table, foreignKeyList []ForeignKey, dbType Type) string {
+	var definitionList []string
+	for i := range table.ColumnList {
+		definitionList = append(definitionList, mysqlColumnDefinition(&table.ColumnList[i], dbType))
+	}
+	indexMap := groupIndexList(table.IndexList)
+	for _, name := range sortedIndexNames(indexMap) {
+		definitionList = append(definitionList, mysqlIndexDefinition(name, indexMap[name], dbType))
+	}
+	for i := range foreignKeyList {
+		definitionList = append(definitionList, mysqlForeignKeyDefinition(&foreignKeyList[i]))
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "CREATE TABLE %s (\n  %s\n)", quoteMySQLIdentifier(table.Name), strings.Join(definitionList, ",\n  "))
+	if table.Engine != "" {
+		fmt.Fprintf(&buf, " ENGINE=%s", table.Engine)
+	}
+	if table.Collation != "" {
+		fmt.Fprintf(&buf, " COLLATE=%s", table.Collation)
+	}
+	if table.Comment != "" {
+		fmt.Fprintf(&buf, " COMMENT=%s", quoteMySQLString(table.Comment))
+	}
+	buf.WriteString(";")
+	return buf.String()
+}
+
+// sortedIndexNames returns the index names with the primary key first.
+func sortedIndexNames(indexMap map[string][]Index) []string {
+	var nameList []string
+	for name := range indexMap {
+		if name != mysqlPrimaryKeyName {
+			nameList = append(nameList, name)
+		}
+	}
+	sort.Strings(nameList)
+	if _, ok := indexMap[mysqlPrimaryKeyName]; ok {
+		nameList = append([]string{mysqlPrimaryKeyName}, nameList...)
+	}
+	return nameList
+}
+
+func mysqlColumnDefinition(column *Column, dbType Type) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%s %s", quoteMySQLIdentifier(column.Name), column.Type)
+	if column.CharacterSet != "" {
+		fmt.Fprintf(&buf, " CHARACTER SET %s", column.CharacterSet)
+	}
+	if column.Collation != "" {
+		fmt.Fprintf(&buf, " COLLATE %s", column.Collation)
+	}
+	if !column.Nullable {
+		buf.WriteString(" NOT NULL")
+	}
+	if column.Default != nil {
+		fmt.Fprintf(&buf, " DEFAULT %s", mysqlDefaultValue(column, dbType))
+	}
+	if column.Comment != "" {
+		fmt.Fprintf(&buf, " COMMENT %s", quoteMySQLString(column.Comment))
+	}
+	return buf.String()
+}
+
+// mysqlDefaultValue formats the column default from information_schema.COLUMNS.COLUMN_DEFAULT.
+// MariaDB 10.2.7 and later holds the default as an expression, where the literals are quoted already.
+// MySQL and TiDB hold the literal value without quotes, or the expression without parentheses
+// if the column EXTRA contains DEFAULT_GENERATED, e.g. uuid() for DEFAULT (uuid()).
+func mysqlDefaultValue(column *Column, dbType Type) string {
+	value := *column.Default
+	if dbType == MariaDB {
+		return value
+	}
+	upper := strings.ToUpper(value)
+	if upper == "NULL" || numericRegex.MatchString(value) || strings.HasPrefix(upper, "CURRENT_TIMESTAMP") {
+		return value
+	}
+	if strings.Contains(strings.ToUpper(column.Extra), "DEFAULT_GENERATED") {
+		return fmt.Sprintf("(%s)", value)
+	}
+	return quoteMySQLString(value)
+}
+
+// mysqlPrimaryKeyName is the index name of the primary key in MySQL.
+const mysqlPrimaryKeyName = "PRIMARY"
+
+func mysqlIndexDefinition(name string, indexList []Index, dbType Type) string {
+	var expressionList []string
+	for _, index := range indexList {
+		expressionList = append(expressionList, mysqlIndexExpression(index.Expression))
+	}
+	keys := strings.Join(expressionList, ", ")
+	if name == mysqlPrimaryKeyName {
+		return fmt.Sprintf("PRIMARY KEY (%s)", keys)
+	}
+
+	// All entries of an index share the same attributes.
+	index := indexList[0]
+	var buf strings.Builder
+	switch {
+	case index.Type == "FULLTEXT":
+		buf.WriteString("FULLTEXT INDEX")
+	case index.Type == "SPATIAL":
+		buf.WriteString("SPATIAL INDEX")
+	case index.Unique:
+		buf.WriteString("UNIQUE INDEX")
+	default:
+		buf.WriteString("INDEX")
+	}
+	fmt.Fprintf(&buf, " %s (%s)", quoteMySQLIdentifier(name), keys)
+	if index.Comment != "" {
+		fmt.Fprintf(&buf, " COMMENT %s", quoteMySQLString(index.Comment))
+	}
+	if !index.Visible {
+		// MariaDB calls the invisible indexes ignored indexes.
+		if dbType == MariaDB {
+			buf.WriteString(" IGNORED")
+		} else {
+			buf.WriteString(" INVISIBLE")
+		}
+	}
+	return buf.String()
+}
+
+func mysqlForeignKeyDefinition(foreignKey *ForeignKey) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "CONSTRAINT %s FOREIGN KEY (%s) REFERENCES ", quoteMySQLIdentifier(foreignKey.Name), mysqlIdentifierList(foreignKey.ColumnList))
+	if foreignKey.ReferencedSchema != "" {
+		fmt.Fprintf(&buf, "%s.", quoteMySQLIdentifier(foreignKey.ReferencedSchema))
+	}
+	fmt.Fprintf(&buf, "%s (%s)", quoteMySQLIdentifier(foreignKey.ReferencedTable), mysqlIdentifierList(foreignKey.ReferencedColumnList))
+	if foreignKey.OnDelete != "" {
+		fmt.Fprintf(&buf, " ON DELETE %s", foreignKey.OnDelete)
+	}
+	if foreignKey.OnUpdate != "" {
+		fmt.Fprintf(&buf, " ON UPDATE %s", foreignKey.OnUpdate)
+	}
+	return buf.String()
+}
+
+func mysqlIdentifierList(nameList []string) string {
+	var quotedList []string
+	for _, name := range nameList {
+		quotedList = append(quotedList, quoteMySQLIdentifier(name))
+	}
+	return strings.Join(quotedList, ", ")
+}
+
+// mysqlIndexExpression quotes the index column name, and wraps the functional key part in parentheses.
+func mysqlIndexExpression(expression string) string {
+	if strings.ContainsAny(expression, "() ") {
+		return fmt.Sprintf("(%s)", expression)
+	}
+	return quoteMySQLIdentifier(expression)
+}
+
+func mysqlDropIndexClause(name string) string {
+	if name == mysqlPrimaryKeyName {
+		return "DROP PRIMARY KEY"
+	}
+	return fmt.Sprintf("DROP INDEX %s", quoteMySQLIdentifier(name))
+}
+
+func quoteMySQLIdentifier(name string) string {
+	return fmt.Sprintf("`%s`", strings.ReplaceAll(name, "`", "``"))
+}
+
+func quoteMySQLString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(s, "'", "''"))
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateMigration(t *testing.T) {
+	defaultZero := "0"
+	defaultName := "it's"
+	oldSchema := &Schema{
+		TableList: []Table{
+			{Name: "t_drop"},
+			{
+				Name:   "t1",
+				Engine: "InnoDB",
+				ColumnList: []Column{
+					{Name: "id", Position: 1, Type: "int"},
+					{Name: "name", Position: 2, Type: "varchar(64)", Nullable: true},
+					{Name: "deleted", Position: 3, Type: "tinyint"},
+				},
+				IndexList: []Index{
+					{Name: "PRIMARY", Expression: "id", Position: 1, Type: "BTREE", Unique: true, Visible: true},
+					{Name: "idx_deleted", Expression: "deleted", Position: 1, Type: "BTREE", Visible: true},
+				},
+			},
+		},
+	}
+	newSchema := &Schema{
+		TableList: []Table{
+			{
+				Name:   "t1",
+				Engine: "InnoDB",
+				ColumnList: []Column{
+					{Name: "id", Position: 1, Type: "int"},
+					{Name: "name", Position: 2, Type: "varchar(128)", Default: &defaultName, Comment: "the name"},
+					{Name: "count", Position: 3, Type: "int", Default: &defaultZero},
+					{Name: "created_ts", Position: 4, Type: "bigint"},
+				},
+				IndexList: []Index{
+					{Name: "PRIMARY", Expression: "id", Position: 1, Type: "BTREE", Unique: true, Visible: true},
+					{Name: "idx_name_count", Expression: "name", Position: 1, Type: "BTREE", Unique: true, Visible: true},
+					{Name: "idx_name_count", Expression: "count", Position: 2, Type: "BTREE", Unique: true, Visible: true},
+				},
+			},
+			{
+				Name:    "t_add",
+				Engine:  "InnoDB",
+				Comment: "new table",
+				ColumnList: []Column{
+					{Name: "id", Position: 1, Type: "int"},
+					{Name: "payload", Position: 2, Type: "json", Nullable: true},
+				},
+				IndexList: []Index{
+					{Name: "idx_payload", Expression: "cast(`payload` as unsigned array)", Position: 1, Type: "BTREE", Visible: false},
+					{Name: "PRIMARY", Expression: "id", Position: 1, Type: "BTREE", Unique: true, Visible: true},
+				},
+				ForeignKeyList: []ForeignKey{
+					{Name: "fk_t1", ColumnList: []string{"id"}, ReferencedTable: "t1", ReferencedColumnList: []string{"id"}, OnDelete: "CASCADE"},
+				},
+			},
+		},
+	}
+
+	statementList, warningList, err := GenerateMigration(DiffSchema(oldSchema, newSchema), MySQL)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"DROP TABLE `t_drop`;",
+		"CREATE TABLE `t_add` (\n" +
+			"  `id` int NOT NULL,\n" +
+			"  `payload` json,\n" +
+			"  PRIMARY KEY (`id`),\n" +
+			"  INDEX `idx_payload` ((cast(`payload` as unsigned array))) INVISIBLE\n" +
+			") ENGINE=InnoDB COMMENT='new table';",
+		"ALTER TABLE `t1` DROP INDEX `idx_deleted`;",
+		"ALTER TABLE `t1` DROP COLUMN `deleted`;",
+		"ALTER TABLE `t1` MODIFY COLUMN `name` varchar(128) NOT NULL DEFAULT 'it''s' COMMENT 'the name';",
+		"ALTER TABLE `t1` ADD COLUMN `count` int NOT NULL DEFAULT 0;",
+		"ALTER TABLE `t1` ADD COLUMN `created_ts` bigint NOT NULL;",
+		"ALTER TABLE `t1` ADD UNIQUE INDEX `idx_name_count` (`name`, `count`);",
+		"ALTER TABLE `t_add` ADD CONSTRAINT `fk_t1` FOREIGN KEY (`id`) REFERENCES `t1` (`id`) ON DELETE CASCADE;",
+	}, statementList)
+	require.Equal(t, []string{
+		`Dropping table "t_drop" deletes all its data.`,
+		`Dropping column "t1"."deleted" deletes all its data.`,
+		`Changing the type of column "t1"."name" from varchar(64) to varchar(128) may truncate or fail to convert the data.`,
+		`Changing column "t1"."name" to NOT NULL fails if it contains NULL values.`,
+		`Adding NOT NULL column "t1"."created_ts" without a default value may fail on a non-empty table.`,
+	}, warningList)
+
+	_, _, err = GenerateMigration(DiffSchema(oldSchema, newSchema), Postgres)
+	require.Error(t, err)
+}
+
+func TestGenerateMigrationDialect(t *testing.T) {
+	uuidDefault := "uuid()"
+	nameDefault := "it's"
+	quotedNameDefault := "'it''s'"
+	table := &Table{
+		Name: "t1",
+		ColumnList: []Column{
+			{Name: "id", Position: 1, Type: "varchar(36)", Default: &uuidDefault, Extra: "DEFAULT_GENERATED"},
+			{Name: "name", Position: 2, Type: "varchar(64)", Default: &nameDefault},
+		},
+		IndexList: []Index{
+			{Name: "idx_name", Expression: "name", Position: 1, Type: "BTREE", Visible: false},
+		},
+	}
+	statement, err := GenerateCreateTable(table, MySQL)
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE `t1` (\n"+
+		"  `id` varchar(36) NOT NULL DEFAULT (uuid()),\n"+
+		"  `name` varchar(64) NOT NULL DEFAULT 'it''s',\n"+
+		"  INDEX `idx_name` (`name`) INVISIBLE\n"+
+		");", statement)
+
+	// MariaDB holds the expressions and the quoted literals as the defaults, and calls the invisible indexes ignored indexes.
+	table.ColumnList[0].Extra = ""
+	table.ColumnList[1].Default = &quotedNameDefault
+	statement, err = GenerateCreateTable(table, MariaDB)
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE `t1` (\n"+
+		"  `id` varchar(36) NOT NULL DEFAULT uuid(),\n"+
+		"  `name` varchar(64) NOT NULL DEFAULT 'it''s',\n"+
+		"  INDEX `idx_name` (`name`) IGNORED\n"+
+		");", statement)
+}
+
+func TestGenerateMigrationForeignKey(t *testing.T) {
+	newTable := func(foreignKeyList ...ForeignKey) Table {
+		return Table{
+			Name:           "t1",
+			ColumnList:     []Column{{Name: "id", Position: 1, Type: "int"}, {Name: "parent_id", Position: 2, Type: "int"}},
+			ForeignKeyList: foreignKeyList,
+		}
+	}
+	fkParent := ForeignKey{Name: "fk_parent", ColumnList: []string{"parent_id"}, ReferencedTable: "t1", ReferencedColumnList: []string{"id"}}
+	fkParentCascade := fkParent
+	fkParentCascade.OnDelete = "CASCADE"
+	fkDrop := ForeignKey{Name: "fk_drop", ColumnList: []string{"id"}, ReferencedTable: "t_drop", ReferencedColumnList: []string{"id"}}
+	fkAdd := ForeignKey{Name: "fk_add", ColumnList: []string{"id"}, ReferencedTable: "t_add", ReferencedColumnList: []string{"id"}}
+	oldSchema := &Schema{TableList: []Table{newTable(fkParent, fkDrop), {Name: "t_drop"}}}
+	newSchema := &Schema{TableList: []Table{newTable(fkParentCascade, fkAdd), {Name: "t_add", ColumnList: []Column{{Name: "id", Position: 1, Type: "int"}}}}}
+
+	diff := DiffSchema(oldSchema, newSchema)
+	require.Len(t, diff.ForeignKeyChangeList, 3)
+	statementList, _, err := GenerateMigration(diff, MySQL)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"ALTER TABLE `t1` DROP FOREIGN KEY `fk_drop`;",
+		"ALTER TABLE `t1` DROP FOREIGN KEY `fk_parent`;",
+		"DROP TABLE `t_drop`;",
+		"CREATE TABLE `t_add` (\n  `id` int NOT NULL\n);",
+		"ALTER TABLE `t1` ADD CONSTRAINT `fk_add` FOREIGN KEY (`id`) REFERENCES `t_add` (`id`);",
+		"ALTER TABLE `t1` ADD CONSTRAINT `fk_parent` FOREIGN KEY (`parent_id`) REFERENCES `t1` (`id`) ON DELETE CASCADE;",
+	}, statementList)
+
+	require.True(t, DiffSchema(newSchema, newSchema).IsEmpty())
+}
+
+func TestGenerateCreateTable(t *testing.T) {
+	table := &Table{
+		Name:   "order",
+		Engine: "InnoDB",
+		ColumnList: []Column{
+			{Name: "id", Position: 1, Type: "int"},
+			{Name: "user_id", Position: 2, Type: "int"},
+		},
+		IndexList: []Index{
+			{Name: "idx_user_id", Expression: "user_id", Position: 1, Type: "BTREE", Visible: true},
+			{Name: "PRIMARY", Expression: "id", Position: 1, Type: "BTREE", Unique: true, Visible: true},
+		},
+		ForeignKeyList: []ForeignKey{
+			{Name: "fk_user", ColumnList: []string{"user_id"}, ReferencedSchema: "db1", ReferencedTable: "user", ReferencedColumnList: []string{"id"}, OnDelete: "CASCADE"},
+		},
+	}
+	statement, err := GenerateCreateTable(table, MariaDB)
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE `order` (\n"+
+		"  `id` int NOT NULL,\n"+
+		"  `user_id` int NOT NULL,\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  INDEX `idx_user_id` (`user_id`),\n"+
+		"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `db1`.`user` (`id`) ON DELETE CASCADE\n"+
+		") ENGINE=InnoDB;", statement)
+
+	_, err = GenerateCreateTable(table, Postgres)
+	require.EqualError(t, err, "generating CREATE TABLE isn't supported for POSTGRES")
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Migration is a migration loaded from a file by LoadMigrations.
+type Migration struct {
+	Info *MigrationInfo
+	// Path is the file path in the file system.
+	Path      string
+	Statement string
+}
+
+// LoadError is the error of the files LoadMigrations fails to load.
+type LoadError struct {
+	// ErrList is the error of each file failing to load.
+	ErrList []error
+}
+
+func (e *LoadError) Error() string {
+	var msgList []string
+	for _, err := range e.ErrList {
+		msgList = append(msgList, err.Error())
+	}
+	return fmt.Sprintf("failed to load %d migration files, error: %s", len(e.ErrList), strings.Join(msgList, "; "))
+}
+
+// LoadMigrations loads the migrations from the ".sql" files in dir and its subdirectories of fsys, sorted by version.
+// The file path relative to dir is parsed by ParseMigrationInfo with filePathTemplate, e.g. "{{VERSION}}_{{DB_NAME}}_{{DESCRIPTION}}.sql".
+// It works with embed.FS as well as os.DirFS.
+// The files failing to parse or read don't stop the walk, and LoadMigrations returns the loaded migrations together with a *LoadError listing them.
+func LoadMigrations(fsys fs.FS, dir string, filePathTemplate string) ([]*Migration, error) {
+	var migrationList []*Migration
+	var errList []error
+	if err := fs.WalkDir(fsys, dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(filePath) != ".sql" {
+			return nil
+		}
+		relativePath := strings.TrimPrefix(strings.TrimPrefix(filePath, dir), "/")
+		mi, err := ParseMigrationInfo(relativePath, filePathTemplate)
+		if err != nil {
+			errList = append(errList, err)
+			return nil
+		}
+		buf, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("failed to read file %q, error: %w", filePath, err))
+			return nil
+		}
+		migrationList = append(migrationList, &Migration{
+			Info:      mi,
+			Path:      filePath,
+			Statement: string(buf),
+		})
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walk directory %q, error: %w", dir, err)
+	}
+
+	sort.SliceStable(migrationList, func(i, j int) bool {
+		if c := CompareVersion(migrationList[i].Info.Version, migrationList[j].Info.Version); c != 0 {
+			return c < 0
+		}
+		return migrationList[i].Path < migrationList[j].Path
+	})
+	if len(errList) > 0 {
+		return migrationList, &LoadError{ErrList: errList}
+	}
+	return migrationList, nil
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/db1/1.10__add_index.sql":   {Data: []byte("CREATE INDEX idx ON t(a);")},
+		"migrations/db1/1.2__create_table.sql": {Data: []byte("CREATE TABLE t(a INT);")},
+		"migrations/db2/1.9__insert.sql":       {Data: []byte("INSERT INTO t VALUES (1);")},
+		"migrations/README.md":                 {Data: []byte("# Migrations")},
+		"migrations/invalid.sql":               {Data: []byte("SELECT 1;")},
+		"other/db1/1.0__ignored.sql":           {Data: []byte("SELECT 1;")},
+	}
+
+	migrationList, err := LoadMigrations(fsys, "migrations", "{{DB_NAME}}/{{VERSION}}__{{DESCRIPTION}}.sql")
+	var loadErr *LoadError
+	require.True(t, errors.As(err, &loadErr))
+	require.Len(t, loadErr.ErrList, 1)
+	require.Contains(t, loadErr.ErrList[0].Error(), `file path "invalid.sql" does not match file path template`)
+
+	var pathList []string
+	for _, m := range migrationList {
+		pathList = append(pathList, m.Path)
+	}
+	require.Equal(t, []string{
+		"migrations/db1/1.2__create_table.sql",
+		"migrations/db2/1.9__insert.sql",
+		"migrations/db1/1.10__add_index.sql",
+	}, pathList)
+	require.Equal(t, "CREATE TABLE t(a INT);", migrationList[0].Statement)
+	require.Equal(t, "db1", migrationList[0].Info.Database)
+	require.Equal(t, "Create table", migrationList[0].Info.Description)
+	require.Equal(t, "db2", migrationList[1].Info.Database)
+}
@@ -0,0 +1,443 @@
+// Package mock provides an in-memory driver for testing the code depending on db.Driver without a database.
+package mock
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/bytebase/bytebase/common"
+	"github.com/bytebase/bytebase/plugin/db"
+)
+
+var (
+	_ db.Driver = (*Driver)(nil)
+)
+
+// Call is a call to the driver recorded by Driver.
+type Call struct {
+	// Method is the name of the called method, e.g. "ExecuteMigration".
+	Method string
+	// Args are the arguments of the call except for the context.
+	Args []interface{}
+}
+
+// Driver is an in-memory driver recording the calls, which is safe for concurrent use by multiple goroutines.
+// The responses of SyncSchema, Execute, Query and ExecuteMigration are programmable by the optional functions,
+// which should be set before the driver is used. Without them, SyncSchema returns no schema, Execute and Query return empty results,
+// and ExecuteMigration records the migration history in memory, so FindMigrationHistoryList and Rollback work on the recorded histories.
+// Execute and Query of the transactions started by BeginTx are recorded as the calls of the driver.
+type Driver struct {
+	// Version is the version returned by GetVersion.
+	Version string
+	// SyncSchemaFunc returns the response of SyncSchema.
+	SyncSchemaFunc func(ctx context.Context, databaseList ...string) ([]*db.User, []*db.Schema, error)
+	// ExecuteFunc returns the response of Execute, and it's called for each statement of ExecuteBatch.
+	ExecuteFunc func(ctx context.Context, statement string) (db.ExecuteResult, error)
+	// QueryFunc returns the response of Query.
+	QueryFunc func(ctx context.Context, statement string, limit int) ([]interface{}, error)
+	// ExecuteMigrationFunc returns the updated schema of ExecuteMigration, and the migration is recorded as failed if it returns an error.
+	ExecuteMigrationFunc func(ctx context.Context, m *db.MigrationInfo, statement string) (string, error)
+	// DriverConfig validates the statements of ExecuteMigration and Rollback by ValidateStatement like the real drivers,
+	// using the quoting rules of the database type passed to Open.
+	DriverConfig db.DriverConfig
+
+	mu             sync.Mutex
+	dbType         db.Type
+	calls          []Call
+	migrationSetup bool
+	historyList    []*db.MigrationHistory
+	// rollbackStatements are the rollback statements of the migration histories by id.
+	rollbackStatements map[int]string
+}
+
+// New returns an in-memory driver.
+func New() *Driver {
+	return &Driver{
+		Version:            "mock",
+		rollbackStatements: make(map[int]string),
+	}
+}
+
+func (driver *Driver) record(method string, args ...interface{}) {
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	driver.calls = append(driver.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns the recorded calls in order.
+func (driver *Driver) Calls() []Call {
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	return append([]Call(nil), driver.calls...)
+}
+
+// CallsOf returns the recorded calls of the method in order.
+func (driver *Driver) CallsOf(method string) []Call {
+	var callList []Call
+	for _, call := range driver.Calls() {
+		if call.Method == method {
+			callList = append(callList, call)
+		}
+	}
+	return callList
+}
+
+// AppliedMigrations returns the migration histories of the successful migrations in the applied order.
+func (driver *Driver) AppliedMigrations() []*db.MigrationHistory {
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	var historyList []*db.MigrationHistory
+	for _, history := range driver.historyList {
+		if history.Status == db.Done {
+			h := *history
+			historyList = append(historyList, &h)
+		}
+	}
+	return historyList
+}
+
+// CheckAppliedMigrations returns an error if the versions of the successful migrations aren't the versions in order,
+// e.g. require.NoError(t, driver.CheckAppliedMigrations("0001", "0002")).
+func (driver *Driver) CheckAppliedMigrations(versions ...string) error {
+	var appliedVersions []string
+	for _, history := range driver.AppliedMigrations() {
+		appliedVersions = append(appliedVersions, history.Version)
+	}
+	if len(appliedVersions) != len(versions) {
+		return fmt.Errorf("expecting the migrations %q to be applied in order, but got %q", versions, appliedVersions)
+	}
+	for i, version := range versions {
+		if appliedVersions[i] != version {
+			return fmt.Errorf("expecting the migrations %q to be applied in order, but got %q", versions, appliedVersions)
+		}
+	}
+	return nil
+}
+
+// Open returns the driver itself.
+func (driver *Driver) Open(_ context.Context, dbType db.Type, config db.ConnectionConfig, connCtx db.ConnectionContext) (db.Driver, error) {
+	driver.record("Open", dbType, config, connCtx)
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	driver.dbType = dbType
+	return driver, nil
+}
+
+// Close closes the driver.
+func (driver *Driver) Close(_ context.Context) error {
+	driver.record("Close")
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	driver.migrationSetup = false
+	return nil
+}
+
+// Ping pings the database.
+func (driver *Driver) Ping(_ context.Context) error {
+	driver.record("Ping")
+	return nil
+}
+
+// Stats returns the zero value since there is no connection pool.
+func (*Driver) Stats() sql.DBStats {
+	return sql.DBStats{}
+}
+
+// GetDbConnection isn't supported since there is no database.
+func (driver *Driver) GetDbConnection(_ context.Context, database string) (*sql.DB, error) {
+	driver.record("GetDbConnection", database)
+	return nil, fmt.Errorf("GetDbConnection isn't supported by the mock driver")
+}
+
+// GetVersion gets the version.
+func (driver *Driver) GetVersion(_ context.Context) (string, error) {
+	driver.record("GetVersion")
+	return driver.Version, nil
+}
+
+// SyncSchema syncs the schema.
+func (driver *Driver) SyncSchema(ctx context.Context, databaseList ...string) ([]*db.User, []*db.Schema, error) {
+	driver.record("SyncSchema", databaseList)
+	if driver.SyncSchemaFunc == nil {
+		return nil, nil, nil
+	}
+	return driver.SyncSchemaFunc(ctx, databaseList...)
+}
+
+// Execute executes a SQL statement.
+func (driver *Driver) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	driver.record("Execute", statement)
+	return driver.execute(ctx, statement)
+}
+
+func (driver *Driver) execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	if driver.ExecuteFunc == nil {
+		return db.ExecuteResult{}, nil
+	}
+	return driver.ExecuteFunc(ctx, statement)
+}
+
+// ExecuteBatch executes the statements one by one, and stops at the first failure.
+func (driver *Driver) ExecuteBatch(ctx context.Context, statementList []string) error {
+	driver.record("ExecuteBatch", statementList)
+	for i, statement := range statementList {
+		if _, err := driver.execute(ctx, statement); err != nil {
+			return &db.BatchError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// Query queries a SQL statement.
+func (driver *Driver) Query(ctx context.Context, statement string, limit int) ([]interface{}, error) {
+	driver.record("Query", statement, limit)
+	if driver.QueryFunc == nil {
+		return []interface{}{[]string{}, []string{}, []interface{}{}}, nil
+	}
+	return driver.QueryFunc(ctx, statement, limit)
+}
+
+// BeginTx begins a transaction, whose Execute and Query calls are recorded as those of the driver, and Commit and Rollback calls as "Tx.Commit" and "Tx.Rollback".
+func (driver *Driver) BeginTx(_ context.Context) (db.Tx, error) {
+	driver.record("BeginTx")
+	return &tx{driver: driver}, nil
+}
+
+// tx is a transaction of Driver.
+type tx struct {
+	driver *Driver
+}
+
+func (t *tx) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	return t.driver.Execute(ctx, statement)
+}
+
+func (t *tx) Query(ctx context.Context, statement string, limit int) ([]interface{}, error) {
+	return t.driver.Query(ctx, statement, limit)
+}
+
+func (t *tx) Commit() error {
+	t.driver.record("Tx.Commit")
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	t.driver.record("Tx.Rollback")
+	return nil
+}
+
+// NeedsSetupMigration returns whether SetupMigrationIfNeeded hasn't been called since the driver is created or closed.
+func (driver *Driver) NeedsSetupMigration(_ context.Context) (bool, error) {
+	driver.record("NeedsSetupMigration")
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	return !driver.migrationSetup, nil
+}
+
+// SetupMigrationIfNeeded sets up migration if needed.
+func (driver *Driver) SetupMigrationIfNeeded(_ context.Context) error {
+	driver.record("SetupMigrationIfNeeded")
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	driver.migrationSetup = true
+	return nil
+}
+
+// ExecuteMigration records the migration history, and returns an error if the version has been applied to the namespace or is in progress,
+// or if it isn't higher than the versions since the last baseline or branch of the namespace.
+func (driver *Driver) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
+	driver.record("ExecuteMigration", m, statement)
+	return driver.executeMigration(ctx, m, statement)
+}
+
+func (driver *Driver) executeMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
+	driver.mu.Lock()
+	dbType := driver.dbType
+	driver.mu.Unlock()
+	if err := driver.DriverConfig.ValidateStatement(dbType, statement, m); err != nil {
+		return -1, "", err
+	}
+
+	// The history is recorded as PENDING in the same critical section as the duplicate version check,
+	// so the concurrent migrations of the same version can't both pass the check.
+	driver.mu.Lock()
+	var applied *db.MigrationHistory
+	for _, history := range driver.historyList {
+		if history.Namespace != m.Namespace || history.Version != m.Version {
+			continue
+		}
+		switch history.Status {
+		case db.Done:
+			if !m.Force {
+				driver.mu.Unlock()
+				return -1, "", common.Errorf(common.MigrationAlreadyApplied,
+					fmt.Errorf("%w, database %q has already applied version %s", db.ErrMigrationAlreadyApplied, m.Database, m.Version))
+			}
+			applied = history
+		case db.Pending:
+			driver.mu.Unlock()
+			return -1, "", common.Errorf(common.MigrationPending,
+				fmt.Errorf("database %q version %s migration is already in progress", m.Database, m.Version))
+		case db.Failed:
+			driver.mu.Unlock()
+			return -1, "", common.Errorf(common.MigrationFailed,
+				fmt.Errorf("database %q version %s migration has failed, please start a new migration using a new version", m.Database, m.Version))
+		}
+	}
+	// Like the real drivers, the version must be higher than all the versions since the last baseline or branch of the namespace,
+	// unless it's the forced re-run of an applied version.
+	for i := len(driver.historyList) - 1; applied == nil && i >= 0; i-- {
+		history := driver.historyList[i]
+		if history.Namespace != m.Namespace {
+			continue
+		}
+		if db.CompareVersion(history.Version, m.Version) >= 0 {
+			driver.mu.Unlock()
+			return -1, "", common.Errorf(common.MigrationOutOfOrder,
+				fmt.Errorf("database %q has already applied version %s which >= %s", m.Database, history.Version, m.Version))
+		}
+		if history.Type == db.Baseline || history.Type == db.Branch {
+			break
+		}
+	}
+	history := applied
+	if history != nil {
+		// The forced re-run updates the applied history like the real drivers.
+		history.Updater = m.Creator
+		history.Status = db.Pending
+		history.Statement = statement
+		history.Payload = m.Payload
+		history.ErrorMessage = ""
+	} else {
+		history = &db.MigrationHistory{
+			ID:                    len(driver.historyList) + 1,
+			Creator:               m.Creator,
+			Updater:               m.Creator,
+			ReleaseVersion:        m.ReleaseVersion,
+			Namespace:             m.Namespace,
+			Sequence:              len(driver.historyList) + 1,
+			Source:                m.Source,
+			Type:                  m.Type,
+			Status:                db.Pending,
+			Version:               m.Version,
+			Description:           m.Description,
+			Statement:             statement,
+			IssueID:               m.IssueID,
+			Payload:               m.Payload,
+			UseSemanticVersion:    m.UseSemanticVersion,
+			SemanticVersionSuffix: m.SemanticVersionSuffix,
+			Environment:           m.Environment,
+			Labels:                m.Labels,
+		}
+		driver.historyList = append(driver.historyList, history)
+	}
+	driver.mu.Unlock()
+
+	var updatedSchema string
+	var err error
+	if driver.ExecuteMigrationFunc != nil {
+		updatedSchema, err = driver.ExecuteMigrationFunc(ctx, m, statement)
+	}
+
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	if err != nil {
+		history.Status = db.Failed
+		history.ErrorMessage = err.Error()
+		return -1, "", err
+	}
+	history.Status = db.Done
+	history.Schema = updatedSchema
+	if m.RollbackStatement != "" {
+		driver.rollbackStatements[history.ID] = m.RollbackStatement
+	}
+	return int64(history.ID), updatedSchema, nil
+}
+
+// Rollback executes the rollback statement of the applied version as migration m, and marks the version as rolled back by m.Version.
+func (driver *Driver) Rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	driver.record("Rollback", m, version)
+	driver.mu.Lock()
+	var reverted *db.MigrationHistory
+	for _, history := range driver.historyList {
+		if history.Namespace == m.Namespace && history.Version == version && history.Status == db.Done {
+			reverted = history
+		}
+	}
+	var rollbackStatement, rolledBackBy string
+	if reverted != nil {
+		rollbackStatement, rolledBackBy = driver.rollbackStatements[reverted.ID], reverted.RolledBackBy
+	}
+	driver.mu.Unlock()
+	if reverted == nil {
+		return -1, "", common.Errorf(common.NotFound, fmt.Errorf("database %q has not applied version %s", m.Database, version))
+	}
+	if rolledBackBy != "" {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("version %s of database %q has already been rolled back by version %s", version, m.Database, rolledBackBy))
+	}
+	if rollbackStatement == "" {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("version %s of database %q has no rollback statement", version, m.Database))
+	}
+	migrationHistoryID, updatedSchema, err := driver.executeMigration(ctx, m, rollbackStatement)
+	if err != nil {
+		return -1, "", err
+	}
+	driver.mu.Lock()
+	reverted.RolledBackBy = m.Version
+	driver.mu.Unlock()
+	return migrationHistoryID, updatedSchema, nil
+}
+
+// FindMigrationHistoryList finds the recorded migration history, most recent first.
+func (driver *Driver) FindMigrationHistoryList(_ context.Context, find *db.MigrationHistoryFind) ([]*db.MigrationHistory, error) {
+	driver.record("FindMigrationHistoryList", find)
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	var historyList []*db.MigrationHistory
+	for i := len(driver.historyList) - 1; i >= 0; i-- {
+		history := driver.historyList[i]
+		if v := find.ID; v != nil && history.ID != *v {
+			continue
+		}
+		if v := find.Database; v != nil && history.Namespace != *v {
+			continue
+		}
+		if v := find.Source; v != nil && history.Source != *v {
+			continue
+		}
+		if v := find.Version; v != nil && history.Version != *v {
+			continue
+		}
+		if v := find.Status; v != nil && history.Status != *v {
+			continue
+		}
+		if v := find.IssueID; v != nil && history.IssueID != *v {
+			continue
+		}
+		if v := find.Limit; v != nil && len(historyList) >= *v {
+			break
+		}
+		h := *history
+		if find.OmitStatement {
+			h.Statement = ""
+		}
+		historyList = append(historyList, &h)
+	}
+	return historyList, nil
+}
+
+// Dump dumps nothing.
+func (driver *Driver) Dump(_ context.Context, database string, _ io.Writer, schemaOnly bool) error {
+	driver.record("Dump", database, schemaOnly)
+	return nil
+}
+
+// Restore restores nothing.
+func (driver *Driver) Restore(_ context.Context, _ *bufio.Scanner) error {
+	driver.record("Restore")
+	return nil
+}
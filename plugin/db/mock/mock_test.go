@@ -0,0 +1,143 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bytebase/bytebase/common"
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteMigration(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+	d.ExecuteMigrationFunc = func(_ context.Context, m *db.MigrationInfo, statement string) (string, error) {
+		if m.Version == "0003" {
+			return "", errors.New("syntax error")
+		}
+		return statement, nil
+	}
+
+	for _, version := range []string{"0001", "0002", "0003"} {
+		_, _, err := d.ExecuteMigration(ctx, &db.MigrationInfo{Version: version, Namespace: "db1", Database: "db1", RollbackStatement: "DROP TABLE t" + version}, "CREATE TABLE t"+version)
+		if version == "0003" {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+	_, _, err := d.ExecuteMigration(ctx, &db.MigrationInfo{Version: "0001", Namespace: "db1", Database: "db1"}, "CREATE TABLE t0001")
+	require.Equal(t, common.MigrationAlreadyApplied, common.ErrorCode(err))
+	require.ErrorIs(t, err, db.ErrMigrationAlreadyApplied)
+	_, _, err = d.ExecuteMigration(ctx, &db.MigrationInfo{Version: "0003", Namespace: "db1", Database: "db1"}, "CREATE TABLE t0003")
+	require.Equal(t, common.MigrationFailed, common.ErrorCode(err))
+	_, _, err = d.ExecuteMigration(ctx, &db.MigrationInfo{Version: "0000", Namespace: "db1", Database: "db1"}, "CREATE TABLE t0000")
+	require.Equal(t, common.MigrationOutOfOrder, common.ErrorCode(err))
+	_, _, err = d.ExecuteMigration(ctx, &db.MigrationInfo{Version: "0004", Namespace: "db1", Database: "db1"}, "DROP TABLE t0001")
+	require.ErrorIs(t, err, db.ErrDestructiveStatement)
+	require.NoError(t, d.CheckAppliedMigrations("0001", "0002"))
+	require.Error(t, d.CheckAppliedMigrations("0002", "0001"))
+	require.Len(t, d.CallsOf("ExecuteMigration"), 7)
+
+	_, schema, err := d.Rollback(ctx, &db.MigrationInfo{Version: "0004", Namespace: "db1", Database: "db1", AllowDestructive: true}, "0002")
+	require.NoError(t, err)
+	require.Equal(t, "DROP TABLE t0002", schema)
+	require.NoError(t, d.CheckAppliedMigrations("0001", "0002", "0004"))
+	_, _, err = d.Rollback(ctx, &db.MigrationInfo{Version: "0005", Namespace: "db1", Database: "db1", AllowDestructive: true}, "0002")
+	require.Equal(t, common.Invalid, common.ErrorCode(err))
+	version := "0002"
+	historyList, err := d.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{Version: &version})
+	require.NoError(t, err)
+	require.Equal(t, "0004", historyList[0].RolledBackBy)
+
+	limit := 2
+	historyList, err = d.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{Limit: &limit})
+	require.NoError(t, err)
+	require.Len(t, historyList, 2)
+	require.Equal(t, "0004", historyList[0].Version)
+	require.Equal(t, db.Failed, historyList[1].Status)
+}
+
+func TestExecuteMigrationWithForce(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+	for _, version := range []string{"0001", "0002"} {
+		_, _, err := d.ExecuteMigration(ctx, &db.MigrationInfo{Version: version, Namespace: "db1", Database: "db1"}, "CREATE TABLE t"+version)
+		require.NoError(t, err)
+	}
+
+	// The forced re-run of an applied version skips the out-of-order check, and updates the applied history.
+	id, _, err := d.ExecuteMigration(ctx, &db.MigrationInfo{Version: "0001", Namespace: "db1", Database: "db1", Force: true}, "CREATE TABLE IF NOT EXISTS t0001")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), id)
+	require.NoError(t, d.CheckAppliedMigrations("0001", "0002"))
+	version := "0001"
+	historyList, err := d.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{Version: &version})
+	require.NoError(t, err)
+	require.Len(t, historyList, 1)
+	require.Equal(t, "CREATE TABLE IF NOT EXISTS t0001", historyList[0].Statement)
+	historyList, err = d.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{Version: &version, OmitStatement: true})
+	require.NoError(t, err)
+	require.Equal(t, "", historyList[0].Statement)
+
+	// Force doesn't re-run a version that isn't applied.
+	d.ExecuteMigrationFunc = func(_ context.Context, _ *db.MigrationInfo, _ string) (string, error) {
+		return "", errors.New("syntax error")
+	}
+	_, _, err = d.ExecuteMigration(ctx, &db.MigrationInfo{Version: "0003", Namespace: "db1", Database: "db1"}, "CREATE TABLE t0003")
+	require.Error(t, err)
+	_, _, err = d.ExecuteMigration(ctx, &db.MigrationInfo{Version: "0003", Namespace: "db1", Database: "db1", Force: true}, "CREATE TABLE t0003")
+	require.Equal(t, common.MigrationFailed, common.ErrorCode(err))
+}
+
+func TestExecuteMigrationConcurrently(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+	started, release := make(chan struct{}), make(chan struct{})
+	d.ExecuteMigrationFunc = func(context.Context, *db.MigrationInfo, string) (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	}
+
+	errc := make(chan error)
+	go func() {
+		_, _, err := d.ExecuteMigration(ctx, &db.MigrationInfo{Version: "0001", Namespace: "db1", Database: "db1"}, "CREATE TABLE t")
+		errc <- err
+	}()
+	<-started
+	// The version is recorded as in progress while the first migration is running.
+	_, _, err := d.ExecuteMigration(ctx, &db.MigrationInfo{Version: "0001", Namespace: "db1", Database: "db1"}, "CREATE TABLE t")
+	require.Equal(t, common.MigrationPending, common.ErrorCode(err))
+	close(release)
+	require.NoError(t, <-errc)
+	require.NoError(t, d.CheckAppliedMigrations("0001"))
+}
+
+func TestRunMigrations(t *testing.T) {
+	ctx := context.Background()
+	newMigration := func(version string) *db.Migration {
+		return &db.Migration{
+			Info:      &db.MigrationInfo{Version: version, Namespace: "db1", Database: "db1"},
+			Path:      version + ".sql",
+			Statement: "CREATE TABLE t" + version,
+		}
+	}
+	d := New()
+
+	// The migrations are applied in the numeric version order, which passes the out-of-order check.
+	result, err := db.RunMigrations(ctx, d, []*db.Migration{newMigration("1.10"), newMigration("1.1")}, "bob")
+	require.NoError(t, err)
+	require.Len(t, result.AppliedList, 2)
+	require.NoError(t, d.CheckAppliedMigrations("1.1", "1.10"))
+
+	// A migration added below the applied versions fails the out-of-order check.
+	migration := newMigration("1.2")
+	result, err = db.RunMigrations(ctx, d, []*db.Migration{newMigration("1.10"), migration, newMigration("1.1")}, "bob")
+	require.Error(t, err)
+	require.Len(t, result.SkippedList, 1)
+	require.Equal(t, migration, result.Failed)
+	require.NoError(t, d.CheckAppliedMigrations("1.1", "1.10"))
+}
@@ -0,0 +1,1044 @@
+package mongodb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bytebase/bytebase/common"
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/bytebase/bytebase/plugin/db/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMigrationDatabase is the database of the migration history if DriverConfig.MigrationHistorySchema is empty.
+	defaultMigrationDatabase = "bytebase"
+	// migrationHistoryCollection is the collection of the migration history in the migration database.
+	migrationHistoryCollection = "bytebase_migration_history"
+	// useCommand is the pseudo command switching the database, e.g. {"use": "db1"}, which isn't a MongoDB command.
+	useCommand = "use"
+)
+
+var (
+	systemDatabases = map[string]bool{
+		"admin":  true,
+		"config": true,
+		"local":  true,
+	}
+
+	// queryCommands are the commands allowed by Query, which return a cursor.
+	queryCommands = map[string]bool{
+		"find":      true,
+		"aggregate": true,
+	}
+
+	_ db.Driver                = (*Driver)(nil)
+	_ db.StatementListExecutor = (*Driver)(nil)
+)
+
+func init() {
+	db.Register(db.MongoDB, newDriver)
+}
+
+// Driver is the MongoDB driver.
+//
+// The statements aren't SQL but the database commands in MongoDB Extended JSON, which are run by the runCommand of the current database.
+// A statement text is a sequence of command documents separated by whitespaces, see db.SplitStatements, e.g.
+//
+//	{"create": "users"}
+//	{"createIndexes": "users", "indexes": [{"key": {"email": 1}, "name": "email_1", "unique": true}]}
+//	{"insert": "users", "documents": [{"email": "a@example.com", "createdAt": {"$date": "2022-01-01T00:00:00Z"}}]}
+//
+// The first key of a document is the command name, and the pseudo command {"use": "db1"} switches the current database.
+// The shell helpers such as db.users.insertOne() aren't supported, since they're JavaScript run by mongosh.
+//
+// The collections are the tables without columns. The migration history is recorded in the bytebase_migration_history collection
+// of the migration database, which is DriverConfig.MigrationHistorySchema or "bytebase" by default.
+// The commands of a migration aren't run in a transaction, so the migration history is recorded on a best-effort basis like Cassandra.
+// The PENDING record is inserted before the commands run, and it's updated to DONE or FAILED afterward by a separate write.
+// A failed migration leaves the commands before the failing one applied, and a crash in between leaves a PENDING record behind.
+// The unique index on the namespace and the version guards the concurrent migrations of the same version against each other.
+type Driver struct {
+	l             *zap.Logger
+	driverConfig  db.DriverConfig
+	connectionCtx db.ConnectionContext
+	dbType        db.Type
+
+	client *mongo.Client
+	// database is the current database, which is the connection database initially.
+	database string
+
+	// migrationSetup caches whether the migration schema has been set up by this driver instance.
+	migrationSetup bool
+}
+
+func newDriver(config db.DriverConfig) db.Driver {
+	return &Driver{
+		l:            config.Logger,
+		driverConfig: config,
+	}
+}
+
+// Open opens a MongoDB driver. The host can be a comma-separated list of the hosts of a replica set.
+// The user is authenticated against the admin database.
+func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.ConnectionConfig, connCtx db.ConnectionContext) (db.Driver, error) {
+	port := config.Port
+	if port == "" {
+		port = "27017"
+	}
+	var hostList []string
+	for _, host := range strings.Split(config.Host, ",") {
+		hostList = append(hostList, net.JoinHostPort(host, port))
+	}
+	opts := options.Client().SetHosts(hostList).SetAppName("bytebase")
+	if config.Username != "" {
+		opts.SetAuth(options.Credential{
+			Username: config.Username,
+			Password: config.Password,
+		})
+	}
+	tlsConfig, err := config.TLSConfig.GetSslConfig()
+	if err != nil {
+		return nil, fmt.Errorf("sql: tls config error: %v", err)
+	}
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+	if config.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(config.ConnectTimeout).SetServerSelectionTimeout(config.ConnectTimeout)
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	driver.l.Debug("Opening MongoDB driver",
+		zap.Strings("hosts", hostList),
+		zap.String("environment", connCtx.EnvironmentName),
+		zap.String("database", connCtx.InstanceName),
+	)
+
+	// Connect doesn't dial the servers, which is done by Ping.
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	driver.dbType = dbType
+	driver.client = client
+	driver.database = config.Database
+	driver.connectionCtx = connCtx
+
+	return driver, nil
+}
+
+// Close closes the driver.
+func (driver *Driver) Close(ctx context.Context) error {
+	driver.migrationSetup = false
+	if driver.client == nil {
+		return nil
+	}
+	err := driver.client.Disconnect(ctx)
+	driver.client = nil
+	return err
+}
+
+// Ping pings the database.
+func (driver *Driver) Ping(ctx context.Context) error {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Ping", driver.dbType, nil)
+	err := driver.client.Ping(ctx, nil)
+	span.End(err)
+	return err
+}
+
+// Stats returns the zero value, since MongoDB doesn't use the connection pool of database/sql.
+func (*Driver) Stats() sql.DBStats {
+	return sql.DBStats{}
+}
+
+// GetDbConnection returns an error, since MongoDB doesn't support database/sql.
+func (*Driver) GetDbConnection(ctx context.Context, database string) (*sql.DB, error) {
+	return nil, fmt.Errorf("database/sql connection isn't supported for %s", db.MongoDB)
+}
+
+// GetVersion gets the version.
+func (driver *Driver) GetVersion(ctx context.Context) (string, error) {
+	var buildInfo struct {
+		Version string `bson:"version"`
+	}
+	if err := driver.client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&buildInfo); err != nil {
+		return "", err
+	}
+	return buildInfo.Version, nil
+}
+
+// SyncSchema syncs the schema of the databases. The users aren't synced.
+// The row count of each collection is counted exactly by countDocuments.
+func (driver *Driver) SyncSchema(ctx context.Context, _ ...string) ([]*db.User, []*db.Schema, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "SyncSchema", driver.dbType, nil)
+	schemaList, err := driver.syncSchema(ctx)
+	span.End(err)
+	return nil, schemaList, err
+}
+
+func (driver *Driver) syncSchema(ctx context.Context) ([]*db.Schema, error) {
+	databaseList, err := driver.getDatabaseList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var schemaList []*db.Schema
+	for _, database := range databaseList {
+		// Skip our internal "bytebase" database.
+		if database == defaultMigrationDatabase {
+			continue
+		}
+		collectionList, err := driver.getCollectionList(ctx, database)
+		if err != nil {
+			return nil, err
+		}
+		schema := &db.Schema{Name: database}
+		for _, collection := range collectionList {
+			if collection.Type == "view" {
+				definition, err := marshalCommand(collection.options)
+				if err != nil {
+					return nil, err
+				}
+				schema.ViewList = append(schema.ViewList, db.View{
+					Name:       collection.Name,
+					Definition: definition,
+				})
+				continue
+			}
+			rowCount, err := driver.client.Database(database).Collection(collection.Name).CountDocuments(ctx, bson.D{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to count documents of collection %q in database %q, error: %w", collection.Name, database, err)
+			}
+			var indexList []db.Index
+			for _, index := range collection.indexList {
+				for i, key := range index.Key {
+					indexList = append(indexList, db.Index{
+						Name:       index.Name,
+						Expression: key.Key,
+						Position:   i + 1,
+						Type:       fmt.Sprint(key.Value),
+						Unique:     index.Unique,
+						Visible:    !index.Hidden,
+					})
+				}
+			}
+			schema.TableList = append(schema.TableList, db.Table{
+				Name:            collection.Name,
+				Type:            "BASE TABLE",
+				RowCount:        rowCount,
+				IsRowCountExact: true,
+				IndexList:       indexList,
+			})
+		}
+		schemaList = append(schemaList, schema)
+	}
+	return schemaList, nil
+}
+
+// getDatabaseList returns the user databases in order.
+func (driver *Driver) getDatabaseList(ctx context.Context) ([]string, error) {
+	nameList, err := driver.client.ListDatabaseNames(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	var databaseList []string
+	for _, name := range nameList {
+		if systemDatabases[name] {
+			continue
+		}
+		databaseList = append(databaseList, name)
+	}
+	sort.Strings(databaseList)
+	return databaseList, nil
+}
+
+// collectionSchema is the schema of a collection or a view.
+type collectionSchema struct {
+	*mongo.CollectionSpecification
+	// options are the options of the collection, e.g. viewOn and pipeline of the views.
+	options bson.D
+	// indexList is empty for the views.
+	indexList []*indexSchema
+}
+
+// indexSchema is the schema of an index.
+type indexSchema struct {
+	Name   string `bson:"name"`
+	Key    bson.D `bson:"key"`
+	Unique bool   `bson:"unique"`
+	Hidden bool   `bson:"hidden"`
+	// raw is the index document without the version and the namespace, which is the index of createIndexes.
+	raw bson.D
+}
+
+// getCollectionList returns the collections and the views of the database in order, except for the system collections and the migration history.
+func (driver *Driver) getCollectionList(ctx context.Context, database string) ([]*collectionSchema, error) {
+	specList, err := driver.client.Database(database).ListCollectionSpecifications(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections of database %q, error: %w", database, err)
+	}
+	var collectionList []*collectionSchema
+	for _, spec := range specList {
+		if strings.HasPrefix(spec.Name, "system.") {
+			continue
+		}
+		if database == driver.migrationDatabase() && spec.Name == migrationHistoryCollection {
+			continue
+		}
+		collection := &collectionSchema{CollectionSpecification: spec}
+		if len(spec.Options) > 0 {
+			if err := bson.Unmarshal(spec.Options, &collection.options); err != nil {
+				return nil, err
+			}
+		}
+		if spec.Type != "view" {
+			if collection.indexList, err = driver.getIndexList(ctx, database, spec.Name); err != nil {
+				return nil, err
+			}
+		}
+		collectionList = append(collectionList, collection)
+	}
+	sort.Slice(collectionList, func(i, j int) bool {
+		return collectionList[i].Name < collectionList[j].Name
+	})
+	return collectionList, nil
+}
+
+// getIndexList returns the indexes of the collection in order, except for the _id index.
+func (driver *Driver) getIndexList(ctx context.Context, database, collection string) ([]*indexSchema, error) {
+	cursor, err := driver.client.Database(database).Collection(collection).Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes of collection %q in database %q, error: %w", collection, database, err)
+	}
+	defer cursor.Close(ctx)
+	var indexList []*indexSchema
+	for cursor.Next(ctx) {
+		index := &indexSchema{}
+		if err := cursor.Decode(index); err != nil {
+			return nil, err
+		}
+		if index.Name == "_id_" {
+			continue
+		}
+		var raw bson.D
+		if err := cursor.Decode(&raw); err != nil {
+			return nil, err
+		}
+		for _, e := range raw {
+			if e.Key != "v" && e.Key != "ns" {
+				index.raw = append(index.raw, e)
+			}
+		}
+		indexList = append(indexList, index)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(indexList, func(i, j int) bool {
+		return indexList[i].Name < indexList[j].Name
+	})
+	return indexList, nil
+}
+
+// parseCommand parses the command document in MongoDB Extended JSON, and returns the document and the command name.
+func parseCommand(statement string) (bson.D, string, error) {
+	var command bson.D
+	if err := bson.UnmarshalExtJSON([]byte(statement), false /* canonical */, &command); err != nil {
+		return nil, "", fmt.Errorf("invalid MongoDB command %s, error: %w", statement, err)
+	}
+	if len(command) == 0 {
+		return nil, "", fmt.Errorf("invalid MongoDB command %s, it's empty", statement)
+	}
+	return command, command[0].Key, nil
+}
+
+// marshalCommand marshals the command document in relaxed MongoDB Extended JSON.
+func marshalCommand(command bson.D) (string, error) {
+	b, err := bson.MarshalExtJSON(command, false /* canonical */, false /* escapeHTML */)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// commandReply is the reply of a command.
+type commandReply struct {
+	// N is the number of the documents inserted, matched or deleted by the write commands.
+	N           int64 `bson:"n"`
+	WriteErrors []struct {
+		Index  int    `bson:"index"`
+		Code   int    `bson:"code"`
+		ErrMsg string `bson:"errmsg"`
+	} `bson:"writeErrors"`
+	WriteConcernError *struct {
+		Code   int    `bson:"code"`
+		ErrMsg string `bson:"errmsg"`
+	} `bson:"writeConcernError"`
+}
+
+// err returns the write error of the reply, since the write commands succeed with the errors of the documents in the reply.
+func (r *commandReply) err() error {
+	if len(r.WriteErrors) > 0 {
+		e := r.WriteErrors[0]
+		return fmt.Errorf("write error of document #%d, code %d: %s", e.Index, e.Code, e.ErrMsg)
+	}
+	if e := r.WriteConcernError; e != nil {
+		return fmt.Errorf("write concern error, code %d: %s", e.Code, e.ErrMsg)
+	}
+	return nil
+}
+
+// Execute executes the commands one by one, where the {"use": "db1"} pseudo commands switch the database.
+// The rows affected are the sum of the n of the replies, e.g. the documents inserted, matched or deleted.
+func (driver *Driver) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Execute", driver.dbType, nil)
+	result, err := driver.execute(ctx, statement)
+	span.End(err)
+	return result, err
+}
+
+func (driver *Driver) execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	statementList, err := db.SplitStatements(statement, db.MongoDB)
+	if err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return driver.ExecuteStatementList(ctx, statementList)
+}
+
+// GetType returns the database type.
+func (driver *Driver) GetType() db.Type {
+	return driver.dbType
+}
+
+// ExecuteStatementList executes the commands in order without a transaction, so the commands before the failed one stay applied.
+func (driver *Driver) ExecuteStatementList(ctx context.Context, statementList []string) (db.ExecuteResult, error) {
+	var result db.ExecuteResult
+	for i, statement := range statementList {
+		n, err := driver.runCommand(ctx, statement, true /* allowUse */)
+		if err != nil {
+			return result, &db.StatementError{Index: i, Statement: statement, Committed: i > 0, Err: err}
+		}
+		result.RowsAffected += n
+	}
+	return result, nil
+}
+
+// runCommand runs the command in the current database, and returns the n of the reply.
+// The {"use": "db1"} pseudo command switches the current database if allowUse is true.
+func (driver *Driver) runCommand(ctx context.Context, statement string, allowUse bool) (int64, error) {
+	command, name, err := parseCommand(statement)
+	if err != nil {
+		return 0, err
+	}
+	if name == useCommand && allowUse {
+		database, ok := command[0].Value.(string)
+		if !ok || database == "" || len(command) > 1 {
+			return 0, fmt.Errorf("invalid MongoDB command %s, it should be in the form of {\"use\": \"db1\"}", statement)
+		}
+		driver.database = database
+		return 0, nil
+	}
+	if driver.database == "" {
+		return 0, fmt.Errorf("the database isn't specified, use the connection database or {\"use\": \"db1\"}")
+	}
+	var reply commandReply
+	if err := driver.client.Database(driver.database).RunCommand(ctx, command).Decode(&reply); err != nil {
+		return 0, err
+	}
+	if err := reply.err(); err != nil {
+		return 0, err
+	}
+	return reply.N, nil
+}
+
+// ExecuteBatch executes the commands one by one in the current database, since the commands aren't run in a transaction.
+// The commands before the failing one take effect.
+func (driver *Driver) ExecuteBatch(ctx context.Context, statementList []string) error {
+	for i, statement := range statementList {
+		if _, err := driver.runCommand(ctx, statement, false /* allowUse */); err != nil {
+			return &db.BatchError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// Query runs a find or aggregate command in the current database, e.g. {"find": "users", "filter": {"age": {"$gt": 18}}}.
+// The aggregate command writing the results by $out or $merge is rejected.
+// Each row is a document in relaxed MongoDB Extended JSON, which is the only column "document" of type "JSON".
+func (driver *Driver) Query(ctx context.Context, statement string, limit int) ([]interface{}, error) {
+	command, name, err := parseCommand(statement)
+	if err != nil {
+		return nil, err
+	}
+	if !queryCommands[name] {
+		return nil, fmt.Errorf("only the find and aggregate commands can be queried for %s", db.MongoDB)
+	}
+	if name == "aggregate" && writesAggregation(command) {
+		return nil, fmt.Errorf("the aggregate command with $out or $merge can't be queried for %s", db.MongoDB)
+	}
+	if driver.database == "" {
+		return nil, fmt.Errorf("the database isn't specified, use the connection database")
+	}
+	cursor, err := driver.client.Database(driver.database).RunCommandCursor(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	data := []interface{}{}
+	for (limit <= 0 || len(data) < limit) && cursor.Next(ctx) {
+		var document bson.D
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		value, err := marshalCommand(document)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, []interface{}{value})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return []interface{}{[]string{"document"}, []string{"JSON"}, data}, nil
+}
+
+// writesAggregation returns whether the pipeline of the aggregate command has a $out or $merge stage.
+func writesAggregation(command bson.D) bool {
+	for _, e := range command {
+		if e.Key != "pipeline" {
+			continue
+		}
+		pipeline, ok := e.Value.(bson.A)
+		if !ok {
+			return false
+		}
+		for _, stage := range pipeline {
+			stageDoc, ok := stage.(bson.D)
+			if !ok {
+				continue
+			}
+			for _, s := range stageDoc {
+				if s.Key == "$out" || s.Key == "$merge" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// BeginTx returns an error, since the MongoDB transactions require a replica set and aren't supported by the driver.
+func (*Driver) BeginTx(ctx context.Context) (db.Tx, error) {
+	return nil, fmt.Errorf("transaction isn't supported for %s", db.MongoDB)
+}
+
+// migrationDatabase returns the database of the migration history.
+func (driver *Driver) migrationDatabase() string {
+	if driver.driverConfig.MigrationHistorySchema != "" {
+		return driver.driverConfig.MigrationHistorySchema
+	}
+	return defaultMigrationDatabase
+}
+
+// historyCollection returns the migration history collection.
+func (driver *Driver) historyCollection() *mongo.Collection {
+	return driver.client.Database(driver.migrationDatabase()).Collection(migrationHistoryCollection)
+}
+
+// NeedsSetupMigration returns whether it needs to setup migration.
+func (driver *Driver) NeedsSetupMigration(ctx context.Context) (bool, error) {
+	if driver.migrationSetup {
+		return false, nil
+	}
+	nameList, err := driver.client.Database(driver.migrationDatabase()).ListCollectionNames(ctx, bson.D{{Key: "name", Value: migrationHistoryCollection}})
+	if err != nil {
+		return false, err
+	}
+	return len(nameList) == 0, nil
+}
+
+// SetupMigrationIfNeeded sets up migration if needed.
+// It creates the migration history collection with the unique index on the namespace and the version.
+func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
+	setup, err := driver.NeedsSetupMigration(ctx)
+	if err != nil {
+		return err
+	}
+
+	if setup {
+		driver.l.Info("Bytebase migration schema not found, creating schema...",
+			zap.String("environment", driver.connectionCtx.EnvironmentName),
+			zap.String("database", driver.connectionCtx.InstanceName),
+		)
+		if err := driver.client.Database(driver.migrationDatabase()).CreateCollection(ctx, migrationHistoryCollection); err != nil {
+			driver.l.Error("Failed to initialize migration schema.",
+				zap.Error(err),
+				zap.String("environment", driver.connectionCtx.EnvironmentName),
+				zap.String("database", driver.connectionCtx.InstanceName),
+			)
+			return err
+		}
+		if _, err := driver.historyCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "namespace", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetName("bytebase_idx_unique_migration_history_namespace_version").SetUnique(true),
+		}); err != nil {
+			driver.l.Error("Failed to initialize migration schema.",
+				zap.Error(err),
+				zap.String("environment", driver.connectionCtx.EnvironmentName),
+				zap.String("database", driver.connectionCtx.InstanceName),
+			)
+			return err
+		}
+		driver.l.Info("Successfully created migration schema.",
+			zap.String("environment", driver.connectionCtx.EnvironmentName),
+			zap.String("database", driver.connectionCtx.InstanceName),
+		)
+	}
+
+	driver.migrationSetup = true
+	return nil
+}
+
+// ExecuteMigration will execute the migration.
+// The semantic versions and running the migration as a role aren't supported.
+func (driver *Driver) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "ExecuteMigration", driver.dbType, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := driver.executeMigration(ctx, m, statement)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+func (driver *Driver) executeMigration(ctx context.Context, m *db.MigrationInfo, statement string) (migrationHistoryID int64, updatedSchema string, resErr error) {
+	if err := driver.driverConfig.ValidateStatement(driver.dbType, statement, m); err != nil {
+		return -1, "", err
+	}
+	if m.UseSemanticVersion {
+		return -1, "", fmt.Errorf("semantic versioning isn't supported for %s", db.MongoDB)
+	}
+	if m.RunAsRole != "" {
+		return -1, "", fmt.Errorf("running the migration as role %q isn't supported by the driver", m.RunAsRole)
+	}
+	payload, err := util.BuildMigrationPayload(m, statement)
+	if err != nil {
+		return -1, "", err
+	}
+
+	var prevSchemaBuf bytes.Buffer
+	// Don't record schema if the database hasn't exist yet.
+	if !m.CreateDatabase {
+		if err := driver.Dump(ctx, m.Database, &prevSchemaBuf, true /* schemaOnly */); err != nil {
+			return -1, "", err
+		}
+	}
+
+	// Phase 1 - Precheck before executing migration
+	// Phase 2 - Record migration history as PENDING
+	insertedID, err := driver.beginMigration(ctx, m, prevSchemaBuf.String(), statement, payload)
+	if err != nil {
+		return -1, "", err
+	}
+
+	startedNs := time.Now().UnixNano()
+	defer func() {
+		if err := driver.endMigration(ctx, insertedID, time.Now().UnixNano()-startedNs, updatedSchema, payload, resErr); err != nil {
+			driver.l.Error("Failed to update migration history record",
+				zap.Error(err),
+				zap.Int64("migration_id", insertedID),
+			)
+		}
+	}()
+
+	// Phase 3 - Executing migration
+	// Baseline migration type could has non-empty sql but will not execute, except for CreateDatabase.
+	// MongoDB creates the database implicitly, so the commands always run in the target database.
+	if statement != "" && (m.Type != db.Baseline || m.CreateDatabase) {
+		driver.database = m.Database
+		if _, err := driver.execute(ctx, statement); err != nil {
+			return -1, "", err
+		}
+	}
+
+	// Phase 4 - Dump the schema after migration
+	var afterSchemaBuf bytes.Buffer
+	if err := driver.Dump(ctx, m.Database, &afterSchemaBuf, true /* schemaOnly */); err != nil {
+		return -1, "", err
+	}
+	return insertedID, afterSchemaBuf.String(), nil
+}
+
+// migrationHistory is the document of the migration history.
+type migrationHistory struct {
+	ID                  int64  `bson:"_id"`
+	Namespace           string `bson:"namespace"`
+	Creator             string `bson:"created_by"`
+	CreatedTs           int64  `bson:"created_ts"`
+	Updater             string `bson:"updated_by"`
+	UpdatedTs           int64  `bson:"updated_ts"`
+	ReleaseVersion      string `bson:"release_version"`
+	Sequence            int    `bson:"sequence"`
+	Source              string `bson:"source"`
+	Type                string `bson:"type"`
+	Status              string `bson:"status"`
+	Version             string `bson:"version"`
+	Description         string `bson:"description"`
+	Statement           string `bson:"statement"`
+	Schema              string `bson:"schema"`
+	SchemaPrev          string `bson:"schema_prev"`
+	ExecutionDurationNs int64  `bson:"execution_duration_ns"`
+	IssueID             string `bson:"issue_id"`
+	Payload             string `bson:"payload"`
+}
+
+// beginMigration checks before executing migration and inserts a migration history record with pending status.
+func (driver *Driver) beginMigration(ctx context.Context, m *db.MigrationInfo, prevSchema string, statement string, payload string) (int64, error) {
+	historyList, err := driver.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{
+		Database: &m.Namespace,
+	})
+	if err != nil {
+		return -1, err
+	}
+	// Check if the same migration version has already been applied.
+	for _, history := range historyList {
+		if history.Version != m.Version {
+			continue
+		}
+		switch history.Status {
+		case db.Done:
+			if !m.Force {
+				return -1, common.Errorf(common.MigrationAlreadyApplied,
+					fmt.Errorf("%w, database %q has already applied version %s", db.ErrMigrationAlreadyApplied, m.Database, m.Version))
+			}
+			// The forced re-run reuses the applied record, and endMigration updates it as DONE or FAILED again.
+			update := bson.D{
+				{Key: "updated_by", Value: m.Creator},
+				{Key: "updated_ts", Value: time.Now().Unix()},
+				{Key: "payload", Value: payload},
+			}
+			if _, err := driver.historyCollection().UpdateByID(ctx, int64(history.ID), bson.D{{Key: "$set", Value: update}}); err != nil {
+				return -1, err
+			}
+			return int64(history.ID), nil
+		case db.Pending:
+			return -1, common.Errorf(common.MigrationPending,
+				fmt.Errorf("database %q version %s migration is already in progress", m.Database, m.Version))
+		case db.Failed:
+			return -1, common.Errorf(common.MigrationFailed,
+				fmt.Errorf("database %q version %s migration has failed, please check your database to make sure things are fine and then start a new migration using a new version ", m.Database, m.Version))
+		}
+	}
+	// Check if there is any higher version already been applied since the last baseline or branch.
+	// The history list is the most recent first.
+	largestSequence := 0
+	for _, history := range historyList {
+		if history.Sequence > largestSequence {
+			largestSequence = history.Sequence
+		}
+	}
+	for _, history := range historyList {
+		if db.CompareVersion(history.Version, m.Version) >= 0 {
+			return -1, common.Errorf(common.MigrationOutOfOrder, fmt.Errorf("database %q has already applied version %s which >= %s", m.Database, history.Version, m.Version))
+		}
+		if history.Type == db.Baseline || history.Type == db.Branch {
+			break
+		}
+	}
+
+	// The id is the creation time in nanoseconds, since MongoDB doesn't generate sequential ids.
+	now := time.Now()
+	history := &migrationHistory{
+		ID:             now.UnixNano(),
+		Namespace:      m.Namespace,
+		Creator:        m.Creator,
+		CreatedTs:      now.Unix(),
+		Updater:        m.Creator,
+		UpdatedTs:      now.Unix(),
+		ReleaseVersion: m.ReleaseVersion,
+		Sequence:       largestSequence + 1,
+		Source:         string(m.Source),
+		Type:           string(m.Type),
+		Status:         string(db.Pending),
+		Version:        m.Version,
+		Description:    m.Description,
+		Statement:      statement,
+		Schema:         prevSchema,
+		SchemaPrev:     prevSchema,
+		IssueID:        m.IssueID,
+		Payload:        payload,
+	}
+	if _, err := driver.historyCollection().InsertOne(ctx, history); err != nil {
+		// The version inserted by a concurrent migration violates the unique index.
+		if mongo.IsDuplicateKeyError(err) {
+			return -1, common.Errorf(common.MigrationPending,
+				fmt.Errorf("database %q version %s migration is already in progress", m.Database, m.Version))
+		}
+		return -1, err
+	}
+	return history.ID, nil
+}
+
+// endMigration updates the migration history record to DONE, or FAILED with the error message in the payload if migrationErr isn't nil.
+func (driver *Driver) endMigration(ctx context.Context, id int64, migrationDurationNs int64, updatedSchema string, payload string, migrationErr error) error {
+	update := bson.D{
+		{Key: "execution_duration_ns", Value: migrationDurationNs},
+		{Key: "updated_ts", Value: time.Now().Unix()},
+	}
+	if migrationErr == nil {
+		update = append(update, bson.E{Key: "status", Value: string(db.Done)}, bson.E{Key: "schema", Value: updatedSchema})
+	} else {
+		payload, err := util.SetMigrationPayloadError(payload, migrationErr.Error())
+		if err != nil {
+			return err
+		}
+		update = append(update, bson.E{Key: "status", Value: string(db.Failed)}, bson.E{Key: "payload", Value: payload})
+	}
+	_, err := driver.historyCollection().UpdateByID(ctx, id, bson.D{{Key: "$set", Value: update}})
+	return err
+}
+
+// Rollback will revert the applied migration version by executing its rollback statement as migration m with ExecuteMigration.
+// The reverted migration is marked as rolled back by m.Version in its payload, and it can't be rolled back again.
+func (driver *Driver) Rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Rollback", driver.dbType, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := driver.rollback(ctx, m, version)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+func (driver *Driver) rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	list, err := driver.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{
+		Database: &m.Namespace,
+		Version:  &version,
+	})
+	if err != nil {
+		return -1, "", fmt.Errorf("failed to find migration history of version %s, error %w", version, err)
+	}
+	if len(list) == 0 {
+		return -1, "", common.Errorf(common.NotFound, fmt.Errorf("database %q has never applied version %s", m.Database, version))
+	}
+	history := list[0]
+	if history.Type == db.Baseline {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s is a baseline, which can't be rolled back", m.Database, version))
+	}
+	if history.Status != db.Done {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration is %s, only the applied migration can be rolled back", m.Database, version, history.Status))
+	}
+	var miPayload db.MigrationInfoPayload
+	if history.Payload != "" {
+		if err := json.Unmarshal([]byte(history.Payload), &miPayload); err != nil {
+			return -1, "", fmt.Errorf("failed to unmarshal migration history payload of version %s, error %w", version, err)
+		}
+	}
+	if miPayload.RolledBackBy != "" {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration has already been rolled back by version %s", m.Database, version, miPayload.RolledBackBy))
+	}
+	if miPayload.RollbackStatement == "" {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration doesn't have a rollback statement", m.Database, version))
+	}
+
+	mi := *m
+	mi.Type = db.Migrate
+	mi.RollbackStatement = ""
+	if mi.Description == "" {
+		mi.Description = fmt.Sprintf("Rollback %s version %s", m.Database, version)
+	}
+	migrationHistoryID, updatedSchema, err := driver.ExecuteMigration(ctx, &mi, miPayload.RollbackStatement)
+	if err != nil {
+		return -1, "", err
+	}
+
+	miPayload.RolledBackBy = mi.Version
+	payloadBytes, err := json.Marshal(miPayload)
+	if err != nil {
+		return -1, "", fmt.Errorf("failed to marshal migration info payload, error %w", err)
+	}
+	if _, err := driver.historyCollection().UpdateByID(ctx, int64(history.ID), bson.D{{Key: "$set", Value: bson.D{{Key: "payload", Value: string(payloadBytes)}}}}); err != nil {
+		return -1, "", fmt.Errorf("database %q version %s migration has been rolled back by version %s, but failed to mark it as rolled back, error %w", m.Database, version, mi.Version, err)
+	}
+	return migrationHistoryID, updatedSchema, nil
+}
+
+// FindMigrationHistoryList finds the migration history, most recent first.
+func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.MigrationHistoryFind) ([]*db.MigrationHistory, error) {
+	filter := migrationHistoryFilter(find)
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+	if v := find.Limit; v != nil {
+		opts.SetLimit(int64(*v))
+	}
+	if find.OmitStatement {
+		opts.SetProjection(bson.D{{Key: "statement", Value: 0}})
+	}
+	cursor, err := driver.historyCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var historyList []*db.MigrationHistory
+	for cursor.Next(ctx) {
+		var h migrationHistory
+		if err := cursor.Decode(&h); err != nil {
+			return nil, err
+		}
+		history := &db.MigrationHistory{
+			ID:                  int(h.ID),
+			Creator:             h.Creator,
+			CreatedTs:           h.CreatedTs,
+			Updater:             h.Updater,
+			UpdatedTs:           h.UpdatedTs,
+			ReleaseVersion:      h.ReleaseVersion,
+			Namespace:           h.Namespace,
+			Sequence:            h.Sequence,
+			Source:              db.MigrationSource(h.Source),
+			Type:                db.MigrationType(h.Type),
+			Status:              db.MigrationStatus(h.Status),
+			Version:             h.Version,
+			Description:         h.Description,
+			Statement:           h.Statement,
+			Schema:              h.Schema,
+			SchemaPrev:          h.SchemaPrev,
+			ExecutionDurationNs: h.ExecutionDurationNs,
+			IssueID:             h.IssueID,
+			Payload:             h.Payload,
+		}
+		if history.Payload != "" {
+			var miPayload db.MigrationInfoPayload
+			if err := json.Unmarshal([]byte(history.Payload), &miPayload); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal migration history payload of version %s, error %w", history.Version, err)
+			}
+			history.ErrorMessage, history.Environment, history.Labels = miPayload.ErrorMessage, miPayload.Environment, miPayload.Labels
+			history.RolledBackBy = miPayload.RolledBackBy
+		}
+		historyList = append(historyList, history)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return historyList, nil
+}
+
+// migrationHistoryFilter returns the filter of the migration history documents matching find.
+func migrationHistoryFilter(find *db.MigrationHistoryFind) bson.D {
+	filter := bson.D{}
+	if v := find.ID; v != nil {
+		filter = append(filter, bson.E{Key: "_id", Value: int64(*v)})
+	}
+	if v := find.Database; v != nil {
+		filter = append(filter, bson.E{Key: "namespace", Value: *v})
+	}
+	if v := find.Version; v != nil {
+		filter = append(filter, bson.E{Key: "version", Value: *v})
+	}
+	if v := find.Source; v != nil {
+		filter = append(filter, bson.E{Key: "source", Value: string(*v)})
+	}
+	if v := find.Status; v != nil {
+		filter = append(filter, bson.E{Key: "status", Value: string(*v)})
+	}
+	if v := find.IssueID; v != nil {
+		filter = append(filter, bson.E{Key: "issue_id", Value: *v})
+	}
+	return filter
+}
+
+// Dump dumps the collections, the views and the indexes of the database, or all user databases if database is empty.
+// The dump is a sequence of the create and createIndexes commands, which is executable by Execute and Restore.
+// schemaOnly isn't supported yet and true by default.
+func (driver *Driver) Dump(ctx context.Context, database string, out io.Writer, schemaOnly bool) error {
+	databaseList, err := driver.getDatabaseList(ctx)
+	if err != nil {
+		return err
+	}
+
+	var dumpableDatabaseList []string
+	if database != "" {
+		exist := false
+		for _, name := range databaseList {
+			if name == database {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			return common.Errorf(common.NotFound, fmt.Errorf("database %s not found", database))
+		}
+		dumpableDatabaseList = []string{database}
+	} else {
+		for _, name := range databaseList {
+			if name != defaultMigrationDatabase {
+				dumpableDatabaseList = append(dumpableDatabaseList, name)
+			}
+		}
+	}
+
+	for _, database := range dumpableDatabaseList {
+		collectionList, err := driver.getCollectionList(ctx, database)
+		if err != nil {
+			return err
+		}
+		var commandList []bson.D
+		// Include the "use" pseudo command if dumping multiple databases.
+		if len(dumpableDatabaseList) > 1 {
+			commandList = append(commandList, bson.D{{Key: useCommand, Value: database}})
+		}
+		commandList = append(commandList, dumpCommandList(collectionList)...)
+		for _, command := range commandList {
+			s, err := marshalCommand(command)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(out, s+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dumpCommandList returns the create and createIndexes commands of the collections and the views.
+// The views are created after the collections, since they may be on the collections.
+func dumpCommandList(collectionList []*collectionSchema) []bson.D {
+	var commandList, viewCommandList []bson.D
+	for _, collection := range collectionList {
+		command := append(bson.D{{Key: "create", Value: collection.Name}}, collection.options...)
+		if collection.Type == "view" {
+			viewCommandList = append(viewCommandList, command)
+			continue
+		}
+		commandList = append(commandList, command)
+		if len(collection.indexList) == 0 {
+			continue
+		}
+		var indexList bson.A
+		for _, index := range collection.indexList {
+			indexList = append(indexList, index.raw)
+		}
+		commandList = append(commandList, bson.D{{Key: "createIndexes", Value: collection.Name}, {Key: "indexes", Value: indexList}})
+	}
+	return append(commandList, viewCommandList...)
+}
+
+// Restore runs the commands one by one without a transaction, so the commands before a failure stay applied.
+func (driver *Driver) Restore(ctx context.Context, sc *bufio.Scanner) error {
+	f := func(stmt string) error {
+		if _, err := driver.ExecuteStatementList(ctx, []string{stmt}); err != nil {
+			return err
+		}
+		return nil
+	}
+	return db.ApplyStatements(sc, db.MongoDB, f)
+}
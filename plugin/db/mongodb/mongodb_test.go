@@ -0,0 +1,110 @@
+package mongodb
+
+import (
+	"testing"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestParseCommand(t *testing.T) {
+	command, name, err := parseCommand(`{"insert": "users", "documents": [{"n": 1, "at": {"$date": "2022-01-01T00:00:00Z"}}]}`)
+	require.NoError(t, err)
+	require.Equal(t, "insert", name)
+	require.Len(t, command, 2)
+	got, err := marshalCommand(command)
+	require.NoError(t, err)
+	require.Equal(t, `{"insert":"users","documents":[{"n":1,"at":{"$date":"2022-01-01T00:00:00Z"}}]}`, got)
+
+	_, _, err = parseCommand(`{}`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "it's empty")
+
+	_, _, err = parseCommand(`{"find": `)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid MongoDB command")
+}
+
+func TestWritesAggregation(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{command: `{"aggregate": "users", "pipeline": [{"$match": {"age": 18}}], "cursor": {}}`, want: false},
+		{command: `{"aggregate": "users", "pipeline": [{"$match": {"age": 18}}, {"$out": "adults"}], "cursor": {}}`, want: true},
+		{command: `{"aggregate": "users", "pipeline": [{"$merge": {"into": "adults"}}], "cursor": {}}`, want: true},
+	}
+	for _, test := range tests {
+		command, _, err := parseCommand(test.command)
+		require.NoError(t, err)
+		require.Equal(t, test.want, writesAggregation(command), test.command)
+	}
+}
+
+func TestDumpCommandList(t *testing.T) {
+	collectionList := []*collectionSchema{
+		{
+			CollectionSpecification: &mongo.CollectionSpecification{Name: "active_users", Type: "view"},
+			options:                 bson.D{{Key: "viewOn", Value: "users"}, {Key: "pipeline", Value: bson.A{bson.D{{Key: "$match", Value: bson.D{{Key: "active", Value: true}}}}}}},
+		},
+		{
+			CollectionSpecification: &mongo.CollectionSpecification{Name: "events", Type: "collection"},
+			options:                 bson.D{{Key: "capped", Value: true}, {Key: "size", Value: int32(4096)}},
+		},
+		{
+			CollectionSpecification: &mongo.CollectionSpecification{Name: "users", Type: "collection"},
+			indexList: []*indexSchema{
+				{raw: bson.D{{Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}}, {Key: "name", Value: "email_1"}, {Key: "unique", Value: true}}},
+			},
+		},
+	}
+	var got []string
+	for _, command := range dumpCommandList(collectionList) {
+		s, err := marshalCommand(command)
+		require.NoError(t, err)
+		got = append(got, s)
+	}
+	require.Equal(t, []string{
+		`{"create":"events","capped":true,"size":4096}`,
+		`{"create":"users"}`,
+		`{"createIndexes":"users","indexes":[{"key":{"email":1},"name":"email_1","unique":true}]}`,
+		`{"create":"active_users","viewOn":"users","pipeline":[{"$match":{"active":true}}]}`,
+	}, got)
+
+	// The dump is executable as the statements.
+	text := ""
+	for _, s := range got {
+		text += s + "\n"
+	}
+	statementList, err := db.SplitStatements(text, db.MongoDB)
+	require.NoError(t, err)
+	require.Equal(t, got, statementList)
+}
+
+func TestMigrationHistoryFilter(t *testing.T) {
+	namespace, version := "db1", "0001"
+	status := db.Done
+	filter := migrationHistoryFilter(&db.MigrationHistoryFind{
+		Database: &namespace,
+		Version:  &version,
+		Status:   &status,
+	})
+	require.Equal(t, bson.D{
+		{Key: "namespace", Value: "db1"},
+		{Key: "version", Value: "0001"},
+		{Key: "status", Value: "DONE"},
+	}, filter)
+}
+
+func TestCommandReplyErr(t *testing.T) {
+	var reply commandReply
+	require.NoError(t, bson.UnmarshalExtJSON([]byte(`{"n": 1, "ok": 1}`), false, &reply))
+	require.NoError(t, reply.err())
+	require.Equal(t, int64(1), reply.N)
+
+	reply = commandReply{}
+	require.NoError(t, bson.UnmarshalExtJSON([]byte(`{"n": 0, "writeErrors": [{"index": 0, "code": 11000, "errmsg": "E11000 duplicate key error"}], "ok": 1}`), false, &reply))
+	require.EqualError(t, reply.err(), "write error of document #0, code 11000: E11000 duplicate key error")
+}
@@ -0,0 +1,1389 @@
+package mssql
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	// embed will embeds the migration schema.
+	_ "embed"
+
+	mssqldb "github.com/denisenkom/go-mssqldb"
+	"github.com/denisenkom/go-mssqldb/msdsn"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/bytebase/bytebase/plugin/db/util"
+	"go.uber.org/zap"
+)
+
+//go:embed mssql_migration_schema.sql
+var migrationSchema string
+
+var (
+	systemDatabases = map[string]bool{
+		"master": true,
+		"model":  true,
+		"msdb":   true,
+		"tempdb": true,
+	}
+	databaseHeaderFmt = "" +
+		"--\n" +
+		"-- SQL Server database structure for %s\n" +
+		"--\n"
+	useDatabaseFmt             = "USE %s;\n\n"
+	bytebaseDatabase           = "bytebase"
+	createBytebaseDatabaseStmt = "CREATE DATABASE bytebase;"
+
+	// nonTransactionalStatementRegexp matches the statements SQL Server refuses to run inside an explicit transaction.
+	// https://docs.microsoft.com/en-us/sql/t-sql/language-elements/transactions-transact-sql
+	nonTransactionalStatementRegexp = regexp.MustCompile(`(?i)^((ALTER|CREATE|DROP)\s+(DATABASE|FULLTEXT\s+(CATALOG|INDEX))|BACKUP|RECONFIGURE|RESTORE)\b`)
+	// useStatementRegexp matches the USE statement, and captures the database name.
+	useStatementRegexp = regexp.MustCompile(`(?is)^USE\s+(.+?)\s*;?$`)
+
+	_ db.Driver              = (*Driver)(nil)
+	_ util.MigrationExecutor = (*Driver)(nil)
+)
+
+func init() {
+	db.Register(db.SQLServer, newDriver)
+}
+
+// Driver is the SQL Server driver.
+type Driver struct {
+	l             *zap.Logger
+	driverConfig  db.DriverConfig
+	connectionCtx db.ConnectionContext
+
+	db        *sql.DB
+	baseURL   url.URL
+	tlsConfig *tls.Config
+
+	// migrationSetup caches whether the migration schema has been set up by this driver instance.
+	migrationSetup bool
+}
+
+func newDriver(config db.DriverConfig) db.Driver {
+	return &Driver{
+		l:            config.Logger,
+		driverConfig: config,
+	}
+}
+
+// Open opens a SQL Server driver.
+// The Host can be in the form of "host\instance" to connect to a named instance.
+func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.ConnectionConfig, connCtx db.ConnectionContext) (db.Driver, error) {
+	tlsConfig, err := config.TLSConfig.GetSslConfig()
+	if err != nil {
+		return nil, fmt.Errorf("sql: tls config error: %v", err)
+	}
+
+	host, instance := config.Host, ""
+	if i := strings.Index(host, `\`); i >= 0 {
+		host, instance = host[:i], host[i+1:]
+	}
+	if config.Port != "" {
+		host = fmt.Sprintf("%s:%s", host, config.Port)
+	}
+	driver.baseURL = url.URL{
+		Scheme: "sqlserver",
+		User:   url.UserPassword(config.Username, config.Password),
+		Host:   host,
+		Path:   instance,
+	}
+	driver.tlsConfig = tlsConfig
+	driver.connectionCtx = connCtx
+
+	if err := driver.switchDatabase(config.Database); err != nil {
+		return nil, err
+	}
+	return driver, nil
+}
+
+// Close closes the driver.
+func (driver *Driver) Close(ctx context.Context) error {
+	driver.migrationSetup = false
+	if driver.db != nil {
+		return driver.db.Close()
+	}
+	return nil
+}
+
+// Ping pings the database.
+func (driver *Driver) Ping(ctx context.Context) error {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Ping", db.SQLServer, nil)
+	err := driver.db.PingContext(ctx)
+	span.End(err)
+	return err
+}
+
+// Stats returns the statistics of the connection pool.
+func (driver *Driver) Stats() sql.DBStats {
+	if driver.db == nil {
+		return sql.DBStats{}
+	}
+	return driver.db.Stats()
+}
+
+// GetDbConnection gets a database connection.
+func (driver *Driver) GetDbConnection(ctx context.Context, database string) (*sql.DB, error) {
+	if err := driver.switchDatabase(database); err != nil {
+		return nil, err
+	}
+	return driver.db, nil
+}
+
+// switchDatabase reopens the connection on the given database.
+// We don't rely on the USE statement because it only affects a single pooled connection.
+func (driver *Driver) switchDatabase(dbName string) error {
+	if driver.db != nil {
+		if err := driver.db.Close(); err != nil {
+			return err
+		}
+	}
+
+	u := driver.baseURL
+	q := url.Values{}
+	if dbName != "" {
+		q.Set("database", dbName)
+	}
+	u.RawQuery = q.Encode()
+	config, _, err := msdsn.Parse(u.String())
+	if err != nil {
+		return err
+	}
+	if driver.tlsConfig != nil {
+		config.Encryption = msdsn.EncryptionRequired
+		config.TLSConfig = driver.tlsConfig
+	}
+	driver.db = sql.OpenDB(mssqldb.NewConnectorConfig(config))
+	driver.driverConfig.ApplyConnectionPool(driver.db)
+	return nil
+}
+
+// GetVersion gets the version.
+func (driver *Driver) GetVersion(ctx context.Context) (string, error) {
+	query := "SELECT CAST(SERVERPROPERTY('ProductVersion') AS NVARCHAR(128))"
+	var version string
+	if err := driver.db.QueryRowContext(ctx, query).Scan(&version); err != nil {
+		return "", util.FormatErrorWithQuery(err, query)
+	}
+	return version, nil
+}
+
+// SyncSchema synces the schema.
+func (driver *Driver) SyncSchema(ctx context.Context, _ ...string) ([]*db.User, []*db.Schema, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "SyncSchema", db.SQLServer, nil)
+	userList, schemaList, err := driver.syncSchema(ctx)
+	span.End(err)
+	return userList, schemaList, err
+}
+
+func (driver *Driver) syncSchema(ctx context.Context) ([]*db.User, []*db.Schema, error) {
+	excludedDatabaseList := map[string]bool{
+		// Skip our internal "bytebase" database
+		bytebaseDatabase: true,
+		// Skip internal databases from cloud service providers
+		"rdsadmin": true,
+	}
+	for k := range systemDatabases {
+		excludedDatabaseList[k] = true
+	}
+
+	userList, err := driver.getUserList(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	databases, err := driver.getDatabases(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var schemaList []*db.Schema
+	for _, database := range databases {
+		if excludedDatabaseList[database.name] {
+			continue
+		}
+
+		schema, err := driver.syncDatabaseSchema(ctx, database)
+		if err != nil {
+			return nil, nil, err
+		}
+		schemaList = append(schemaList, schema)
+	}
+
+	return userList, schemaList, nil
+}
+
+// syncDatabaseSchema syncs the schema of a single database in a transaction.
+func (driver *Driver) syncDatabaseSchema(ctx context.Context, database *databaseSchema) (*db.Schema, error) {
+	if err := driver.switchDatabase(database.name); err != nil {
+		return nil, err
+	}
+	// The TDS client doesn't support read-only transactions.
+	txn, err := driver.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+
+	schema := db.Schema{
+		Name:      database.name,
+		Collation: database.collation,
+	}
+	tables, err := getTables(txn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tables from database %q: %s", database.name, err)
+	}
+	columnMap, err := getTableColumns(txn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns from database %q: %s", database.name, err)
+	}
+	indexMap, err := getIndices(txn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get indices from database %q: %s", database.name, err)
+	}
+	for _, tbl := range tables {
+		key := fmt.Sprintf("%s.%s", tbl.schemaName, tbl.name)
+		dbTable := db.Table{
+			Name:      key,
+			CreatedTs: tbl.createdTs,
+			UpdatedTs: tbl.updatedTs,
+			Type:      "BASE TABLE",
+			RowCount:  tbl.rowCount,
+			DataSize:  tbl.dataSize,
+			IndexSize: tbl.indexSize,
+			Comment:   tbl.comment,
+		}
+		for _, col := range columnMap[key] {
+			dbTable.ColumnList = append(dbTable.ColumnList, db.Column{
+				Name:      col.name,
+				Position:  col.position,
+				Default:   col.defaultValue,
+				Nullable:  col.nullable,
+				Type:      col.typeString(),
+				Collation: col.collation,
+				Comment:   col.comment,
+			})
+		}
+		for _, idx := range indexMap[key] {
+			for i, column := range idx.columns {
+				dbTable.IndexList = append(dbTable.IndexList, db.Index{
+					Name:       idx.name,
+					Expression: column,
+					Position:   i + 1,
+					Type:       idx.indexType,
+					Unique:     idx.unique,
+					Visible:    !idx.disabled,
+				})
+			}
+		}
+		schema.TableList = append(schema.TableList, dbTable)
+	}
+
+	views, err := getViews(txn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get views from database %q: %s", database.name, err)
+	}
+	for _, view := range views {
+		schema.ViewList = append(schema.ViewList, db.View{
+			Name:       fmt.Sprintf("%s.%s", view.schemaName, view.name),
+			CreatedTs:  view.createdTs,
+			UpdatedTs:  view.updatedTs,
+			Definition: view.definition,
+		})
+	}
+
+	if err := txn.Commit(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (driver *Driver) getUserList(ctx context.Context) ([]*db.User, error) {
+	// Collect the server roles of each login. We don't use STRING_AGG because it requires SQL Server 2017.
+	query := `
+		SELECT p.name, ISNULL(r.name, '')
+		FROM sys.server_principals p
+		LEFT JOIN sys.server_role_members m ON m.member_principal_id = p.principal_id
+		LEFT JOIN sys.server_principals r ON r.principal_id = m.role_principal_id
+		WHERE p.type IN ('S', 'U', 'G') AND p.name NOT LIKE '##%'
+		ORDER BY p.name, r.name`
+	rows, err := driver.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	var userList []*db.User
+	roleMap := make(map[string][]string)
+	for rows.Next() {
+		var name, role string
+		if err := rows.Scan(&name, &role); err != nil {
+			return nil, err
+		}
+		if _, ok := roleMap[name]; !ok {
+			userList = append(userList, &db.User{Name: name})
+			roleMap[name] = nil
+		}
+		if role != "" {
+			roleMap[name] = append(roleMap[name], role)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, user := range userList {
+		user.Grant = strings.Join(roleMap[user.Name], ", ")
+	}
+	return userList, nil
+}
+
+// Execute executes a SQL statement.
+// The statements are split by db.SplitStatements and executed in order. Statements that SQL Server can't run in a transaction
+// are executed directly, and each run of the other statements between them is executed in a transaction.
+func (driver *Driver) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Execute", db.SQLServer, nil)
+	result, err := driver.execute(ctx, statement)
+	span.End(err)
+	return result, err
+}
+
+func (driver *Driver) execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	statementList, err := db.SplitStatements(statement, db.SQLServer)
+	if err != nil {
+		return db.ExecuteResult{}, err
+	}
+
+	var result db.ExecuteResult
+	// tx is the transaction of the consecutive transactional statements, which is committed before
+	// the next statement running outside of it, so that the statements are executed in order.
+	var tx *sql.Tx
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		err := tx.Commit()
+		tx = nil
+		return err
+	}
+
+	for _, stmt := range statementList {
+		useDatabase, isUse := useStatementDatabase(stmt)
+		switch {
+		case isNonTransactionalStatement(stmt):
+			if err := commit(); err != nil {
+				return db.ExecuteResult{}, err
+			}
+			sqlResult, err := driver.db.ExecContext(ctx, stmt)
+			if err != nil {
+				return db.ExecuteResult{}, util.FormatErrorWithQuery(err, stmt)
+			}
+			result.Add(sqlResult)
+		case isUse:
+			// The connection is reopened on the database, so the pending transaction must be committed first.
+			if err := commit(); err != nil {
+				return db.ExecuteResult{}, err
+			}
+			// For the case of `USE [dbname];`, we need to use GetDbConnection() instead of executing the statement.
+			if _, err := driver.GetDbConnection(ctx, useDatabase); err != nil {
+				return db.ExecuteResult{}, err
+			}
+		default:
+			if tx == nil {
+				if tx, err = driver.db.BeginTx(ctx, nil); err != nil {
+					return db.ExecuteResult{}, err
+				}
+			}
+			// Some statements such as CREATE VIEW must be the only statement in a batch, so we execute them one by one.
+			sqlResult, err := tx.ExecContext(ctx, stmt)
+			if err != nil {
+				return db.ExecuteResult{}, util.FormatErrorWithQuery(err, stmt)
+			}
+			result.Add(sqlResult)
+		}
+	}
+
+	if err := commit(); err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return result, nil
+}
+
+// ExecuteBatch executes the statements in a single transaction, which are sent in a single round trip.
+func (driver *Driver) ExecuteBatch(ctx context.Context, statementList []string) error {
+	return util.ExecuteMultiStatementBatch(ctx, driver.db, statementList)
+}
+
+// isNonTransactionalStatement returns whether the statement can't run inside a transaction.
+func isNonTransactionalStatement(stmt string) bool {
+	return nonTransactionalStatementRegexp.MatchString(trimLeadingComments(stmt))
+}
+
+// useStatementDatabase returns the unquoted database name of the USE statement, and whether the statement is a USE statement.
+func useStatementDatabase(stmt string) (string, bool) {
+	match := useStatementRegexp.FindStringSubmatch(trimLeadingComments(stmt))
+	if match == nil {
+		return "", false
+	}
+	return unquoteIdentifier(match[1]), true
+}
+
+// trimLeadingComments trims the leading whitespaces and comments of the statement, and the trailing whitespaces.
+// It returns an empty string for an unterminated block comment.
+func trimLeadingComments(stmt string) string {
+	for {
+		stmt = strings.TrimSpace(stmt)
+		switch {
+		case strings.HasPrefix(stmt, "--"):
+			i := strings.IndexByte(stmt, '\n')
+			if i < 0 {
+				return ""
+			}
+			stmt = stmt[i+1:]
+		case strings.HasPrefix(stmt, "/*"):
+			i := strings.Index(stmt, "*/")
+			if i < 0 {
+				return ""
+			}
+			stmt = stmt[i+2:]
+		default:
+			return stmt
+		}
+	}
+}
+
+// Query queries a SQL statement.
+func (driver *Driver) Query(ctx context.Context, statement string, limit int) ([]interface{}, error) {
+	return util.Query(ctx, driver.l, driver.db, statement, limit)
+}
+
+// BeginTx begins a transaction.
+func (driver *Driver) BeginTx(ctx context.Context) (db.Tx, error) {
+	return util.BeginTx(ctx, driver.db)
+}
+
+// NeedsSetupMigration returns whether it needs to setup migration.
+func (driver *Driver) NeedsSetupMigration(ctx context.Context) (bool, error) {
+	if driver.migrationSetup {
+		return false, nil
+	}
+	exist, err := driver.hasBytebaseDatabase(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !exist {
+		return true, nil
+	}
+
+	const query = `
+		SELECT
+		    1
+		FROM bytebase.INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_SCHEMA = 'dbo' AND TABLE_NAME = 'migration_history'
+	`
+	return util.NeedsSetupMigrationSchema(ctx, driver.db, query)
+}
+
+func (driver *Driver) hasBytebaseDatabase(ctx context.Context) (bool, error) {
+	databases, err := driver.getDatabases(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, database := range databases {
+		if database.name == bytebaseDatabase {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetupMigrationIfNeeded sets up migration if needed.
+func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
+	setup, err := driver.NeedsSetupMigration(ctx)
+	if err != nil {
+		return err
+	}
+
+	if setup {
+		driver.l.Info("Bytebase migration schema not found, creating schema...",
+			zap.String("environment", driver.connectionCtx.EnvironmentName),
+			zap.String("database", driver.connectionCtx.InstanceName),
+		)
+
+		exist, err := driver.hasBytebaseDatabase(ctx)
+		if err != nil {
+			driver.l.Error("Failed to find bytebase database.",
+				zap.Error(err),
+				zap.String("environment", driver.connectionCtx.EnvironmentName),
+				zap.String("database", driver.connectionCtx.InstanceName),
+			)
+			return fmt.Errorf("failed to find bytebase database error: %v", err)
+		}
+
+		if !exist {
+			// CREATE DATABASE can't run inside a transaction, so we create the database before the migration schema.
+			if _, err := driver.db.ExecContext(ctx, createBytebaseDatabaseStmt); err != nil {
+				driver.l.Error("Failed to create bytebase database.",
+					zap.Error(err),
+					zap.String("environment", driver.connectionCtx.EnvironmentName),
+					zap.String("database", driver.connectionCtx.InstanceName),
+				)
+				return util.FormatErrorWithQuery(err, createBytebaseDatabaseStmt)
+			}
+		}
+
+		if _, err := driver.db.ExecContext(ctx, migrationSchema); err != nil {
+			driver.l.Error("Failed to initialize migration schema.",
+				zap.Error(err),
+				zap.String("environment", driver.connectionCtx.EnvironmentName),
+				zap.String("database", driver.connectionCtx.InstanceName),
+			)
+			return util.FormatErrorWithQuery(err, migrationSchema)
+		}
+		driver.l.Info("Successfully created migration schema.",
+			zap.String("environment", driver.connectionCtx.EnvironmentName),
+			zap.String("database", driver.connectionCtx.InstanceName),
+		)
+	}
+
+	driver.migrationSetup = true
+	return nil
+}
+
+// FindVersionListSinceBaseline will find the stored versions since last baseline or branch.
+func (driver Driver) FindVersionListSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) ([]string, error) {
+	largestBaselineSequence, err := driver.FindLargestSequence(ctx, tx, namespace, true /* baseline */)
+	if err != nil {
+		return nil, err
+	}
+	const getVersionListSinceLastBaselineQuery = `
+		SELECT version FROM bytebase.dbo.migration_history
+		WHERE namespace = @p1 AND sequence >= @p2
+	`
+	rows, err := tx.QueryContext(ctx, getVersionListSinceLastBaselineQuery,
+		namespace, largestBaselineSequence,
+	)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, getVersionListSinceLastBaselineQuery)
+	}
+	defer rows.Close()
+
+	var versionList []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versionList = append(versionList, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return versionList, nil
+}
+
+// FindLargestSequence will return the largest sequence number.
+func (Driver) FindLargestSequence(ctx context.Context, tx *sql.Tx, namespace string, baseline bool) (int, error) {
+	findLargestSequenceQuery := `
+		SELECT MAX(sequence) FROM bytebase.dbo.migration_history
+		WHERE namespace = @p1`
+	if baseline {
+		findLargestSequenceQuery = fmt.Sprintf("%s AND (type = '%s' OR type = '%s')", findLargestSequenceQuery, db.Baseline, db.Branch)
+	}
+	var sequence sql.NullInt32
+	if err := tx.QueryRowContext(ctx, findLargestSequenceQuery,
+		namespace,
+	).Scan(&sequence); err != nil {
+		return -1, util.FormatErrorWithQuery(err, findLargestSequenceQuery)
+	}
+
+	if !sequence.Valid {
+		// Returns 0 if we haven't applied any migration for this namespace.
+		return 0, nil
+	}
+
+	return int(sequence.Int32), nil
+}
+
+// InsertPendingHistory will insert the migration record with pending status and return the inserted ID.
+func (Driver) InsertPendingHistory(ctx context.Context, tx *sql.Tx, sequence int, prevSchema string, m *db.MigrationInfo, storedVersion, statement string) (int64, error) {
+	const insertHistoryQuery = `
+	INSERT INTO bytebase.dbo.migration_history (
+		created_by,
+		created_ts,
+		updated_by,
+		updated_ts,
+		release_version,
+		namespace,
+		sequence,
+		source,
+		type,
+		status,
+		version,
+		description,
+		statement,
+		[schema],
+		schema_prev,
+		execution_duration_ns,
+		issue_id,
+		payload
+	)
+	OUTPUT INSERTED.id
+	VALUES (@p1, @p2, @p3, @p4, @p5, @p6, @p7, @p8, @p9, 'PENDING', @p10, @p11, @p12, @p13, @p14, 0, @p15, @p16)
+	`
+	now := time.Now().Unix()
+	var insertedID int64
+	if err := tx.QueryRowContext(ctx, insertHistoryQuery,
+		m.Creator,
+		now,
+		m.Creator,
+		now,
+		m.ReleaseVersion,
+		m.Namespace,
+		sequence,
+		m.Source,
+		m.Type,
+		storedVersion,
+		m.Description,
+		statement,
+		prevSchema,
+		prevSchema,
+		m.IssueID,
+		m.Payload,
+	).Scan(&insertedID); err != nil {
+		return 0, util.FormatErrorWithQuery(err, insertHistoryQuery)
+	}
+	return insertedID, nil
+}
+
+// UpdateHistoryAsDone will update the migration record as done.
+func (Driver) UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, updatedSchema string, insertedID int64) error {
+	const updateHistoryAsDoneQuery = `
+	UPDATE
+		bytebase.dbo.migration_history
+	SET
+		status = 'DONE',
+		execution_duration_ns = @p1,
+		[schema] = @p2
+	WHERE id = @p3
+	`
+	_, err := tx.ExecContext(ctx, updateHistoryAsDoneQuery, migrationDurationNs, updatedSchema, insertedID)
+	return err
+}
+
+// UpdateHistoryAsFailed will update the migration record as failed with the payload recording the error message.
+func (Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, payload string, insertedID int64) error {
+	const updateHistoryAsFailedQuery = `
+	UPDATE
+		bytebase.dbo.migration_history
+	SET
+		status = 'FAILED',
+		execution_duration_ns = @p1,
+		payload = @p2
+	WHERE id = @p3
+	`
+	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, payload, insertedID)
+	return err
+}
+
+// UpdateHistoryPayload will replace the payload of the migration record.
+func (Driver) UpdateHistoryPayload(ctx context.Context, tx *sql.Tx, payload string, id int64) error {
+	const updateHistoryPayloadQuery = `
+	UPDATE
+		bytebase.dbo.migration_history
+	SET
+		payload = @p1
+	WHERE id = @p2
+	`
+	_, err := tx.ExecContext(ctx, updateHistoryPayloadQuery, payload, id)
+	return err
+}
+
+// ExecuteMigration will execute the migration.
+// The migration history is recorded in its own transaction, and Execute runs the statements that can't be
+// in a transaction (e.g. CREATE DATABASE) outside of it, so the PENDING record is updated to DONE or FAILED afterward.
+func (driver *Driver) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "ExecuteMigration", db.SQLServer, db.MigrationSpanAttributes(m))
+	if err := driver.driverConfig.ValidateStatement(db.SQLServer, statement, m); err != nil {
+		span.End(err)
+		return -1, "", err
+	}
+	migrationHistoryID, updatedSchema, err := util.ExecuteMigration(ctx, driver.l, driver, m, statement)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+// Rollback will revert the applied migration version.
+func (driver *Driver) Rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Rollback", db.SQLServer, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := util.Rollback(ctx, driver.l, driver, m, version)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+// FindMigrationHistoryList finds the migration history.
+func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.MigrationHistoryFind) ([]*db.MigrationHistory, error) {
+	baseQuery := `
+	SELECT
+		id,
+		created_by,
+		created_ts,
+		updated_by,
+		updated_ts,
+		release_version,
+		namespace,
+		sequence,
+		source,
+		type,
+		status,
+		version,
+		description,
+		` + util.MigrationHistoryStatementColumn(find) + `,
+		[schema],
+		schema_prev,
+		execution_duration_ns,
+		issue_id,
+		payload
+		FROM bytebase.dbo.migration_history `
+	paramNames, params := []string{}, []interface{}{}
+	if v := find.ID; v != nil {
+		paramNames, params = append(paramNames, "id"), append(params, *v)
+	}
+	if v := find.Database; v != nil {
+		paramNames, params = append(paramNames, "namespace"), append(params, *v)
+	}
+	if v := find.Version; v != nil {
+		// TODO(d): support semantic versioning.
+		storedVersion, err := util.ToStoredVersion(false, *v, "")
+		if err != nil {
+			return nil, err
+		}
+		paramNames, params = append(paramNames, "version"), append(params, storedVersion)
+	}
+	if v := find.Source; v != nil {
+		paramNames, params = append(paramNames, "source"), append(params, *v)
+	}
+	if v := find.Status; v != nil {
+		paramNames, params = append(paramNames, "status"), append(params, *v)
+	}
+	if v := find.IssueID; v != nil {
+		paramNames, params = append(paramNames, "issue_id"), append(params, *v)
+	}
+	var query = baseQuery +
+		db.FormatParamNameInAtSignPosition(paramNames) +
+		`ORDER BY created_ts DESC`
+	if v := find.Limit; v != nil {
+		query += fmt.Sprintf(" OFFSET 0 ROWS FETCH NEXT %d ROWS ONLY", *v)
+	}
+	return util.FindMigrationHistoryList(ctx, query, params, driver, find, baseQuery)
+}
+
+// Dump and restore
+
+// Dump dumps the database.
+func (driver *Driver) Dump(ctx context.Context, database string, out io.Writer, schemaOnly bool) error {
+	databases, err := driver.getDatabases(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get databases: %s", err)
+	}
+
+	var dumpableDbNames []string
+	if database != "" {
+		exist := false
+		for _, n := range databases {
+			if n.name == database {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			return fmt.Errorf("database %s not found", database)
+		}
+		dumpableDbNames = []string{database}
+	} else {
+		for _, n := range databases {
+			if systemDatabases[n.name] || n.name == bytebaseDatabase {
+				continue
+			}
+			dumpableDbNames = append(dumpableDbNames, n.name)
+		}
+	}
+
+	for _, dbName := range dumpableDbNames {
+		includeUseDatabase := len(dumpableDbNames) > 1
+		if err := driver.dumpOneDatabase(ctx, dbName, out, schemaOnly, includeUseDatabase); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore restores a database.
+func (driver *Driver) Restore(ctx context.Context, sc *bufio.Scanner) (err error) {
+	txn, err := driver.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	f := func(stmt string) error {
+		if _, err := txn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if err := db.ApplyStatements(sc, db.SQLServer, f); err != nil {
+		return err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (driver *Driver) dumpOneDatabase(ctx context.Context, database string, out io.Writer, schemaOnly bool, includeUseDatabase bool) error {
+	if err := driver.switchDatabase(database); err != nil {
+		return err
+	}
+
+	txn, err := driver.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	if includeUseDatabase {
+		header := fmt.Sprintf(databaseHeaderFmt, database)
+		if _, err := io.WriteString(out, header); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(out, fmt.Sprintf(useDatabaseFmt, quoteIdentifier(database))); err != nil {
+			return err
+		}
+	}
+
+	schemas, err := getSchemas(txn)
+	if err != nil {
+		return err
+	}
+	for _, schema := range schemas {
+		if _, err := io.WriteString(out, fmt.Sprintf("CREATE SCHEMA %s;\n\n", quoteIdentifier(schema))); err != nil {
+			return err
+		}
+	}
+
+	tables, err := getTables(txn)
+	if err != nil {
+		return err
+	}
+	columnMap, err := getTableColumns(txn)
+	if err != nil {
+		return err
+	}
+	indexMap, err := getIndices(txn)
+	if err != nil {
+		return err
+	}
+	for _, tbl := range tables {
+		key := fmt.Sprintf("%s.%s", tbl.schemaName, tbl.name)
+		if _, err := io.WriteString(out, tbl.statement(columnMap[key], indexMap[key])); err != nil {
+			return err
+		}
+		if !schemaOnly {
+			if err := exportTableData(txn, tbl, out); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Views, functions, procedures and triggers.
+	modules, err := getModules(txn)
+	if err != nil {
+		return err
+	}
+	for _, module := range modules {
+		if _, err := io.WriteString(out, fmt.Sprintf("%s;\n\n", strings.TrimSpace(module))); err != nil {
+			return err
+		}
+	}
+
+	return txn.Commit()
+}
+
+type databaseSchema struct {
+	name      string
+	collation string
+}
+
+// getDatabases gets all databases of an instance.
+func (driver *Driver) getDatabases(ctx context.Context) ([]*databaseSchema, error) {
+	query := "SELECT name, ISNULL(collation_name, '') FROM sys.databases;"
+	rows, err := driver.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	var databases []*databaseSchema
+	for rows.Next() {
+		var d databaseSchema
+		if err := rows.Scan(&d.name, &d.collation); err != nil {
+			return nil, err
+		}
+		databases = append(databases, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return databases, nil
+}
+
+// getSchemas gets all user-defined schemas except dbo.
+func getSchemas(txn *sql.Tx) ([]string, error) {
+	// Schema ids 1 to 4 are dbo, guest, INFORMATION_SCHEMA and sys, and ids from 16384 are the fixed database roles.
+	query := "SELECT name FROM sys.schemas WHERE schema_id BETWEEN 5 AND 16383 ORDER BY name;"
+	rows, err := txn.Query(query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, rows.Err()
+}
+
+// tableSchema describes the schema of a table.
+type tableSchema struct {
+	schemaName string
+	name       string
+	createdTs  int64
+	updatedTs  int64
+	rowCount   int64
+	dataSize   int64
+	indexSize  int64
+	comment    string
+}
+
+// getTables gets all tables of a database.
+// The row count and sizes come from sys.dm_db_partition_stats, where the heap (0) or clustered index (1) holds the data,
+// and the rest are non-clustered indexes. A page is 8 KB.
+func getTables(txn *sql.Tx) ([]*tableSchema, error) {
+	query := `
+		SELECT
+			s.name,
+			t.name,
+			DATEDIFF(SECOND, '19700101', t.create_date),
+			DATEDIFF(SECOND, '19700101', t.modify_date),
+			ISNULL(SUM(CASE WHEN ps.index_id IN (0, 1) THEN ps.row_count ELSE 0 END), 0),
+			ISNULL(SUM(CASE WHEN ps.index_id IN (0, 1) THEN ps.used_page_count ELSE 0 END), 0) * 8192,
+			ISNULL(SUM(CASE WHEN ps.index_id > 1 THEN ps.used_page_count ELSE 0 END), 0) * 8192,
+			ISNULL((
+				SELECT CAST(ep.value AS NVARCHAR(MAX)) FROM sys.extended_properties ep
+				WHERE ep.class = 1 AND ep.major_id = t.object_id AND ep.minor_id = 0 AND ep.name = 'MS_Description'
+			), '')
+		FROM sys.tables t
+		JOIN sys.schemas s ON t.schema_id = s.schema_id
+		LEFT JOIN sys.dm_db_partition_stats ps ON ps.object_id = t.object_id
+		WHERE t.is_ms_shipped = 0
+		GROUP BY s.name, t.name, t.object_id, t.create_date, t.modify_date
+		ORDER BY s.name, t.name;`
+	rows, err := txn.Query(query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	var tables []*tableSchema
+	for rows.Next() {
+		var tbl tableSchema
+		if err := rows.Scan(
+			&tbl.schemaName,
+			&tbl.name,
+			&tbl.createdTs,
+			&tbl.updatedTs,
+			&tbl.rowCount,
+			&tbl.dataSize,
+			&tbl.indexSize,
+			&tbl.comment,
+		); err != nil {
+			return nil, err
+		}
+		tables = append(tables, &tbl)
+	}
+	return tables, rows.Err()
+}
+
+// statement returns the CREATE TABLE statement along with its constraints and indexes.
+func (t *tableSchema) statement(columns []*columnSchema, indices []*indexSchema) string {
+	tableName := fmt.Sprintf("%s.%s", quoteIdentifier(t.schemaName), quoteIdentifier(t.name))
+	var lines []string
+	for _, col := range columns {
+		lines = append(lines, "    "+col.statement())
+	}
+	for _, idx := range indices {
+		if idx.primary {
+			lines = append(lines, fmt.Sprintf("    CONSTRAINT %s PRIMARY KEY %s (%s)", quoteIdentifier(idx.name), idx.indexType, quoteIdentifiers(idx.columns)))
+		}
+	}
+	stmt := fmt.Sprintf("CREATE TABLE %s (\n%s\n);\n\n", tableName, strings.Join(lines, ",\n"))
+	for _, idx := range indices {
+		if idx.primary {
+			continue
+		}
+		unique := ""
+		if idx.unique {
+			unique = "UNIQUE "
+		}
+		stmt += fmt.Sprintf("CREATE %s%s INDEX %s ON %s (%s);\n\n", unique, idx.indexType, quoteIdentifier(idx.name), tableName, quoteIdentifiers(idx.columns))
+	}
+	return stmt
+}
+
+// columnSchema describes the schema of a table column.
+type columnSchema struct {
+	name         string
+	position     int
+	typeName     string
+	maxLength    int
+	precision    int
+	scale        int
+	nullable     bool
+	identity     bool
+	seed         string
+	increment    string
+	defaultValue *string
+	collation    string
+	comment      string
+}
+
+// getTableColumns gets the columns of all tables keyed by "schema.table".
+func getTableColumns(txn *sql.Tx) (map[string][]*columnSchema, error) {
+	query := `
+		SELECT
+			s.name,
+			t.name,
+			c.name,
+			c.column_id,
+			ty.name,
+			c.max_length,
+			c.precision,
+			c.scale,
+			c.is_nullable,
+			c.is_identity,
+			ISNULL(CAST(ic.seed_value AS NVARCHAR(64)), ''),
+			ISNULL(CAST(ic.increment_value AS NVARCHAR(64)), ''),
+			dc.definition,
+			ISNULL(c.collation_name, ''),
+			ISNULL(CAST(ep.value AS NVARCHAR(MAX)), '')
+		FROM sys.columns c
+		JOIN sys.tables t ON c.object_id = t.object_id
+		JOIN sys.schemas s ON t.schema_id = s.schema_id
+		JOIN sys.types ty ON c.user_type_id = ty.user_type_id
+		LEFT JOIN sys.identity_columns ic ON ic.object_id = c.object_id AND ic.column_id = c.column_id
+		LEFT JOIN sys.default_constraints dc ON dc.object_id = c.default_object_id
+		LEFT JOIN sys.extended_properties ep ON ep.class = 1 AND ep.major_id = c.object_id AND ep.minor_id = c.column_id AND ep.name = 'MS_Description'
+		WHERE t.is_ms_shipped = 0
+		ORDER BY s.name, t.name, c.column_id;`
+	rows, err := txn.Query(query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	columnMap := make(map[string][]*columnSchema)
+	for rows.Next() {
+		var schemaName, tableName string
+		var col columnSchema
+		var defaultValue sql.NullString
+		if err := rows.Scan(
+			&schemaName,
+			&tableName,
+			&col.name,
+			&col.position,
+			&col.typeName,
+			&col.maxLength,
+			&col.precision,
+			&col.scale,
+			&col.nullable,
+			&col.identity,
+			&col.seed,
+			&col.increment,
+			&defaultValue,
+			&col.collation,
+			&col.comment,
+		); err != nil {
+			return nil, err
+		}
+		if defaultValue.Valid {
+			col.defaultValue = &defaultValue.String
+		}
+		key := fmt.Sprintf("%s.%s", schemaName, tableName)
+		columnMap[key] = append(columnMap[key], &col)
+	}
+	return columnMap, rows.Err()
+}
+
+// typeString returns the column type with its length, precision or scale.
+func (c *columnSchema) typeString() string {
+	switch c.typeName {
+	case "char", "varchar", "binary", "varbinary":
+		if c.maxLength == -1 {
+			return fmt.Sprintf("%s(MAX)", c.typeName)
+		}
+		return fmt.Sprintf("%s(%d)", c.typeName, c.maxLength)
+	case "nchar", "nvarchar":
+		// max_length is in bytes, and each character takes two bytes.
+		if c.maxLength == -1 {
+			return fmt.Sprintf("%s(MAX)", c.typeName)
+		}
+		return fmt.Sprintf("%s(%d)", c.typeName, c.maxLength/2)
+	case "decimal", "numeric":
+		return fmt.Sprintf("%s(%d, %d)", c.typeName, c.precision, c.scale)
+	case "datetime2", "datetimeoffset", "time":
+		return fmt.Sprintf("%s(%d)", c.typeName, c.scale)
+	}
+	return c.typeName
+}
+
+func (c *columnSchema) statement() string {
+	s := fmt.Sprintf("%s %s", quoteIdentifier(c.name), c.typeString())
+	if c.identity {
+		s += fmt.Sprintf(" IDENTITY(%s, %s)", c.seed, c.increment)
+	}
+	if c.collation != "" {
+		s += fmt.Sprintf(" COLLATE %s", c.collation)
+	}
+	if !c.nullable {
+		s += " NOT NULL"
+	}
+	if c.defaultValue != nil {
+		s += fmt.Sprintf(" DEFAULT %s", *c.defaultValue)
+	}
+	return s
+}
+
+// indexSchema describes the schema of an index.
+type indexSchema struct {
+	name      string
+	indexType string
+	unique    bool
+	primary   bool
+	disabled  bool
+	columns   []string
+}
+
+// getIndices gets the indexes of all tables keyed by "schema.table".
+func getIndices(txn *sql.Tx) (map[string][]*indexSchema, error) {
+	query := `
+		SELECT
+			s.name,
+			t.name,
+			i.name,
+			i.type_desc,
+			i.is_unique,
+			i.is_primary_key,
+			i.is_disabled,
+			COL_NAME(ic.object_id, ic.column_id)
+		FROM sys.indexes i
+		JOIN sys.tables t ON i.object_id = t.object_id
+		JOIN sys.schemas s ON t.schema_id = s.schema_id
+		JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+		WHERE t.is_ms_shipped = 0 AND i.type > 0 AND ic.is_included_column = 0
+		ORDER BY s.name, t.name, i.name, ic.key_ordinal;`
+	rows, err := txn.Query(query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	indexMap := make(map[string][]*indexSchema)
+	for rows.Next() {
+		var schemaName, tableName, column string
+		var idx indexSchema
+		if err := rows.Scan(
+			&schemaName,
+			&tableName,
+			&idx.name,
+			&idx.indexType,
+			&idx.unique,
+			&idx.primary,
+			&idx.disabled,
+			&column,
+		); err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("%s.%s", schemaName, tableName)
+		indices := indexMap[key]
+		if len(indices) > 0 && indices[len(indices)-1].name == idx.name {
+			last := indices[len(indices)-1]
+			last.columns = append(last.columns, column)
+			continue
+		}
+		idx.columns = []string{column}
+		indexMap[key] = append(indices, &idx)
+	}
+	return indexMap, rows.Err()
+}
+
+// viewSchema describes the schema of a view.
+type viewSchema struct {
+	schemaName string
+	name       string
+	createdTs  int64
+	updatedTs  int64
+	definition string
+}
+
+// getViews gets all views of a database.
+func getViews(txn *sql.Tx) ([]*viewSchema, error) {
+	query := `
+		SELECT
+			s.name,
+			v.name,
+			DATEDIFF(SECOND, '19700101', v.create_date),
+			DATEDIFF(SECOND, '19700101', v.modify_date),
+			ISNULL(OBJECT_DEFINITION(v.object_id), '')
+		FROM sys.views v
+		JOIN sys.schemas s ON v.schema_id = s.schema_id
+		WHERE v.is_ms_shipped = 0
+		ORDER BY s.name, v.name;`
+	rows, err := txn.Query(query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	var views []*viewSchema
+	for rows.Next() {
+		var view viewSchema
+		if err := rows.Scan(&view.schemaName, &view.name, &view.createdTs, &view.updatedTs, &view.definition); err != nil {
+			return nil, err
+		}
+		views = append(views, &view)
+	}
+	return views, rows.Err()
+}
+
+// getModules gets the definitions of views, functions, procedures and triggers in creation order.
+func getModules(txn *sql.Tx) ([]string, error) {
+	query := `
+		SELECT m.definition
+		FROM sys.sql_modules m
+		JOIN sys.objects o ON m.object_id = o.object_id
+		WHERE o.is_ms_shipped = 0 AND m.definition IS NOT NULL
+		ORDER BY o.create_date, o.object_id;`
+	rows, err := txn.Query(query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	var modules []string
+	for rows.Next() {
+		var definition string
+		if err := rows.Scan(&definition); err != nil {
+			return nil, err
+		}
+		modules = append(modules, definition)
+	}
+	return modules, rows.Err()
+}
+
+// exportTableData gets the data of a table.
+func exportTableData(txn *sql.Tx, tbl *tableSchema, out io.Writer) error {
+	tableName := fmt.Sprintf("%s.%s", quoteIdentifier(tbl.schemaName), quoteIdentifier(tbl.name))
+	query := fmt.Sprintf("SELECT * FROM %s;", tableName)
+	rows, err := txn.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	if len(cols) <= 0 {
+		return nil
+	}
+	values := make([]*sql.NullString, len(cols))
+	refs := make([]interface{}, len(cols))
+	for i := 0; i < len(cols); i++ {
+		refs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(refs...); err != nil {
+			return err
+		}
+		tokens := make([]string, len(cols))
+		for i, v := range values {
+			switch {
+			case v == nil || !v.Valid:
+				tokens[i] = "NULL"
+			case isNumeric(cols[i].DatabaseTypeName()):
+				tokens[i] = v.String
+			default:
+				tokens[i] = fmt.Sprintf("N'%s'", strings.ReplaceAll(v.String, "'", "''"))
+			}
+		}
+		stmt := fmt.Sprintf("INSERT INTO %s VALUES (%s);\n", tableName, strings.Join(tokens, ", "))
+		if _, err := io.WriteString(out, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(out, "\n"); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// isNumeric determines whether the value needs quotes.
+// Even if the function returns incorrect result, the data dump will still work.
+func isNumeric(t string) bool {
+	t = strings.ToUpper(t)
+	return strings.Contains(t, "INT") || t == "BIT" || t == "FLOAT" || t == "REAL" || t == "DECIMAL" || t == "NUMERIC" || strings.Contains(t, "MONEY")
+}
+
+func quoteIdentifier(s string) string {
+	return "[" + strings.ReplaceAll(s, "]", "]]") + "]"
+}
+
+func quoteIdentifiers(list []string) string {
+	var quoted []string
+	for _, s := range list {
+		quoted = append(quoted, quoteIdentifier(s))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func unquoteIdentifier(s string) string {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		return strings.ReplaceAll(s[1:len(s)-1], "]]", "]")
+	}
+	return strings.Trim(s, `"`)
+}
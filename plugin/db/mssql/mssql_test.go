@@ -0,0 +1,53 @@
+package mssql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNonTransactionalStatement(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want bool
+	}{
+		{stmt: "CREATE DATABASE db1;", want: true},
+		{stmt: "create database db1", want: true},
+		{stmt: "  \n\tALTER DATABASE db1 SET RECOVERY SIMPLE;", want: true},
+		{stmt: "DROP\tDATABASE db1;", want: true},
+		{stmt: "CREATE  FULLTEXT\n  CATALOG ftc;", want: true},
+		{stmt: "CREATE FULLTEXT INDEX ON t1(c1) KEY INDEX pk;", want: true},
+		{stmt: "-- Create the database.\nCREATE DATABASE db1;", want: true},
+		{stmt: "/* Back up. */ BACKUP DATABASE db1 TO DISK = 'db1.bak';", want: true},
+		{stmt: "RECONFIGURE;", want: true},
+		{stmt: "RESTORE DATABASE db1 FROM DISK = 'db1.bak';", want: true},
+		{stmt: "CREATE TABLE t1 (id INT);", want: false},
+		{stmt: "CREATE DATABASES_LOG (id INT);", want: false},
+		{stmt: "CREATE INDEX idx1 ON t1(c1);", want: false},
+		{stmt: "-- CREATE DATABASE db1;\nCREATE TABLE t1 (id INT);", want: false},
+		{stmt: "/* unterminated CREATE DATABASE db1;", want: false},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.want, isNonTransactionalStatement(test.stmt), test.stmt)
+	}
+}
+
+func TestUseStatementDatabase(t *testing.T) {
+	tests := []struct {
+		stmt   string
+		want   bool
+		dbName string
+	}{
+		{stmt: "USE db1;", want: true, dbName: "db1"},
+		{stmt: "use\t[my db];", want: true, dbName: "my db"},
+		{stmt: "-- Switch the database.\nUSE\n  db1 ;\n", want: true, dbName: "db1"},
+		{stmt: "/* db */ USE \"db1\"", want: true, dbName: "db1"},
+		{stmt: "USER_TABLE;", want: false},
+		{stmt: "UPDATE t1 SET c1 = 1;", want: false},
+	}
+	for _, test := range tests {
+		dbName, ok := useStatementDatabase(test.stmt)
+		require.Equal(t, test.want, ok, test.stmt)
+		require.Equal(t, test.dbName, dbName, test.stmt)
+	}
+}
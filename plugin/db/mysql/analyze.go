@@ -0,0 +1,270 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/bytebase/bytebase/plugin/db/util"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+)
+
+var (
+	_ db.StatementAnalyzer = (*Driver)(nil)
+)
+
+// serverVersion is the major, minor and patch version of a MySQL or MariaDB server.
+type serverVersion struct {
+	major, minor, patch int
+}
+
+// parseServerVersion parses the version such as "8.0.29", "5.7.38-log" and "10.6.7-MariaDB".
+// The missing or invalid parts are parsed as 0.
+func parseServerVersion(version string) serverVersion {
+	if i := strings.IndexFunc(version, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); i >= 0 {
+		version = version[:i]
+	}
+	var parts [3]int
+	for i, s := range strings.SplitN(version, ".", 3) {
+		parts[i], _ = strconv.Atoi(s)
+	}
+	return serverVersion{major: parts[0], minor: parts[1], patch: parts[2]}
+}
+
+func (v serverVersion) atLeast(major, minor, patch int) bool {
+	if v.major != major {
+		return v.major > major
+	}
+	if v.minor != minor {
+		return v.minor > minor
+	}
+	return v.patch >= patch
+}
+
+// ddlAnalyzer derives the algorithm of the ALTER TABLE specs from the server type and version.
+type ddlAnalyzer struct {
+	dbType  db.Type
+	version serverVersion
+}
+
+// supportsInstant returns whether the server supports the INSTANT algorithm for an operation
+// since the MySQL version or the MariaDB version. TiDB changes the metadata only for all these operations.
+func (a *ddlAnalyzer) supportsInstant(mysqlMinor, mysqlPatch, mariaDBMinor int) bool {
+	switch a.dbType {
+	case db.TiDB:
+		return true
+	case db.MariaDB:
+		return a.version.atLeast(10, mariaDBMinor, 0)
+	}
+	return a.version.atLeast(8, mysqlMinor, mysqlPatch)
+}
+
+// metadataOnly returns the algorithm of the operations only changing the metadata, which are INPLACE without a table rebuild
+// before the INSTANT algorithm is introduced.
+func (a *ddlAnalyzer) metadataOnly() (db.DDLAlgorithm, bool) {
+	if a.supportsInstant(0, 12, 3) {
+		return db.DDLAlgorithmInstant, true
+	}
+	return db.DDLAlgorithmInplace, true
+}
+
+// analyzeSpec returns the algorithm of the spec and whether it allows the concurrent DML.
+// The unknown specs are reported as COPY conservatively.
+func (a *ddlAnalyzer) analyzeSpec(spec *ast.AlterTableSpec) (db.DDLAlgorithm, bool) {
+	switch spec.Tp {
+	case ast.AlterTableAddColumns:
+		atEnd := spec.Position == nil || spec.Position.Tp == ast.ColumnPositionNone
+		if a.supportsInstant(0, 29, 4) || (atEnd && a.supportsInstant(0, 12, 3)) {
+			return db.DDLAlgorithmInstant, true
+		}
+		return db.DDLAlgorithmInplace, true
+	case ast.AlterTableDropColumn:
+		if a.supportsInstant(0, 29, 4) {
+			return db.DDLAlgorithmInstant, true
+		}
+		return db.DDLAlgorithmInplace, true
+	case ast.AlterTableRenameColumn:
+		if a.supportsInstant(0, 28, 3) {
+			return db.DDLAlgorithmInstant, true
+		}
+		return db.DDLAlgorithmInplace, true
+	case ast.AlterTableAlterColumn, ast.AlterTableRenameTable, ast.AlterTableRenameIndex, ast.AlterTableIndexInvisible:
+		return a.metadataOnly()
+	case ast.AlterTableAddConstraint:
+		switch spec.Constraint.Tp {
+		case ast.ConstraintKey, ast.ConstraintIndex, ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex, ast.ConstraintPrimaryKey:
+			return db.DDLAlgorithmInplace, true
+		case ast.ConstraintFulltext:
+			// Adding a FULLTEXT index takes a shared lock.
+			return db.DDLAlgorithmInplace, false
+		}
+		// Adding a foreign key is INPLACE only with foreign_key_checks disabled, and the CHECK constraint is always COPY.
+		return db.DDLAlgorithmCopy, false
+	case ast.AlterTableDropIndex, ast.AlterTableDropForeignKey:
+		return db.DDLAlgorithmInplace, true
+	case ast.AlterTableForce:
+		return db.DDLAlgorithmInplace, true
+	case ast.AlterTableOption:
+		algorithm, lockFree := a.metadataOnly()
+		for _, option := range spec.Options {
+			switch option.Tp {
+			case ast.TableOptionComment, ast.TableOptionAutoIncrement:
+			case ast.TableOptionEngine, ast.TableOptionRowFormat:
+				algorithm = db.DDLAlgorithmInplace
+			case ast.TableOptionCharset:
+				if option.UintValue == ast.TableOptionCharsetWithConvertTo {
+					return db.DDLAlgorithmCopy, false
+				}
+			default:
+				return db.DDLAlgorithmCopy, false
+			}
+		}
+		return algorithm, lockFree
+	}
+	// Changing the column type and dropping the primary key copy the table, and so do the partition operations.
+	return db.DDLAlgorithmCopy, false
+}
+
+// AnalyzeStatement analyzes a single statement without executing it.
+// For ALTER TABLE, the algorithm is the slowest one of its specs unless ALGORITHM is specified, and LOCK=SHARED or LOCK=EXCLUSIVE
+// disallows the concurrent DML. The estimated affected rows of a non-INSTANT DDL are the table rows from information_schema.TABLES,
+// and those of a DML statement are from EXPLAIN.
+// TiDB executes all the DDL online, so the DDL statements are always lock-free and never COPY.
+func (driver *Driver) AnalyzeStatement(ctx context.Context, statement string) (*db.StatementAnalysis, error) {
+	p := parser.New()
+	// To support MySQL8 window function syntax.
+	p.EnableWindowFunc(true)
+	nodeList, _, err := p.Parse(statement, "", "")
+	if err != nil {
+		return nil, err
+	}
+	if len(nodeList) != 1 {
+		return nil, fmt.Errorf("expecting a single statement to analyze, but got %d", len(nodeList))
+	}
+
+	node := nodeList[0]
+	analysis := &db.StatementAnalysis{
+		Statement:             strings.TrimSpace(node.Text()),
+		EstimatedAffectedRows: -1,
+	}
+	var table *ast.TableName
+	switch stmt := node.(type) {
+	case *ast.AlterTableStmt:
+		version, err := driver.GetVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+		analyzer := &ddlAnalyzer{dbType: driver.dbType, version: parseServerVersion(version)}
+		algorithm, lockFree := db.DDLAlgorithmInstant, true
+		explicitAlgorithm, lockType := ast.AlgorithmTypeDefault, ast.LockTypeDefault
+		for _, spec := range stmt.Specs {
+			switch spec.Tp {
+			case ast.AlterTableAlgorithm:
+				explicitAlgorithm = spec.Algorithm
+			case ast.AlterTableLock:
+				lockType = spec.LockType
+			default:
+				specAlgorithm, specLockFree := analyzer.analyzeSpec(spec)
+				algorithm = slowerDDLAlgorithm(algorithm, specAlgorithm)
+				lockFree = lockFree && specLockFree
+			}
+		}
+		analysis.Algorithm, analysis.LockFree = applyAlgorithmAndLock(algorithm, lockFree, explicitAlgorithm, lockType)
+		table = stmt.Table
+	case *ast.CreateIndexStmt:
+		analysis.Algorithm, analysis.LockFree = db.DDLAlgorithmInplace, stmt.KeyType != ast.IndexKeyTypeFullText && stmt.KeyType != ast.IndexKeyTypeSpatial
+		if stmt.LockAlg != nil {
+			analysis.Algorithm, analysis.LockFree = applyAlgorithmAndLock(analysis.Algorithm, analysis.LockFree, stmt.LockAlg.AlgorithmTp, stmt.LockAlg.LockTp)
+		}
+		table = stmt.Table
+	case *ast.DropIndexStmt:
+		analysis.Algorithm, analysis.LockFree = db.DDLAlgorithmInplace, true
+		if stmt.LockAlg != nil {
+			analysis.Algorithm, analysis.LockFree = applyAlgorithmAndLock(analysis.Algorithm, analysis.LockFree, stmt.LockAlg.AlgorithmTp, stmt.LockAlg.LockTp)
+		}
+		table = stmt.Table
+	case *ast.InsertStmt, *ast.UpdateStmt, *ast.DeleteStmt:
+		rows, err := driver.explainRows(ctx, analysis.Statement)
+		if err != nil {
+			return nil, err
+		}
+		analysis.LockFree = true
+		analysis.EstimatedAffectedRows = rows
+		return analysis, nil
+	case *ast.CreateDatabaseStmt, *ast.CreateTableStmt, *ast.CreateViewStmt:
+		analysis.LockFree = true
+		analysis.EstimatedAffectedRows = 0
+		return analysis, nil
+	default:
+		// The other DDL statements such as DROP TABLE and TRUNCATE TABLE take an exclusive metadata lock.
+		_, isDDL := node.(ast.DDLNode)
+		analysis.LockFree = !isDDL
+		return analysis, nil
+	}
+
+	if driver.dbType == db.TiDB {
+		analysis.LockFree = true
+		if analysis.Algorithm == db.DDLAlgorithmCopy {
+			analysis.Algorithm = db.DDLAlgorithmInplace
+		}
+	}
+	if analysis.Algorithm == db.DDLAlgorithmInstant {
+		analysis.EstimatedAffectedRows = 0
+		return analysis, nil
+	}
+	rows, err := driver.getTableRows(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	analysis.EstimatedAffectedRows = rows
+	return analysis, nil
+}
+
+// slowerDDLAlgorithm returns the slower one of the algorithms, where COPY is slower than INPLACE, and INPLACE is slower than INSTANT.
+func slowerDDLAlgorithm(a, b db.DDLAlgorithm) db.DDLAlgorithm {
+	rank := map[db.DDLAlgorithm]int{
+		db.DDLAlgorithmInstant: 0,
+		db.DDLAlgorithmInplace: 1,
+		db.DDLAlgorithmCopy:    2,
+	}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// applyAlgorithmAndLock applies the ALGORITHM and LOCK clauses to the detected algorithm and lock-freedom.
+// The server rejects the statement if the requested algorithm or lock isn't supported, so the requested ones are reported as is.
+func applyAlgorithmAndLock(algorithm db.DDLAlgorithm, lockFree bool, explicitAlgorithm ast.AlgorithmType, lockType ast.LockType) (db.DDLAlgorithm, bool) {
+	switch explicitAlgorithm {
+	case ast.AlgorithmTypeInstant:
+		algorithm = db.DDLAlgorithmInstant
+	case ast.AlgorithmTypeInplace:
+		algorithm = db.DDLAlgorithmInplace
+	case ast.AlgorithmTypeCopy:
+		algorithm, lockFree = db.DDLAlgorithmCopy, false
+	}
+	switch lockType {
+	case ast.LockTypeShared, ast.LockTypeExclusive:
+		lockFree = false
+	}
+	return algorithm, lockFree
+}
+
+// getTableRows returns the estimated rows of the table from information_schema.TABLES, or -1 if the table isn't found.
+// The table without a database is looked up in the current database.
+func (driver *Driver) getTableRows(ctx context.Context, table *ast.TableName) (int64, error) {
+	query := "SELECT IFNULL(TABLE_ROWS, 0) FROM information_schema.TABLES WHERE TABLE_SCHEMA = IFNULL(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?"
+	var rows int64
+	if err := driver.db.QueryRowContext(ctx, query, table.Schema.O, table.Name.O).Scan(&rows); err != nil {
+		if err == sql.ErrNoRows {
+			return -1, nil
+		}
+		return 0, util.FormatErrorWithQuery(err, query)
+	}
+	return rows, nil
+}
@@ -0,0 +1,145 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    serverVersion
+	}{
+		{version: "8.0.29", want: serverVersion{major: 8, minor: 0, patch: 29}},
+		{version: "5.7.38-log", want: serverVersion{major: 5, minor: 7, patch: 38}},
+		{version: "10.6.7-MariaDB", want: serverVersion{major: 10, minor: 6, patch: 7}},
+		{version: "5.7.25-TiDB-v6.1.0", want: serverVersion{major: 5, minor: 7, patch: 25}},
+		{version: "10.4", want: serverVersion{major: 10, minor: 4}},
+		{version: "8", want: serverVersion{major: 8}},
+		{version: "", want: serverVersion{}},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.want, parseServerVersion(test.version), test.version)
+	}
+}
+
+func TestServerVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version             string
+		major, minor, patch int
+		want                bool
+	}{
+		{version: "8.0.29", major: 8, minor: 0, patch: 29, want: true},
+		{version: "8.0.29", major: 8, minor: 0, patch: 30, want: false},
+		{version: "8.0.12", major: 8, minor: 0, patch: 29, want: false},
+		{version: "8.1.0", major: 8, minor: 0, patch: 29, want: true},
+		{version: "5.7.38", major: 8, minor: 0, patch: 12, want: false},
+		{version: "10.4.0", major: 10, minor: 3, patch: 0, want: true},
+		{version: "10.3.9", major: 10, minor: 4, patch: 0, want: false},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.want, parseServerVersion(test.version).atLeast(test.major, test.minor, test.patch), "%s >= %d.%d.%d", test.version, test.major, test.minor, test.patch)
+	}
+}
+
+func TestAnalyzeSpec(t *testing.T) {
+	mysql57 := &ddlAnalyzer{dbType: db.MySQL, version: parseServerVersion("5.7.38")}
+	mysql8012 := &ddlAnalyzer{dbType: db.MySQL, version: parseServerVersion("8.0.12")}
+	mysql8029 := &ddlAnalyzer{dbType: db.MySQL, version: parseServerVersion("8.0.29")}
+	mariaDB103 := &ddlAnalyzer{dbType: db.MariaDB, version: parseServerVersion("10.3.36-MariaDB")}
+	mariaDB104 := &ddlAnalyzer{dbType: db.MariaDB, version: parseServerVersion("10.4.26-MariaDB")}
+	tidb := &ddlAnalyzer{dbType: db.TiDB, version: parseServerVersion("5.7.25-TiDB-v6.1.0")}
+
+	tests := []struct {
+		analyzer  *ddlAnalyzer
+		statement string
+		algorithm db.DDLAlgorithm
+		lockFree  bool
+	}{
+		{mysql57, "ALTER TABLE t ADD COLUMN c INT", db.DDLAlgorithmInplace, true},
+		{mysql8012, "ALTER TABLE t ADD COLUMN c INT", db.DDLAlgorithmInstant, true},
+		{mysql8012, "ALTER TABLE t ADD COLUMN c INT FIRST", db.DDLAlgorithmInplace, true},
+		{mysql8029, "ALTER TABLE t ADD COLUMN c INT FIRST", db.DDLAlgorithmInstant, true},
+		{mariaDB103, "ALTER TABLE t ADD COLUMN c INT", db.DDLAlgorithmInstant, true},
+		{mariaDB103, "ALTER TABLE t ADD COLUMN c INT AFTER id", db.DDLAlgorithmInplace, true},
+		{mariaDB104, "ALTER TABLE t ADD COLUMN c INT AFTER id", db.DDLAlgorithmInstant, true},
+		{tidb, "ALTER TABLE t ADD COLUMN c INT FIRST", db.DDLAlgorithmInstant, true},
+
+		{mysql8012, "ALTER TABLE t DROP COLUMN c", db.DDLAlgorithmInplace, true},
+		{mysql8029, "ALTER TABLE t DROP COLUMN c", db.DDLAlgorithmInstant, true},
+		{mariaDB103, "ALTER TABLE t DROP COLUMN c", db.DDLAlgorithmInplace, true},
+		{mariaDB104, "ALTER TABLE t DROP COLUMN c", db.DDLAlgorithmInstant, true},
+
+		{mysql8012, "ALTER TABLE t RENAME COLUMN a TO b", db.DDLAlgorithmInplace, true},
+		{mysql8029, "ALTER TABLE t RENAME COLUMN a TO b", db.DDLAlgorithmInstant, true},
+		{mariaDB103, "ALTER TABLE t RENAME COLUMN a TO b", db.DDLAlgorithmInstant, true},
+
+		{mysql57, "ALTER TABLE t RENAME INDEX a TO b", db.DDLAlgorithmInplace, true},
+		{mysql8012, "ALTER TABLE t RENAME INDEX a TO b", db.DDLAlgorithmInstant, true},
+		{mysql57, "ALTER TABLE t COMMENT = 'x'", db.DDLAlgorithmInplace, true},
+		{mariaDB103, "ALTER TABLE t COMMENT = 'x'", db.DDLAlgorithmInstant, true},
+		{mysql8029, "ALTER TABLE t ENGINE = InnoDB", db.DDLAlgorithmInplace, true},
+		{mysql8029, "ALTER TABLE t CONVERT TO CHARACTER SET utf8mb4", db.DDLAlgorithmCopy, false},
+
+		{mysql8029, "ALTER TABLE t ADD INDEX idx_c (c)", db.DDLAlgorithmInplace, true},
+		{mysql8029, "ALTER TABLE t ADD FULLTEXT INDEX idx_c (c)", db.DDLAlgorithmInplace, false},
+		{mysql8029, "ALTER TABLE t ADD CONSTRAINT fk FOREIGN KEY (c) REFERENCES t2 (id)", db.DDLAlgorithmCopy, false},
+		{mysql8029, "ALTER TABLE t DROP INDEX idx_c", db.DDLAlgorithmInplace, true},
+		{mysql8029, "ALTER TABLE t MODIFY COLUMN c BIGINT", db.DDLAlgorithmCopy, false},
+		{tidb, "ALTER TABLE t MODIFY COLUMN c BIGINT", db.DDLAlgorithmCopy, false},
+	}
+	for _, test := range tests {
+		node, err := parser.New().ParseOneStmt(test.statement, "", "")
+		require.NoError(t, err, test.statement)
+		alter, ok := node.(*ast.AlterTableStmt)
+		require.True(t, ok, test.statement)
+		require.Len(t, alter.Specs, 1, test.statement)
+
+		algorithm, lockFree := test.analyzer.analyzeSpec(alter.Specs[0])
+		require.Equal(t, test.algorithm, algorithm, "%s %v: %s", test.analyzer.dbType, test.analyzer.version, test.statement)
+		require.Equal(t, test.lockFree, lockFree, "%s %v: %s", test.analyzer.dbType, test.analyzer.version, test.statement)
+	}
+}
+
+func TestSlowerDDLAlgorithm(t *testing.T) {
+	tests := []struct {
+		a, b db.DDLAlgorithm
+		want db.DDLAlgorithm
+	}{
+		{db.DDLAlgorithmInstant, db.DDLAlgorithmInstant, db.DDLAlgorithmInstant},
+		{db.DDLAlgorithmInstant, db.DDLAlgorithmInplace, db.DDLAlgorithmInplace},
+		{db.DDLAlgorithmInplace, db.DDLAlgorithmInstant, db.DDLAlgorithmInplace},
+		{db.DDLAlgorithmCopy, db.DDLAlgorithmInplace, db.DDLAlgorithmCopy},
+		{db.DDLAlgorithmInplace, db.DDLAlgorithmCopy, db.DDLAlgorithmCopy},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.want, slowerDDLAlgorithm(test.a, test.b), "%s %s", test.a, test.b)
+	}
+}
+
+func TestApplyAlgorithmAndLock(t *testing.T) {
+	tests := []struct {
+		algorithm         db.DDLAlgorithm
+		lockFree          bool
+		explicitAlgorithm ast.AlgorithmType
+		lockType          ast.LockType
+		wantAlgorithm     db.DDLAlgorithm
+		wantLockFree      bool
+	}{
+		{db.DDLAlgorithmInstant, true, ast.AlgorithmTypeDefault, ast.LockTypeDefault, db.DDLAlgorithmInstant, true},
+		{db.DDLAlgorithmInstant, true, ast.AlgorithmTypeInplace, ast.LockTypeNone, db.DDLAlgorithmInplace, true},
+		{db.DDLAlgorithmInplace, true, ast.AlgorithmTypeCopy, ast.LockTypeDefault, db.DDLAlgorithmCopy, false},
+		{db.DDLAlgorithmCopy, false, ast.AlgorithmTypeInstant, ast.LockTypeDefault, db.DDLAlgorithmInstant, false},
+		{db.DDLAlgorithmInplace, true, ast.AlgorithmTypeDefault, ast.LockTypeShared, db.DDLAlgorithmInplace, false},
+		{db.DDLAlgorithmInstant, true, ast.AlgorithmTypeDefault, ast.LockTypeExclusive, db.DDLAlgorithmInstant, false},
+	}
+	for i, test := range tests {
+		algorithm, lockFree := applyAlgorithmAndLock(test.algorithm, test.lockFree, test.explicitAlgorithm, test.lockType)
+		require.Equal(t, test.wantAlgorithm, algorithm, "case %d", i)
+		require.Equal(t, test.wantLockFree, lockFree, "case %d", i)
+	}
+}
@@ -0,0 +1,23 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/bytebase/bytebase/plugin/db/util"
+)
+
+var (
+	_ db.RowCounter = (*Driver)(nil)
+)
+
+// CountRows returns the exact row count of the table in the database.
+func (driver *Driver) CountRows(ctx context.Context, schema string, table string) (int64, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", quoteIdentifier(schema), quoteIdentifier(table))
+	var count int64
+	if err := driver.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, util.FormatErrorWithQuery(err, query)
+	}
+	return count, nil
+}
@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/bytebase/bytebase/plugin/db/util"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+
+	// Register pingcap parser driver.
+	_ "github.com/pingcap/tidb/types/parser_driver"
+)
+
+var (
+	_ db.DryRunner = (*Driver)(nil)
+)
+
+// DryRun validates the statement without executing it.
+// The statement is parsed to check the syntax. Since MySQL commits DDL implicitly and it can't be rolled back,
+// the DDL statements are only checked for the syntax. The DML statements are further validated against the current
+// schema by EXPLAIN, which also estimates the affected rows. As a result, a DML statement depending on the objects
+// created by the earlier DDL in the same statement is reported as invalid.
+func (driver *Driver) DryRun(ctx context.Context, statement string) (*db.DryRunResult, error) {
+	p := parser.New()
+	// To support MySQL8 window function syntax.
+	p.EnableWindowFunc(true)
+	nodeList, _, err := p.Parse(statement, "", "")
+	if err != nil {
+		return &db.DryRunResult{
+			StatementResultList: []db.DryRunStatementResult{
+				{
+					Statement:             statement,
+					Error:                 err.Error(),
+					EstimatedAffectedRows: -1,
+				},
+			},
+		}, nil
+	}
+
+	result := &db.DryRunResult{}
+	for _, node := range nodeList {
+		stmtResult := db.DryRunStatementResult{
+			Statement:             strings.TrimSpace(node.Text()),
+			EstimatedAffectedRows: -1,
+		}
+		switch node.(type) {
+		case *ast.InsertStmt, *ast.UpdateStmt, *ast.DeleteStmt:
+			rows, err := driver.explainRows(ctx, stmtResult.Statement)
+			if err != nil {
+				stmtResult.Error = err.Error()
+			} else {
+				stmtResult.EstimatedAffectedRows = rows
+			}
+		}
+		result.StatementResultList = append(result.StatementResultList, stmtResult)
+	}
+	return result, nil
+}
+
+// explainRows returns the estimated rows of the statement from EXPLAIN.
+// MySQL reports the estimated rows in the "rows" column and TiDB reports them in the "estRows" column.
+// For MySQL, the rows of the first table is returned, which is the table modified by the DML statement.
+// For TiDB, the rows of the root operator is returned.
+func (driver *Driver) explainRows(ctx context.Context, statement string) (int64, error) {
+	query := "EXPLAIN " + statement
+	rows, err := driver.db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	rowsIndex := -1
+	for i, column := range columns {
+		if strings.EqualFold(column, "rows") || strings.EqualFold(column, "estRows") {
+			rowsIndex = i
+			break
+		}
+	}
+
+	values := make([]sql.NullString, len(columns))
+	refs := make([]interface{}, len(columns))
+	for i := range values {
+		refs[i] = &values[i]
+	}
+	var estimatedRows int64
+	if rows.Next() {
+		if err := rows.Scan(refs...); err != nil {
+			return 0, err
+		}
+		if rowsIndex >= 0 && values[rowsIndex].Valid {
+			// TiDB reports the estimated rows as a float.
+			if v, err := strconv.ParseFloat(values[rowsIndex].String, 64); err == nil {
+				estimatedRows = int64(v)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return estimatedRows, nil
+}
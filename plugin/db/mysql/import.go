@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	_ db.BulkImporter = (*Driver)(nil)
+
+	// importReaderID is the sequence to name the reader handlers of the concurrent imports uniquely.
+	importReaderID int64
+)
+
+// BulkImport imports the rows into the table by LOAD DATA LOCAL INFILE.
+func (driver *Driver) BulkImport(ctx context.Context, table string, columns []string, rows io.Reader, format db.ImportFormat) error {
+	name := fmt.Sprintf("bytebase_import_%d", atomic.AddInt64(&importReaderID, 1))
+	query, err := loadDataStatement(name, table, columns, format)
+	if err != nil {
+		return err
+	}
+
+	mysql.RegisterReaderHandler(name, func() io.Reader {
+		return rows
+	})
+	defer mysql.DeregisterReaderHandler(name)
+
+	ctx, cancel := driver.withStatementTimeout(ctx)
+	defer cancel()
+	if _, err := driver.db.ExecContext(ctx, query); err != nil {
+		return driver.convertStatementTimeoutError(ctx, fmt.Errorf("failed to import rows into table %q, error: %w", table, err))
+	}
+	return nil
+}
+
+// loadDataStatement returns the LOAD DATA statement importing the rows of the reader handler into the columns of the table,
+// which can be qualified as "database.table".
+func loadDataStatement(readerName string, table string, columns []string, format db.ImportFormat) (string, error) {
+	fields, err := loadDataFieldsClause(format)
+	if err != nil {
+		return "", err
+	}
+
+	quotedTable := quoteIdentifier(table)
+	if i := strings.Index(table, "."); i >= 0 {
+		quotedTable = quoteIdentifier(table[:i]) + "." + quoteIdentifier(table[i+1:])
+	}
+	var quotedColumns []string
+	for _, column := range columns {
+		quotedColumns = append(quotedColumns, quoteIdentifier(column))
+	}
+	return fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s CHARACTER SET utf8mb4 %s LINES TERMINATED BY '\\n' (%s)",
+		readerName, quotedTable, fields, strings.Join(quotedColumns, ", ")), nil
+}
+
+// loadDataFieldsClause returns the FIELDS clause of LOAD DATA for the format.
+func loadDataFieldsClause(format db.ImportFormat) (string, error) {
+	switch format {
+	case db.ImportFormatCSV:
+		// Disable the backslash escape, so the values are imported as is except the doubled double quote.
+		return `FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"' ESCAPED BY ''`, nil
+	case db.ImportFormatTSV:
+		// The default field and line handling of LOAD DATA is the TSV format.
+		return `FIELDS TERMINATED BY '\t' ESCAPED BY '\\'`, nil
+	default:
+		return "", fmt.Errorf("unsupported import format %q", format)
+	}
+}
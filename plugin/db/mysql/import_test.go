@@ -0,0 +1,47 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDataStatement(t *testing.T) {
+	tests := []struct {
+		table   string
+		columns []string
+		format  db.ImportFormat
+		want    string
+		wantErr bool
+	}{
+		{
+			table:   "t1",
+			columns: []string{"id", "name"},
+			format:  db.ImportFormatCSV,
+			want:    "LOAD DATA LOCAL INFILE 'Reader::r1' INTO TABLE `t1` CHARACTER SET utf8mb4 FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '\"' ESCAPED BY '' LINES TERMINATED BY '\\n' (`id`, `name`)",
+		},
+		{
+			table:   "db1.t1",
+			columns: []string{"id"},
+			format:  db.ImportFormatTSV,
+			want:    "LOAD DATA LOCAL INFILE 'Reader::r1' INTO TABLE `db1`.`t1` CHARACTER SET utf8mb4 FIELDS TERMINATED BY '\\t' ESCAPED BY '\\\\' LINES TERMINATED BY '\\n' (`id`)",
+		},
+		{
+			table:   "db`1.t`1",
+			columns: []string{"c`1"},
+			format:  db.ImportFormatCSV,
+			want:    "LOAD DATA LOCAL INFILE 'Reader::r1' INTO TABLE `db``1`.`t``1` CHARACTER SET utf8mb4 FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '\"' ESCAPED BY '' LINES TERMINATED BY '\\n' (`c``1`)",
+		},
+		{table: "t1", columns: []string{"id"}, format: "JSON", wantErr: true},
+	}
+	for _, test := range tests {
+		query, err := loadDataStatement("r1", test.table, test.columns, test.format)
+		if test.wantErr {
+			require.Error(t, err, test.table)
+			continue
+		}
+		require.NoError(t, err, test.table)
+		require.Equal(t, test.want, query, test.table)
+	}
+}
@@ -0,0 +1,71 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"go.uber.org/zap"
+)
+
+var (
+	_ db.MigrationLocker = (*Driver)(nil)
+)
+
+// maxLockNameLength is the maximum length of a lock name of GET_LOCK.
+const maxLockNameLength = 64
+
+// migrationLockName returns the GET_LOCK name of the namespace, and the long namespace is hashed to fit the lock name length.
+func migrationLockName(namespace string) string {
+	name := "bytebase.migration." + namespace
+	if len(name) <= maxLockNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(namespace))
+	return "bytebase.migration." + hex.EncodeToString(sum[:])[:maxLockNameLength-len("bytebase.migration.")]
+}
+
+// WithMigrationLock calls fn holding the migration lock of the namespace, which is a GET_LOCK lock on a dedicated connection.
+// The lock is server-wide and released by RELEASE_LOCK, or by the server when the connection is closed.
+func (driver *Driver) WithMigrationLock(ctx context.Context, namespace string, fn func(ctx context.Context) error) error {
+	// GET_LOCK waits forever for a negative timeout, and fails immediately for zero.
+	timeoutSeconds := -1
+	switch timeout := driver.driverConfig.MigrationLockTimeout; {
+	case timeout < 0:
+		timeoutSeconds = 0
+	case timeout > 0:
+		timeoutSeconds = int(math.Ceil(timeout.Seconds()))
+	}
+
+	conn, err := driver.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	name := migrationLockName(namespace)
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, timeoutSeconds).Scan(&acquired); err != nil {
+		return fmt.Errorf("failed to acquire the migration lock of namespace %q, error: %w", namespace, err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return fmt.Errorf("%w, namespace %q is being migrated by another runner", db.ErrMigrationLocked, namespace)
+	}
+	defer func() {
+		// Use a new context since ctx may have been canceled.
+		if _, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name); err != nil {
+			driver.l.Warn("Failed to release the migration lock, discarding the connection", zap.String("namespace", namespace), zap.Error(err))
+			// Closing the connection releases the lock.
+			conn.Raw(func(interface{}) error {
+				return sqldriver.ErrBadConn
+			})
+		}
+	}()
+
+	return fn(ctx)
+}
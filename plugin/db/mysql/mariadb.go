@@ -0,0 +1,43 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/bytebase/bytebase/plugin/db/util"
+)
+
+// getMariaDBSequenceMap gets the MariaDB sequences of the sequence names keyed by the database name.
+// MariaDB stores each sequence as a table with a single row, and lists it in information_schema.TABLES as a SEQUENCE,
+// so we read the sequence options from the sequence table itself.
+func getMariaDBSequenceMap(ctx context.Context, sqldb *sql.DB, sequenceNameMap map[string][]string) (map[string][]db.Sequence, error) {
+	sequenceMap := make(map[string][]db.Sequence)
+	for dbName, sequenceNameList := range sequenceNameMap {
+		for _, name := range sequenceNameList {
+			query := fmt.Sprintf(`
+				SELECT
+					start_value,
+					minimum_value,
+					maximum_value,
+					increment,
+					cache_size,
+					cycle_option
+				FROM %s.%s`, quoteIdentifier(dbName), quoteIdentifier(name))
+			sequence := db.Sequence{Name: name}
+			if err := sqldb.QueryRowContext(ctx, query).Scan(
+				&sequence.StartValue,
+				&sequence.MinValue,
+				&sequence.MaxValue,
+				&sequence.Increment,
+				&sequence.CacheSize,
+				&sequence.Cycle,
+			); err != nil {
+				return nil, util.FormatErrorWithQuery(err, query)
+			}
+			sequenceMap[dbName] = append(sequenceMap[dbName], sequence)
+		}
+	}
+	return sequenceMap, nil
+}
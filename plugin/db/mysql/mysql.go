@@ -4,10 +4,17 @@ import (
 	"bufio"
 	"context"
 	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
 
 	// embed will embeds the migration schema.
 	_ "embed"
@@ -35,46 +42,125 @@ var (
 	viewTableType        = "VIEW"
 	excludeAutoIncrement = regexp.MustCompile(`AUTO_INCREMENT=\d+ `)
 
+	// MariaDB only
+	systemVersionedTableType = "SYSTEM VERSIONED"
+	sequenceTableType        = "SEQUENCE"
+
+	// tlsConfigID is the sequence to name the TLS configs of the concurrent opens uniquely.
+	tlsConfigID int64
+
 	_ db.Driver              = (*Driver)(nil)
 	_ util.MigrationExecutor = (*Driver)(nil)
+
+	_ db.StatementListExecutor   = (*Driver)(nil)
+	_ db.IncrementalSchemaSyncer = (*Driver)(nil)
+	_ db.SchemaStreamer          = (*Driver)(nil)
+	_ db.ServerInfoProvider      = (*Driver)(nil)
+	_ db.RoleExecutor            = (*Driver)(nil)
+)
+
+// MySQL error numbers, see https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	erDBAccessDenied          = 1044
+	erAccessDenied            = 1045
+	erBadDB                   = 1049
+	erTableAccessDenied       = 1142
+	erNoSuchTable             = 1146
+	erQueryTimeout            = 3024
+	erSecureTransportRequired = 3159
 )
 
 func init() {
 	db.Register(db.MySQL, newDriver)
 	db.Register(db.TiDB, newDriver)
+	db.Register(db.MariaDB, newDriver)
 }
 
 // Driver is the MySQL driver.
+// After Open, a Driver is safe for concurrent use by multiple goroutines, except that Close must not be called concurrently with the other methods.
+// The connection pools and the prepared statement cache are safe for concurrent use, and mu guards the rest of the state changed after Open.
 type Driver struct {
 	l             *zap.Logger
+	driverConfig  db.DriverConfig
 	connectionCtx db.ConnectionContext
 	dbType        db.Type
+	config        db.ConnectionConfig
 
 	db *sql.DB
+
+	mu sync.Mutex
+	// replicaDB is the connection to the read replica, which is opened on first use.
+	replicaDB *sql.DB
+	// migrationSetup caches whether the migration schema has been set up by this driver instance.
+	migrationSetup bool
+
+	// stmtCache caches the prepared statements of ExecutePrepared.
+	stmtCache *stmtCache
 }
 
 func newDriver(config db.DriverConfig) db.Driver {
 	return &Driver{
-		l: config.Logger,
+		l:            config.Logger,
+		driverConfig: config,
+		stmtCache:    newStmtCache(stmtCacheSize),
 	}
 }
 
 // Open opens a MySQL driver.
 func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.ConnectionConfig, connCtx db.ConnectionContext) (db.Driver, error) {
-	protocol := "tcp"
-	if strings.HasPrefix(config.Host, "/") {
-		protocol = "unix"
+	if config.Socket != "" && ((config.Host != "" && config.Host != "localhost") || config.Port != "") {
+		return nil, fmt.Errorf("socket %q can't be used together with host %q and port %q, the host should be empty or localhost and the port should be empty", config.Socket, config.Host, config.Port)
 	}
+	driver.dbType = dbType
+	driver.config = config
+	driver.connectionCtx = connCtx
 
-	params := []string{"multiStatements=true"}
+	protocol, address := "tcp", driver.getTCPAddress(config.Host, config.Port)
+	if config.Socket != "" {
+		protocol, address = "unix", config.Socket
+	} else if strings.HasPrefix(config.Host, "/") {
+		// The socket path can also be passed as the host.
+		protocol, address = "unix", config.Host
+	}
+	db, err := driver.openDB(protocol, address)
+	if err != nil {
+		return nil, err
+	}
+	driver.db = db
+
+	return driver, nil
+}
 
-	port := config.Port
+// getTCPAddress returns the TCP address of the host and port, the port defaults to the MySQL or TiDB default port.
+func (driver *Driver) getTCPAddress(host, port string) string {
 	if port == "" {
 		port = "3306"
-		if dbType == db.TiDB {
+		if driver.dbType == db.TiDB {
 			port = "4000"
 		}
 	}
+	return net.JoinHostPort(host, port)
+}
+
+// openDB opens a connection to the given address with the rest of the connection config.
+// The protocol is either "tcp" or "unix".
+func (driver *Driver) openDB(protocol, address string) (*sql.DB, error) {
+	config := driver.config
+	params := []string{"multiStatements=true"}
+	// MariaDB uses max_statement_time instead, which also interrupts the writes, so we only rely on the context deadline.
+	if timeout := driver.driverConfig.StatementTimeout; timeout > 0 && driver.dbType != db.MariaDB {
+		params = append(params, fmt.Sprintf("max_execution_time=%d", timeout.Milliseconds()))
+	}
+	if config.Charset != "" {
+		params = append(params, fmt.Sprintf("charset=%s", url.QueryEscape(config.Charset)))
+	}
+	if config.Collation != "" {
+		params = append(params, fmt.Sprintf("collation=%s", url.QueryEscape(config.Collation)))
+	}
+	// The driver sets the unknown parameters as the session variables on each new connection, and the value should be quoted.
+	if config.SQLMode != "" {
+		params = append(params, fmt.Sprintf("sql_mode=%s", url.QueryEscape("'"+strings.ReplaceAll(config.SQLMode, "'", "''")+"'")))
+	}
 
 	tlsConfig, err := config.TLSConfig.GetSslConfig()
 
@@ -82,44 +168,116 @@ func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.Connec
 		return nil, fmt.Errorf("sql: tls config error: %v", err)
 	}
 
-	loggedDSN := fmt.Sprintf("%s:<<redacted password>>@%s(%s:%s)/%s?%s", config.Username, protocol, config.Host, port, config.Database, strings.Join(params, "&"))
-	dsn := fmt.Sprintf("%s@%s(%s:%s)/%s?%s", config.Username, protocol, config.Host, port, config.Database, strings.Join(params, "&"))
+	loggedDSN := fmt.Sprintf("%s:<<redacted password>>@%s(%s)/%s?%s", config.Username, protocol, address, config.Database, strings.Join(params, "&"))
+	dsn := fmt.Sprintf("%s@%s(%s)/%s?%s", config.Username, protocol, address, config.Database, strings.Join(params, "&"))
 	if config.Password != "" {
-		dsn = fmt.Sprintf("%s:%s@%s(%s:%s)/%s?%s", config.Username, config.Password, protocol, config.Host, port, config.Database, strings.Join(params, "&"))
+		dsn = fmt.Sprintf("%s:%s@%s(%s)/%s?%s", config.Username, config.Password, protocol, address, config.Database, strings.Join(params, "&"))
 	}
-	tlsKey := "db.mysql.tls"
+	// The TLS config is registered globally, so each open uses a unique key not to race with the concurrent opens.
+	tlsKey := fmt.Sprintf("db.mysql.tls.%d", atomic.AddInt64(&tlsConfigID, 1))
 	if tlsConfig != nil {
 		if err := mysql.RegisterTLSConfig(tlsKey, tlsConfig); err != nil {
 			return nil, fmt.Errorf("sql: failed to register tls config: %v", err)
 		}
 		// TLS config is only used during sql.Open, so should be safe to deregister afterwards.
 		defer mysql.DeregisterTLSConfig(tlsKey)
-		dsn += fmt.Sprintf("?tls=%s", tlsKey)
+		// The DSN already has parameters, so the tls parameter is appended with "&".
+		dsn += fmt.Sprintf("&tls=%s", tlsKey)
 	}
 	driver.l.Debug("Opening MySQL driver",
 		zap.String("dsn", loggedDSN),
-		zap.String("environment", connCtx.EnvironmentName),
-		zap.String("database", connCtx.InstanceName),
+		zap.String("environment", driver.connectionCtx.EnvironmentName),
+		zap.String("database", driver.connectionCtx.InstanceName),
 	)
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		panic(err)
 	}
-	driver.dbType = dbType
-	driver.db = db
-	driver.connectionCtx = connCtx
+	driver.driverConfig.ApplyConnectionPool(db)
+	return db, nil
+}
 
-	return driver, nil
+// getReadOnlyDB returns the connection to the read replica if it's configured, otherwise the connection to the primary.
+func (driver *Driver) getReadOnlyDB() (*sql.DB, error) {
+	if driver.config.ReadReplicaHost == "" {
+		return driver.db, nil
+	}
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	if driver.replicaDB == nil {
+		replicaDB, err := driver.openDB("tcp", driver.getTCPAddress(driver.config.ReadReplicaHost, driver.config.ReadReplicaPort))
+		if err != nil {
+			return nil, err
+		}
+		driver.replicaDB = replicaDB
+	}
+	return driver.replicaDB, nil
 }
 
 // Close closes the driver.
 func (driver *Driver) Close(ctx context.Context) error {
-	return driver.db.Close()
+	driver.stmtCache.close()
+	driver.mu.Lock()
+	driver.migrationSetup = false
+	replicaDB := driver.replicaDB
+	driver.replicaDB = nil
+	driver.mu.Unlock()
+	// Both pools are closed even if closing the replica fails, and the first error is returned.
+	var err error
+	if replicaDB != nil {
+		err = replicaDB.Close()
+	}
+	if driver.db != nil {
+		if closeErr := driver.db.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
 // Ping pings the database.
 func (driver *Driver) Ping(ctx context.Context) error {
-	return driver.db.PingContext(ctx)
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Ping", driver.dbType, nil)
+	err := convertConnectionError(driver.db.PingContext(ctx))
+	span.End(err)
+	return err
+}
+
+// Stats returns the statistics of the connection pool to the primary, excluding the read replica.
+func (driver *Driver) Stats() sql.DBStats {
+	if driver.db == nil {
+		return sql.DBStats{}
+	}
+	return driver.db.Stats()
+}
+
+// convertConnectionError converts the error of connecting to the database into a *db.ConnectionError if it's a known kind.
+func convertConnectionError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case erAccessDenied:
+			return &db.ConnectionError{Kind: db.ErrAuthFailed, Err: err}
+		case erBadDB:
+			return &db.ConnectionError{Kind: db.ErrDatabaseNotFound, Err: err}
+		case erSecureTransportRequired:
+			return &db.ConnectionError{Kind: db.ErrTLSRequired, Err: err}
+		}
+		return err
+	}
+	// The dial errors, e.g. the connection is refused, the host is unreachable or can't be resolved.
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return &db.ConnectionError{Kind: db.ErrConnRefused, Err: err}
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return &db.ConnectionError{Kind: db.ErrConnRefused, Err: err}
+	}
+	return err
 }
 
 // GetDbConnection gets a database connection.
@@ -144,33 +302,140 @@ func (driver *Driver) GetVersion(ctx context.Context) (string, error) {
 	return version, nil
 }
 
-// SyncSchema syncs the schema.
-func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema, error) {
+// ServerInfo returns the server information.
+func (driver *Driver) ServerInfo(ctx context.Context) (*db.ServerInfo, error) {
+	var info db.ServerInfo
+	query := "SELECT VERSION(), @@character_set_server, @@collation_server, @@GLOBAL.sql_mode"
+	if err := driver.db.QueryRowContext(ctx, query).Scan(
+		&info.Version,
+		&info.CharacterSet,
+		&info.Collation,
+		&info.SQLMode,
+	); err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+
+	query = "SELECT COLLATION_NAME FROM information_schema.COLLATIONS ORDER BY COLLATION_NAME"
+	rows, err := driver.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var collation string
+		if err := rows.Scan(&collation); err != nil {
+			return nil, err
+		}
+		info.CollationList = append(info.CollationList, collation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// SyncSchema syncs the schema of the databases in the database list, or all databases except the system databases if it's empty.
+func (driver *Driver) SyncSchema(ctx context.Context, databaseList ...string) ([]*db.User, []*db.Schema, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "SyncSchema", driver.dbType, nil)
+	userList, schemaList, err := driver.syncSchema(ctx, 0 /* since */, databaseList)
+	span.End(err)
+	return userList, schemaList, err
+}
+
+// SyncSchemaSince is SyncSchema only returning the tables created or updated at or after the since timestamp in seconds.
+func (driver *Driver) SyncSchemaSince(ctx context.Context, since int64, databaseList ...string) ([]*db.User, []*db.Schema, error) {
+	return driver.syncSchema(ctx, since, databaseList)
+}
+
+// SyncSchemaStream syncs the schema of each database and calls fn on it. The users aren't synced.
+func (driver *Driver) SyncSchemaStream(ctx context.Context, fn func(*db.Schema) error, databaseList ...string) error {
+	sqldb, err := driver.getReadOnlyDB()
+	if err != nil {
+		return err
+	}
+	query := `
+		SELECT SCHEMA_NAME
+		FROM information_schema.SCHEMATA
+		WHERE ` + getDatabaseWhere("SCHEMA_NAME", databaseList)
+	rows, err := sqldb.QueryContext(ctx, query)
+	if err != nil {
+		return util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	var nameList []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		nameList = append(nameList, name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The version is fetched once and the users aren't synced for each database.
+	version, err := driver.GetVersion(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range nameList {
+		schemaList, err := driver.syncDatabaseSchemaList(ctx, sqldb, version, 0 /* since */, []string{name})
+		if err != nil {
+			return err
+		}
+		for _, schema := range schemaList {
+			if err := fn(schema); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncSchema syncs the schema, and only syncs the tables changed at or after the since timestamp if it's positive.
+func (driver *Driver) syncSchema(ctx context.Context, since int64, databaseList []string) ([]*db.User, []*db.Schema, error) {
 	// Query MySQL version
 	version, err := driver.GetVersion(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
-	isMySQL8 := strings.HasPrefix(version, "8.0")
 
-	excludedDatabaseList := []string{
-		// Skip our internal "bytebase" database
-		"'bytebase'",
+	// Read the schema from the replica if there is one to reduce the load on the primary.
+	sqldb, err := driver.getReadOnlyDB()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Skip all system databases
-	for k := range systemDatabases {
-		excludedDatabaseList = append(excludedDatabaseList, fmt.Sprintf("'%s'", k))
+	// Query user info
+	userList, err := driver.getUserList(ctx, sqldb)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Query user info
-	userList, err := driver.getUserList(ctx)
+	schemaList, err := driver.syncDatabaseSchemaList(ctx, sqldb, version, since, databaseList)
 	if err != nil {
 		return nil, nil, err
 	}
+	return userList, schemaList, nil
+}
+
+// syncDatabaseSchemaList syncs the schema of the databases without the users on the server of the version.
+// It only syncs the tables changed at or after the since timestamp if it's positive.
+func (driver *Driver) syncDatabaseSchemaList(ctx context.Context, sqldb *sql.DB, version string, since int64, databaseList []string) ([]*db.Schema, error) {
+	// changedTableWhere limits the tables to the ones changed since the timestamp, and keeps the other table types such as views.
+	changedTableWhere := ""
+	if since > 0 {
+		changedTableWhere = fmt.Sprintf(" AND (TABLE_TYPE NOT IN ('%s', '%s') OR UNIX_TIMESTAMP(CREATE_TIME) >= %d OR UNIX_TIMESTAMP(UPDATE_TIME) >= %d)", baseTableType, systemVersionedTableType, since, since)
+	}
+	isMySQL8 := strings.HasPrefix(version, "8.0")
 
 	// Query index info
-	indexWhere := fmt.Sprintf("LOWER(TABLE_SCHEMA) NOT IN (%s)", strings.Join(excludedDatabaseList, ", "))
+	indexWhere := getDatabaseWhere("TABLE_SCHEMA", databaseList)
+	if changedTableWhere != "" {
+		indexWhere += " AND (TABLE_SCHEMA, TABLE_NAME) IN (SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES WHERE " + indexWhere + changedTableWhere + ")"
+	}
 	query := `
 			SELECT
 				TABLE_SCHEMA,
@@ -201,9 +466,9 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 			FROM information_schema.STATISTICS
 			WHERE ` + indexWhere
 	}
-	indexRows, err := driver.db.QueryContext(ctx, query)
+	indexRows, err := sqldb.QueryContext(ctx, query)
 	if err != nil {
-		return nil, nil, util.FormatErrorWithQuery(err, query)
+		return nil, util.FormatErrorWithQuery(err, query)
 	}
 	defer indexRows.Close()
 
@@ -227,7 +492,7 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 			&index.Visible,
 			&index.Comment,
 		); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		if columnName.Valid {
@@ -245,7 +510,10 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 	}
 
 	// Query column info
-	columnWhere := fmt.Sprintf("LOWER(TABLE_SCHEMA) NOT IN (%s)", strings.Join(excludedDatabaseList, ", "))
+	columnWhere := getDatabaseWhere("TABLE_SCHEMA", databaseList)
+	if changedTableWhere != "" {
+		columnWhere += " AND (TABLE_SCHEMA, TABLE_NAME) IN (SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES WHERE " + columnWhere + changedTableWhere + ")"
+	}
 	query = `
 			SELECT
 				TABLE_SCHEMA,
@@ -257,12 +525,13 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 				COLUMN_TYPE,
 				IFNULL(CHARACTER_SET_NAME, ''),
 				IFNULL(COLLATION_NAME, ''),
-				COLUMN_COMMENT
+				COLUMN_COMMENT,
+				EXTRA
 			FROM information_schema.COLUMNS
 			WHERE ` + columnWhere
-	columnRows, err := driver.db.QueryContext(ctx, query)
+	columnRows, err := sqldb.QueryContext(ctx, query)
 	if err != nil {
-		return nil, nil, util.FormatErrorWithQuery(err, query)
+		return nil, util.FormatErrorWithQuery(err, query)
 	}
 	defer columnRows.Close()
 
@@ -285,8 +554,9 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 			&column.CharacterSet,
 			&column.Collation,
 			&column.Comment,
+			&column.Extra,
 		); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		if defaultStr.Valid {
@@ -301,8 +571,34 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 		}
 	}
 
+	// Query foreign key info
+	foreignKeyMap, err := getForeignKeyMap(ctx, sqldb, getDatabaseWhere("kcu.TABLE_SCHEMA", databaseList))
+	if err != nil {
+		return nil, err
+	}
+
+	// Query trigger info
+	triggerMap, err := getTriggerMap(ctx, sqldb, getDatabaseWhere("EVENT_OBJECT_SCHEMA", databaseList))
+	if err != nil {
+		return nil, err
+	}
+
+	// Query partition info
+	partitionMap, err := getPartitionMap(ctx, sqldb, getDatabaseWhere("TABLE_SCHEMA", databaseList))
+	if err != nil {
+		return nil, err
+	}
+
+	// Query TiDB table sizes from the TiKV regions.
+	var tidbTableSizeMap map[string]tidbTableSize
+	if driver.dbType == db.TiDB {
+		if tidbTableSizeMap, err = getTiDBTableSizeMap(ctx, sqldb, getDatabaseWhere("DB_NAME", databaseList)); err != nil {
+			return nil, err
+		}
+	}
+
 	// Query table info
-	tableWhere := fmt.Sprintf("LOWER(TABLE_SCHEMA) NOT IN (%s)", strings.Join(excludedDatabaseList, ", "))
+	tableWhere := getDatabaseWhere("TABLE_SCHEMA", databaseList) + changedTableWhere
 	query = `
 			SELECT
 				TABLE_SCHEMA,
@@ -320,9 +616,9 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 				IFNULL(TABLE_COMMENT, '')
 			FROM information_schema.TABLES
 			WHERE ` + tableWhere
-	tableRows, err := driver.db.QueryContext(ctx, query)
+	tableRows, err := sqldb.QueryContext(ctx, query)
 	if err != nil {
-		return nil, nil, util.FormatErrorWithQuery(err, query)
+		return nil, util.FormatErrorWithQuery(err, query)
 	}
 	defer tableRows.Close()
 
@@ -335,6 +631,8 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 	}
 	// dbName/viewName -> ViewInfo
 	viewInfoMap := make(map[string]ViewInfo)
+	// dbName -> sequenceNameList map
+	sequenceNameMap := make(map[string][]string)
 	for tableRows.Next() {
 		var dbName string
 		// Workaround TiDB bug https://github.com/pingcap/tidb/issues/27970
@@ -355,10 +653,18 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 			&table.CreateOptions,
 			&table.Comment,
 		); err != nil {
-			return nil, nil, err
+			return nil, err
+		}
+
+		// MariaDB reports the system-versioned tables with their own table type.
+		if table.Type == systemVersionedTableType {
+			table.Type = baseTableType
+			table.SystemVersioned = true
 		}
 
 		if table.Type == baseTableType {
+			// MyISAM keeps the exact row count, while InnoDB samples it.
+			table.IsRowCountExact = table.Engine == "MyISAM"
 			if tableCollation.Valid {
 				table.Collation = tableCollation.String
 			}
@@ -366,6 +672,13 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 			key := fmt.Sprintf("%s/%s", dbName, table.Name)
 			table.ColumnList = columnMap[key]
 			table.IndexList = indexMap[key]
+			table.ForeignKeyList = foreignKeyMap[key]
+			table.TriggerList = triggerMap[key]
+			table.PartitionList = partitionMap[key]
+			if size, ok := tidbTableSizeMap[key]; ok {
+				table.DataSize = size.dataSize
+				table.IndexSize = size.indexSize
+			}
 
 			if tableList, ok := tableMap[dbName]; ok {
 				tableMap[dbName] = append(tableList, table)
@@ -378,11 +691,22 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 				updatedTs: table.UpdatedTs,
 				comment:   table.Comment,
 			}
+		} else if table.Type == sequenceTableType {
+			sequenceNameMap[dbName] = append(sequenceNameMap[dbName], table.Name)
 		}
 	}
+	if err := tableRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Query MariaDB sequence info
+	sequenceMap, err := getMariaDBSequenceMap(ctx, sqldb, sequenceNameMap)
+	if err != nil {
+		return nil, err
+	}
 
 	// Query view info
-	viewWhere := fmt.Sprintf("LOWER(TABLE_SCHEMA) NOT IN (%s)", strings.Join(excludedDatabaseList, ", "))
+	viewWhere := getDatabaseWhere("TABLE_SCHEMA", databaseList)
 	query = `
 			SELECT
 				TABLE_SCHEMA,
@@ -390,9 +714,9 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 				VIEW_DEFINITION
 			FROM information_schema.VIEWS
 			WHERE ` + viewWhere
-	viewRows, err := driver.db.QueryContext(ctx, query)
+	viewRows, err := sqldb.QueryContext(ctx, query)
 	if err != nil {
-		return nil, nil, util.FormatErrorWithQuery(err, query)
+		return nil, util.FormatErrorWithQuery(err, query)
 	}
 	defer viewRows.Close()
 
@@ -406,7 +730,7 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 			&view.Name,
 			&view.Definition,
 		); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		info := viewInfoMap[fmt.Sprintf("%s/%s", dbName, view.Name)]
@@ -421,8 +745,14 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 		}
 	}
 
+	// Query routine info
+	procedureMap, functionMap, err := getRoutineMap(ctx, sqldb, getDatabaseWhere("ROUTINE_SCHEMA", databaseList))
+	if err != nil {
+		return nil, err
+	}
+
 	// Query db info
-	where := fmt.Sprintf("LOWER(SCHEMA_NAME) NOT IN (%s)", strings.Join(excludedDatabaseList, ", "))
+	where := getDatabaseWhere("SCHEMA_NAME", databaseList)
 	query = `
 			SELECT
 		    SCHEMA_NAME,
@@ -430,9 +760,9 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 			DEFAULT_COLLATION_NAME
 		FROM information_schema.SCHEMATA
 		WHERE ` + where
-	rows, err := driver.db.QueryContext(ctx, query)
+	rows, err := sqldb.QueryContext(ctx, query)
 	if err != nil {
-		return nil, nil, util.FormatErrorWithQuery(err, query)
+		return nil, util.FormatErrorWithQuery(err, query)
 	}
 	defer rows.Close()
 
@@ -444,22 +774,254 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 			&schema.CharacterSet,
 			&schema.Collation,
 		); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		schema.TableList = tableMap[schema.Name]
 		schema.ViewList = viewMap[schema.Name]
+		schema.ProcedureList = procedureMap[schema.Name]
+		schema.FunctionList = functionMap[schema.Name]
+		schema.SequenceList = sequenceMap[schema.Name]
 
 		schemaList = append(schemaList, &schema)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schemaList, nil
+}
+
+// getDatabaseWhere returns the condition on the database column to match the databases in the database list,
+// or all databases except the system databases if the database list is empty.
+// The system databases are only included if they're explicitly listed, and our internal "bytebase" database is always skipped.
+func getDatabaseWhere(column string, databaseList []string) string {
+	if len(databaseList) == 0 {
+		excludedDatabaseList := []string{
+			// Skip our internal "bytebase" database
+			"'bytebase'",
+		}
+		// Skip all system databases
+		for k := range systemDatabases {
+			excludedDatabaseList = append(excludedDatabaseList, fmt.Sprintf("'%s'", k))
+		}
+		return fmt.Sprintf("LOWER(%s) NOT IN (%s)", column, strings.Join(excludedDatabaseList, ", "))
+	}
+
+	var quotedList []string
+	for _, database := range databaseList {
+		quoted := strings.ReplaceAll(strings.ReplaceAll(database, `\`, `\\`), "'", "''")
+		quotedList = append(quotedList, fmt.Sprintf("'%s'", quoted))
+	}
+	return fmt.Sprintf("%s IN (%s) AND LOWER(%s) <> 'bytebase'", column, strings.Join(quotedList, ", "), column)
+}
+
+// getPartitionMap gets the table partitions matching the where condition keyed by "dbName/tableName".
+// The subpartitions are aggregated into their partitions.
+func getPartitionMap(ctx context.Context, sqldb *sql.DB, where string) (map[string][]db.Partition, error) {
+	query := `
+			SELECT
+				TABLE_SCHEMA,
+				TABLE_NAME,
+				PARTITION_NAME,
+				IFNULL(PARTITION_METHOD, ''),
+				IFNULL(PARTITION_EXPRESSION, ''),
+				IFNULL(PARTITION_DESCRIPTION, ''),
+				CAST(IFNULL(SUM(TABLE_ROWS), 0) AS SIGNED)
+			FROM information_schema.PARTITIONS
+			WHERE PARTITION_NAME IS NOT NULL AND ` + where + `
+			GROUP BY TABLE_SCHEMA, TABLE_NAME, PARTITION_NAME, PARTITION_METHOD, PARTITION_EXPRESSION, PARTITION_DESCRIPTION, PARTITION_ORDINAL_POSITION
+			ORDER BY TABLE_SCHEMA, TABLE_NAME, PARTITION_ORDINAL_POSITION`
+	rows, err := sqldb.QueryContext(ctx, query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	partitionMap := make(map[string][]db.Partition)
+	for rows.Next() {
+		var dbName, tableName string
+		var partition db.Partition
+		if err := rows.Scan(
+			&dbName,
+			&tableName,
+			&partition.Name,
+			&partition.Method,
+			&partition.Expression,
+			&partition.Value,
+			&partition.RowCount,
+		); err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%s/%s", dbName, tableName)
+		partitionMap[key] = append(partitionMap[key], partition)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return partitionMap, nil
+}
+
+// getTriggerMap gets the triggers matching the where condition keyed by "dbName/tableName" of the triggering table.
+func getTriggerMap(ctx context.Context, sqldb *sql.DB, where string) (map[string][]db.Trigger, error) {
+	query := `
+			SELECT
+				EVENT_OBJECT_SCHEMA,
+				EVENT_OBJECT_TABLE,
+				TRIGGER_NAME,
+				EVENT_MANIPULATION,
+				ACTION_TIMING,
+				ACTION_STATEMENT
+			FROM information_schema.TRIGGERS
+			WHERE ` + where + `
+			ORDER BY EVENT_OBJECT_SCHEMA, EVENT_OBJECT_TABLE, ACTION_TIMING, EVENT_MANIPULATION, ACTION_ORDER`
+	rows, err := sqldb.QueryContext(ctx, query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	triggerMap := make(map[string][]db.Trigger)
+	for rows.Next() {
+		var dbName, tableName string
+		var trigger db.Trigger
+		if err := rows.Scan(
+			&dbName,
+			&tableName,
+			&trigger.Name,
+			&trigger.Event,
+			&trigger.Timing,
+			&trigger.Statement,
+		); err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%s/%s", dbName, tableName)
+		triggerMap[key] = append(triggerMap[key], trigger)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return triggerMap, nil
+}
+
+// getRoutineMap gets the stored procedures and functions matching the where condition keyed by the database name.
+func getRoutineMap(ctx context.Context, sqldb *sql.DB, where string) (map[string][]db.Routine, map[string][]db.Routine, error) {
+	query := `
+			SELECT
+				ROUTINE_SCHEMA,
+				ROUTINE_NAME,
+				ROUTINE_TYPE,
+				IFNULL(ROUTINE_DEFINITION, ''),
+				ROUTINE_BODY,
+				IFNULL(UNIX_TIMESTAMP(CREATED), 0),
+				IFNULL(UNIX_TIMESTAMP(LAST_ALTERED), 0)
+			FROM information_schema.ROUTINES
+			WHERE ` + where + `
+			ORDER BY ROUTINE_SCHEMA, ROUTINE_NAME`
+	rows, err := sqldb.QueryContext(ctx, query)
+	if err != nil {
+		return nil, nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	procedureMap := make(map[string][]db.Routine)
+	functionMap := make(map[string][]db.Routine)
+	for rows.Next() {
+		var dbName, routineType string
+		var routine db.Routine
+		if err := rows.Scan(
+			&dbName,
+			&routine.Name,
+			&routineType,
+			&routine.Definition,
+			&routine.Language,
+			&routine.CreatedTs,
+			&routine.UpdatedTs,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		switch routineType {
+		case "PROCEDURE":
+			procedureMap[dbName] = append(procedureMap[dbName], routine)
+		case "FUNCTION":
+			functionMap[dbName] = append(functionMap[dbName], routine)
+		}
+	}
 	if err := rows.Err(); err != nil {
 		return nil, nil, err
 	}
+	return procedureMap, functionMap, nil
+}
 
-	return userList, schemaList, err
+// getForeignKeyMap gets the foreign keys matching the where condition keyed by "dbName/tableName".
+func getForeignKeyMap(ctx context.Context, sqldb *sql.DB, where string) (map[string][]db.ForeignKey, error) {
+	// Order by ORDINAL_POSITION to keep the column pairing order of multi-column foreign keys.
+	query := `
+			SELECT
+				kcu.TABLE_SCHEMA,
+				kcu.TABLE_NAME,
+				kcu.CONSTRAINT_NAME,
+				kcu.COLUMN_NAME,
+				kcu.REFERENCED_TABLE_SCHEMA,
+				kcu.REFERENCED_TABLE_NAME,
+				kcu.REFERENCED_COLUMN_NAME,
+				rc.DELETE_RULE,
+				rc.UPDATE_RULE
+			FROM information_schema.KEY_COLUMN_USAGE AS kcu
+			JOIN information_schema.REFERENTIAL_CONSTRAINTS AS rc
+				ON rc.CONSTRAINT_SCHEMA = kcu.CONSTRAINT_SCHEMA
+				AND rc.TABLE_NAME = kcu.TABLE_NAME
+				AND rc.CONSTRAINT_NAME = kcu.CONSTRAINT_NAME
+			WHERE ` + where + `
+			ORDER BY kcu.TABLE_SCHEMA, kcu.TABLE_NAME, kcu.CONSTRAINT_NAME, kcu.ORDINAL_POSITION`
+	rows, err := sqldb.QueryContext(ctx, query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	foreignKeyMap := make(map[string][]db.ForeignKey)
+	for rows.Next() {
+		var dbName, tableName, name, column, referencedColumn string
+		var fk db.ForeignKey
+		if err := rows.Scan(
+			&dbName,
+			&tableName,
+			&name,
+			&column,
+			&fk.ReferencedSchema,
+			&fk.ReferencedTable,
+			&referencedColumn,
+			&fk.OnDelete,
+			&fk.OnUpdate,
+		); err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%s/%s", dbName, tableName)
+		fkList := foreignKeyMap[key]
+		// The rows of the same constraint are adjacent because of the ORDER BY.
+		if len(fkList) > 0 && fkList[len(fkList)-1].Name == name {
+			last := &fkList[len(fkList)-1]
+			last.ColumnList = append(last.ColumnList, column)
+			last.ReferencedColumnList = append(last.ReferencedColumnList, referencedColumn)
+			continue
+		}
+		fk.Name = name
+		fk.ColumnList = []string{column}
+		fk.ReferencedColumnList = []string{referencedColumn}
+		foreignKeyMap[key] = append(fkList, fk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return foreignKeyMap, nil
 }
 
-func (driver *Driver) getUserList(ctx context.Context) ([]*db.User, error) {
+func (driver *Driver) getUserList(ctx context.Context, sqldb *sql.DB) ([]*db.User, error) {
 	// Query user info
 	query := `
 	  SELECT
@@ -469,7 +1031,7 @@ func (driver *Driver) getUserList(ctx context.Context) ([]*db.User, error) {
 		WHERE user NOT LIKE 'mysql.%'
 	`
 	var userList []*db.User
-	userRows, err := driver.db.QueryContext(ctx, query)
+	userRows, err := sqldb.QueryContext(ctx, query)
 
 	if err != nil {
 		return nil, util.FormatErrorWithQuery(err, query)
@@ -491,7 +1053,7 @@ func (driver *Driver) getUserList(ctx context.Context) ([]*db.User, error) {
 		// in both ways. On the other hand, some other MySQL compatible engines might not (OceanBase in this case).
 		name := fmt.Sprintf("'%s'@'%s'", user, host)
 		query = fmt.Sprintf("SHOW GRANTS FOR %s", name)
-		grantRows, err := driver.db.QueryContext(ctx,
+		grantRows, err := sqldb.QueryContext(ctx,
 			query,
 		)
 		if err != nil {
@@ -517,45 +1079,284 @@ func (driver *Driver) getUserList(ctx context.Context) ([]*db.User, error) {
 }
 
 // Execute executes a SQL statement.
-func (driver *Driver) Execute(ctx context.Context, statement string) error {
+func (driver *Driver) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Execute", driver.dbType, nil)
+	ctx, cancel := driver.withStatementTimeout(ctx)
+	defer cancel()
+	result, err := driver.execute(ctx, statement)
+	err = driver.convertStatementTimeoutError(ctx, err)
+	span.End(err)
+	return result, err
+}
+
+func (driver *Driver) execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	var result db.ExecuteResult
 	tx, err := driver.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return result, err
 	}
 	defer tx.Rollback()
 
-	_, err = tx.ExecContext(ctx, statement)
+	sqlResult, err := tx.ExecContext(ctx, statement)
+	if err != nil {
+		return result, err
+	}
+	result.Add(sqlResult)
 
-	if err == nil {
-		if err := tx.Commit(); err != nil {
-			return err
+	if err := tx.Commit(); err != nil {
+		return db.ExecuteResult{}, err
+	}
+
+	if driver.dbType == db.TiDB {
+		if err := driver.waitTiDBDDLJobsDone(ctx, statement); err != nil {
+			return db.ExecuteResult{}, err
 		}
 	}
+	return result, nil
+}
+
+// ExecuteBatch executes the statements in a single transaction.
+func (driver *Driver) ExecuteBatch(ctx context.Context, statementList []string) error {
+	ctx, cancel := driver.withStatementTimeout(ctx)
+	defer cancel()
+	if err := util.ExecuteBatch(ctx, driver.db, statementList); err != nil {
+		return driver.convertStatementTimeoutError(ctx, err)
+	}
+
+	if driver.dbType == db.TiDB {
+		return driver.convertStatementTimeoutError(ctx, driver.waitTiDBDDLJobsDone(ctx, statementList...))
+	}
+	return nil
+}
+
+// GetType returns the database type.
+func (driver *Driver) GetType() db.Type {
+	return driver.dbType
+}
+
+// ExecuteStatementList executes the statements one by one in a transaction.
+// MySQL commits the transaction implicitly before and after a DDL statement, so the statements before the failed one
+// aren't rolled back if there's a DDL statement, and the returned *db.StatementError reports it as committed.
+func (driver *Driver) ExecuteStatementList(ctx context.Context, statementList []string) (db.ExecuteResult, error) {
+	ctx, cancel := driver.withStatementTimeout(ctx)
+	defer cancel()
+
+	tx, err := driver.db.BeginTx(ctx, nil)
+	if err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return driver.executeStatementListInTransaction(ctx, tx, statementList)
+}
+
+// ExecuteStatementListAsRole executes the statements like ExecuteStatementList on a dedicated connection after SET ROLE.
+// The role is a role name such as "ddl_admin", or with the host such as "ddl_admin@%".
+// The role of the connection is restored by SET ROLE DEFAULT afterward, or SET ROLE NONE for MariaDB, and the connection is discarded if it can't be restored.
+func (driver *Driver) ExecuteStatementListAsRole(ctx context.Context, role string, statementList []string) (db.ExecuteResult, error) {
+	ctx, cancel := driver.withStatementTimeout(ctx)
+	defer cancel()
 
+	conn, err := driver.db.Conn(ctx)
+	if err != nil {
+		return db.ExecuteResult{}, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET ROLE %s", quoteRole(role))); err != nil {
+		return db.ExecuteResult{}, fmt.Errorf("failed to set role %q, error: %w", role, err)
+	}
+	defer func() {
+		// Use a new context since ctx may have been canceled.
+		if _, err := conn.ExecContext(context.Background(), resetRoleStatement(driver.dbType)); err != nil {
+			driver.l.Warn("Failed to restore the default role, discarding the connection", zap.String("role", role), zap.Error(err))
+			conn.Raw(func(interface{}) error {
+				return sqldriver.ErrBadConn
+			})
+		}
+	}()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return driver.executeStatementListInTransaction(ctx, tx, statementList)
+}
+
+// resetRoleStatement returns the statement restoring the role of the connection after SET ROLE.
+// MariaDB doesn't support SET ROLE DEFAULT, and it activates the default role only on login, so the role is reset to none instead.
+func resetRoleStatement(dbType db.Type) string {
+	if dbType == db.MariaDB {
+		return "SET ROLE NONE"
+	}
+	return "SET ROLE DEFAULT"
+}
+
+// quoteRole quotes the role with the optional host, e.g. "ddl_admin@%" is quoted as 'ddl_admin'@'%'.
+func quoteRole(role string) string {
+	quote := func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	if i := strings.LastIndex(role, "@"); i >= 0 {
+		return quote(role[:i]) + "@" + quote(role[i+1:])
+	}
+	return quote(role)
+}
+
+// executeStatementListInTransaction executes the statements in tx one by one and commits tx, and tx is rolled back on failure.
+func (driver *Driver) executeStatementListInTransaction(ctx context.Context, tx *sql.Tx, statementList []string) (db.ExecuteResult, error) {
+	defer tx.Rollback()
+
+	var result db.ExecuteResult
+	committed := false
+	for i, stmt := range statementList {
+		// The implicit commit happens both before and after a DDL statement, and the one before happens even if the DDL statement fails.
+		if i > 0 && (causesImplicitCommit(stmt) || causesImplicitCommit(statementList[i-1])) {
+			committed = true
+		}
+		sqlResult, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			if committed {
+				driver.l.Warn("The statements before the failed statement are committed implicitly by DDL and not rolled back",
+					zap.Int("statement_index", i),
+					zap.Int("statement_count", len(statementList)),
+				)
+			}
+			return db.ExecuteResult{}, &db.StatementError{Index: i, Statement: stmt, Committed: committed, Err: driver.convertStatementTimeoutError(ctx, err)}
+		}
+		result.Add(sqlResult)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.ExecuteResult{}, driver.convertStatementTimeoutError(ctx, err)
+	}
+
+	if driver.dbType == db.TiDB {
+		if err := driver.waitTiDBDDLJobsDone(ctx, statementList...); err != nil {
+			return db.ExecuteResult{}, driver.convertStatementTimeoutError(ctx, err)
+		}
+	}
+	return result, nil
+}
+
+// implicitCommitKeywords are the leading keywords of the statements causing an implicit commit.
+// See https://dev.mysql.com/doc/refman/8.0/en/implicit-commit.html
+var implicitCommitKeywords = map[string]bool{
+	"ALTER":    true,
+	"CREATE":   true,
+	"DROP":     true,
+	"GRANT":    true,
+	"RENAME":   true,
+	"REVOKE":   true,
+	"TRUNCATE": true,
+}
+
+// causesImplicitCommit returns whether the statement is a DDL statement causing an implicit commit.
+func causesImplicitCommit(stmt string) bool {
+	stmt = skipLeadingComments(stmt)
+	end := strings.IndexFunc(stmt, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if end < 0 {
+		end = len(stmt)
+	}
+	return implicitCommitKeywords[strings.ToUpper(stmt[:end])]
+}
+
+// skipLeadingComments returns the statement without the leading whitespaces and comments.
+func skipLeadingComments(stmt string) string {
+	for {
+		stmt = strings.TrimSpace(stmt)
+		switch {
+		case strings.HasPrefix(stmt, "--"), strings.HasPrefix(stmt, "#"):
+			i := strings.IndexByte(stmt, '\n')
+			if i < 0 {
+				return ""
+			}
+			stmt = stmt[i+1:]
+		// The executable comments "/*! ... */" are executed, but we don't look into them.
+		case strings.HasPrefix(stmt, "/*"):
+			i := strings.Index(stmt, "*/")
+			if i < 0 {
+				return ""
+			}
+			stmt = stmt[i+2:]
+		default:
+			return stmt
+		}
+	}
+}
+
+// withStatementTimeout returns the context bounded by the configured statement timeout.
+func (driver *Driver) withStatementTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if driver.driverConfig.StatementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, driver.driverConfig.StatementTimeout)
+}
+
+// convertStatementTimeoutError wraps the error with db.ErrStatementTimeout if the statement times out,
+// either by the context deadline or by max_execution_time on the server.
+func (driver *Driver) convertStatementTimeoutError(ctx context.Context, err error) error {
+	if err == nil || driver.driverConfig.StatementTimeout <= 0 {
+		return err
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || (errors.As(err, &mysqlErr) && mysqlErr.Number == erQueryTimeout) {
+		return fmt.Errorf("%w after %v, error: %v", db.ErrStatementTimeout, driver.driverConfig.StatementTimeout, err)
+	}
 	return err
 }
 
 // Query queries a SQL statement.
 func (driver *Driver) Query(ctx context.Context, statement string, limit int) ([]interface{}, error) {
-	return util.Query(ctx, driver.l, driver.db, statement, limit)
+	sqldb, err := driver.getReadOnlyDB()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := driver.withStatementTimeout(ctx)
+	defer cancel()
+	result, err := util.Query(ctx, driver.l, sqldb, statement, limit)
+	return result, driver.convertStatementTimeoutError(ctx, err)
+}
+
+// BeginTx begins a transaction.
+func (driver *Driver) BeginTx(ctx context.Context) (db.Tx, error) {
+	return util.BeginTx(ctx, driver.db)
 }
 
 // NeedsSetupMigration returns whether it needs to setup migration.
 func (driver *Driver) NeedsSetupMigration(ctx context.Context) (bool, error) {
-	const query = `
-		SELECT
-		    1
-		FROM information_schema.TABLES
-		WHERE TABLE_SCHEMA = 'bytebase' AND TABLE_NAME = 'migration_history'
-		`
-	return util.NeedsSetupMigrationSchema(ctx, driver.db, query)
+	driver.mu.Lock()
+	migrationSetup := driver.migrationSetup
+	driver.mu.Unlock()
+	if migrationSetup {
+		return false, nil
+	}
+	// We select from the migration history table directly instead of querying information_schema.TABLES, because
+	// information_schema only lists the tables the user has privileges on, and we can't tell a missing table from
+	// an inaccessible one. The query reads no rows and only requires the SELECT privilege.
+	query := fmt.Sprintf("SELECT 1 FROM %s LIMIT 0", driver.migrationHistory())
+	rows, err := driver.db.QueryContext(ctx, query)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) {
+			switch mysqlErr.Number {
+			case erBadDB, erNoSuchTable:
+				return true, nil
+			case erDBAccessDenied, erTableAccessDenied:
+				return false, common.Errorf(common.MigrationSchemaAccessDenied, fmt.Errorf("insufficient privilege to check the migration schema, error: %w", err))
+			}
+		}
+		return false, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+	return false, nil
 }
 
 // SetupMigrationIfNeeded sets up migration if needed.
 func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
 	setup, err := driver.NeedsSetupMigration(ctx)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	if setup {
@@ -566,6 +1367,7 @@ func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
 		// Do not wrap it in a single transaction here because:
 		// 1. For MySQL, each DDL is in its own transaction. See https://dev.mysql.com/doc/refman/8.0/en/implicit-commit.html
 		// 2. For TiDB, the created database/table is not visible to the followup statements from the same transaction.
+		migrationSchema := driver.getMigrationSchema()
 		if _, err := driver.db.ExecContext(ctx, migrationSchema); err != nil {
 			driver.l.Error("Failed to initialize migration schema.",
 				zap.Error(err),
@@ -580,44 +1382,88 @@ func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
 		)
 	}
 
+	driver.mu.Lock()
+	driver.migrationSetup = true
+	driver.mu.Unlock()
 	return nil
 }
 
-// FindLargestVersionSinceBaseline will find the largest version since last baseline or branch.
-func (driver Driver) FindLargestVersionSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) (*string, error) {
+const (
+	defaultMigrationHistorySchema = "bytebase"
+	defaultMigrationHistoryTable  = "migration_history"
+)
+
+// getMigrationHistorySchema returns the configured schema, i.e. database, of the migration history table.
+func (driver *Driver) getMigrationHistorySchema() string {
+	if driver.driverConfig.MigrationHistorySchema != "" {
+		return driver.driverConfig.MigrationHistorySchema
+	}
+	return defaultMigrationHistorySchema
+}
+
+// migrationHistory returns the quoted qualified name of the configured migration history table.
+func (driver *Driver) migrationHistory() string {
+	table := defaultMigrationHistoryTable
+	if driver.driverConfig.MigrationHistoryTable != "" {
+		table = driver.driverConfig.MigrationHistoryTable
+	}
+	return fmt.Sprintf("%s.%s", quoteIdentifier(driver.getMigrationHistorySchema()), quoteIdentifier(table))
+}
+
+// getMigrationSchema returns the migration schema statements creating the configured migration history table.
+func (driver *Driver) getMigrationSchema() string {
+	if driver.driverConfig.MigrationHistorySchema == "" && driver.driverConfig.MigrationHistoryTable == "" {
+		return migrationSchema
+	}
+	// The configured schema may be shared with other tables, so it's only created if it doesn't exist.
+	return strings.NewReplacer(
+		"CREATE DATABASE bytebase ", fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s ", quoteIdentifier(driver.getMigrationHistorySchema())),
+		"bytebase.migration_history", driver.migrationHistory(),
+	).Replace(migrationSchema)
+}
+
+// quoteIdentifier quotes the identifier with backticks, and escapes the backticks in it.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// FindVersionListSinceBaseline will find the stored versions since last baseline or branch.
+func (driver *Driver) FindVersionListSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) ([]string, error) {
 	largestBaselineSequence, err := driver.FindLargestSequence(ctx, tx, namespace, true /* baseline */)
 	if err != nil {
 		return nil, err
 	}
-	const getLargestVersionSinceLastBaselineQuery = `
-		SELECT MAX(version) FROM bytebase.migration_history
+	getVersionListSinceLastBaselineQuery := `
+		SELECT version FROM ` + driver.migrationHistory() + `
 		WHERE namespace = ? AND sequence >= ?
 	`
-	row, err := tx.QueryContext(ctx, getLargestVersionSinceLastBaselineQuery,
+	rows, err := tx.QueryContext(ctx, getVersionListSinceLastBaselineQuery,
 		namespace, largestBaselineSequence,
 	)
 	if err != nil {
-		return nil, util.FormatErrorWithQuery(err, getLargestVersionSinceLastBaselineQuery)
+		return nil, util.FormatErrorWithQuery(err, getVersionListSinceLastBaselineQuery)
 	}
-	defer row.Close()
+	defer rows.Close()
 
-	var version sql.NullString
-	row.Next()
-	if err := row.Scan(&version); err != nil {
-		return nil, err
+	var versionList []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versionList = append(versionList, version)
 	}
-
-	if version.Valid {
-		return &version.String, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil, nil
+	return versionList, nil
 }
 
 // FindLargestSequence will return the largest sequence number.
-func (Driver) FindLargestSequence(ctx context.Context, tx *sql.Tx, namespace string, baseline bool) (int, error) {
+func (driver *Driver) FindLargestSequence(ctx context.Context, tx *sql.Tx, namespace string, baseline bool) (int, error) {
 	findLargestSequenceQuery := `
-		SELECT MAX(sequence) FROM bytebase.migration_history
+		SELECT MAX(sequence) FROM ` + driver.migrationHistory() + `
 		WHERE namespace = ?`
 	if baseline {
 		findLargestSequenceQuery = fmt.Sprintf("%s AND (type = '%s' OR type = '%s')", findLargestSequenceQuery, db.Baseline, db.Branch)
@@ -645,9 +1491,9 @@ func (Driver) FindLargestSequence(ctx context.Context, tx *sql.Tx, namespace str
 }
 
 // InsertPendingHistory will insert the migration record with pending status and return the inserted ID.
-func (Driver) InsertPendingHistory(ctx context.Context, tx *sql.Tx, sequence int, prevSchema string, m *db.MigrationInfo, storedVersion, statement string) (int64, error) {
-	const insertHistoryQuery = `
-		INSERT INTO bytebase.migration_history (
+func (driver *Driver) InsertPendingHistory(ctx context.Context, tx *sql.Tx, sequence int, prevSchema string, m *db.MigrationInfo, storedVersion, statement string) (int64, error) {
+	insertHistoryQuery := `
+		INSERT INTO ` + driver.migrationHistory() + ` (
 			created_by,
 			created_ts,
 			updated_by,
@@ -697,10 +1543,10 @@ func (Driver) InsertPendingHistory(ctx context.Context, tx *sql.Tx, sequence int
 }
 
 // UpdateHistoryAsDone will update the migration record as done.
-func (Driver) UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, updatedSchema string, insertedID int64) error {
-	const updateHistoryAsDoneQuery = `
+func (driver *Driver) UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, updatedSchema string, insertedID int64) error {
+	updateHistoryAsDoneQuery := `
 		UPDATE
-			bytebase.migration_history
+			` + driver.migrationHistory() + `
 		SET
 			status = 'DONE',
 			execution_duration_ns = ?,
@@ -711,23 +1557,52 @@ func (Driver) UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDura
 	return err
 }
 
-// UpdateHistoryAsFailed will update the migration record as failed.
-func (Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, insertedID int64) error {
-	const updateHistoryAsFailedQuery = `
+// UpdateHistoryAsFailed will update the migration record as failed with the payload recording the error message.
+func (driver *Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, payload string, insertedID int64) error {
+	updateHistoryAsFailedQuery := `
 		UPDATE
-			bytebase.migration_history
+			` + driver.migrationHistory() + `
 		SET
 			status = 'FAILED',
-			execution_duration_ns = ?
+			execution_duration_ns = ?,
+			payload = ?
 		WHERE id = ?
 		`
-	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, insertedID)
+	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, payload, insertedID)
+	return err
+}
+
+// UpdateHistoryPayload will replace the payload of the migration record.
+func (driver *Driver) UpdateHistoryPayload(ctx context.Context, tx *sql.Tx, payload string, id int64) error {
+	updateHistoryPayloadQuery := `
+		UPDATE
+			` + driver.migrationHistory() + `
+		SET
+			payload = ?
+		WHERE id = ?
+		`
+	_, err := tx.ExecContext(ctx, updateHistoryPayloadQuery, payload, id)
 	return err
 }
 
 // ExecuteMigration will execute the migration.
 func (driver *Driver) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
-	return util.ExecuteMigration(ctx, driver.l, driver, m, statement)
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "ExecuteMigration", driver.dbType, db.MigrationSpanAttributes(m))
+	if err := driver.driverConfig.ValidateStatement(driver.dbType, statement, m); err != nil {
+		span.End(err)
+		return -1, "", err
+	}
+	migrationHistoryID, updatedSchema, err := util.ExecuteMigration(ctx, driver.l, driver, m, statement)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+// Rollback will revert the applied migration version.
+func (driver *Driver) Rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Rollback", driver.dbType, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := util.Rollback(ctx, driver.l, driver, m, version)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
 }
 
 // FindMigrationHistoryList finds the migration history.
@@ -747,13 +1622,13 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 		status,
 		version,
 		description,
-		statement,
+		` + util.MigrationHistoryStatementColumn(find) + `,
 		` + "`schema`," + `
 		schema_prev,
 		execution_duration_ns,
 		issue_id,
 		payload
-		FROM bytebase.migration_history `
+		FROM ` + driver.migrationHistory() + ` `
 	paramNames, params := []string{}, []interface{}{}
 	if v := find.ID; v != nil {
 		paramNames, params = append(paramNames, "id"), append(params, *v)
@@ -772,6 +1647,12 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 	if v := find.Source; v != nil {
 		paramNames, params = append(paramNames, "source"), append(params, *v)
 	}
+	if v := find.Status; v != nil {
+		paramNames, params = append(paramNames, "status"), append(params, *v)
+	}
+	if v := find.IssueID; v != nil {
+		paramNames, params = append(paramNames, "issue_id"), append(params, *v)
+	}
 	var query = baseQuery +
 		db.FormatParamNameInQuestionMark(paramNames) +
 		`ORDER BY created_ts DESC`
@@ -793,11 +1674,11 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 }
 
 func (driver *Driver) updateMigrationHistoryStorageVersion(ctx context.Context) error {
-	sqldb, err := driver.GetDbConnection(ctx, "bytebase")
+	sqldb, err := driver.GetDbConnection(ctx, driver.getMigrationHistorySchema())
 	if err != nil {
 		return err
 	}
-	query := `SELECT id, version FROM bytebase.migration_history`
+	query := `SELECT id, version FROM ` + driver.migrationHistory()
 	rows, err := sqldb.Query(query)
 	if err != nil {
 		return err
@@ -820,7 +1701,7 @@ func (driver *Driver) updateMigrationHistoryStorageVersion(ctx context.Context)
 
 	updateQuery := `
 		UPDATE
-			bytebase.migration_history
+			` + driver.migrationHistory() + `
 		SET
 			version = ?
 		WHERE id = ? AND version = ?
@@ -891,8 +1772,8 @@ func (driver *Driver) Dump(ctx context.Context, database string, out io.Writer,
 	// mysqldump -u root --databases dbName --no-data --routines --events --triggers --compact
 
 	options := sql.TxOptions{}
-	// TiDB does not support readonly, so we only set for MySQL.
-	if driver.dbType == "MYSQL" {
+	// TiDB does not support readonly, so we only set for MySQL and MariaDB.
+	if driver.dbType != db.TiDB {
 		options.ReadOnly = true
 	}
 	txn, err := driver.db.BeginTx(ctx, &options)
@@ -921,13 +1802,13 @@ func (driver *Driver) Restore(ctx context.Context, sc *bufio.Scanner) (err error
 	defer txn.Rollback()
 
 	f := func(stmt string) error {
-		if _, err := txn.Exec(stmt); err != nil {
+		if _, err := txn.ExecContext(ctx, stmt); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	if err := util.ApplyMultiStatements(sc, f); err != nil {
+	if err := db.ApplyStatements(sc, driver.dbType, f); err != nil {
 		return err
 	}
 
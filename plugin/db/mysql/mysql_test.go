@@ -0,0 +1,219 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func init() {
+	sql.Register("fakemysql", fakeSQLDriver{})
+}
+
+// fakeSQLDriver is a database/sql driver serving a MySQL 8.0.29 server with an empty database "db1".
+// It answers the queries other than those of the version, the database and the lock with no rows.
+type fakeSQLDriver struct{}
+
+func (fakeSQLDriver) Open(string) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{query: query}, nil
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+// BeginTx supports the read-only transactions of Dump.
+func (fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (fakeStmt) Close() error {
+	return nil
+}
+
+func (fakeStmt) NumInput() int {
+	return -1
+}
+
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return fakeResult{}, nil
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	query := strings.TrimSpace(s.query)
+	switch {
+	case strings.HasPrefix(query, "SELECT VERSION()"):
+		return &fakeRows{columns: []string{"VERSION()"}, values: [][]driver.Value{{"8.0.29"}}}, nil
+	case strings.HasPrefix(query, "SELECT MAX("):
+		// The aggregation returns NULL for no migration history.
+		return &fakeRows{columns: []string{"MAX"}, values: [][]driver.Value{{nil}}}, nil
+	case strings.HasPrefix(query, "SELECT GET_LOCK("):
+		return &fakeRows{columns: []string{"GET_LOCK"}, values: [][]driver.Value{{int64(1)}}}, nil
+	case strings.HasPrefix(query, "SHOW DATABASES"):
+		return &fakeRows{columns: []string{"Database"}, values: [][]driver.Value{{"db1"}}}, nil
+	case strings.HasPrefix(query, "SHOW CREATE DATABASE"):
+		return &fakeRows{columns: []string{"Database", "Create Database"}, values: [][]driver.Value{{"db1", "CREATE DATABASE `db1`"}}}, nil
+	}
+	return &fakeRows{columns: []string{"c"}}, nil
+}
+
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) {
+	return 1, nil
+}
+
+func (fakeResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// TestDriverConcurrency calls the driver concurrently, which is meant to be run with -race.
+func TestDriverConcurrency(t *testing.T) {
+	sqldb, err := sql.Open("fakemysql", "")
+	require.NoError(t, err)
+	d := newDriver(db.DriverConfig{Logger: zap.NewNop()}).(*Driver)
+	d.dbType = db.MySQL
+	d.db = sqldb
+	// The read replica is opened lazily without connecting to it, and the primary serves the reads of d.
+	replicaDriver := newDriver(db.DriverConfig{Logger: zap.NewNop()}).(*Driver)
+	replicaDriver.dbType = db.MySQL
+	replicaDriver.config = db.ConnectionConfig{ReadReplicaHost: "127.0.0.1", ReadReplicaPort: "3306"}
+
+	ctx := context.Background()
+	const goroutines, iterations = 8, 20
+	// The goroutines use assert instead of require, which can only stop the test from the test goroutine.
+	// start releases the goroutines together, so that they race on the lazily initialized state.
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			for j := 0; j < iterations; j++ {
+				_, err := replicaDriver.getReadOnlyDB()
+				assert.NoError(t, err)
+				_, err = d.Execute(ctx, "INSERT INTO t VALUES (1)")
+				assert.NoError(t, err)
+				_, err = d.ExecutePrepared(ctx, fmt.Sprintf("INSERT INTO t%d VALUES (?)", j%(stmtCacheSize+1)), i)
+				assert.NoError(t, err)
+				_, _, err = d.SyncSchema(ctx)
+				assert.NoError(t, err)
+				assert.NoError(t, d.SetupMigrationIfNeeded(ctx))
+				_, err = d.NeedsSetupMigration(ctx)
+				assert.NoError(t, err)
+				_, _, err = d.ExecuteMigration(ctx, &db.MigrationInfo{
+					Version:   fmt.Sprintf("%d.%d", i, j),
+					Namespace: "db1",
+					Database:  "db1",
+					Source:    db.LIBRARY,
+					Type:      db.Migrate,
+				}, "CREATE TABLE t (id INT)")
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	require.NoError(t, d.Close(ctx))
+	require.NoError(t, replicaDriver.Close(ctx))
+}
+
+func TestBeginTx(t *testing.T) {
+	sqldb, err := sql.Open("fakemysql", "")
+	require.NoError(t, err)
+	d := newDriver(db.DriverConfig{Logger: zap.NewNop()}).(*Driver)
+	d.dbType = db.MySQL
+	d.db = sqldb
+	ctx := context.Background()
+
+	tx, err := d.BeginTx(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	result, err := tx.Execute(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.Equal(t, db.ExecuteResult{RowsAffected: 1, LastInsertID: 1}, result)
+	rows, err := tx.Query(ctx, "SELECT VERSION()", 0)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"8.0.29"}, rows[2].([]interface{})[0])
+	require.NoError(t, tx.Commit())
+	require.NoError(t, tx.Rollback())
+	require.NoError(t, d.Close(ctx))
+}
+
+func TestQuoteRole(t *testing.T) {
+	tests := []struct {
+		role string
+		want string
+	}{
+		{role: "ddl_admin", want: "'ddl_admin'"},
+		{role: "ddl_admin@%", want: "'ddl_admin'@'%'"},
+		{role: "o'brien@localhost", want: "'o''brien'@'localhost'"},
+		{role: "a@b@10.0.0.1", want: "'a@b'@'10.0.0.1'"},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.want, quoteRole(test.role), test.role)
+	}
+}
+
+func TestResetRoleStatement(t *testing.T) {
+	require.Equal(t, "SET ROLE DEFAULT", resetRoleStatement(db.MySQL))
+	require.Equal(t, "SET ROLE DEFAULT", resetRoleStatement(db.TiDB))
+	require.Equal(t, "SET ROLE NONE", resetRoleStatement(db.MariaDB))
+}
@@ -0,0 +1,121 @@
+package mysql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/bytebase/bytebase/plugin/db"
+)
+
+var (
+	_ db.PreparedExecutor = (*Driver)(nil)
+)
+
+// stmtCacheSize is the maximum number of the prepared statements cached by a driver.
+const stmtCacheSize = 64
+
+// stmtCache is the LRU cache of the prepared statements keyed by the statement text.
+type stmtCache struct {
+	mu       sync.Mutex
+	capacity int
+	// lru is the list of *stmtCacheEntry with the most recently used one at the front.
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+type stmtCacheEntry struct {
+	statement string
+	stmt      *sql.Stmt
+	// refs is the number of the callers using the statement, which are between get and release.
+	refs int
+	// evicted is whether the entry has been removed from the cache, and the statement is closed when refs drops to 0.
+	evicted bool
+}
+
+func newStmtCache(capacity int) *stmtCache {
+	return &stmtCache{
+		capacity: capacity,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the cache entry of the statement text, and prepares the statement on a cache miss.
+// The least recently used entry is evicted if the cache is full. The caller must call release after using the statement.
+func (c *stmtCache) get(ctx context.Context, sqldb *sql.DB, statement string) (*stmtCacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[statement]; ok {
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*stmtCacheEntry)
+		entry.refs++
+		return entry, nil
+	}
+
+	stmt, err := sqldb.PrepareContext(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+	entry := &stmtCacheEntry{statement: statement, stmt: stmt, refs: 1}
+	c.items[statement] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		c.evict(oldest.Value.(*stmtCacheEntry))
+	}
+	return entry, nil
+}
+
+// release releases the entry returned by get, and closes its statement if it has been evicted and isn't used by others.
+func (c *stmtCache) release(entry *stmtCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// evict removes the entry from the items, and closes its statement unless it's in use. It must be called holding the mutex.
+func (c *stmtCache) evict(entry *stmtCacheEntry) {
+	delete(c.items, entry.statement)
+	entry.evicted = true
+	if entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// close evicts all the prepared statements, and the ones in use are closed when they're released.
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		c.evict(elem.Value.(*stmtCacheEntry))
+	}
+	c.lru.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// ExecutePrepared executes the parameterized statement with the args using a cached prepared statement.
+func (driver *Driver) ExecutePrepared(ctx context.Context, statement string, args ...interface{}) (db.ExecuteResult, error) {
+	ctx, cancel := driver.withStatementTimeout(ctx)
+	defer cancel()
+
+	var result db.ExecuteResult
+	entry, err := driver.stmtCache.get(ctx, driver.db, statement)
+	if err != nil {
+		return result, driver.convertStatementTimeoutError(ctx, err)
+	}
+	defer driver.stmtCache.release(entry)
+	sqlResult, err := entry.stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return result, driver.convertStatementTimeoutError(ctx, err)
+	}
+	result.Add(sqlResult)
+	return result, nil
+}
@@ -0,0 +1,40 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStmtCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	sqldb, err := sql.Open("fakemysql", "")
+	require.NoError(t, err)
+	defer sqldb.Close()
+	c := newStmtCache(1)
+
+	a, err := c.get(ctx, sqldb, "INSERT INTO a VALUES (?)")
+	require.NoError(t, err)
+	b, err := c.get(ctx, sqldb, "INSERT INTO b VALUES (?)")
+	require.NoError(t, err)
+	// a is evicted by b, but it isn't closed until it's released.
+	_, err = a.stmt.ExecContext(ctx, 1)
+	require.NoError(t, err)
+	c.release(a)
+	_, err = a.stmt.ExecContext(ctx, 1)
+	require.Error(t, err)
+
+	// b is still cached after it's released.
+	c.release(b)
+	b2, err := c.get(ctx, sqldb, "INSERT INTO b VALUES (?)")
+	require.NoError(t, err)
+	require.Same(t, b, b2)
+	c.close()
+	_, err = b.stmt.ExecContext(ctx, 1)
+	require.NoError(t, err)
+	c.release(b2)
+	_, err = b.stmt.ExecContext(ctx, 1)
+	require.Error(t, err)
+}
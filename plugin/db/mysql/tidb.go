@@ -0,0 +1,235 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bytebase/bytebase/plugin/db/util"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+)
+
+var (
+	// tidbDDLJobPollInterval is the interval to poll the TiDB DDL jobs.
+	tidbDDLJobPollInterval = 500 * time.Millisecond
+	// tidbDDLJobDoneStates are the states of the DDL jobs that are no longer running.
+	tidbDDLJobDoneStates = map[string]bool{
+		"synced":        true,
+		"cancelled":     true,
+		"rollback done": true,
+	}
+)
+
+// tidbTableSize is the approximate size of a TiDB table.
+type tidbTableSize struct {
+	dataSize  int64
+	indexSize int64
+}
+
+// getTiDBTableSizeMap gets the approximate table sizes from the TiKV regions matching the where condition, keyed by "dbName/tableName".
+// The DATA_LENGTH and INDEX_LENGTH in information_schema.TABLES are estimated from the row count and the column types in TiDB,
+// while the region sizes reflect the data stored in TiKV.
+func getTiDBTableSizeMap(ctx context.Context, sqldb *sql.DB, where string) (map[string]tidbTableSize, error) {
+	query := `
+			SELECT
+				DB_NAME,
+				TABLE_NAME,
+				IS_INDEX,
+				CAST(SUM(APPROXIMATE_SIZE) AS SIGNED)
+			FROM information_schema.TIKV_REGION_STATUS
+			WHERE ` + where + `
+			GROUP BY DB_NAME, TABLE_NAME, IS_INDEX`
+	rows, err := sqldb.QueryContext(ctx, query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	sizeMap := make(map[string]tidbTableSize)
+	for rows.Next() {
+		var dbName, tableName string
+		var isIndex bool
+		var sizeMB int64
+		if err := rows.Scan(
+			&dbName,
+			&tableName,
+			&isIndex,
+			&sizeMB,
+		); err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("%s/%s", dbName, tableName)
+		size := sizeMap[key]
+		// APPROXIMATE_SIZE is in MiB.
+		if isIndex {
+			size.indexSize += sizeMB * 1024 * 1024
+		} else {
+			size.dataSize += sizeMB * 1024 * 1024
+		}
+		sizeMap[key] = size
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sizeMap, nil
+}
+
+// tidbDDLTarget is the schema and the table changed by a DDL statement.
+// The table is empty for the schema DDL such as CREATE DATABASE.
+type tidbDDLTarget struct {
+	schema string
+	table  string
+}
+
+// tidbDDLTargetCollector collects the tables in a DDL statement.
+type tidbDDLTargetCollector struct {
+	currentDatabase string
+	targets         []tidbDDLTarget
+}
+
+func (c *tidbDDLTargetCollector) Enter(in ast.Node) (ast.Node, bool) {
+	if table, ok := in.(*ast.TableName); ok {
+		schema := table.Schema.O
+		if schema == "" {
+			schema = c.currentDatabase
+		}
+		c.targets = append(c.targets, tidbDDLTarget{schema: schema, table: table.Name.O})
+	}
+	return in, false
+}
+
+func (*tidbDDLTargetCollector) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// getTiDBDDLTargets returns the schemas and tables changed by the DDL statements, and whether there's any DDL statement.
+// The unqualified tables are in the current database, which starts from the connection database and follows the USE statements.
+// If the statements can't be parsed, it returns nil targets and true, so that the caller waits for all the running DDL jobs.
+func getTiDBDDLTargets(currentDatabase string, statements ...string) ([]tidbDDLTarget, bool) {
+	p := parser.New()
+	collector := &tidbDDLTargetCollector{currentDatabase: currentDatabase}
+	hasDDL := false
+	for _, statement := range statements {
+		nodeList, _, err := p.Parse(statement, "", "")
+		if err != nil {
+			return nil, true
+		}
+		for _, node := range nodeList {
+			switch stmt := node.(type) {
+			case *ast.UseStmt:
+				collector.currentDatabase = stmt.DBName
+			case *ast.CreateDatabaseStmt:
+				hasDDL = true
+				collector.targets = append(collector.targets, tidbDDLTarget{schema: stmt.Name})
+			case *ast.AlterDatabaseStmt:
+				hasDDL = true
+				schema := stmt.Name
+				if schema == "" {
+					schema = collector.currentDatabase
+				}
+				collector.targets = append(collector.targets, tidbDDLTarget{schema: schema})
+			case *ast.DropDatabaseStmt:
+				hasDDL = true
+				collector.targets = append(collector.targets, tidbDDLTarget{schema: stmt.Name})
+			case ast.DDLNode:
+				hasDDL = true
+				stmt.Accept(collector)
+			}
+		}
+	}
+	return collector.targets, hasDDL
+}
+
+// matchTiDBDDLTarget returns whether the DDL job on the schema and the table is for one of the targets.
+// Nil targets match all the jobs.
+func matchTiDBDDLTarget(targets []tidbDDLTarget, schema, table string) bool {
+	if targets == nil {
+		return true
+	}
+	for _, target := range targets {
+		if strings.EqualFold(target.schema, schema) && (target.table == "" || strings.EqualFold(target.table, table)) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitTiDBDDLJobsDone waits until the DDL jobs of the executed statements are done.
+// TiDB runs DDL as asynchronous jobs and the new schema may not be visible to all TiDB servers right after the statement returns,
+// so we wait for the jobs to be synced before recording the migration history. It returns immediately if there's no DDL statement.
+// The jobs are matched on the schemas and tables changed by the statements, so the DDL jobs of the other sessions on the other tables
+// aren't waited for.
+func (driver *Driver) waitTiDBDDLJobsDone(ctx context.Context, statements ...string) error {
+	targets, hasDDL := getTiDBDDLTargets(driver.config.Database, statements...)
+	if !hasDDL {
+		return nil
+	}
+	ticker := time.NewTicker(tidbDDLJobPollInterval)
+	defer ticker.Stop()
+	for {
+		running, err := driver.hasRunningTiDBDDLJob(ctx, targets)
+		if err != nil {
+			return err
+		}
+		if !running {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// hasRunningTiDBDDLJob returns whether there is any DDL job on the targets that isn't done.
+func (driver *Driver) hasRunningTiDBDDLJob(ctx context.Context, targets []tidbDDLTarget) (bool, error) {
+	query := "ADMIN SHOW DDL JOBS"
+	rows, err := driver.db.QueryContext(ctx, query)
+	if err != nil {
+		return false, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+
+	// The columns differ among TiDB versions, so we locate the columns by name.
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+	columnIndex := map[string]int{"DB_NAME": -1, "TABLE_NAME": -1, "STATE": -1}
+	for i, column := range columns {
+		if _, ok := columnIndex[strings.ToUpper(column)]; ok {
+			columnIndex[strings.ToUpper(column)] = i
+		}
+	}
+	for column, i := range columnIndex {
+		if i < 0 {
+			return false, fmt.Errorf("column %s not found in %q", column, query)
+		}
+	}
+
+	values := make([]sql.NullString, len(columns))
+	refs := make([]interface{}, len(columns))
+	for i := range values {
+		refs[i] = &values[i]
+	}
+	running := false
+	for rows.Next() {
+		if err := rows.Scan(refs...); err != nil {
+			return false, err
+		}
+		if tidbDDLJobDoneStates[strings.ToLower(values[columnIndex["STATE"]].String)] {
+			continue
+		}
+		if matchTiDBDDLTarget(targets, values[columnIndex["DB_NAME"]].String, values[columnIndex["TABLE_NAME"]].String) {
+			running = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return running, nil
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTiDBDDLTargets(t *testing.T) {
+	tests := []struct {
+		statements []string
+		targets    []tidbDDLTarget
+		hasDDL     bool
+	}{
+		{
+			statements: []string{"INSERT INTO t1 VALUES (1)", "SELECT * FROM t2"},
+			targets:    nil,
+			hasDDL:     false,
+		},
+		{
+			statements: []string{"CREATE TABLE t1 (id INT)", "ALTER TABLE db2.t2 ADD COLUMN c INT"},
+			targets:    []tidbDDLTarget{{schema: "db1", table: "t1"}, {schema: "db2", table: "t2"}},
+			hasDDL:     true,
+		},
+		{
+			statements: []string{"USE db3; DROP TABLE t3; CREATE DATABASE db4"},
+			targets:    []tidbDDLTarget{{schema: "db3", table: "t3"}, {schema: "db4"}},
+			hasDDL:     true,
+		},
+		{
+			statements: []string{"CREATE TABLE"},
+			targets:    nil,
+			hasDDL:     true,
+		},
+	}
+
+	for _, test := range tests {
+		targets, hasDDL := getTiDBDDLTargets("db1", test.statements...)
+		require.Equal(t, test.targets, targets, test.statements)
+		require.Equal(t, test.hasDDL, hasDDL, test.statements)
+	}
+}
+
+func TestMatchTiDBDDLTarget(t *testing.T) {
+	targets := []tidbDDLTarget{{schema: "db1", table: "t1"}, {schema: "db2"}}
+	require.True(t, matchTiDBDDLTarget(targets, "DB1", "T1"))
+	require.False(t, matchTiDBDDLTarget(targets, "db1", "t2"))
+	require.True(t, matchTiDBDDLTarget(targets, "db2", "t2"))
+	require.False(t, matchTiDBDDLTarget(targets, "db3", "t1"))
+	require.True(t, matchTiDBDDLTarget(nil, "db3", "t1"))
+}
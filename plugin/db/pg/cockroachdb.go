@@ -0,0 +1,75 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const (
+	// cockroachRetryErrorCode is the SQLSTATE of the retryable serialization errors in CockroachDB.
+	// See https://www.cockroachlabs.com/docs/stable/transaction-retry-error-reference.html
+	cockroachRetryErrorCode = "40001"
+	// cockroachMaxRetries is the maximum number of times to re-run a transaction after a retryable error.
+	cockroachMaxRetries = 5
+)
+
+var (
+	// cockroachRetryBaseInterval is the backoff before the first retry, which doubles on each retry.
+	cockroachRetryBaseInterval = 100 * time.Millisecond
+
+	// cockroachSystemDatabases are the CockroachDB internal databases skipped by SyncSchema.
+	cockroachSystemDatabases = map[string]bool{
+		"system": true,
+	}
+	// cockroachSystemSchemas are the CockroachDB internal schemas in addition to the Postgres ones.
+	cockroachSystemSchemas = map[string]bool{
+		"crdb_internal": true,
+		"pg_extension":  true,
+	}
+)
+
+// cockroachTableQuery gets the tables with the estimated row count from the CockroachDB table statistics,
+// because CockroachDB doesn't maintain pg_class.reltuples nor support pg_table_size() and pg_indexes_size().
+// The data size and the index size aren't available and are always 0.
+const cockroachTableQuery = "" +
+	"SELECT tbl.schemaname, tbl.tablename, tbl.tableowner, 0, 0, COALESCE(s.estimated_row_count, 0) " +
+	"FROM pg_catalog.pg_tables tbl " +
+	"LEFT JOIN crdb_internal.tables t ON t.database_name = current_database() AND t.schema_name = tbl.schemaname AND t.name = tbl.tablename AND t.drop_time IS NULL " +
+	"LEFT JOIN crdb_internal.table_row_statistics s ON s.table_id = t.table_id " +
+	"WHERE tbl.schemaname NOT IN ('pg_catalog', 'information_schema', 'crdb_internal', 'pg_extension');"
+
+// isCockroachRetryError returns whether the transaction failed with a retryable error and can be re-run.
+func isCockroachRetryError(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == cockroachRetryErrorCode
+}
+
+// executeWithCockroachRetry runs f, which should execute a whole transaction, and re-runs it with exponential backoff
+// if it fails with a retryable error, at most cockroachMaxRetries times.
+func (driver *Driver) executeWithCockroachRetry(ctx context.Context, f func() (db.ExecuteResult, error)) (db.ExecuteResult, error) {
+	interval := cockroachRetryBaseInterval
+	for retry := 0; ; retry++ {
+		result, err := f()
+		if err == nil || !isCockroachRetryError(err) || retry >= cockroachMaxRetries {
+			return result, err
+		}
+		driver.l.Debug("Retrying the CockroachDB transaction after a retryable error",
+			zap.Int("retry", retry+1),
+			zap.Duration("interval", interval),
+			zap.Error(err),
+		)
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return db.ExecuteResult{}, ctx.Err()
+		case <-timer.C:
+		}
+		interval *= 2
+	}
+}
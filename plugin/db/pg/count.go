@@ -0,0 +1,25 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/bytebase/bytebase/plugin/db/util"
+)
+
+var (
+	_ db.RowCounter = (*Driver)(nil)
+)
+
+// CountRows returns the exact row count of the table in the schema of the connected database.
+// The schema and table are the names as they're synced, so they're always quoted to keep their cases.
+func (driver *Driver) CountRows(ctx context.Context, schema string, table string) (int64, error) {
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"."%s"`, strings.ReplaceAll(schema, `"`, `""`), strings.ReplaceAll(table, `"`, `""`))
+	var count int64
+	if err := driver.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, util.FormatErrorWithQuery(err, query)
+	}
+	return count, nil
+}
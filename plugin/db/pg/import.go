@@ -0,0 +1,140 @@
+package pg
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/lib/pq"
+)
+
+var (
+	_ db.BulkImporter = (*Driver)(nil)
+)
+
+// maxImportLineSize is the maximum size of a TSV line to import.
+const maxImportLineSize = 64 * 1024 * 1024
+
+// BulkImport imports the rows into the table by COPY FROM STDIN in a transaction.
+func (driver *Driver) BulkImport(ctx context.Context, table string, columns []string, rows io.Reader, format db.ImportFormat) error {
+	if driver.dbType == db.Redshift {
+		return fmt.Errorf("bulk import isn't supported for %s", driver.dbType)
+	}
+	var readRow func() ([]interface{}, error)
+	switch format {
+	case db.ImportFormatCSV:
+		reader := csv.NewReader(rows)
+		reader.FieldsPerRecord = len(columns)
+		readRow = func() ([]interface{}, error) {
+			record, err := reader.Read()
+			if err != nil {
+				return nil, err
+			}
+			var values []interface{}
+			for _, field := range record {
+				values = append(values, field)
+			}
+			return values, nil
+		}
+	case db.ImportFormatTSV:
+		sc := bufio.NewScanner(rows)
+		// Allow the lines longer than the default limit, a row can contain large values.
+		sc.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxImportLineSize)
+		readRow = func() ([]interface{}, error) {
+			if !sc.Scan() {
+				if err := sc.Err(); err != nil {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+			fieldList := strings.Split(strings.TrimSuffix(sc.Text(), "\r"), "\t")
+			if len(fieldList) != len(columns) {
+				return nil, fmt.Errorf("row %q has %d fields, expecting %d", sc.Text(), len(fieldList), len(columns))
+			}
+			var values []interface{}
+			for _, field := range fieldList {
+				values = append(values, unescapeTSVField(field))
+			}
+			return values, nil
+		}
+	default:
+		return fmt.Errorf("unsupported import format %q", format)
+	}
+
+	txn, err := driver.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	stmt, err := txn.PrepareContext(ctx, copyInStatement(table, columns))
+	if err != nil {
+		return fmt.Errorf("failed to import rows into table %q, error: %w", table, err)
+	}
+	defer stmt.Close()
+
+	for {
+		values, err := readRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read the rows to import, error: %w", err)
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return fmt.Errorf("failed to import rows into table %q, error: %w", table, err)
+		}
+	}
+	// Flush the buffered rows.
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to import rows into table %q, error: %w", table, err)
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// copyInStatement returns the COPY FROM STDIN statement of the columns of the table, which can be qualified as "schema.table".
+func copyInStatement(table string, columns []string) string {
+	if i := strings.Index(table, "."); i >= 0 {
+		return pq.CopyInSchema(table[:i], table[i+1:], columns...)
+	}
+	return pq.CopyIn(table, columns...)
+}
+
+// unescapeTSVField unescapes the TSV field, and returns nil for NULL.
+func unescapeTSVField(field string) interface{} {
+	if field == `\N` {
+		return nil
+	}
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] != '\\' || i+1 == len(field) {
+			b.WriteByte(field[i])
+			continue
+		}
+		i++
+		switch field[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case '0':
+			b.WriteByte(0)
+		default:
+			// "\\" and the other escaped characters are the characters themselves.
+			b.WriteByte(field[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,32 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnescapeTSVField(t *testing.T) {
+	tests := []struct {
+		field string
+		want  interface{}
+	}{
+		{field: "abc", want: "abc"},
+		{field: "", want: ""},
+		{field: `\N`, want: nil},
+		{field: `\\N`, want: `\N`},
+		{field: `a\tb\nc\rd`, want: "a\tb\nc\rd"},
+		{field: `a\0b`, want: "a\x00b"},
+		{field: `a\\b`, want: `a\b`},
+		{field: `a\,b`, want: "a,b"},
+		{field: `trailing\`, want: `trailing\`},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.want, unescapeTSVField(test.field), test.field)
+	}
+}
+
+func TestCopyInStatement(t *testing.T) {
+	require.Equal(t, `COPY "t" ("a", "b") FROM STDIN`, copyInStatement("t", []string{"a", "b"}))
+	require.Equal(t, `COPY "s"."t" ("a") FROM STDIN`, copyInStatement("s.t", []string{"a"}))
+}
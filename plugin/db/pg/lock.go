@@ -0,0 +1,77 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"go.uber.org/zap"
+)
+
+var (
+	_ db.MigrationLocker = (*Driver)(nil)
+)
+
+// migrationLockKey returns the advisory lock key of the namespace.
+func migrationLockKey(namespace string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("bytebase.migration." + namespace))
+	return int64(h.Sum64())
+}
+
+// WithMigrationLock calls fn holding the migration lock of the namespace, which is a session-level advisory lock.
+// The advisory locks are scoped to a database, so the lock is taken on a dedicated connection to the database connected by Open,
+// and the concurrent runners should connect with the same connection config. The lock is released by the server if the connection is lost.
+// CockroachDB accepts the advisory lock functions without locking, and Redshift doesn't support them, so fn is called without the lock for them.
+func (driver *Driver) WithMigrationLock(ctx context.Context, namespace string, fn func(ctx context.Context) error) error {
+	if driver.dbType != db.Postgres {
+		return fn(ctx)
+	}
+
+	// The connection pool of the driver is reopened when switching databases, so the lock uses its own one.
+	lockDB, err := sql.Open("postgres", driver.baseDSN)
+	if err != nil {
+		return err
+	}
+	defer lockDB.Close()
+	conn, err := lockDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	key := migrationLockKey(namespace)
+	timeout := driver.driverConfig.MigrationLockTimeout
+	if timeout < 0 {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+			return fmt.Errorf("failed to acquire the migration lock of namespace %q, error: %w", namespace, err)
+		}
+		if !acquired {
+			return fmt.Errorf("%w, namespace %q is being migrated by another runner", db.ErrMigrationLocked, namespace)
+		}
+	} else {
+		lockCtx := ctx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			lockCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		if _, err := conn.ExecContext(lockCtx, "SELECT pg_advisory_lock($1)", key); err != nil {
+			if ctx.Err() == nil && lockCtx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("%w, namespace %q is being migrated by another runner after %v", db.ErrMigrationLocked, namespace, timeout)
+			}
+			return fmt.Errorf("failed to acquire the migration lock of namespace %q, error: %w", namespace, err)
+		}
+	}
+	defer func() {
+		// Use a new context since ctx may have been canceled. Closing the connection also releases the lock if it fails.
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			driver.l.Warn("Failed to release the migration lock", zap.String("namespace", namespace), zap.Error(err))
+		}
+	}()
+
+	return fn(ctx)
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"regexp"
@@ -16,7 +17,7 @@ import (
 	_ "embed"
 
 	// Import pg driver.
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/bytebase/bytebase/plugin/db"
 	"github.com/bytebase/bytebase/plugin/db/util"
@@ -42,24 +43,36 @@ var (
 
 	_ db.Driver              = (*Driver)(nil)
 	_ util.MigrationExecutor = (*Driver)(nil)
+
+	_ db.StatementListExecutor = (*Driver)(nil)
+	_ db.RoleExecutor          = (*Driver)(nil)
 )
 
 func init() {
 	db.Register(db.Postgres, newDriver)
+	// CockroachDB and Redshift speak the Postgres wire protocol.
+	db.Register(db.CockroachDB, newDriver)
+	db.Register(db.Redshift, newDriver)
 }
 
 // Driver is the Postgres driver.
 type Driver struct {
 	l             *zap.Logger
+	driverConfig  db.DriverConfig
 	connectionCtx db.ConnectionContext
+	dbType        db.Type
 
 	db      *sql.DB
 	baseDSN string
+
+	// migrationSetup caches whether the migration schema has been set up by this driver instance.
+	migrationSetup bool
 }
 
 func newDriver(config db.DriverConfig) db.Driver {
 	return &Driver{
-		l: config.Logger,
+		l:            config.Logger,
+		driverConfig: config,
 	}
 }
 
@@ -71,6 +84,7 @@ func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.Connec
 	}
 
 	dsn, err := guessDSN(
+		ctx,
 		config.Username,
 		config.Password,
 		config.Host,
@@ -79,6 +93,7 @@ func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.Connec
 		config.TLSConfig.SslCA,
 		config.TLSConfig.SslCert,
 		config.TLSConfig.SslKey,
+		string(config.TLSConfig.SslMode),
 	)
 	if err != nil {
 		return nil, err
@@ -88,17 +103,19 @@ func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.Connec
 	}
 	driver.baseDSN = dsn
 	driver.connectionCtx = connCtx
+	driver.dbType = dbType
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
+	driver.driverConfig.ApplyConnectionPool(db)
 	driver.db = db
 	return driver, nil
 }
 
 // guessDSN will guess the dsn of a valid DB connection.
-func guessDSN(username, password, hostname, port, database, sslCA, sslCert, sslKey string) (string, error) {
+func guessDSN(ctx context.Context, username, password, hostname, port, database, sslCA, sslCert, sslKey, sslMode string) (string, error) {
 	// dbname is guessed if not specified.
 	m := map[string]string{
 		"host":     hostname,
@@ -107,11 +124,18 @@ func guessDSN(username, password, hostname, port, database, sslCA, sslCert, sslK
 		"password": password,
 	}
 
-	if sslCA == "" {
-		m["sslmode"] = "disable"
-	} else {
-		m["sslmode"] = "verify-ca"
-		m["sslrootcert"] = sslCA
+	// The SSL modes of db.TLSConfig share the names with libpq.
+	if sslMode == "" {
+		sslMode = "disable"
+		if sslCA != "" {
+			sslMode = "verify-ca"
+		}
+	}
+	m["sslmode"] = sslMode
+	if sslMode != "disable" {
+		if sslCA != "" {
+			m["sslrootcert"] = sslCA
+		}
 		if sslCert != "" && sslKey != "" {
 			m["sslcert"] = sslCert
 			m["sslkey"] = sslKey
@@ -143,7 +167,7 @@ func guessDSN(username, password, hostname, port, database, sslCA, sslCert, sslK
 		}
 		defer db.Close()
 
-		if err = db.Ping(); err != nil {
+		if err = db.PingContext(ctx); err != nil {
 			continue
 		}
 		return dsn, nil
@@ -156,12 +180,27 @@ func guessDSN(username, password, hostname, port, database, sslCA, sslCert, sslK
 
 // Close closes the driver.
 func (driver *Driver) Close(ctx context.Context) error {
-	return driver.db.Close()
+	driver.migrationSetup = false
+	if driver.db != nil {
+		return driver.db.Close()
+	}
+	return nil
 }
 
 // Ping pings the database.
 func (driver *Driver) Ping(ctx context.Context) error {
-	return driver.db.PingContext(ctx)
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Ping", driver.dbType, nil)
+	err := driver.db.PingContext(ctx)
+	span.End(err)
+	return err
+}
+
+// Stats returns the statistics of the connection pool.
+func (driver *Driver) Stats() sql.DBStats {
+	if driver.db == nil {
+		return sql.DBStats{}
+	}
+	return driver.db.Stats()
 }
 
 // GetDbConnection gets a database connection.
@@ -190,7 +229,14 @@ func (driver *Driver) GetVersion(ctx context.Context) (string, error) {
 }
 
 // SyncSchema synces the schema.
-func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema, error) {
+func (driver *Driver) SyncSchema(ctx context.Context, _ ...string) ([]*db.User, []*db.Schema, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "SyncSchema", driver.dbType, nil)
+	userList, schemaList, err := driver.syncSchema(ctx)
+	span.End(err)
+	return userList, schemaList, err
+}
+
+func (driver *Driver) syncSchema(ctx context.Context) ([]*db.User, []*db.Schema, error) {
 	excludedDatabaseList := map[string]bool{
 		// Skip our internal "bytebase" database
 		"bytebase": true,
@@ -205,6 +251,16 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 	for k := range systemDatabases {
 		excludedDatabaseList[k] = true
 	}
+	if driver.dbType == db.CockroachDB {
+		for k := range cockroachSystemDatabases {
+			excludedDatabaseList[k] = true
+		}
+	}
+	if driver.dbType == db.Redshift {
+		for k := range redshiftSystemDatabases {
+			excludedDatabaseList[k] = true
+		}
+	}
 
 	// Query user info
 	userList, err := driver.getUserList(ctx)
@@ -252,10 +308,16 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 		}
 
 		// Table statements.
-		tables, err := getPgTables(txn)
+		tables, err := getPgTables(txn, driver.dbType)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to get tables from database %q: %s", dbName, err)
 		}
+		var redshiftTableKeyMap map[string]*redshiftTableKey
+		if driver.dbType == db.Redshift {
+			if redshiftTableKeyMap, err = getRedshiftTableKeyMap(txn); err != nil {
+				return nil, nil, fmt.Errorf("failed to get distribution and sort keys from database %q: %s", dbName, err)
+			}
+		}
 		for _, tbl := range tables {
 			var dbTable db.Table
 			dbTable.Name = fmt.Sprintf("%s.%s", tbl.schemaName, tbl.name)
@@ -264,6 +326,11 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 			dbTable.RowCount = tbl.rowCount
 			dbTable.DataSize = tbl.tableSizeByte
 			dbTable.IndexSize = tbl.indexSizeByte
+			if key, ok := redshiftTableKeyMap[dbTable.Name]; ok {
+				dbTable.DistributionStyle = key.distributionStyle
+				dbTable.DistributionKey = key.distributionKey
+				dbTable.SortKeyList = key.sortKeyList
+			}
 			for _, col := range tbl.columns {
 				var dbColumn db.Column
 				dbColumn.Name = col.columnName
@@ -360,17 +427,24 @@ func (driver *Driver) getUserList(ctx context.Context) ([]*db.User, error) {
 }
 
 // Execute executes a SQL statement.
-func (driver *Driver) Execute(ctx context.Context, statement string) error {
+func (driver *Driver) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Execute", driver.dbType, nil)
+	result, err := driver.execute(ctx, statement)
+	span.End(err)
+	return result, err
+}
+
+func (driver *Driver) execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
 	var remainingStmts []string
 	f := func(stmt string) error {
 		stmt = strings.TrimLeft(stmt, " \t")
-		if strings.HasPrefix(stmt, "CREATE DATABASE ") {
+		if isCreateDatabaseStatement(stmt) {
 			// We don't use transaction for creating databases in Postgres.
 			// https://github.com/bytebase/bytebase/issues/202
 			if _, err := driver.db.ExecContext(ctx, stmt); err != nil {
 				return err
 			}
-		} else if strings.HasPrefix(stmt, "\\connect ") {
+		} else if isConnectStatement(stmt) {
 			// For the case of `\connect "dbname";`, we need to use GetDbConnection() instead of executing the statement.
 			parts := strings.Split(stmt, `"`)
 			if len(parts) != 3 {
@@ -385,26 +459,196 @@ func (driver *Driver) Execute(ctx context.Context, statement string) error {
 	}
 	sc := bufio.NewScanner(strings.NewReader(statement))
 	if err := util.ApplyMultiStatements(sc, f); err != nil {
-		return err
+		return db.ExecuteResult{}, err
 	}
 
 	if len(remainingStmts) == 0 {
+		return db.ExecuteResult{}, nil
+	}
+
+	if driver.dbType == db.CockroachDB {
+		// The serializable transactions in CockroachDB may fail with retryable errors under contention, and should be re-run.
+		return driver.executeWithCockroachRetry(ctx, func() (db.ExecuteResult, error) {
+			return driver.executeInTransaction(ctx, remainingStmts)
+		})
+	}
+	return driver.executeInTransaction(ctx, remainingStmts)
+}
+
+// GetType returns the database type.
+func (driver *Driver) GetType() db.Type {
+	return driver.dbType
+}
+
+// ExecuteStatementList executes the statements in one transaction, so a failed statement rolls back the whole list.
+// If there's CREATE DATABASE or `\connect`, which can't be executed in a transaction, see executeStatementListInOrder.
+func (driver *Driver) ExecuteStatementList(ctx context.Context, statementList []string) (db.ExecuteResult, error) {
+	for _, stmt := range statementList {
+		stmt = strings.TrimLeft(stmt, " \t")
+		if isCreateDatabaseStatement(stmt) || isConnectStatement(stmt) {
+			return driver.executeStatementListInOrder(ctx, statementList)
+		}
+	}
+
+	if driver.dbType == db.CockroachDB {
+		return driver.executeWithCockroachRetry(ctx, func() (db.ExecuteResult, error) {
+			return driver.executeStatementListInTransaction(ctx, "", statementList)
+		})
+	}
+	return driver.executeStatementListInTransaction(ctx, "", statementList)
+}
+
+// ExecuteStatementListAsRole executes the statements in a single transaction as the role by SET LOCAL ROLE,
+// which is reset when the transaction finishes. The statements which can't be executed in a transaction, e.g. CREATE DATABASE, aren't supported.
+func (driver *Driver) ExecuteStatementListAsRole(ctx context.Context, role string, statementList []string) (db.ExecuteResult, error) {
+	if driver.dbType == db.Redshift {
+		return db.ExecuteResult{}, fmt.Errorf("running the statements as a role isn't supported for %s", driver.dbType)
+	}
+	for _, stmt := range statementList {
+		trimmed := strings.TrimLeft(stmt, " \t")
+		if isCreateDatabaseStatement(trimmed) || isConnectStatement(trimmed) {
+			return db.ExecuteResult{}, fmt.Errorf("statement %q can't be executed as role %q, because it can't be executed in a transaction", stmt, role)
+		}
+	}
+
+	if driver.dbType == db.CockroachDB {
+		return driver.executeWithCockroachRetry(ctx, func() (db.ExecuteResult, error) {
+			return driver.executeStatementListInTransaction(ctx, role, statementList)
+		})
+	}
+	return driver.executeStatementListInTransaction(ctx, role, statementList)
+}
+
+// executeStatementListInOrder executes the statements one by one in order. CREATE DATABASE and `\connect` are executed
+// outside of a transaction, and each run of the other statements between them is executed in a transaction.
+// The returned *db.StatementError reports the statements before the failed one as committed if any of them has been committed.
+func (driver *Driver) executeStatementListInOrder(ctx context.Context, statementList []string) (db.ExecuteResult, error) {
+	var result db.ExecuteResult
+	// start is the index of the first statement of the run not executed yet.
+	start := 0
+	executeRun := func(end int) error {
+		if start == end {
+			return nil
+		}
+		runList := statementList[start:end]
+		var runResult db.ExecuteResult
+		var err error
+		if driver.dbType == db.CockroachDB {
+			runResult, err = driver.executeWithCockroachRetry(ctx, func() (db.ExecuteResult, error) {
+				return driver.executeStatementListInTransaction(ctx, "", runList)
+			})
+		} else {
+			runResult, err = driver.executeStatementListInTransaction(ctx, "", runList)
+		}
+		if err != nil {
+			var stmtErr *db.StatementError
+			if errors.As(err, &stmtErr) {
+				stmtErr.Index += start
+				stmtErr.Committed = start > 0
+			}
+			return err
+		}
+		result.RowsAffected += runResult.RowsAffected
+		if runResult.LastInsertID > 0 {
+			result.LastInsertID = runResult.LastInsertID
+		}
+		start = end
 		return nil
 	}
 
+	for i, stmt := range statementList {
+		trimmed := strings.TrimLeft(stmt, " \t")
+		if !isCreateDatabaseStatement(trimmed) && !isConnectStatement(trimmed) {
+			continue
+		}
+		if err := executeRun(i); err != nil {
+			return db.ExecuteResult{}, err
+		}
+		if isCreateDatabaseStatement(trimmed) {
+			if _, err := driver.db.ExecContext(ctx, trimmed); err != nil {
+				return db.ExecuteResult{}, &db.StatementError{Index: i, Statement: stmt, Committed: i > 0, Err: err}
+			}
+		} else {
+			// For the case of `\connect "dbname";`, we need to use GetDbConnection() instead of executing the statement.
+			parts := strings.Split(trimmed, `"`)
+			if len(parts) != 3 {
+				return db.ExecuteResult{}, &db.StatementError{Index: i, Statement: stmt, Committed: i > 0, Err: fmt.Errorf("invalid statement %q", stmt)}
+			}
+			if _, err := driver.GetDbConnection(ctx, parts[1]); err != nil {
+				return db.ExecuteResult{}, &db.StatementError{Index: i, Statement: stmt, Committed: i > 0, Err: err}
+			}
+		}
+		start = i + 1
+	}
+	if err := executeRun(len(statementList)); err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return result, nil
+}
+
+// executeStatementListInTransaction executes the statements in a single transaction, as the role if it's not empty.
+func (driver *Driver) executeStatementListInTransaction(ctx context.Context, role string, statementList []string) (db.ExecuteResult, error) {
+	var result db.ExecuteResult
 	tx, err := driver.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return result, err
 	}
 	defer tx.Rollback()
 
-	if _, err = tx.ExecContext(ctx, strings.Join(remainingStmts, "\n")); err == nil {
-		if err := tx.Commit(); err != nil {
-			return err
+	if role != "" {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL ROLE %s", quoteIdentifier(role))); err != nil {
+			return db.ExecuteResult{}, fmt.Errorf("failed to set role %q, error: %w", role, err)
 		}
 	}
 
-	return err
+	for i, stmt := range statementList {
+		sqlResult, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return db.ExecuteResult{}, &db.StatementError{Index: i, Statement: stmt, Err: err}
+		}
+		result.Add(sqlResult)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return result, nil
+}
+
+// isCreateDatabaseStatement returns whether the statement creates a database, which can't be executed in a transaction.
+func isCreateDatabaseStatement(stmt string) bool {
+	return strings.HasPrefix(stmt, "CREATE DATABASE ")
+}
+
+// isConnectStatement returns whether the statement is the psql meta-command to connect to a database, e.g. `\connect "dbname"`.
+func isConnectStatement(stmt string) bool {
+	return strings.HasPrefix(stmt, "\\connect ")
+}
+
+// executeInTransaction executes the statements in a single transaction.
+func (driver *Driver) executeInTransaction(ctx context.Context, statementList []string) (db.ExecuteResult, error) {
+	var result db.ExecuteResult
+	tx, err := driver.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	sqlResult, err := tx.ExecContext(ctx, strings.Join(statementList, "\n"))
+	if err != nil {
+		return result, err
+	}
+	result.Add(sqlResult)
+
+	if err := tx.Commit(); err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return result, nil
+}
+
+// ExecuteBatch executes the statements in a single transaction, which are sent in a single round trip.
+func (driver *Driver) ExecuteBatch(ctx context.Context, statementList []string) error {
+	return util.ExecuteMultiStatementBatch(ctx, driver.db, statementList)
 }
 
 // Query queries a SQL statement.
@@ -412,8 +656,16 @@ func (driver *Driver) Query(ctx context.Context, statement string, limit int) ([
 	return util.Query(ctx, driver.l, driver.db, statement, limit)
 }
 
+// BeginTx begins a transaction.
+func (driver *Driver) BeginTx(ctx context.Context) (db.Tx, error) {
+	return util.BeginTx(ctx, driver.db)
+}
+
 // NeedsSetupMigration returns whether it needs to setup migration.
 func (driver *Driver) NeedsSetupMigration(ctx context.Context) (bool, error) {
+	if driver.migrationSetup {
+		return false, nil
+	}
 	exist, err := driver.hasBytebaseDatabase(ctx)
 	if err != nil {
 		return false, err
@@ -425,12 +677,14 @@ func (driver *Driver) NeedsSetupMigration(ctx context.Context) (bool, error) {
 		return false, err
 	}
 
-	const query = `
+	query := `
 		SELECT
 		    1
 		FROM information_schema.tables
-		WHERE table_name = 'migration_history'
-	`
+		WHERE table_name = ` + pq.QuoteLiteral(driver.getMigrationHistoryTable())
+	if driver.driverConfig.MigrationHistorySchema != "" {
+		query += " AND table_schema = " + pq.QuoteLiteral(driver.driverConfig.MigrationHistorySchema)
+	}
 	return util.NeedsSetupMigrationSchema(ctx, driver.db, query)
 }
 
@@ -492,6 +746,7 @@ func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
 			return fmt.Errorf("failed to switch to bytebase database error: %v", err)
 		}
 
+		migrationSchema := driver.getMigrationSchema()
 		if _, err := driver.db.ExecContext(ctx, migrationSchema); err != nil {
 			driver.l.Error("Failed to initialize migration schema.",
 				zap.Error(err),
@@ -506,44 +761,84 @@ func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
 		)
 	}
 
+	driver.migrationSetup = true
 	return nil
 }
 
-// FindLargestVersionSinceBaseline will find the largest version since last baseline or branch.
-func (driver Driver) FindLargestVersionSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) (*string, error) {
+const defaultMigrationHistoryTable = "migration_history"
+
+// getMigrationHistoryTable returns the configured name of the migration history table.
+func (driver *Driver) getMigrationHistoryTable() string {
+	if driver.driverConfig.MigrationHistoryTable != "" {
+		return driver.driverConfig.MigrationHistoryTable
+	}
+	return defaultMigrationHistoryTable
+}
+
+// migrationHistory returns the quoted name of the configured migration history table in the "bytebase" database,
+// which is qualified by the configured schema, or resolved by the search path if the schema isn't configured.
+func (driver *Driver) migrationHistory() string {
+	table := pq.QuoteIdentifier(driver.getMigrationHistoryTable())
+	if driver.driverConfig.MigrationHistorySchema == "" {
+		return table
+	}
+	return pq.QuoteIdentifier(driver.driverConfig.MigrationHistorySchema) + "." + table
+}
+
+// getMigrationSchema returns the migration schema statements creating the configured migration history table.
+func (driver *Driver) getMigrationSchema() string {
+	if driver.driverConfig.MigrationHistorySchema == "" && driver.driverConfig.MigrationHistoryTable == "" {
+		return migrationSchema
+	}
+	schema := strings.NewReplacer(
+		"CREATE TABLE migration_history ", fmt.Sprintf("CREATE TABLE %s ", driver.migrationHistory()),
+		"ON migration_history ", fmt.Sprintf("ON %s ", driver.migrationHistory()),
+		"ON migration_history(", fmt.Sprintf("ON %s(", driver.migrationHistory()),
+	).Replace(migrationSchema)
+	// The configured schema may be shared with other tables, so it's only created if it doesn't exist.
+	if driver.driverConfig.MigrationHistorySchema != "" {
+		schema = fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;\n", pq.QuoteIdentifier(driver.driverConfig.MigrationHistorySchema)) + schema
+	}
+	return schema
+}
+
+// FindVersionListSinceBaseline will find the stored versions since last baseline or branch.
+func (driver Driver) FindVersionListSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) ([]string, error) {
 	largestBaselineSequence, err := driver.FindLargestSequence(ctx, tx, namespace, true /* baseline */)
 	if err != nil {
 		return nil, err
 	}
-	const getLargestVersionSinceLastBaselineQuery = `
-		SELECT MAX(version) FROM migration_history
+	getVersionListSinceLastBaselineQuery := `
+		SELECT version FROM ` + driver.migrationHistory() + `
 		WHERE namespace = $1 AND sequence >= $2
 	`
-	row, err := tx.QueryContext(ctx, getLargestVersionSinceLastBaselineQuery,
+	rows, err := tx.QueryContext(ctx, getVersionListSinceLastBaselineQuery,
 		namespace, largestBaselineSequence,
 	)
 	if err != nil {
-		return nil, util.FormatErrorWithQuery(err, getLargestVersionSinceLastBaselineQuery)
+		return nil, util.FormatErrorWithQuery(err, getVersionListSinceLastBaselineQuery)
 	}
-	defer row.Close()
+	defer rows.Close()
 
-	var version sql.NullString
-	row.Next()
-	if err := row.Scan(&version); err != nil {
-		return nil, err
+	var versionList []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versionList = append(versionList, version)
 	}
-
-	if version.Valid {
-		return &version.String, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil, nil
+	return versionList, nil
 }
 
 // FindLargestSequence will return the largest sequence number.
-func (Driver) FindLargestSequence(ctx context.Context, tx *sql.Tx, namespace string, baseline bool) (int, error) {
+func (driver Driver) FindLargestSequence(ctx context.Context, tx *sql.Tx, namespace string, baseline bool) (int, error) {
 	findLargestSequenceQuery := `
-		SELECT MAX(sequence) FROM migration_history
+		SELECT MAX(sequence) FROM ` + driver.migrationHistory() + `
 		WHERE namespace = $1`
 	if baseline {
 		findLargestSequenceQuery = fmt.Sprintf("%s AND (type = '%s' OR type = '%s')", findLargestSequenceQuery, db.Baseline, db.Branch)
@@ -571,9 +866,9 @@ func (Driver) FindLargestSequence(ctx context.Context, tx *sql.Tx, namespace str
 }
 
 // InsertPendingHistory will insert the migration record with pending status and return the inserted ID.
-func (Driver) InsertPendingHistory(ctx context.Context, tx *sql.Tx, sequence int, prevSchema string, m *db.MigrationInfo, storedVersion, statement string) (int64, error) {
-	const insertHistoryQuery = `
-	INSERT INTO migration_history (
+func (driver Driver) InsertPendingHistory(ctx context.Context, tx *sql.Tx, sequence int, prevSchema string, m *db.MigrationInfo, storedVersion, statement string) (int64, error) {
+	insertHistoryQuery := `
+	INSERT INTO ` + driver.migrationHistory() + ` (
 		created_by,
 		created_ts,
 		updated_by,
@@ -619,10 +914,10 @@ func (Driver) InsertPendingHistory(ctx context.Context, tx *sql.Tx, sequence int
 }
 
 // UpdateHistoryAsDone will update the migration record as done.
-func (Driver) UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, updatedSchema string, insertedID int64) error {
-	const updateHistoryAsDoneQuery = `
+func (driver Driver) UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, updatedSchema string, insertedID int64) error {
+	updateHistoryAsDoneQuery := `
 	UPDATE
-		migration_history
+		` + driver.migrationHistory() + `
 	SET
 		status = 'DONE',
 		execution_duration_ns = $1,
@@ -633,23 +928,52 @@ func (Driver) UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDura
 	return err
 }
 
-// UpdateHistoryAsFailed will update the migration record as failed.
-func (Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, insertedID int64) error {
-	const updateHistoryAsFailedQuery = `
+// UpdateHistoryAsFailed will update the migration record as failed with the payload recording the error message.
+func (driver Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, payload string, insertedID int64) error {
+	updateHistoryAsFailedQuery := `
 	UPDATE
-		migration_history
+		` + driver.migrationHistory() + `
 	SET
 		status = 'FAILED',
-		execution_duration_ns = $1
+		execution_duration_ns = $1,
+		payload = $2
+	WHERE id = $3
+	`
+	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, payload, insertedID)
+	return err
+}
+
+// UpdateHistoryPayload will replace the payload of the migration record.
+func (driver Driver) UpdateHistoryPayload(ctx context.Context, tx *sql.Tx, payload string, id int64) error {
+	updateHistoryPayloadQuery := `
+	UPDATE
+		` + driver.migrationHistory() + `
+	SET
+		payload = $1
 	WHERE id = $2
 	`
-	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, insertedID)
+	_, err := tx.ExecContext(ctx, updateHistoryPayloadQuery, payload, id)
 	return err
 }
 
 // ExecuteMigration will execute the migration.
 func (driver *Driver) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
-	return util.ExecuteMigration(ctx, driver.l, driver, m, statement)
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "ExecuteMigration", driver.dbType, db.MigrationSpanAttributes(m))
+	if err := driver.driverConfig.ValidateStatement(driver.dbType, statement, m); err != nil {
+		span.End(err)
+		return -1, "", err
+	}
+	migrationHistoryID, updatedSchema, err := util.ExecuteMigration(ctx, driver.l, driver, m, statement)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+// Rollback will revert the applied migration version.
+func (driver *Driver) Rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Rollback", driver.dbType, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := util.Rollback(ctx, driver.l, driver, m, version)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
 }
 
 // FindMigrationHistoryList finds the migration history.
@@ -669,13 +993,13 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 		status,
 		version,
 		description,
-		statement,
+		` + util.MigrationHistoryStatementColumn(find) + `,
 		` + `"schema",` + `
 		schema_prev,
 		execution_duration_ns,
 		issue_id,
 		payload
-		FROM migration_history `
+		FROM ` + driver.migrationHistory() + ` `
 	paramNames, params := []string{}, []interface{}{}
 	if v := find.ID; v != nil {
 		paramNames, params = append(paramNames, "id"), append(params, *v)
@@ -694,6 +1018,12 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 	if v := find.Source; v != nil {
 		paramNames, params = append(paramNames, "source"), append(params, *v)
 	}
+	if v := find.Status; v != nil {
+		paramNames, params = append(paramNames, "status"), append(params, *v)
+	}
+	if v := find.IssueID; v != nil {
+		paramNames, params = append(paramNames, "issue_id"), append(params, *v)
+	}
 	var query = baseQuery +
 		db.FormatParamNameInNumberedPosition(paramNames) +
 		`ORDER BY created_ts DESC`
@@ -721,7 +1051,7 @@ func (driver *Driver) updateMigrationHistoryStorageVersion(ctx context.Context)
 	if err != nil {
 		return err
 	}
-	query := `SELECT id, version FROM migration_history`
+	query := `SELECT id, version FROM ` + driver.migrationHistory()
 	rows, err := sqldb.Query(query)
 	if err != nil {
 		return err
@@ -744,7 +1074,7 @@ func (driver *Driver) updateMigrationHistoryStorageVersion(ctx context.Context)
 
 	updateQuery := `
 		UPDATE
-			migration_history
+			` + driver.migrationHistory() + `
 		SET
 			version = $1
 		WHERE id = $2 AND version = $3
@@ -814,13 +1144,13 @@ func (driver *Driver) Restore(ctx context.Context, sc *bufio.Scanner) (err error
 	defer txn.Rollback()
 
 	f := func(stmt string) error {
-		if _, err := txn.Exec(stmt); err != nil {
+		if _, err := txn.ExecContext(ctx, stmt); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	if err := util.ApplyMultiStatements(sc, f); err != nil {
+	if err := db.ApplyStatements(sc, db.Postgres, f); err != nil {
 		return err
 	}
 
@@ -857,7 +1187,7 @@ func (driver *Driver) dumpOneDatabase(ctx context.Context, database string, out
 	}
 
 	// Schema statements.
-	schemas, err := getPgSchemas(txn)
+	schemas, err := getPgSchemas(txn, driver.dbType)
 	if err != nil {
 		return err
 	}
@@ -879,7 +1209,7 @@ func (driver *Driver) dumpOneDatabase(ctx context.Context, database string, out
 	}
 
 	// Table statements.
-	tables, err := getPgTables(txn)
+	tables, err := getPgTables(txn, driver.dbType)
 	if err != nil {
 		return fmt.Errorf("failed to get tables from database %q: %s", database, err)
 	}
@@ -978,6 +1308,7 @@ func (driver *Driver) switchDatabase(dbName string) error {
 	if err != nil {
 		return err
 	}
+	driver.driverConfig.ApplyConnectionPool(db)
 	driver.db = db
 	return nil
 }
@@ -1265,7 +1596,7 @@ func (t eventTriggerSchema) Statement() string {
 }
 
 // getPgSchemas gets all schemas of a database.
-func getPgSchemas(txn *sql.Tx) ([]*pgSchema, error) {
+func getPgSchemas(txn *sql.Tx, dbType db.Type) ([]*pgSchema, error) {
 	var schemas []*pgSchema
 	rows, err := txn.Query("SELECT schema_name, schema_owner FROM information_schema.schemata;")
 	if err != nil {
@@ -1279,7 +1610,7 @@ func getPgSchemas(txn *sql.Tx) ([]*pgSchema, error) {
 			return nil, err
 		}
 		schema.name = quoteIdentifier(schema.name)
-		if ok := pgSystemSchema(schema.name); ok {
+		if ok := pgSystemSchema(schema.name, dbType); ok {
 			continue
 		}
 		schemas = append(schemas, &schema)
@@ -1288,10 +1619,15 @@ func getPgSchemas(txn *sql.Tx) ([]*pgSchema, error) {
 }
 
 // pgSystemSchema returns whether the schema is a system or user defined schema.
-func pgSystemSchema(s string) bool {
+// The CockroachDB and Redshift internal schemas are only system schemas for the database type,
+// and they're user defined schemas in Postgres.
+func pgSystemSchema(s string, dbType db.Type) bool {
 	if common.HasPrefixes(s, "pg_toast", "pg_temp") {
 		return true
 	}
+	if (dbType == db.CockroachDB && cockroachSystemSchemas[s]) || (dbType == db.Redshift && redshiftSystemSchemas[s]) {
+		return true
+	}
 	switch s {
 	case "pg_catalog":
 		return true
@@ -1304,7 +1640,7 @@ func pgSystemSchema(s string) bool {
 }
 
 // getTables gets all tables of a database.
-func getPgTables(txn *sql.Tx) ([]*tableSchema, error) {
+func getPgTables(txn *sql.Tx, dbType db.Type) ([]*tableSchema, error) {
 	constraints, err := getTableConstraints(txn)
 	if err != nil {
 		return nil, fmt.Errorf("getTableConstraints() got error: %v", err)
@@ -1312,9 +1648,15 @@ func getPgTables(txn *sql.Tx) ([]*tableSchema, error) {
 
 	var tables []*tableSchema
 	query := "" +
-		"SELECT tbl.schemaname, tbl.tablename, tbl.tableowner, pg_table_size(c.oid), pg_indexes_size(c.oid) " +
+		"SELECT tbl.schemaname, tbl.tablename, tbl.tableowner, pg_table_size(c.oid), pg_indexes_size(c.oid), GREATEST(c.reltuples, 0)::bigint " +
 		"FROM pg_catalog.pg_tables tbl, pg_catalog.pg_class c " +
 		"WHERE schemaname NOT IN ('pg_catalog', 'information_schema') AND tbl.schemaname=c.relnamespace::regnamespace::text AND tbl.tablename = c.relname;"
+	switch dbType {
+	case db.CockroachDB:
+		query = cockroachTableQuery
+	case db.Redshift:
+		query = redshiftTableQuery
+	}
 	rows, err := txn.Query(query)
 	if err != nil {
 		return nil, err
@@ -1324,8 +1666,8 @@ func getPgTables(txn *sql.Tx) ([]*tableSchema, error) {
 	for rows.Next() {
 		var tbl tableSchema
 		var schemaname, tablename, tableowner string
-		var tableSizeByte, indexSizeByte int64
-		if err := rows.Scan(&schemaname, &tablename, &tableowner, &tableSizeByte, &indexSizeByte); err != nil {
+		var tableSizeByte, indexSizeByte, rowCount int64
+		if err := rows.Scan(&schemaname, &tablename, &tableowner, &tableSizeByte, &indexSizeByte, &rowCount); err != nil {
 			return nil, err
 		}
 		tbl.schemaName = quoteIdentifier(schemaname)
@@ -1333,6 +1675,10 @@ func getPgTables(txn *sql.Tx) ([]*tableSchema, error) {
 		tbl.tableowner = tableowner
 		tbl.tableSizeByte = tableSizeByte
 		tbl.indexSizeByte = indexSizeByte
+		// The row count is estimated from pg_class.reltuples, which is maintained by VACUUM and ANALYZE,
+		// so that we don't need to scan every table with COUNT(*) on each schema sync.
+		// reltuples is -1 for tables that have never been vacuumed or analyzed.
+		tbl.rowCount = rowCount
 
 		tables = append(tables, &tbl)
 	}
@@ -1354,19 +1700,6 @@ func getPgTables(txn *sql.Tx) ([]*tableSchema, error) {
 }
 
 func getTable(txn *sql.Tx, tbl *tableSchema) error {
-	countQuery := fmt.Sprintf("SELECT COUNT(1) FROM %s.%s;", tbl.schemaName, tbl.name)
-	rows, err := txn.Query(countQuery)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		if err := rows.Scan(&tbl.rowCount); err != nil {
-			return err
-		}
-	}
-
 	commentQuery := fmt.Sprintf("SELECT obj_description('%s.%s'::regclass);", tbl.schemaName, tbl.name)
 	crows, err := txn.Query(commentQuery)
 	if err != nil {
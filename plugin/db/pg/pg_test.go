@@ -0,0 +1,24 @@
+package pg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMigrationSchema(t *testing.T) {
+	driver := &Driver{}
+	require.Equal(t, `"migration_history"`, driver.migrationHistory())
+	require.Equal(t, migrationSchema, driver.getMigrationSchema())
+
+	driver = &Driver{driverConfig: db.DriverConfig{MigrationHistorySchema: "Ops", MigrationHistoryTable: "schema_history"}}
+	require.Equal(t, `"Ops"."schema_history"`, driver.migrationHistory())
+	schema := driver.getMigrationSchema()
+	require.True(t, strings.HasPrefix(schema, `CREATE SCHEMA IF NOT EXISTS "Ops";`))
+	require.Contains(t, schema, `CREATE TABLE "Ops"."schema_history" (`)
+	require.Contains(t, schema, `ON "Ops"."schema_history" (namespace, version);`)
+	require.Contains(t, schema, `ON "Ops"."schema_history"(namespace, created_ts);`)
+	require.NotContains(t, schema, "ON migration_history")
+}
@@ -0,0 +1,101 @@
+package pg
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+var (
+	// redshiftSystemDatabases are the Redshift internal databases skipped by SyncSchema.
+	redshiftSystemDatabases = map[string]bool{
+		"padb_harvest": true,
+	}
+	// redshiftSystemSchemas are the Redshift internal schemas in addition to the Postgres ones.
+	redshiftSystemSchemas = map[string]bool{
+		"pg_internal": true,
+	}
+)
+
+// redshiftTableQuery gets the tables with the size and the row count from svv_table_info, because Redshift doesn't
+// support pg_table_size() and pg_indexes_size(). svv_table_info doesn't list the empty tables, so we join it with pg_tables.
+// The size is reported in 1 MB blocks, and Redshift has no indexes so the index size is always 0.
+const redshiftTableQuery = "" +
+	"SELECT tbl.schemaname, tbl.tablename, tbl.tableowner, COALESCE(info.size, 0)::bigint * 1024 * 1024, 0, COALESCE(info.tbl_rows, 0)::bigint " +
+	"FROM pg_catalog.pg_tables tbl " +
+	"LEFT JOIN svv_table_info info ON info.\"schema\" = tbl.schemaname AND info.\"table\" = tbl.tablename " +
+	"WHERE tbl.schemaname NOT IN ('pg_catalog', 'information_schema', 'pg_internal');"
+
+// redshiftTableKey is the distribution and sort key metadata of a Redshift table.
+type redshiftTableKey struct {
+	distributionStyle string
+	distributionKey   string
+	sortKeyList       []string
+}
+
+// getRedshiftTableKeyMap gets the distribution style, the distribution key and the sort keys of the tables, keyed by "schemaName.tableName"
+// with the quoted identifiers like the table names in SyncSchema.
+func getRedshiftTableKeyMap(txn *sql.Tx) (map[string]*redshiftTableKey, error) {
+	keyMap := make(map[string]*redshiftTableKey)
+
+	styleQuery := `SELECT "schema", "table", diststyle FROM svv_table_info;`
+	styleRows, err := txn.Query(styleQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer styleRows.Close()
+	for styleRows.Next() {
+		var schemaName, tableName string
+		var distributionStyle sql.NullString
+		if err := styleRows.Scan(&schemaName, &tableName, &distributionStyle); err != nil {
+			return nil, err
+		}
+		keyMap[redshiftTableKeyName(schemaName, tableName)] = &redshiftTableKey{distributionStyle: distributionStyle.String}
+	}
+	if err := styleRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// pg_attribute has the Redshift specific attisdistkey and attsortkeyord columns, and attsortkeyord is negative for the interleaved sort keys.
+	// Unlike pg_table_def, it isn't limited to the schemas in the search path.
+	keyQuery := "" +
+		"SELECT n.nspname, c.relname, a.attname, a.attisdistkey, a.attsortkeyord " +
+		"FROM pg_catalog.pg_attribute a " +
+		"JOIN pg_catalog.pg_class c ON a.attrelid = c.oid " +
+		"JOIN pg_catalog.pg_namespace n ON c.relnamespace = n.oid " +
+		"WHERE c.relkind = 'r' AND a.attnum > 0 AND (a.attisdistkey OR a.attsortkeyord <> 0) " +
+		"AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_internal') " +
+		"ORDER BY n.nspname, c.relname, ABS(a.attsortkeyord);"
+	keyRows, err := txn.Query(keyQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer keyRows.Close()
+	for keyRows.Next() {
+		var schemaName, tableName, columnName string
+		var isDistributionKey bool
+		var sortKeyOrder int
+		if err := keyRows.Scan(&schemaName, &tableName, &columnName, &isDistributionKey, &sortKeyOrder); err != nil {
+			return nil, err
+		}
+		name := redshiftTableKeyName(schemaName, tableName)
+		key, ok := keyMap[name]
+		if !ok {
+			key = &redshiftTableKey{}
+			keyMap[name] = key
+		}
+		if isDistributionKey {
+			key.distributionKey = columnName
+		}
+		if sortKeyOrder != 0 {
+			key.sortKeyList = append(key.sortKeyList, columnName)
+		}
+	}
+	if err := keyRows.Err(); err != nil {
+		return nil, err
+	}
+	return keyMap, nil
+}
+
+func redshiftTableKeyName(schemaName, tableName string) string {
+	return fmt.Sprintf("%s.%s", quoteIdentifier(schemaName), quoteIdentifier(tableName))
+}
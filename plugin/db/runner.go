@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// RunResult is the result of RunMigrations.
+type RunResult struct {
+	// AppliedList is the migrations applied by this run in order.
+	AppliedList []*Migration
+	// SkippedList is the migrations skipped because their versions have been applied.
+	SkippedList []*Migration
+	// Failed is the migration failing to apply, which stops the run. It's nil if all the migrations are applied or skipped.
+	Failed *Migration
+	// RolledBackList is the applied migrations rolled back by this run in order, see RunConfig.Rollback.
+	RolledBackList []*MigrationHistory
+}
+
+// RunConfig is the config of RunMigrationsWithConfig.
+type RunConfig struct {
+	// TargetVersion is the optional version to migrate up to, inclusive. The migrations with higher versions aren't applied.
+	// Empty value means applying all the migrations.
+	TargetVersion string
+	// Rollback is whether to roll back the applied migrations with versions higher than TargetVersion by Driver.Rollback,
+	// from the highest version down. It requires TargetVersion and RollbackVersion.
+	Rollback bool
+	// RollbackVersion returns the version of the migration recording the rollback of the applied version.
+	// The rollback is recorded as a new migration, so the returned version must be higher than all the applied versions
+	// of the namespace, e.g. a timestamp.
+	RollbackVersion func(version string) string
+	// AllowDestructive is whether the migrations can contain the destructive statements, see MigrationInfo.AllowDestructive.
+	// It allows them for all the migrations in addition to the ones allowing them by their infos.
+	AllowDestructive bool
+}
+
+// RunMigrations sets up the migration schema if needed, and applies the migrations in version order by ExecuteMigration as the creator,
+// e.g. the migrations loaded by LoadMigrations. The migrations whose versions have been applied to their namespaces are skipped unless their infos set Force.
+// It stops at the first failure, and returns the result with the applied migrations so far together with the error.
+// The migration infos aren't modified, and the migrations without a source are applied as LIBRARY.
+func RunMigrations(ctx context.Context, driver Driver, migrations []*Migration, creator string) (*RunResult, error) {
+	return RunMigrationsWithConfig(ctx, driver, migrations, creator, RunConfig{})
+}
+
+// RunMigrationsWithConfig is RunMigrations with the config.
+// If config.TargetVersion is set, the applied migrations with higher versions in the namespaces of the migrations are rolled back
+// if config.Rollback is set. Otherwise, it returns an error without applying anything if there's any, since a migration can only
+// be reverted by Driver.Rollback, which records the rollback as a new version.
+func RunMigrationsWithConfig(ctx context.Context, driver Driver, migrations []*Migration, creator string, config RunConfig) (*RunResult, error) {
+	result := &RunResult{}
+	if config.Rollback && (config.TargetVersion == "" || config.RollbackVersion == nil) {
+		return result, fmt.Errorf("rolling back migrations requires the target version and the rollback version")
+	}
+	if err := driver.SetupMigrationIfNeeded(ctx); err != nil {
+		return result, fmt.Errorf("failed to set up migration, error: %w", err)
+	}
+
+	migrationList := append([]*Migration(nil), migrations...)
+	sort.SliceStable(migrationList, func(i, j int) bool {
+		return CompareVersion(migrationList[i].Info.Version, migrationList[j].Info.Version) < 0
+	})
+	if config.TargetVersion != "" {
+		aboveList, err := findAppliedMigrationsAbove(ctx, driver, migrationList, config.TargetVersion)
+		if err != nil {
+			return result, err
+		}
+		if len(aboveList) > 0 && !config.Rollback {
+			return result, fmt.Errorf("namespace %q has applied version %s, which is higher than the target version %s", aboveList[0].Namespace, aboveList[0].Version, config.TargetVersion)
+		}
+		if err := rollbackMigrations(ctx, driver, migrationList, aboveList, creator, config, result); err != nil {
+			return result, err
+		}
+	}
+	for _, migration := range migrationList {
+		if config.TargetVersion != "" && CompareVersion(migration.Info.Version, config.TargetVersion) > 0 {
+			break
+		}
+		mi := *migration.Info
+		mi.Creator = creator
+		if config.AllowDestructive {
+			mi.AllowDestructive = true
+		}
+		if mi.Source == "" {
+			mi.Source = LIBRARY
+		}
+		if mi.Namespace == "" {
+			mi.Namespace = mi.Database
+		}
+
+		historyList, err := driver.FindMigrationHistoryList(ctx, &MigrationHistoryFind{
+			Database: &mi.Namespace,
+			Version:  &mi.Version,
+		})
+		if err != nil {
+			result.Failed = migration
+			return result, fmt.Errorf("failed to find migration history of namespace %q version %s, error: %w", mi.Namespace, mi.Version, err)
+		}
+		if len(historyList) > 0 && historyList[0].Status == Done && !mi.Force {
+			result.SkippedList = append(result.SkippedList, migration)
+			continue
+		}
+
+		if _, _, err := driver.ExecuteMigration(ctx, &mi, migration.Statement); err != nil {
+			result.Failed = migration
+			return result, fmt.Errorf("failed to apply migration %q version %s, error: %w", migration.Path, mi.Version, err)
+		}
+		result.AppliedList = append(result.AppliedList, migration)
+	}
+	return result, nil
+}
+
+// findAppliedMigrationsAbove returns the applied migrations with versions higher than the target version in the namespaces of
+// the migrations, from the highest version down. The migrations rolled back and the ones recording the rollbacks are excluded.
+func findAppliedMigrationsAbove(ctx context.Context, driver Driver, migrationList []*Migration, targetVersion string) ([]*MigrationHistory, error) {
+	var aboveList []*MigrationHistory
+	checked := make(map[string]bool)
+	for _, migration := range migrationList {
+		namespace := migration.Info.Namespace
+		if namespace == "" {
+			namespace = migration.Info.Database
+		}
+		if checked[namespace] {
+			continue
+		}
+		checked[namespace] = true
+
+		historyList, err := driver.FindMigrationHistoryList(ctx, &MigrationHistoryFind{
+			Database: &namespace,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find migration history of namespace %q, error: %w", namespace, err)
+		}
+		rollbackVersions := make(map[string]bool)
+		for _, history := range historyList {
+			if history.RolledBackBy != "" {
+				rollbackVersions[history.RolledBackBy] = true
+			}
+		}
+		for _, history := range historyList {
+			if history.Status != Done || history.RolledBackBy != "" || rollbackVersions[history.Version] {
+				continue
+			}
+			if CompareVersion(history.Version, targetVersion) > 0 {
+				aboveList = append(aboveList, history)
+			}
+		}
+	}
+	sort.SliceStable(aboveList, func(i, j int) bool {
+		return CompareVersion(aboveList[i].Version, aboveList[j].Version) > 0
+	})
+	return aboveList, nil
+}
+
+// rollbackMigrations rolls back the applied migrations in order by Driver.Rollback as the creator,
+// and records them in the result. It stops at the first failure.
+// The rollback statements are allowed to be destructive, since reverting a migration usually drops what it created.
+func rollbackMigrations(ctx context.Context, driver Driver, migrationList []*Migration, historyList []*MigrationHistory, creator string, config RunConfig, result *RunResult) error {
+	databaseMap := make(map[string]string)
+	for _, migration := range migrationList {
+		namespace := migration.Info.Namespace
+		if namespace == "" {
+			namespace = migration.Info.Database
+		}
+		databaseMap[namespace] = migration.Info.Database
+	}
+	for _, history := range historyList {
+		mi := &MigrationInfo{
+			Version:          config.RollbackVersion(history.Version),
+			Namespace:        history.Namespace,
+			Database:         databaseMap[history.Namespace],
+			Creator:          creator,
+			Source:           history.Source,
+			Type:             Migrate,
+			AllowDestructive: true,
+		}
+		if _, _, err := driver.Rollback(ctx, mi, history.Version); err != nil {
+			return fmt.Errorf("failed to roll back namespace %q version %s as version %s, error: %w", history.Namespace, history.Version, mi.Version, err)
+		}
+		result.RolledBackList = append(result.RolledBackList, history)
+	}
+	return nil
+}
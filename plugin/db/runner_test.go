@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runnerDriver is a fake driver validating the statements and recording the executed migrations in the history.
+type runnerDriver struct {
+	Driver
+	driverConfig DriverConfig
+	setup        bool
+	historyList  []*MigrationHistory
+	failVersion  string
+}
+
+func (d *runnerDriver) SetupMigrationIfNeeded(_ context.Context) error {
+	d.setup = true
+	return nil
+}
+
+func (d *runnerDriver) FindMigrationHistoryList(_ context.Context, find *MigrationHistoryFind) ([]*MigrationHistory, error) {
+	var list []*MigrationHistory
+	for _, history := range d.historyList {
+		if history.Namespace == *find.Database && (find.Version == nil || history.Version == *find.Version) {
+			list = append(list, history)
+		}
+	}
+	return list, nil
+}
+
+func (d *runnerDriver) ExecuteMigration(_ context.Context, m *MigrationInfo, statement string) (int64, string, error) {
+	if err := d.driverConfig.ValidateStatement(MySQL, statement, m); err != nil {
+		return -1, "", err
+	}
+	if m.Version == d.failVersion {
+		return -1, "", errors.New("syntax error")
+	}
+	d.historyList = append(d.historyList, &MigrationHistory{
+		Creator:   m.Creator,
+		Namespace: m.Namespace,
+		Source:    m.Source,
+		Status:    Done,
+		Version:   m.Version,
+		Statement: statement,
+	})
+	return int64(len(d.historyList)), "", nil
+}
+
+func (d *runnerDriver) Rollback(ctx context.Context, m *MigrationInfo, version string) (int64, string, error) {
+	for _, history := range d.historyList {
+		if history.Namespace == m.Namespace && history.Version == version && history.Status == Done {
+			if history.RolledBackBy != "" {
+				return -1, "", errors.New("already rolled back")
+			}
+			id, schema, err := d.ExecuteMigration(ctx, m, "DROP TABLE t"+version)
+			if err != nil {
+				return -1, "", err
+			}
+			history.RolledBackBy = m.Version
+			return id, schema, nil
+		}
+	}
+	return -1, "", errors.New("not applied")
+}
+
+func TestRunMigrations(t *testing.T) {
+	ctx := context.Background()
+	newMigration := func(version string) *Migration {
+		return &Migration{
+			Info:      &MigrationInfo{Version: version, Namespace: "db1", Database: "db1"},
+			Path:      version + ".sql",
+			Statement: "CREATE TABLE t" + version,
+		}
+	}
+	driver := &runnerDriver{
+		historyList: []*MigrationHistory{{Namespace: "db1", Version: "1.1", Status: Done}},
+		failVersion: "1.10",
+	}
+	migrationList := []*Migration{newMigration("1.10"), newMigration("1.2"), newMigration("1.1"), newMigration("1.11")}
+
+	result, err := RunMigrations(ctx, driver, migrationList, "bob")
+	require.Error(t, err)
+	require.True(t, driver.setup)
+	require.Equal(t, []*Migration{migrationList[2]}, result.SkippedList)
+	require.Equal(t, []*Migration{migrationList[1]}, result.AppliedList)
+	require.Equal(t, migrationList[0], result.Failed)
+	require.Len(t, driver.historyList, 2)
+	require.Equal(t, "bob", driver.historyList[1].Creator)
+	require.Equal(t, LIBRARY, driver.historyList[1].Source)
+	require.Equal(t, MigrationSource(""), migrationList[1].Info.Source)
+
+	driver.failVersion = ""
+	result, err = RunMigrations(ctx, driver, migrationList, "bob")
+	require.NoError(t, err)
+	require.Len(t, result.SkippedList, 2)
+	require.Equal(t, []*Migration{migrationList[0], migrationList[3]}, result.AppliedList)
+	require.Nil(t, result.Failed)
+}
+
+func TestRunMigrationsWithTargetVersion(t *testing.T) {
+	ctx := context.Background()
+	var migrationList []*Migration
+	for _, version := range []string{"1.1", "1.2", "1.10"} {
+		migrationList = append(migrationList, &Migration{
+			Info:      &MigrationInfo{Version: version, Namespace: "db1", Database: "db1"},
+			Path:      version + ".sql",
+			Statement: "CREATE TABLE t" + version,
+		})
+	}
+	driver := &runnerDriver{}
+
+	result, err := RunMigrationsWithConfig(ctx, driver, migrationList, "bob", RunConfig{TargetVersion: "1.2"})
+	require.NoError(t, err)
+	require.Equal(t, migrationList[:2], result.AppliedList)
+	require.Len(t, driver.historyList, 2)
+
+	result, err = RunMigrationsWithConfig(ctx, driver, migrationList, "bob", RunConfig{TargetVersion: "1.10"})
+	require.NoError(t, err)
+	require.Equal(t, migrationList[2:], result.AppliedList)
+
+	_, err = RunMigrationsWithConfig(ctx, driver, migrationList, "bob", RunConfig{TargetVersion: "1.2"})
+	require.Error(t, err)
+	require.Len(t, driver.historyList, 3)
+}
+
+func TestRunMigrationsWithRollback(t *testing.T) {
+	ctx := context.Background()
+	var migrationList []*Migration
+	for _, version := range []string{"1.1", "1.2", "1.10"} {
+		migrationList = append(migrationList, &Migration{
+			Info:      &MigrationInfo{Version: version, Namespace: "db1", Database: "db1"},
+			Path:      version + ".sql",
+			Statement: "CREATE TABLE t" + version,
+		})
+	}
+	driver := &runnerDriver{}
+	_, err := RunMigrations(ctx, driver, migrationList, "bob")
+	require.NoError(t, err)
+
+	_, err = RunMigrationsWithConfig(ctx, driver, migrationList, "bob", RunConfig{TargetVersion: "1.1", Rollback: true})
+	require.Error(t, err)
+
+	n := 0
+	config := RunConfig{
+		TargetVersion: "1.1",
+		Rollback:      true,
+		RollbackVersion: func(string) string {
+			n++
+			return fmt.Sprintf("2.%d", n)
+		},
+	}
+	result, err := RunMigrationsWithConfig(ctx, driver, migrationList, "bob", config)
+	require.NoError(t, err)
+	require.Len(t, result.RolledBackList, 2)
+	require.Equal(t, "1.10", result.RolledBackList[0].Version)
+	require.Equal(t, "1.2", result.RolledBackList[1].Version)
+	require.Equal(t, []*Migration{migrationList[0]}, result.SkippedList)
+	require.Len(t, driver.historyList, 5)
+	require.Equal(t, "2.1", driver.historyList[2].RolledBackBy)
+	require.Equal(t, "DROP TABLE t1.2", driver.historyList[4].Statement)
+
+	// The rolled back migrations and the rollback migrations aren't above the target version.
+	result, err = RunMigrationsWithConfig(ctx, driver, migrationList, "bob", config)
+	require.NoError(t, err)
+	require.Empty(t, result.RolledBackList)
+	_, err = RunMigrationsWithConfig(ctx, driver, migrationList, "bob", RunConfig{TargetVersion: "1.1"})
+	require.NoError(t, err)
+}
+
+func TestRunMigrationsWithAllowDestructive(t *testing.T) {
+	ctx := context.Background()
+	migrationList := []*Migration{{
+		Info:      &MigrationInfo{Version: "1.1", Namespace: "db1", Database: "db1"},
+		Path:      "1.1.sql",
+		Statement: "DROP TABLE t1",
+	}}
+	driver := &runnerDriver{}
+
+	result, err := RunMigrations(ctx, driver, migrationList, "bob")
+	require.ErrorIs(t, err, ErrDestructiveStatement)
+	require.Equal(t, migrationList[0], result.Failed)
+
+	result, err = RunMigrationsWithConfig(ctx, driver, migrationList, "bob", RunConfig{AllowDestructive: true})
+	require.NoError(t, err)
+	require.Equal(t, migrationList, result.AppliedList)
+	require.False(t, migrationList[0].Info.AllowDestructive)
+}
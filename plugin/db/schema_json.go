@@ -0,0 +1,119 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ExportSchemaJSON writes the schemas as indented JSON in a deterministic order, so that the snapshots can be stored and diffed in git.
+// The schemas are sorted by name, and so are the users, tables, views, routines and sequences in a schema, and the foreign keys
+// and triggers in a table. The columns are sorted by position, and the indexes by name and position. The partitions keep their order.
+// The stats such as the row count, sizes and timestamps change without any schema change, so they're left out as zero values.
+// The schemas passed in aren't modified.
+func ExportSchemaJSON(schemaList []*Schema, w io.Writer) error {
+	var exportList []*Schema
+	for _, schema := range schemaList {
+		exportList = append(exportList, normalizeSchema(schema))
+	}
+	sort.Slice(exportList, func(i, j int) bool {
+		return exportList[i].Name < exportList[j].Name
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(exportList); err != nil {
+		return fmt.Errorf("failed to export schema, error: %w", err)
+	}
+	return nil
+}
+
+// ImportSchemaJSON reads the schemas written by ExportSchemaJSON, e.g. to compare a snapshot with DiffSchema without a live connection.
+func ImportSchemaJSON(r io.Reader) ([]*Schema, error) {
+	var schemaList []*Schema
+	if err := json.NewDecoder(r).Decode(&schemaList); err != nil {
+		return nil, fmt.Errorf("failed to import schema, error: %w", err)
+	}
+	return schemaList, nil
+}
+
+// normalizeSchema returns a sorted copy of the schema without the stats.
+func normalizeSchema(schema *Schema) *Schema {
+	s := *schema
+	s.UserList = append([]User(nil), schema.UserList...)
+	sort.Slice(s.UserList, func(i, j int) bool {
+		return s.UserList[i].Name < s.UserList[j].Name
+	})
+
+	s.TableList = nil
+	for _, table := range schema.TableList {
+		s.TableList = append(s.TableList, normalizeTable(table))
+	}
+	sort.Slice(s.TableList, func(i, j int) bool {
+		return s.TableList[i].Name < s.TableList[j].Name
+	})
+
+	s.ViewList = nil
+	for _, view := range schema.ViewList {
+		view.CreatedTs, view.UpdatedTs = 0, 0
+		s.ViewList = append(s.ViewList, view)
+	}
+	sort.Slice(s.ViewList, func(i, j int) bool {
+		return s.ViewList[i].Name < s.ViewList[j].Name
+	})
+
+	s.ProcedureList = normalizeRoutineList(schema.ProcedureList)
+	s.FunctionList = normalizeRoutineList(schema.FunctionList)
+
+	s.SequenceList = append([]Sequence(nil), schema.SequenceList...)
+	sort.Slice(s.SequenceList, func(i, j int) bool {
+		return s.SequenceList[i].Name < s.SequenceList[j].Name
+	})
+	return &s
+}
+
+func normalizeTable(table Table) Table {
+	table.CreatedTs, table.UpdatedTs = 0, 0
+	table.RowCount, table.DataSize, table.IndexSize, table.DataFree = 0, 0, 0, 0
+	table.IsRowCountExact = false
+
+	table.ColumnList = append([]Column(nil), table.ColumnList...)
+	sort.SliceStable(table.ColumnList, func(i, j int) bool {
+		return table.ColumnList[i].Position < table.ColumnList[j].Position
+	})
+	table.IndexList = append([]Index(nil), table.IndexList...)
+	sort.SliceStable(table.IndexList, func(i, j int) bool {
+		if table.IndexList[i].Name != table.IndexList[j].Name {
+			return table.IndexList[i].Name < table.IndexList[j].Name
+		}
+		return table.IndexList[i].Position < table.IndexList[j].Position
+	})
+	table.ForeignKeyList = append([]ForeignKey(nil), table.ForeignKeyList...)
+	sort.SliceStable(table.ForeignKeyList, func(i, j int) bool {
+		return table.ForeignKeyList[i].Name < table.ForeignKeyList[j].Name
+	})
+	table.TriggerList = append([]Trigger(nil), table.TriggerList...)
+	sort.SliceStable(table.TriggerList, func(i, j int) bool {
+		return table.TriggerList[i].Name < table.TriggerList[j].Name
+	})
+	var partitionList []Partition
+	for _, partition := range table.PartitionList {
+		partition.RowCount = 0
+		partitionList = append(partitionList, partition)
+	}
+	table.PartitionList = partitionList
+	return table
+}
+
+func normalizeRoutineList(routineList []Routine) []Routine {
+	var list []Routine
+	for _, routine := range routineList {
+		routine.CreatedTs, routine.UpdatedTs = 0, 0
+		list = append(list, routine)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
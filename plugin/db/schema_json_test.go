@@ -0,0 +1,69 @@
+package db
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportSchemaJSON(t *testing.T) {
+	newSchemaList := func() []*Schema {
+		return []*Schema{
+			{
+				Name: "db2",
+			},
+			{
+				Name: "db1",
+				TableList: []Table{
+					{
+						Name:     "t2",
+						RowCount: 100,
+						ColumnList: []Column{
+							{Name: "b", Position: 2, Type: "int"},
+							{Name: "a", Position: 1, Type: "int"},
+						},
+						IndexList: []Index{
+							{Name: "idx", Expression: "b", Position: 2},
+							{Name: "PRIMARY", Expression: "a", Position: 1, Unique: true},
+							{Name: "idx", Expression: "a", Position: 1},
+						},
+					},
+					{Name: "t1", CreatedTs: 1650000000},
+				},
+				ViewList: []View{{Name: "v1", Definition: "SELECT 1", UpdatedTs: 1650000000}},
+			},
+		}
+	}
+
+	schemaList := newSchemaList()
+	var buf bytes.Buffer
+	require.NoError(t, ExportSchemaJSON(schemaList, &buf))
+	// The schemas passed in aren't modified.
+	require.Equal(t, newSchemaList(), schemaList)
+
+	// The output doesn't depend on the order and the stats.
+	reordered := newSchemaList()
+	reordered[0], reordered[1] = reordered[1], reordered[0]
+	reordered[0].TableList[0].RowCount = 200
+	var reorderedBuf bytes.Buffer
+	require.NoError(t, ExportSchemaJSON(reordered, &reorderedBuf))
+	require.Equal(t, buf.String(), reorderedBuf.String())
+
+	imported, err := ImportSchemaJSON(&buf)
+	require.NoError(t, err)
+	require.Len(t, imported, 2)
+	require.Equal(t, "db1", imported[0].Name)
+	require.Equal(t, "db2", imported[1].Name)
+	require.Equal(t, "t1", imported[0].TableList[0].Name)
+	table := imported[0].TableList[1]
+	require.Equal(t, int64(0), table.RowCount)
+	require.Equal(t, []Column{{Name: "a", Position: 1, Type: "int"}, {Name: "b", Position: 2, Type: "int"}}, table.ColumnList)
+	require.Equal(t, []Index{
+		{Name: "PRIMARY", Expression: "a", Position: 1, Unique: true},
+		{Name: "idx", Expression: "a", Position: 1},
+		{Name: "idx", Expression: "b", Position: 2},
+	}, table.IndexList)
+	require.Equal(t, []View{{Name: "v1", Definition: "SELECT 1"}}, imported[0].ViewList)
+	require.True(t, DiffSchema(imported[0], normalizeSchema(schemaList[1])).IsEmpty())
+}
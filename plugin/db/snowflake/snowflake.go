@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"fmt"
 	"io"
+	"net/url"
 	"strings"
 
 	// embed will embeds the migration schema.
@@ -39,15 +40,20 @@ func init() {
 // Driver is the Snowflake driver.
 type Driver struct {
 	l             *zap.Logger
+	driverConfig  db.DriverConfig
 	connectionCtx db.ConnectionContext
 	dbType        db.Type
 
 	db *sql.DB
+
+	// migrationSetup caches whether the migration schema has been set up by this driver instance.
+	migrationSetup bool
 }
 
 func newDriver(config db.DriverConfig) db.Driver {
 	return &Driver{
-		l: config.Logger,
+		l:            config.Logger,
+		driverConfig: config,
 	}
 }
 
@@ -79,6 +85,11 @@ func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.Connec
 	} else {
 		suffix = account
 	}
+	for _, key := range []string{"warehouse", "role"} {
+		if value := config.Options[key]; value != "" {
+			params = append(params, fmt.Sprintf("%s=%s", key, url.QueryEscape(value)))
+		}
+	}
 
 	dsn := fmt.Sprintf("%s@%s/%s", strings.Join(prefixParts, ":"), suffix, config.Database)
 	loggedDSN := fmt.Sprintf("%s@%s/%s", strings.Join(loggedPrefixParts, ":"), suffix, config.Database)
@@ -95,6 +106,7 @@ func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.Connec
 	if err != nil {
 		panic(err)
 	}
+	driver.driverConfig.ApplyConnectionPool(db)
 	driver.dbType = dbType
 	driver.db = db
 	driver.connectionCtx = connCtx
@@ -104,12 +116,27 @@ func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.Connec
 
 // Close closes the driver.
 func (driver *Driver) Close(ctx context.Context) error {
-	return driver.db.Close()
+	driver.migrationSetup = false
+	if driver.db != nil {
+		return driver.db.Close()
+	}
+	return nil
 }
 
 // Ping pings the database.
 func (driver *Driver) Ping(ctx context.Context) error {
-	return driver.db.PingContext(ctx)
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Ping", driver.dbType, nil)
+	err := driver.db.PingContext(ctx)
+	span.End(err)
+	return err
+}
+
+// Stats returns the statistics of the connection pool.
+func (driver *Driver) Stats() sql.DBStats {
+	if driver.db == nil {
+		return sql.DBStats{}
+	}
+	return driver.db.Stats()
 }
 
 // GetDbConnection gets a database connection.
@@ -143,7 +170,14 @@ func (driver *Driver) useRole(ctx context.Context, role string) error {
 }
 
 // SyncSchema synces the schema.
-func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema, error) {
+func (driver *Driver) SyncSchema(ctx context.Context, _ ...string) ([]*db.User, []*db.Schema, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "SyncSchema", driver.dbType, nil)
+	userList, schemaList, err := driver.syncSchema(ctx)
+	span.End(err)
+	return userList, schemaList, err
+}
+
+func (driver *Driver) syncSchema(ctx context.Context) ([]*db.User, []*db.Schema, error) {
 	// Query user info
 	if err := driver.useRole(ctx, accountAdminRole); err != nil {
 		return nil, nil, err
@@ -278,6 +312,8 @@ func (driver *Driver) syncTableSchema(ctx context.Context, database string) ([]d
 		}
 
 		table.Name = fmt.Sprintf("%s.%s", schemaName, tableName)
+		// Snowflake maintains the exact row count in the metadata.
+		table.IsRowCountExact = true
 		table.ColumnList = columnMap[table.Name]
 		tables = append(tables, table)
 	}
@@ -475,7 +511,14 @@ func (driver *Driver) getUserList(ctx context.Context) ([]*db.User, error) {
 }
 
 // Execute executes a SQL statement.
-func (driver *Driver) Execute(ctx context.Context, statement string) error {
+func (driver *Driver) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Execute", driver.dbType, nil)
+	result, err := driver.execute(ctx, statement)
+	span.End(err)
+	return result, err
+}
+
+func (driver *Driver) execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
 	count := 0
 	f := func(stmt string) error {
 		count++
@@ -483,34 +526,44 @@ func (driver *Driver) Execute(ctx context.Context, statement string) error {
 	}
 	sc := bufio.NewScanner(strings.NewReader(statement))
 	if err := util.ApplyMultiStatements(sc, f); err != nil {
-		return err
+		return db.ExecuteResult{}, err
 	}
 
+	var result db.ExecuteResult
 	if count <= 0 {
-		return nil
+		return result, nil
 	}
 
 	if err := driver.useRole(ctx, sysAdminRole); err != nil {
-		return nil
+		return result, nil
 	}
 	tx, err := driver.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return result, err
 	}
 	defer tx.Rollback()
 	mctx, err := snow.WithMultiStatement(ctx, count)
 	if err != nil {
-		return err
+		return result, err
 	}
-	if _, err := tx.ExecContext(mctx, statement); err != nil {
-		return err
+	sqlResult, err := tx.ExecContext(mctx, statement)
+	if err != nil {
+		return result, err
 	}
+	result.Add(sqlResult)
 
 	if err := tx.Commit(); err != nil {
-		return err
+		return db.ExecuteResult{}, err
 	}
+	return result, nil
+}
 
-	return err
+// ExecuteBatch executes the statements in a single transaction.
+func (driver *Driver) ExecuteBatch(ctx context.Context, statementList []string) error {
+	if err := driver.useRole(ctx, sysAdminRole); err != nil {
+		return err
+	}
+	return util.ExecuteBatch(ctx, driver.db, statementList)
 }
 
 // Query queries a SQL statement.
@@ -518,8 +571,19 @@ func (driver *Driver) Query(ctx context.Context, statement string, limit int) ([
 	return util.Query(ctx, driver.l, driver.db, statement, limit)
 }
 
+// BeginTx begins a transaction with the sysadmin role like Execute.
+func (driver *Driver) BeginTx(ctx context.Context) (db.Tx, error) {
+	if err := driver.useRole(ctx, sysAdminRole); err != nil {
+		return nil, err
+	}
+	return util.BeginTx(ctx, driver.db)
+}
+
 // NeedsSetupMigration returns whether it needs to setup migration.
 func (driver *Driver) NeedsSetupMigration(ctx context.Context) (bool, error) {
+	if driver.migrationSetup {
+		return false, nil
+	}
 	exist, err := driver.hasBytebaseDatabase(ctx)
 	if err != nil {
 		return false, err
@@ -565,7 +629,7 @@ func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
 			zap.String("database", driver.connectionCtx.InstanceName),
 		)
 		// Should use role SYSADMIN.
-		if err := driver.Execute(ctx, migrationSchema); err != nil {
+		if _, err := driver.Execute(ctx, migrationSchema); err != nil {
 			driver.l.Error("Failed to initialize migration schema.",
 				zap.Error(err),
 				zap.String("environment", driver.connectionCtx.EnvironmentName),
@@ -579,38 +643,41 @@ func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
 		)
 	}
 
+	driver.migrationSetup = true
 	return nil
 }
 
-// FindLargestVersionSinceBaseline will find the largest version since last baseline or branch.
-func (driver Driver) FindLargestVersionSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) (*string, error) {
+// FindVersionListSinceBaseline will find the stored versions since last baseline or branch.
+func (driver Driver) FindVersionListSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) ([]string, error) {
 	largestBaselineSequence, err := driver.FindLargestSequence(ctx, tx, namespace, true /* baseline */)
 	if err != nil {
 		return nil, err
 	}
-	const getLargestVersionSinceLastBaselineQuery = `
-		SELECT MAX(version) FROM bytebase.public.migration_history
+	const getVersionListSinceLastBaselineQuery = `
+		SELECT version FROM bytebase.public.migration_history
 		WHERE namespace = ? AND sequence >= ?
 	`
-	row, err := tx.QueryContext(ctx, getLargestVersionSinceLastBaselineQuery,
+	rows, err := tx.QueryContext(ctx, getVersionListSinceLastBaselineQuery,
 		namespace, largestBaselineSequence,
 	)
 	if err != nil {
-		return nil, util.FormatErrorWithQuery(err, getLargestVersionSinceLastBaselineQuery)
+		return nil, util.FormatErrorWithQuery(err, getVersionListSinceLastBaselineQuery)
 	}
-	defer row.Close()
+	defer rows.Close()
 
-	var version sql.NullString
-	row.Next()
-	if err := row.Scan(&version); err != nil {
-		return nil, err
+	var versionList []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versionList = append(versionList, version)
 	}
-
-	if version.Valid {
-		return &version.String, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil, nil
+	return versionList, nil
 }
 
 // FindLargestSequence will return the largest sequence number.
@@ -729,28 +796,61 @@ func (Driver) UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDura
 	return err
 }
 
-// UpdateHistoryAsFailed will update the migration record as failed.
-func (Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, insertedID int64) error {
+// UpdateHistoryAsFailed will update the migration record as failed with the payload recording the error message.
+func (Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, payload string, insertedID int64) error {
 	const updateHistoryAsFailedQuery = `
 		UPDATE
 			bytebase.public.migration_history
 		SET
 			status = 'FAILED',
-			execution_duration_ns = ?
+			execution_duration_ns = ?,
+			payload = ?
 		WHERE id = ?
 	`
-	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, insertedID)
+	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, payload, insertedID)
+	return err
+}
+
+// UpdateHistoryPayload will replace the payload of the migration record.
+func (Driver) UpdateHistoryPayload(ctx context.Context, tx *sql.Tx, payload string, id int64) error {
+	const updateHistoryPayloadQuery = `
+		UPDATE
+			bytebase.public.migration_history
+		SET
+			payload = ?
+		WHERE id = ?
+	`
+	_, err := tx.ExecContext(ctx, updateHistoryPayloadQuery, payload, id)
 	return err
 }
 
 // ExecuteMigration will execute the migration.
 func (driver *Driver) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "ExecuteMigration", driver.dbType, db.MigrationSpanAttributes(m))
+	if err := driver.driverConfig.ValidateStatement(driver.dbType, statement, m); err != nil {
+		span.End(err)
+		return -1, "", err
+	}
+	migrationHistoryID, updatedSchema, err := driver.executeMigration(ctx, m, statement)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+func (driver *Driver) executeMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
 	if err := driver.useRole(ctx, sysAdminRole); err != nil {
 		return int64(0), "", err
 	}
 	return util.ExecuteMigration(ctx, driver.l, driver, m, statement)
 }
 
+// Rollback will revert the applied migration version.
+func (driver *Driver) Rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Rollback", driver.dbType, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := util.Rollback(ctx, driver.l, driver, m, version)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
 // FindMigrationHistoryList finds the migration history.
 func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.MigrationHistoryFind) ([]*db.MigrationHistory, error) {
 	baseQuery := `
@@ -768,7 +868,7 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 		status,
 		version,
 		description,
-		statement,
+		` + util.MigrationHistoryStatementColumn(find) + `,
 		schema,
 		schema_prev,
 		execution_duration_ns,
@@ -793,6 +893,12 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 	if v := find.Source; v != nil {
 		paramNames, params = append(paramNames, "source"), append(params, *v)
 	}
+	if v := find.Status; v != nil {
+		paramNames, params = append(paramNames, "status"), append(params, *v)
+	}
+	if v := find.IssueID; v != nil {
+		paramNames, params = append(paramNames, "issue_id"), append(params, *v)
+	}
 	var query = baseQuery +
 		db.FormatParamNameInQuestionMark(paramNames) +
 		`ORDER BY created_ts DESC`
@@ -993,13 +1099,13 @@ func (driver *Driver) Restore(ctx context.Context, sc *bufio.Scanner) (err error
 	defer txn.Rollback()
 
 	f := func(stmt string) error {
-		if _, err := txn.Exec(stmt); err != nil {
+		if _, err := txn.ExecContext(ctx, stmt); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	if err := util.ApplyMultiStatements(sc, f); err != nil {
+	if err := db.ApplyStatements(sc, db.Snowflake, f); err != nil {
 		return err
 	}
 
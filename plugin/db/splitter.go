@@ -0,0 +1,344 @@
+package db
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// sqlServerModuleRegexp matches the beginning of the SQL Server statements whose bodies contain statements.
+var sqlServerModuleRegexp = regexp.MustCompile(`(?i)^(CREATE(\s+OR\s+ALTER)?|ALTER)\s+(PROC|PROCEDURE|FUNCTION|TRIGGER)\s`)
+
+// SplitStatements splits the SQL text into statements for the database type.
+// See ApplyStatements for the splitting rules.
+func SplitStatements(text string, dbType Type) ([]string, error) {
+	var list []string
+	sc := bufio.NewScanner(strings.NewReader(text))
+	// Don't limit the line length for the text already in memory.
+	sc.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), len(text)+1)
+	if err := ApplyStatements(sc, dbType, func(statement string) error {
+		list = append(list, statement)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ApplyStatements splits the SQL text read from the scanner into statements for the database type and calls f on each statement in order.
+// It reads the text line by line, so it doesn't buffer the whole text, e.g. a large dump.
+// The statements are split by the delimiter ";" outside of the string literals, quoted identifiers and comments.
+//   - The single quoted, double quoted and backtick quoted text can contain the doubled quote,
+//     and can also contain the backslash escaped quote for MySQL, TiDB, MariaDB.
+//   - The comments are "-- ...", "/* ... */", and "# ..." for MySQL, TiDB, MariaDB.
+//     For MySQL, TiDB, MariaDB, "--" starts a comment only if it's followed by a whitespace or the end of the line.
+//   - For Postgres, CockroachDB, Redshift, the dollar quoted text such as "$$ ... $$" and "$body$ ... $body$" is supported.
+//   - For MySQL, TiDB, MariaDB, the "DELIMITER" command at the beginning of a statement changes the delimiter, e.g. "DELIMITER ;;".
+//   - For SQLServer, the bracket quoted identifiers such as "[a;b]" are supported, and a line of only "GO" ends the statement.
+//     CREATE or ALTER PROCEDURE, FUNCTION and TRIGGER extend to the next "GO" or the end of the text, so their bodies aren't split.
+//   - For Cassandra, the "$$ ... $$" quoted text and the "// ..." comments are supported.
+//   - For MongoDB, the text isn't SQL but a sequence of the database commands in MongoDB Extended JSON separated by whitespaces,
+//     e.g. `{"create": "users"}`, and each command document is a statement. See applyMongoDBCommands.
+//
+// The statements are trimmed and don't contain the trailing delimiter. The comments are kept in the statements,
+// but the statements consisting of only comments are skipped, except for the MySQL executable comments "/*! ... */".
+// It returns an error if a quoted text or a block comment isn't closed at the end.
+func ApplyStatements(sc *bufio.Scanner, dbType Type, f func(string) error) error {
+	if dbType == MongoDB {
+		return applyMongoDBCommands(sc, f)
+	}
+	s := newStatementSplitter(dbType)
+	for sc.Scan() {
+		if err := s.feedLine(sc.Text(), f); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return s.finish(f)
+}
+
+type splitterState int
+
+const (
+	stateNormal splitterState = iota
+	stateQuoted
+	stateBlockComment
+	stateDollarQuoted
+)
+
+type statementSplitter struct {
+	isMySQL     bool
+	isPostgres  bool
+	isSQLServer bool
+	isCassandra bool
+	delimiter   string
+
+	state splitterState
+	// quote is the closing quote of the quoted text, or the closing tag of the dollar quoted text.
+	quote string
+	buf   strings.Builder
+	// hasContent is whether the buffer has anything other than whitespaces and comments.
+	hasContent bool
+}
+
+func newStatementSplitter(dbType Type) *statementSplitter {
+	return &statementSplitter{
+		isMySQL:     dbType == MySQL || dbType == TiDB || dbType == MariaDB,
+		isPostgres:  dbType == Postgres || dbType == CockroachDB || dbType == Redshift,
+		isSQLServer: dbType == SQLServer,
+		isCassandra: dbType == Cassandra,
+		delimiter:   ";",
+	}
+}
+
+func (s *statementSplitter) feedLine(line string, f func(string) error) error {
+	if s.isMySQL && s.state == stateNormal && !s.hasContent {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) > len("DELIMITER ") && strings.EqualFold(trimmed[:len("DELIMITER ")], "DELIMITER ") {
+			s.delimiter = strings.TrimSpace(trimmed[len("DELIMITER "):])
+			// Drop the comments before the DELIMITER command.
+			s.buf.Reset()
+			return nil
+		}
+	}
+	if s.isSQLServer && s.state == stateNormal && strings.EqualFold(strings.TrimSpace(line), "GO") {
+		return s.flush(f)
+	}
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		switch s.state {
+		case stateQuoted:
+			switch {
+			// MySQL supports the backslash escape in the string literals but not in the quoted identifiers.
+			case s.isMySQL && s.quote != "`" && rest[0] == '\\' && len(rest) > 1:
+				s.buf.WriteString(rest[:2])
+				i += 2
+			case strings.HasPrefix(rest, s.quote+s.quote):
+				s.buf.WriteString(rest[:2])
+				i += 2
+			case strings.HasPrefix(rest, s.quote):
+				s.buf.WriteString(s.quote)
+				s.state = stateNormal
+				i++
+			default:
+				s.buf.WriteByte(rest[0])
+				i++
+			}
+		case stateDollarQuoted:
+			if strings.HasPrefix(rest, s.quote) {
+				s.buf.WriteString(s.quote)
+				s.state = stateNormal
+				i += len(s.quote)
+			} else {
+				s.buf.WriteByte(rest[0])
+				i++
+			}
+		case stateBlockComment:
+			if strings.HasPrefix(rest, "*/") {
+				s.buf.WriteString("*/")
+				s.state = stateNormal
+				i += 2
+			} else {
+				s.buf.WriteByte(rest[0])
+				i++
+			}
+		case stateNormal:
+			switch {
+			case strings.HasPrefix(rest, s.delimiter) && s.isSQLServer && s.inSQLServerModule():
+				s.buf.WriteString(s.delimiter)
+				i += len(s.delimiter)
+			case strings.HasPrefix(rest, s.delimiter):
+				if err := s.flush(f); err != nil {
+					return err
+				}
+				i += len(s.delimiter)
+			case s.isLineComment(rest):
+				s.buf.WriteString(rest)
+				i = len(line)
+			case strings.HasPrefix(rest, "/*"):
+				// The MySQL executable comments and the optimizer hints are executed.
+				if strings.HasPrefix(rest, "/*!") || strings.HasPrefix(rest, "/*+") {
+					s.hasContent = true
+				}
+				s.buf.WriteString("/*")
+				s.state = stateBlockComment
+				i += 2
+			case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`':
+				s.buf.WriteByte(rest[0])
+				s.quote = rest[:1]
+				s.state = stateQuoted
+				s.hasContent = true
+				i++
+			case s.isSQLServer && rest[0] == '[':
+				s.buf.WriteByte('[')
+				s.quote = "]"
+				s.state = stateQuoted
+				s.hasContent = true
+				i++
+			case s.isCassandra && strings.HasPrefix(rest, "$$"):
+				s.buf.WriteString("$$")
+				s.quote = "$$"
+				s.state = stateDollarQuoted
+				s.hasContent = true
+				i += 2
+			case s.isPostgres && rest[0] == '$':
+				if tag, ok := s.dollarQuoteTag(line, i); ok {
+					s.buf.WriteString(tag)
+					s.quote = tag
+					s.state = stateDollarQuoted
+					s.hasContent = true
+					i += len(tag)
+				} else {
+					s.buf.WriteByte(rest[0])
+					s.hasContent = true
+					i++
+				}
+			default:
+				if !isWhitespace(rest[0]) {
+					s.hasContent = true
+				}
+				s.buf.WriteByte(rest[0])
+				i++
+			}
+		}
+	}
+	s.buf.WriteByte('\n')
+	return nil
+}
+
+func (s *statementSplitter) isLineComment(rest string) bool {
+	if strings.HasPrefix(rest, "--") {
+		// MySQL requires a whitespace or the end of the line after "--".
+		return !s.isMySQL || len(rest) == 2 || isWhitespace(rest[2])
+	}
+	if s.isCassandra && strings.HasPrefix(rest, "//") {
+		return true
+	}
+	return s.isMySQL && rest[0] == '#'
+}
+
+// inSQLServerModule returns whether the buffered statement is a SQL Server procedure, function or trigger.
+func (s *statementSplitter) inSQLServerModule() bool {
+	text := s.buf.String()
+	// Skip the leading whitespaces and comments.
+	for {
+		text = strings.TrimLeft(text, " \t\r\n")
+		switch {
+		case strings.HasPrefix(text, "--"):
+			i := strings.IndexByte(text, '\n')
+			if i < 0 {
+				return false
+			}
+			text = text[i+1:]
+		case strings.HasPrefix(text, "/*"):
+			i := strings.Index(text, "*/")
+			if i < 0 {
+				return false
+			}
+			text = text[i+2:]
+		default:
+			return sqlServerModuleRegexp.MatchString(text)
+		}
+	}
+}
+
+// dollarQuoteTag returns the dollar quote tag such as "$$" or "$body$" starting at line[i].
+func (s *statementSplitter) dollarQuoteTag(line string, i int) (string, bool) {
+	// The dollar sign in an identifier such as "a$b$" doesn't start a dollar quote.
+	if i > 0 && isIdentifierChar(line[i-1]) {
+		return "", false
+	}
+	for j := i + 1; j < len(line); j++ {
+		c := line[j]
+		if c == '$' {
+			return line[i : j+1], true
+		}
+		// The tag follows the identifier rule, and can't start with a digit, so "$1" is a parameter.
+		if !isIdentifierChar(c) || (j == i+1 && c >= '0' && c <= '9') {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func (s *statementSplitter) flush(f func(string) error) error {
+	statement := strings.TrimSpace(s.buf.String())
+	hasContent := s.hasContent
+	s.buf.Reset()
+	s.hasContent = false
+	if statement == "" || !hasContent {
+		return nil
+	}
+	return f(statement)
+}
+
+func (s *statementSplitter) finish(f func(string) error) error {
+	switch s.state {
+	case stateQuoted:
+		return fmt.Errorf("unclosed quote %s", s.quote)
+	case stateDollarQuoted:
+		return fmt.Errorf("unclosed dollar quote %s", s.quote)
+	case stateBlockComment:
+		return fmt.Errorf("unclosed comment /*")
+	}
+	// Apply the remaining content without the trailing delimiter.
+	return s.flush(f)
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+// applyMongoDBCommands decodes the MongoDB command documents read from the scanner and calls f on each document in order.
+// The documents are kept as is, and they're parsed as Extended JSON by the MongoDB driver, so the values such as
+// {"$date": "2022-01-01T00:00:00Z"} keep their BSON types. It returns an error if a value isn't a JSON document.
+func applyMongoDBCommands(sc *bufio.Scanner, f func(string) error) error {
+	decoder := json.NewDecoder(&scannerReader{sc: sc})
+	for {
+		var command json.RawMessage
+		if err := decoder.Decode(&command); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("invalid MongoDB command, error: %w", err)
+		}
+		if !strings.HasPrefix(string(command), "{") {
+			return fmt.Errorf("invalid MongoDB command %s, it should be a document", command)
+		}
+		if err := f(string(command)); err != nil {
+			return err
+		}
+	}
+}
+
+// scannerReader reads the lines of the scanner joined by "\n".
+type scannerReader struct {
+	sc  *bufio.Scanner
+	buf []byte
+}
+
+func (r *scannerReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if !r.sc.Scan() {
+			if err := r.sc.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		// The bytes of the scanner are overwritten by the next scan, so they're copied.
+		r.buf = append(append(r.buf, r.sc.Bytes()...), '\n')
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		text    string
+		dbType  Type
+		want    []string
+		wantErr string
+	}{
+		{
+			text:   "CREATE TABLE t(id INT);\nINSERT INTO t VALUES (1); INSERT INTO t VALUES (2)",
+			dbType: MySQL,
+			want:   []string{"CREATE TABLE t(id INT)", "INSERT INTO t VALUES (1)", "INSERT INTO t VALUES (2)"},
+		},
+		{
+			text:   "INSERT INTO t VALUES ('a;b', \"c;d\", 'it''s', 'it\\'s;');\nSELECT `a;b` FROM t;",
+			dbType: MySQL,
+			want:   []string{"INSERT INTO t VALUES ('a;b', \"c;d\", 'it''s', 'it\\'s;')", "SELECT `a;b` FROM t"},
+		},
+		{
+			// Multi-line string literal.
+			text:   "INSERT INTO t VALUES ('line1;\nline2');",
+			dbType: MySQL,
+			want:   []string{"INSERT INTO t VALUES ('line1;\nline2')"},
+		},
+		{
+			text:   "-- comment;\n# comment;\n/* comment; */\nSELECT 1; -- trailing;\n/* only comment; */",
+			dbType: MySQL,
+			want:   []string{"-- comment;\n# comment;\n/* comment; */\nSELECT 1"},
+		},
+		{
+			// "--" without a following whitespace isn't a comment in MySQL.
+			text:   "SELECT 1--1;",
+			dbType: MySQL,
+			want:   []string{"SELECT 1--1"},
+		},
+		{
+			text:   "/*!40101 SET NAMES utf8mb4 */;\nSELECT 1;",
+			dbType: MySQL,
+			want:   []string{"/*!40101 SET NAMES utf8mb4 */", "SELECT 1"},
+		},
+		{
+			text: "DELIMITER ;;\n" +
+				"CREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND ;;\n" +
+				"DELIMITER ;\n" +
+				"SELECT 3;",
+			dbType: MySQL,
+			want:   []string{"CREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND", "SELECT 3"},
+		},
+		{
+			text:   "delimiter $$\nCREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW SET NEW.a = 1;$$\ndelimiter ;\n",
+			dbType: TiDB,
+			want:   []string{"CREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW SET NEW.a = 1;"},
+		},
+		{
+			text: "CREATE FUNCTION f() RETURNS INT AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;\n" +
+				"CREATE FUNCTION g() RETURNS TEXT AS $body$ SELECT 'a;$$'; $body$ LANGUAGE sql;\n" +
+				"SELECT $1;",
+			dbType: Postgres,
+			want: []string{
+				"CREATE FUNCTION f() RETURNS INT AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql",
+				"CREATE FUNCTION g() RETURNS TEXT AS $body$ SELECT 'a;$$'; $body$ LANGUAGE sql",
+				"SELECT $1",
+			},
+		},
+		{
+			text:   "CREATE FUNCTION f() RETURNS INT AS $$ SELECT 1; $$ LANGUAGE sql;\nSELECT 2;",
+			dbType: CockroachDB,
+			want:   []string{"CREATE FUNCTION f() RETURNS INT AS $$ SELECT 1; $$ LANGUAGE sql", "SELECT 2"},
+		},
+		{
+			// The backslash isn't an escape character in Postgres standard strings, and "#" isn't a comment.
+			text:   "SELECT 'a\\'; SELECT 1 # 2;",
+			dbType: Postgres,
+			want:   []string{"SELECT 'a\\'", "SELECT 1 # 2"},
+		},
+		{
+			// DELIMITER is MySQL only.
+			text:   "DELIMITER ;;\nSELECT 1;",
+			dbType: Postgres,
+			want:   []string{"DELIMITER", "SELECT 1"},
+		},
+		{
+			text: "CREATE TABLE [a;b] (id INT);\nGO\n" +
+				"-- The body extends to GO.\nCREATE PROCEDURE p AS\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND;\ngo\n" +
+				"CREATE OR ALTER TRIGGER tr ON t AFTER INSERT AS\nBEGIN\n  SELECT 3;\nEND;\n",
+			dbType: SQLServer,
+			want: []string{
+				"CREATE TABLE [a;b] (id INT)",
+				"-- The body extends to GO.\nCREATE PROCEDURE p AS\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND;",
+				"CREATE OR ALTER TRIGGER tr ON t AFTER INSERT AS\nBEGIN\n  SELECT 3;\nEND;",
+			},
+		},
+		{
+			// "GO" is SQLServer only, and only as a whole line.
+			text:   "SELECT 1\nGO\nSELECT 'GO'; SELECT 2 AS go;",
+			dbType: SQLServer,
+			want:   []string{"SELECT 1", "SELECT 'GO'", "SELECT 2 AS go"},
+		},
+		{
+			text: "CREATE FUNCTION f(a int) RETURNS NULL ON NULL INPUT RETURNS int LANGUAGE java AS $$ return a; $$;\n" +
+				"// comment;\nSELECT * FROM t WHERE a = 'x;y';",
+			dbType: Cassandra,
+			want: []string{
+				"CREATE FUNCTION f(a int) RETURNS NULL ON NULL INPUT RETURNS int LANGUAGE java AS $$ return a; $$",
+				"// comment;\nSELECT * FROM t WHERE a = 'x;y'",
+			},
+		},
+		{
+			text: `{"create": "users"}
+{"createIndexes": "users",
+  "indexes": [{"key": {"email": 1}, "name": "email_1", "unique": true}]} {"insert": "users", "documents": [{"name": "a;b}"}]}`,
+			dbType: MongoDB,
+			want: []string{
+				`{"create": "users"}`,
+				"{\"createIndexes\": \"users\",\n  \"indexes\": [{\"key\": {\"email\": 1}, \"name\": \"email_1\", \"unique\": true}]}",
+				`{"insert": "users", "documents": [{"name": "a;b}"}]}`,
+			},
+		},
+		{
+			text:    `{"create": "users"} ["drop"]`,
+			dbType:  MongoDB,
+			wantErr: "it should be a document",
+		},
+		{
+			text:    `{"create": "users"`,
+			dbType:  MongoDB,
+			wantErr: "invalid MongoDB command",
+		},
+		{
+			text:    "SELECT 'unclosed;",
+			dbType:  MySQL,
+			wantErr: "unclosed quote '",
+		},
+		{
+			text:    "SELECT 1; /* unclosed",
+			dbType:  MySQL,
+			wantErr: "unclosed comment",
+		},
+		{
+			text:    "SELECT $$unclosed;",
+			dbType:  Postgres,
+			wantErr: "unclosed dollar quote $$",
+		},
+	}
+
+	for _, tc := range tests {
+		got, err := SplitStatements(tc.text, tc.dbType)
+		if tc.wantErr != "" {
+			require.Error(t, err, tc.text)
+			require.Contains(t, err.Error(), tc.wantErr, tc.text)
+			continue
+		}
+		require.NoError(t, err, tc.text)
+		require.Equal(t, tc.want, got, tc.text)
+	}
+}
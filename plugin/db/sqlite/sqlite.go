@@ -26,6 +26,7 @@ var migrationSchema string
 
 var (
 	bytebaseDatabase     = "bytebase"
+	inMemoryDatabase     = ":memory:"
 	excludedDatabaseList = map[string]bool{
 		// Skip our internal "bytebase" database
 		bytebaseDatabase: true,
@@ -40,16 +41,26 @@ func init() {
 }
 
 // Driver is the SQLite driver.
+//
+// If ConnectionConfig.Database is empty or ":memory:", the driver uses a single in-memory database named ":memory:",
+// which also holds the migration history, since switching to the "bytebase" database would destroy it.
 type Driver struct {
-	dir           string
+	dir string
+	// inMemory is whether the driver uses the in-memory database.
+	inMemory      bool
 	db            *sql.DB
 	connectionCtx db.ConnectionContext
 	l             *zap.Logger
+	driverConfig  db.DriverConfig
+
+	// migrationSetup caches whether the migration schema has been set up by this driver instance.
+	migrationSetup bool
 }
 
 func newDriver(config db.DriverConfig) db.Driver {
 	return &Driver{
-		l: config.Logger,
+		l:            config.Logger,
+		driverConfig: config,
 	}
 }
 
@@ -57,6 +68,7 @@ func newDriver(config db.DriverConfig) db.Driver {
 func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.ConnectionConfig, connCtx db.ConnectionContext) (db.Driver, error) {
 	// Host is the directory (instance) containing all SQLite databases.
 	driver.dir = config.Host
+	driver.inMemory = config.Database == "" || config.Database == inMemoryDatabase
 
 	// If config.Database is empty, we will get a connection to in-memory database.
 	if _, err := driver.GetDbConnection(ctx, config.Database); err != nil {
@@ -68,6 +80,7 @@ func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.Connec
 
 // Close closes the driver.
 func (driver *Driver) Close(ctx context.Context) error {
+	driver.migrationSetup = false
 	if driver.db != nil {
 		return driver.db.Close()
 	}
@@ -76,12 +89,27 @@ func (driver *Driver) Close(ctx context.Context) error {
 
 // Ping pings the database.
 func (driver *Driver) Ping(ctx context.Context) error {
-	return driver.db.PingContext(ctx)
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Ping", db.SQLite, nil)
+	err := driver.db.PingContext(ctx)
+	span.End(err)
+	return err
+}
+
+// Stats returns the statistics of the connection pool.
+func (driver *Driver) Stats() sql.DBStats {
+	if driver.db == nil {
+		return sql.DBStats{}
+	}
+	return driver.db.Stats()
 }
 
 // GetDbConnection gets a database connection.
-// If database is empty, we will get a connect to in-memory database.
+// If database is empty or ":memory:", we will get a connect to in-memory database.
+// The driver using the in-memory database always returns the same connection whatever the database is.
 func (driver *Driver) GetDbConnection(ctx context.Context, database string) (*sql.DB, error) {
+	if driver.inMemory && driver.db != nil {
+		return driver.db, nil
+	}
 	if driver.db != nil {
 		if err := driver.db.Close(); err != nil {
 			return nil, err
@@ -89,13 +117,22 @@ func (driver *Driver) GetDbConnection(ctx context.Context, database string) (*sq
 	}
 
 	dns := path.Join(driver.dir, fmt.Sprintf("%s.db", database))
-	if database == "" {
-		dns = ":memory:"
+	if database == "" || database == inMemoryDatabase {
+		dns = inMemoryDatabase
 	}
 	db, err := sql.Open("sqlite3", dns)
 	if err != nil {
 		return nil, err
 	}
+	if driver.inMemory {
+		// Each connection has its own in-memory database, which is gone once the connection is closed.
+		// So the pool is pinned to one connection that is never closed, ignoring the connection pool settings.
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+	} else {
+		driver.driverConfig.ApplyConnectionPool(db)
+	}
 	driver.db = db
 	return db, nil
 }
@@ -111,7 +148,14 @@ func (driver *Driver) GetVersion(ctx context.Context) (string, error) {
 }
 
 // SyncSchema synces the schema.
-func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema, error) {
+func (driver *Driver) SyncSchema(ctx context.Context, _ ...string) ([]*db.User, []*db.Schema, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "SyncSchema", db.SQLite, nil)
+	userList, schemaList, err := driver.syncSchema(ctx)
+	span.End(err)
+	return userList, schemaList, err
+}
+
+func (driver *Driver) syncSchema(ctx context.Context) ([]*db.User, []*db.Schema, error) {
 	databases, err := driver.getDatabases()
 	if err != nil {
 		return nil, nil, err
@@ -149,7 +193,12 @@ func (driver *Driver) SyncSchema(ctx context.Context) ([]*db.User, []*db.Schema,
 		if err != nil {
 			return nil, nil, err
 		}
-		schema.TableList = tbls
+		for _, tbl := range tbls {
+			if driver.isMigrationHistoryObject(tbl.Name) {
+				continue
+			}
+			schema.TableList = append(schema.TableList, tbl)
+		}
 
 		views, err := getViews(txn)
 		if err != nil {
@@ -290,6 +339,9 @@ func getViews(txn *sql.Tx) ([]db.View, error) {
 }
 
 func (driver *Driver) getDatabases() ([]string, error) {
+	if driver.inMemory {
+		return []string{inMemoryDatabase}, nil
+	}
 	files, err := ioutil.ReadDir(driver.dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read directory %q, error %w", driver.dir, err)
@@ -299,12 +351,21 @@ func (driver *Driver) getDatabases() ([]string, error) {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".db") {
 			continue
 		}
-		databases = append(databases, strings.TrimRight(file.Name(), ".db"))
+		databases = append(databases, strings.TrimSuffix(file.Name(), ".db"))
 	}
 	return databases, nil
 }
 
+// isMigrationHistoryObject returns whether the table or index is the migration history of the in-memory database,
+// which shares the database with the user tables.
+func (driver *Driver) isMigrationHistoryObject(name string) bool {
+	return driver.inMemory && strings.HasPrefix(name, "bytebase_")
+}
+
 func (driver *Driver) hasBytebaseDatabase() (bool, error) {
+	if driver.inMemory {
+		return true, nil
+	}
 	databases, err := driver.getDatabases()
 	if err != nil {
 		return false, err
@@ -318,7 +379,14 @@ func (driver *Driver) hasBytebaseDatabase() (bool, error) {
 }
 
 // Execute executes a SQL statement.
-func (driver *Driver) Execute(ctx context.Context, statement string) error {
+func (driver *Driver) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Execute", db.SQLite, nil)
+	result, err := driver.execute(ctx, statement)
+	span.End(err)
+	return result, err
+}
+
+func (driver *Driver) execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
 	var remainingStmts []string
 	f := func(stmt string) error {
 		// This is a fake CREATE DATABASE statement. Engine driver will recognize it and establish a connection to create the database.
@@ -333,7 +401,7 @@ func (driver *Driver) Execute(ctx context.Context, statement string) error {
 				return err
 			}
 			// We need to query to persist the database file.
-			if _, err := db.Query("SELECT 1;"); err != nil {
+			if _, err := db.ExecContext(ctx, "SELECT 1;"); err != nil {
 				return err
 			}
 		} else if strings.HasPrefix(stmt, "USE ") {
@@ -345,26 +413,35 @@ func (driver *Driver) Execute(ctx context.Context, statement string) error {
 	}
 	sc := bufio.NewScanner(strings.NewReader(statement))
 	if err := util.ApplyMultiStatements(sc, f); err != nil {
-		return err
+		return db.ExecuteResult{}, err
 	}
 
+	var result db.ExecuteResult
 	if len(remainingStmts) == 0 {
-		return nil
+		return result, nil
 	}
 
 	tx, err := driver.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return result, err
 	}
 	defer tx.Rollback()
 
-	if _, err = tx.ExecContext(ctx, strings.Join(remainingStmts, "\n")); err == nil {
-		if err := tx.Commit(); err != nil {
-			return err
-		}
+	sqlResult, err := tx.ExecContext(ctx, strings.Join(remainingStmts, "\n"))
+	if err != nil {
+		return result, err
 	}
+	result.Add(sqlResult)
 
-	return err
+	if err := tx.Commit(); err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return result, nil
+}
+
+// ExecuteBatch executes the statements in a single transaction, which are sent in a single round trip.
+func (driver *Driver) ExecuteBatch(ctx context.Context, statementList []string) error {
+	return util.ExecuteMultiStatementBatch(ctx, driver.db, statementList)
 }
 
 // Query queries a SQL statement.
@@ -372,8 +449,16 @@ func (driver *Driver) Query(ctx context.Context, statement string, limit int) ([
 	return util.Query(ctx, driver.l, driver.db, statement, limit)
 }
 
+// BeginTx begins a transaction.
+func (driver *Driver) BeginTx(ctx context.Context) (db.Tx, error) {
+	return util.BeginTx(ctx, driver.db)
+}
+
 // NeedsSetupMigration returns whether it needs to setup migration.
 func (driver *Driver) NeedsSetupMigration(ctx context.Context) (bool, error) {
+	if driver.migrationSetup {
+		return false, nil
+	}
 	exist, err := driver.hasBytebaseDatabase()
 	if err != nil {
 		return false, err
@@ -430,38 +515,41 @@ func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
 		)
 	}
 
+	driver.migrationSetup = true
 	return nil
 }
 
-// FindLargestVersionSinceBaseline will find the largest version since last baseline or branch.
-func (driver Driver) FindLargestVersionSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) (*string, error) {
+// FindVersionListSinceBaseline will find the stored versions since last baseline or branch.
+func (driver Driver) FindVersionListSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) ([]string, error) {
 	largestBaselineSequence, err := driver.FindLargestSequence(ctx, tx, namespace, true /* baseline */)
 	if err != nil {
 		return nil, err
 	}
-	const getLargestVersionSinceLastBaselineQuery = `
-		SELECT MAX(version) FROM bytebase_migration_history
+	const getVersionListSinceLastBaselineQuery = `
+		SELECT version FROM bytebase_migration_history
 		WHERE namespace = ? AND sequence >= ?
 	`
-	row, err := tx.QueryContext(ctx, getLargestVersionSinceLastBaselineQuery,
+	rows, err := tx.QueryContext(ctx, getVersionListSinceLastBaselineQuery,
 		namespace, largestBaselineSequence,
 	)
 	if err != nil {
-		return nil, util.FormatErrorWithQuery(err, getLargestVersionSinceLastBaselineQuery)
+		return nil, util.FormatErrorWithQuery(err, getVersionListSinceLastBaselineQuery)
 	}
-	defer row.Close()
+	defer rows.Close()
 
-	var version sql.NullString
-	row.Next()
-	if err := row.Scan(&version); err != nil {
-		return nil, err
+	var versionList []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versionList = append(versionList, version)
 	}
-
-	if version.Valid {
-		return &version.String, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil, nil
+	return versionList, nil
 }
 
 // FindLargestSequence will return the largest sequence number.
@@ -561,23 +649,52 @@ func (Driver) UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDura
 	return err
 }
 
-// UpdateHistoryAsFailed will update the migration record as failed.
-func (Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, insertedID int64) error {
+// UpdateHistoryAsFailed will update the migration record as failed with the payload recording the error message.
+func (Driver) UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, payload string, insertedID int64) error {
 	const updateHistoryAsFailedQuery = `
 	UPDATE
 		bytebase_migration_history
 	SET
 		status = 'FAILED',
-		execution_duration_ns = ?
+		execution_duration_ns = ?,
+		payload = ?
 	WHERE id = ?
 	`
-	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, insertedID)
+	_, err := tx.ExecContext(ctx, updateHistoryAsFailedQuery, migrationDurationNs, payload, insertedID)
+	return err
+}
+
+// UpdateHistoryPayload will replace the payload of the migration record.
+func (Driver) UpdateHistoryPayload(ctx context.Context, tx *sql.Tx, payload string, id int64) error {
+	const updateHistoryPayloadQuery = `
+	UPDATE
+		bytebase_migration_history
+	SET
+		payload = ?
+	WHERE id = ?
+	`
+	_, err := tx.ExecContext(ctx, updateHistoryPayloadQuery, payload, id)
 	return err
 }
 
 // ExecuteMigration will execute the migration.
 func (driver *Driver) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
-	return util.ExecuteMigration(ctx, driver.l, driver, m, statement)
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "ExecuteMigration", db.SQLite, db.MigrationSpanAttributes(m))
+	if err := driver.driverConfig.ValidateStatement(db.SQLite, statement, m); err != nil {
+		span.End(err)
+		return -1, "", err
+	}
+	migrationHistoryID, updatedSchema, err := util.ExecuteMigration(ctx, driver.l, driver, m, statement)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+// Rollback will revert the applied migration version.
+func (driver *Driver) Rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Rollback", db.SQLite, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := util.Rollback(ctx, driver.l, driver, m, version)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
 }
 
 // FindMigrationHistoryList finds the migration history.
@@ -597,7 +714,7 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 		status,
 		version,
 		description,
-		statement,
+		` + util.MigrationHistoryStatementColumn(find) + `,
 		schema,
 		schema_prev,
 		execution_duration_ns,
@@ -622,6 +739,12 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 	if v := find.Source; v != nil {
 		paramNames, params = append(paramNames, "source"), append(params, *v)
 	}
+	if v := find.Status; v != nil {
+		paramNames, params = append(paramNames, "status"), append(params, *v)
+	}
+	if v := find.IssueID; v != nil {
+		paramNames, params = append(paramNames, "issue_id"), append(params, *v)
+	}
 	var query = baseQuery +
 		db.FormatParamNameInQuestionMark(paramNames) +
 		`ORDER BY created_ts DESC`
@@ -633,6 +756,9 @@ func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.Mig
 
 // Dump dumps the database.
 func (driver *Driver) Dump(ctx context.Context, database string, out io.Writer, schemaOnly bool) error {
+	if driver.inMemory {
+		database = inMemoryDatabase
+	}
 	if database == "" {
 		return fmt.Errorf("SQLite can dump one database only at a time")
 	}
@@ -703,7 +829,7 @@ func (driver *Driver) dumpOneDatabase(ctx context.Context, database string, out
 
 	for _, s := range sqliteSchemas {
 		// We should skip sqlite sequence table.
-		if s.name == "sqlite_sequence" {
+		if s.name == "sqlite_sequence" || driver.isMigrationHistoryObject(s.name) {
 			continue
 		}
 		if _, err := io.WriteString(out, fmt.Sprintf("%s;\n", s.statement)); err != nil {
@@ -779,13 +905,13 @@ func (driver *Driver) Restore(ctx context.Context, sc *bufio.Scanner) (err error
 	defer txn.Rollback()
 
 	f := func(stmt string) error {
-		if _, err := txn.Exec(stmt); err != nil {
+		if _, err := txn.ExecContext(ctx, stmt); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	if err := util.ApplyMultiStatements(sc, f); err != nil {
+	if err := db.ApplyStatements(sc, db.SQLite, f); err != nil {
 		return err
 	}
 
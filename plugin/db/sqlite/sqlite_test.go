@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryMigration(t *testing.T) {
+	ctx := context.Background()
+	driver, err := db.Open(ctx, db.SQLite, db.DriverConfig{}, db.ConnectionConfig{Database: inMemoryDatabase}, db.ConnectionContext{})
+	require.NoError(t, err)
+	defer driver.Close(ctx)
+
+	require.NoError(t, driver.SetupMigrationIfNeeded(ctx))
+	needsSetup, err := driver.NeedsSetupMigration(ctx)
+	require.NoError(t, err)
+	require.False(t, needsSetup)
+
+	_, _, err = driver.ExecuteMigration(ctx, &db.MigrationInfo{
+		Version:   "0001",
+		Namespace: inMemoryDatabase,
+		Database:  inMemoryDatabase,
+		Source:    db.LIBRARY,
+		Type:      db.Migrate,
+		Creator:   "test",
+	}, "CREATE TABLE t1 (id INTEGER PRIMARY KEY, name TEXT);")
+	require.NoError(t, err)
+
+	_, schemaList, err := driver.SyncSchema(ctx)
+	require.NoError(t, err)
+	require.Len(t, schemaList, 1)
+	require.Equal(t, inMemoryDatabase, schemaList[0].Name)
+	require.Len(t, schemaList[0].TableList, 1)
+	require.Equal(t, "t1", schemaList[0].TableList[0].Name)
+
+	historyList, err := driver.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{})
+	require.NoError(t, err)
+	require.Len(t, historyList, 1)
+	require.Equal(t, "0001", historyList[0].Version)
+	require.Equal(t, db.Done, historyList[0].Status)
+}
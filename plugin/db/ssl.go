@@ -7,42 +7,75 @@ import (
 	"os"
 )
 
+// SslMode is the SSL mode for connection.
+type SslMode string
+
+const (
+	// SslModeDisable disables SSL.
+	SslModeDisable SslMode = "disable"
+	// SslModeRequire requires SSL without verifying the server certificate.
+	// The certificate chain is still verified if SslCA is set.
+	SslModeRequire SslMode = "require"
+	// SslModeVerifyFull requires SSL and verifies both the server certificate chain and the host name.
+	// The system root CAs are used if SslCA is not set.
+	SslModeVerifyFull SslMode = "verify-full"
+)
+
 // TLSConfig is the configuration for SSL connection.
 type TLSConfig struct {
 	SslCA   string
 	SslCert string
 	SslKey  string
+	// SslMode is optional. If it's empty, SSL is enabled and the server certificate chain is verified only if SslCA is set.
+	SslMode SslMode
 }
 
 // GetSslConfig gets the SSL config for connection.
 func (tc TLSConfig) GetSslConfig() (*tls.Config, error) {
-	if tc.SslCA == "" {
+	switch tc.SslMode {
+	case "":
+		if tc.SslCA == "" {
+			return nil, nil
+		}
+	case SslModeDisable:
 		return nil, nil
+	case SslModeRequire:
+		if tc.SslCA == "" {
+			cfg := &tls.Config{
+				InsecureSkipVerify: true,
+			}
+			if err := tc.loadClientCert(cfg); err != nil {
+				return nil, err
+			}
+			return cfg, nil
+		}
+	case SslModeVerifyFull:
+		cfg := &tls.Config{}
+		if tc.SslCA != "" {
+			rootCertPool, err := tc.loadRootCertPool()
+			if err != nil {
+				return nil, err
+			}
+			cfg.RootCAs = rootCertPool
+		}
+		if err := tc.loadClientCert(cfg); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	default:
+		return nil, fmt.Errorf("invalid ssl mode %q, should be one of %q, %q, %q", tc.SslMode, SslModeDisable, SslModeRequire, SslModeVerifyFull)
 	}
-	rootCertPool := x509.NewCertPool()
-	pem, err := os.ReadFile(tc.SslCA)
+
+	rootCertPool, err := tc.loadRootCertPool()
 	if err != nil {
 		return nil, err
 	}
-	if ok := rootCertPool.AppendCertsFromPEM(pem); !ok {
-		return nil, fmt.Errorf("rootCertPool.AppendCertsFromPEM() failed to append server CA pem")
-	}
 
 	cfg := &tls.Config{
 		RootCAs: rootCertPool,
 	}
-	if (tc.SslCert == "" && tc.SslKey != "") || (tc.SslCert != "" && tc.SslKey == "") {
-		return nil, fmt.Errorf("ssl-cert and ssl-key must be both set or unset")
-	}
-	if tc.SslCert != "" && tc.SslKey != "" {
-		var clientCert []tls.Certificate
-		certs, err := tls.LoadX509KeyPair(tc.SslCert, tc.SslKey)
-		if err != nil {
-			return nil, err
-		}
-		clientCert = append(clientCert, certs)
-
-		cfg.Certificates = clientCert
+	if err := tc.loadClientCert(cfg); err != nil {
+		return nil, err
 	}
 
 	cfg.InsecureSkipVerify = true
@@ -62,3 +95,31 @@ func (tc TLSConfig) GetSslConfig() (*tls.Config, error) {
 	}
 	return cfg, nil
 }
+
+// loadRootCertPool loads the server CA from SslCA.
+func (tc TLSConfig) loadRootCertPool() (*x509.CertPool, error) {
+	rootCertPool := x509.NewCertPool()
+	pem, err := os.ReadFile(tc.SslCA)
+	if err != nil {
+		return nil, err
+	}
+	if ok := rootCertPool.AppendCertsFromPEM(pem); !ok {
+		return nil, fmt.Errorf("rootCertPool.AppendCertsFromPEM() failed to append server CA pem")
+	}
+	return rootCertPool, nil
+}
+
+// loadClientCert loads the client certificate into cfg if SslCert and SslKey are set.
+func (tc TLSConfig) loadClientCert(cfg *tls.Config) error {
+	if (tc.SslCert == "" && tc.SslKey != "") || (tc.SslCert != "" && tc.SslKey == "") {
+		return fmt.Errorf("ssl-cert and ssl-key must be both set or unset")
+	}
+	if tc.SslCert != "" && tc.SslKey != "" {
+		certs, err := tls.LoadX509KeyPair(tc.SslCert, tc.SslKey)
+		if err != nil {
+			return err
+		}
+		cfg.Certificates = []tls.Certificate{certs}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"strings"
+)
+
+// Tracer creates the spans around the driver operations, e.g. an adapter of an OpenTelemetry tracer.
+// The drivers don't depend on a tracing library, so the callers bridge their tracer with this interface.
+type Tracer interface {
+	// Start starts a span with the attributes, and returns the context carrying the span.
+	Start(ctx context.Context, spanName string, attributes map[string]string) (context.Context, Span)
+}
+
+// Span is a span started by Tracer.
+type Span interface {
+	// End ends the span with the error of the operation, which is nil on success.
+	End(err error)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(error) {}
+
+// StartSpan starts a span named "db.<operation>" with the database type in the "db.system" attribute.
+// It returns a no-op span without any overhead if the tracer is nil.
+func StartSpan(ctx context.Context, tracer Tracer, operation string, dbType Type, attributes map[string]string) (context.Context, Span) {
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	spanAttributes := map[string]string{
+		"db.system": strings.ToLower(string(dbType)),
+	}
+	for key, value := range attributes {
+		spanAttributes[key] = value
+	}
+	return tracer.Start(ctx, "db."+operation, spanAttributes)
+}
+
+// MigrationSpanAttributes returns the span attributes of the migration for ExecuteMigration.
+func MigrationSpanAttributes(m *MigrationInfo) map[string]string {
+	return map[string]string{
+		"db.name":                m.Database,
+		"db.migration.version":   m.Version,
+		"db.migration.type":      string(m.Type),
+		"db.migration.namespace": m.Namespace,
+	}
+}
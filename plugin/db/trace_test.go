@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordedSpan struct {
+	name       string
+	attributes map[string]string
+	ended      bool
+	err        error
+}
+
+func (s *recordedSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, spanName string, attributes map[string]string) (context.Context, Span) {
+	span := &recordedSpan{name: spanName, attributes: attributes}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestStartSpan(t *testing.T) {
+	ctx := context.Background()
+	// No-op without a tracer.
+	gotCtx, span := StartSpan(ctx, nil, "Ping", MySQL, nil)
+	require.Equal(t, ctx, gotCtx)
+	span.End(nil)
+
+	tracer := &recordingTracer{}
+	m := &MigrationInfo{Database: "db1", Namespace: "db1", Version: "1.0", Type: Migrate}
+	_, span = StartSpan(ctx, tracer, "ExecuteMigration", MySQL, MigrationSpanAttributes(m))
+	cause := errors.New("failed")
+	span.End(cause)
+
+	require.Len(t, tracer.spans, 1)
+	require.Equal(t, "db.ExecuteMigration", tracer.spans[0].name)
+	require.Equal(t, map[string]string{
+		"db.system":              "mysql",
+		"db.name":                "db1",
+		"db.migration.version":   "1.0",
+		"db.migration.type":      "MIGRATE",
+		"db.migration.namespace": "db1",
+	}, tracer.spans[0].attributes)
+	require.True(t, tracer.spans[0].ended)
+	require.Equal(t, cause, tracer.spans[0].err)
+}
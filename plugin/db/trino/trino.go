@@ -0,0 +1,1188 @@
+package trino
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bytebase/bytebase/common"
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/bytebase/bytebase/plugin/db/util"
+	trino "github.com/trinodb/trino-go-client/trino"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMigrationSchema is the schema of the migration history in the catalog of the connection database
+	// if DriverConfig.MigrationHistorySchema is empty.
+	defaultMigrationSchema = "bytebase"
+	// migrationHistoryTable is the table of the migration history in the migration schema.
+	migrationHistoryTable = "bytebase_migration_history"
+	// systemCatalog is the catalog of the Trino runtime, which isn't synced.
+	systemCatalog = "system"
+	// informationSchema is the information_schema of each catalog, which isn't synced.
+	informationSchema = "information_schema"
+)
+
+var (
+	// useStatementRegexp matches the USE statement switching the schema, e.g. `USE s1`, `USE hive.s1` and `USE "Hive"."S1"`.
+	useStatementRegexp = regexp.MustCompile(`(?is)^USE\s+("(?:[^"]|"")+"|\w+)(?:\s*\.\s*("(?:[^"]|"")+"|\w+))?$`)
+
+	// readStatementWords are the first words of the statements allowed by Query.
+	readStatementWords = map[string]bool{
+		"SELECT":   true,
+		"WITH":     true,
+		"SHOW":     true,
+		"DESCRIBE": true,
+		"EXPLAIN":  true,
+		"VALUES":   true,
+	}
+
+	_ db.Driver                = (*Driver)(nil)
+	_ db.StatementListExecutor = (*Driver)(nil)
+)
+
+func init() {
+	db.Register(db.Trino, newDriver)
+}
+
+// Driver is the Trino driver.
+//
+// The databases are the schemas of the catalogs in the form of catalog.schema, e.g. hive.sales.
+// The connection database can be a catalog, which limits SyncSchema and Dump to the catalog, or a catalog.schema as the current schema.
+//
+// The migration history is recorded in the bytebase_migration_history table of the migration schema, which is
+// DriverConfig.MigrationHistorySchema in the form of catalog.schema, or the "bytebase" schema of the connection catalog by default.
+// The connector of the migration catalog must support CREATE SCHEMA, CREATE TABLE and INSERT, e.g. Hive, Iceberg or Delta Lake.
+// Since most connectors can't UPDATE, the history is append-only: each state change of a migration inserts a new row,
+// and the row recorded last is the current state of the migration.
+//
+// Trino has no multi-statement transactions, so the migration history is recorded on a best-effort basis like Cassandra,
+// and whether a single statement is atomic depends on the connector. A failed migration leaves the statements before the
+// failing one applied, and a crash in between leaves a PENDING record behind.
+// The duplicate version check reads the history before writing the PENDING record, so the concurrent migrations of the same
+// namespace aren't guarded against each other.
+type Driver struct {
+	l             *zap.Logger
+	driverConfig  db.DriverConfig
+	connectionCtx db.ConnectionContext
+	dbType        db.Type
+
+	// config is the config of db, and its catalog and schema are the current ones.
+	config *trino.Config
+	db     *sql.DB
+	// catalog is the catalog of the connection database, which is empty if the connection database is empty.
+	catalog string
+
+	// migrationSetup caches whether the migration schema has been set up by this driver instance.
+	migrationSetup bool
+}
+
+func newDriver(config db.DriverConfig) db.Driver {
+	return &Driver{
+		l:            config.Logger,
+		driverConfig: config,
+	}
+}
+
+// Open opens a Trino driver. The user defaults to "bytebase", since Trino requires one.
+// The password is sent only with TLS, so it's rejected if the TLS config is empty.
+func (driver *Driver) Open(ctx context.Context, dbType db.Type, config db.ConnectionConfig, connCtx db.ConnectionContext) (db.Driver, error) {
+	port := config.Port
+	if port == "" {
+		port = "8080"
+	}
+	user := config.Username
+	if user == "" {
+		user = "bytebase"
+	}
+	serverURL := url.URL{
+		Scheme: "http",
+		User:   url.User(user),
+		Host:   net.JoinHostPort(config.Host, port),
+	}
+	tlsConfig, err := config.TLSConfig.GetSslConfig()
+	if err != nil {
+		return nil, fmt.Errorf("sql: tls config error: %v", err)
+	}
+	var customClientName string
+	if tlsConfig != nil {
+		serverURL.Scheme = "https"
+		if config.Password != "" {
+			serverURL.User = url.UserPassword(user, config.Password)
+		}
+		// The client registry is global, so the name is unique to the driver instance.
+		customClientName = fmt.Sprintf("bytebase-%p", driver)
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		if err := trino.RegisterCustomClient(customClientName, &http.Client{Transport: transport}); err != nil {
+			return nil, err
+		}
+	} else if config.Password != "" {
+		return nil, fmt.Errorf("the password requires TLS for %s", db.Trino)
+	}
+
+	catalog, schema := splitDatabase(config.Database)
+	trinoConfig := &trino.Config{
+		ServerURI:        serverURL.String(),
+		Source:           "bytebase",
+		Catalog:          catalog,
+		Schema:           schema,
+		CustomClientName: customClientName,
+	}
+
+	driver.l.Debug("Opening Trino driver",
+		zap.String("host", config.Host),
+		zap.String("port", port),
+		zap.String("environment", connCtx.EnvironmentName),
+		zap.String("database", connCtx.InstanceName),
+	)
+
+	sqldb, err := driver.openDB(trinoConfig)
+	if err != nil {
+		if customClientName != "" {
+			trino.DeregisterCustomClient(customClientName)
+		}
+		return nil, err
+	}
+	driver.dbType = dbType
+	driver.config = trinoConfig
+	driver.db = sqldb
+	driver.catalog = catalog
+	driver.connectionCtx = connCtx
+
+	return driver, nil
+}
+
+// openDB opens the connection pool of the config.
+func (driver *Driver) openDB(config *trino.Config) (*sql.DB, error) {
+	dsn, err := config.FormatDSN()
+	if err != nil {
+		return nil, err
+	}
+	sqldb, err := sql.Open("trino", dsn)
+	if err != nil {
+		return nil, err
+	}
+	driver.driverConfig.ApplyConnectionPool(sqldb)
+	return sqldb, nil
+}
+
+// Close closes the driver.
+func (driver *Driver) Close(ctx context.Context) error {
+	driver.migrationSetup = false
+	if driver.db == nil {
+		return nil
+	}
+	err := driver.db.Close()
+	driver.db = nil
+	if driver.config.CustomClientName != "" {
+		trino.DeregisterCustomClient(driver.config.CustomClientName)
+	}
+	return err
+}
+
+// Ping pings the database.
+// The Trino client connects lazily over HTTP, so it runs a query instead of pinging the connection.
+func (driver *Driver) Ping(ctx context.Context) error {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Ping", driver.dbType, nil)
+	_, err := driver.db.ExecContext(ctx, "SELECT 1")
+	span.End(err)
+	return err
+}
+
+// Stats returns the statistics of the connection pool.
+func (driver *Driver) Stats() sql.DBStats {
+	if driver.db == nil {
+		return sql.DBStats{}
+	}
+	return driver.db.Stats()
+}
+
+// GetDbConnection gets a database connection.
+// The connection doesn't support transactions, and it's switched to the database if it's not empty.
+func (driver *Driver) GetDbConnection(ctx context.Context, database string) (*sql.DB, error) {
+	if database != "" {
+		if err := driver.useDatabase(splitDatabase(database)); err != nil {
+			return nil, err
+		}
+	}
+	return driver.db, nil
+}
+
+// GetVersion gets the version.
+func (driver *Driver) GetVersion(ctx context.Context) (string, error) {
+	query := "SELECT version()"
+	var version string
+	if err := driver.db.QueryRowContext(ctx, query).Scan(&version); err != nil {
+		return "", util.FormatErrorWithQuery(err, query)
+	}
+	return version, nil
+}
+
+// useDatabase switches the connection pool to the catalog and the schema, which reopens the pool if they're changed.
+// The current catalog is kept if catalog is empty.
+func (driver *Driver) useDatabase(catalog, schema string) error {
+	if catalog == "" {
+		catalog = driver.config.Catalog
+	}
+	if driver.config.Catalog == catalog && driver.config.Schema == schema {
+		return nil
+	}
+	config := *driver.config
+	config.Catalog, config.Schema = catalog, schema
+	sqldb, err := driver.openDB(&config)
+	if err != nil {
+		return fmt.Errorf("failed to use schema %q, error: %w", joinDatabase(catalog, schema), err)
+	}
+	driver.db.Close()
+	driver.config = &config
+	driver.db = sqldb
+	return nil
+}
+
+// splitDatabase splits the database in the form of catalog.schema, where the schema is empty if there's no dot.
+func splitDatabase(database string) (string, string) {
+	if i := strings.Index(database, "."); i >= 0 {
+		return database[:i], database[i+1:]
+	}
+	return database, ""
+}
+
+// joinDatabase returns the database in the form of catalog.schema.
+func joinDatabase(catalog, schema string) string {
+	return fmt.Sprintf("%s.%s", catalog, schema)
+}
+
+// quoteIdentifier quotes the identifier.
+func quoteIdentifier(name string) string {
+	return fmt.Sprintf(`"%s"`, strings.ReplaceAll(name, `"`, `""`))
+}
+
+// qualifiedName returns the quoted name qualified by the catalog and the schema, e.g. "hive"."s1"."t1".
+func qualifiedName(nameList ...string) string {
+	var quotedList []string
+	for _, name := range nameList {
+		quotedList = append(quotedList, quoteIdentifier(name))
+	}
+	return strings.Join(quotedList, ".")
+}
+
+// parseUseStatement returns the catalog and the schema of the USE statement, and false if the statement isn't a USE statement.
+// The catalog is empty if the statement only has the schema. The unquoted names are case-insensitive and lower-cased.
+func parseUseStatement(statement string) (string, string, bool) {
+	matches := useStatementRegexp.FindStringSubmatch(strings.TrimSpace(statement))
+	if matches == nil {
+		return "", "", false
+	}
+	if matches[2] == "" {
+		return "", unquoteIdentifier(matches[1]), true
+	}
+	return unquoteIdentifier(matches[1]), unquoteIdentifier(matches[2]), true
+}
+
+// unquoteIdentifier unquotes the quoted identifier, and lower-cases the unquoted one.
+func unquoteIdentifier(name string) string {
+	if strings.HasPrefix(name, `"`) {
+		return strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+	}
+	return strings.ToLower(name)
+}
+
+// SyncSchema syncs the schemas of the catalog of the connection database, or all catalogs except system if it's empty.
+// The users aren't synced.
+func (driver *Driver) SyncSchema(ctx context.Context, _ ...string) ([]*db.User, []*db.Schema, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "SyncSchema", driver.dbType, nil)
+	schemaList, err := driver.syncSchema(ctx)
+	span.End(err)
+	return nil, schemaList, err
+}
+
+func (driver *Driver) syncSchema(ctx context.Context) ([]*db.Schema, error) {
+	catalogList, err := driver.getCatalogList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	migrationCatalog, migrationSchema, _ := driver.migrationSchema()
+	var schemaList []*db.Schema
+	for _, catalog := range catalogList {
+		catalogSchemaList, err := driver.getCatalogSchemaList(ctx, catalog)
+		if err != nil {
+			return nil, err
+		}
+		for _, schema := range catalogSchemaList {
+			// Skip our internal "bytebase" schema.
+			if schema.Name == joinDatabase(catalog, defaultMigrationSchema) {
+				continue
+			}
+			if schema.Name == joinDatabase(migrationCatalog, migrationSchema) {
+				var tableList []db.Table
+				for _, table := range schema.TableList {
+					if table.Name != migrationHistoryTable {
+						tableList = append(tableList, table)
+					}
+				}
+				schema.TableList = tableList
+			}
+			schemaList = append(schemaList, schema)
+		}
+	}
+	return schemaList, nil
+}
+
+// getCatalogList returns the catalog of the connection database, or all catalogs except system in order if it's empty.
+func (driver *Driver) getCatalogList(ctx context.Context) ([]string, error) {
+	if driver.catalog != "" {
+		return []string{driver.catalog}, nil
+	}
+	query := "SHOW CATALOGS"
+	rows, err := driver.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+	var catalogList []string
+	for rows.Next() {
+		var catalog string
+		if err := rows.Scan(&catalog); err != nil {
+			return nil, err
+		}
+		if catalog == systemCatalog {
+			continue
+		}
+		catalogList = append(catalogList, catalog)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(catalogList)
+	return catalogList, nil
+}
+
+// getCatalogSchemaList returns the schemas of the catalog in order, which are named in the form of catalog.schema.
+func (driver *Driver) getCatalogSchemaList(ctx context.Context, catalog string) ([]*db.Schema, error) {
+	// catalog.schema -> schema map
+	schemaMap := make(map[string]*db.Schema)
+	var schemaList []*db.Schema
+	query := fmt.Sprintf("SELECT schema_name FROM %s WHERE schema_name <> ?", qualifiedName(catalog, informationSchema, "schemata"))
+	if err := driver.queryRows(ctx, query, []interface{}{informationSchema}, func(rows *sql.Rows) error {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return err
+		}
+		s := &db.Schema{Name: joinDatabase(catalog, schema)}
+		schemaMap[s.Name] = s
+		schemaList = append(schemaList, s)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// catalog.schema.table -> columnList map
+	columnMap := make(map[string][]db.Column)
+	query = fmt.Sprintf(`SELECT table_schema, table_name, column_name, ordinal_position, column_default, is_nullable, data_type
+		FROM %s WHERE table_schema <> ? ORDER BY table_schema, table_name, ordinal_position`, qualifiedName(catalog, informationSchema, "columns"))
+	if err := driver.queryRows(ctx, query, []interface{}{informationSchema}, func(rows *sql.Rows) error {
+		var schema, table, nullable string
+		var defaultStr sql.NullString
+		var column db.Column
+		if err := rows.Scan(&schema, &table, &column.Name, &column.Position, &defaultStr, &nullable, &column.Type); err != nil {
+			return err
+		}
+		if defaultStr.Valid {
+			column.Default = &defaultStr.String
+		}
+		column.Nullable = nullable == "YES"
+		key := fmt.Sprintf("%s.%s", joinDatabase(catalog, schema), table)
+		columnMap[key] = append(columnMap[key], column)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// catalog.schema.view -> definition map
+	viewDefinitionMap := make(map[string]string)
+	query = fmt.Sprintf("SELECT table_schema, table_name, view_definition FROM %s WHERE table_schema <> ?", qualifiedName(catalog, informationSchema, "views"))
+	if err := driver.queryRows(ctx, query, []interface{}{informationSchema}, func(rows *sql.Rows) error {
+		var schema, view string
+		var definition sql.NullString
+		if err := rows.Scan(&schema, &view, &definition); err != nil {
+			return err
+		}
+		viewDefinitionMap[fmt.Sprintf("%s.%s", joinDatabase(catalog, schema), view)] = definition.String
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	query = fmt.Sprintf("SELECT table_schema, table_name, table_type FROM %s WHERE table_schema <> ? ORDER BY table_schema, table_name", qualifiedName(catalog, informationSchema, "tables"))
+	if err := driver.queryRows(ctx, query, []interface{}{informationSchema}, func(rows *sql.Rows) error {
+		var schema, table, tableType string
+		if err := rows.Scan(&schema, &table, &tableType); err != nil {
+			return err
+		}
+		s, ok := schemaMap[joinDatabase(catalog, schema)]
+		if !ok {
+			return nil
+		}
+		key := fmt.Sprintf("%s.%s", s.Name, table)
+		if tableType == "VIEW" {
+			s.ViewList = append(s.ViewList, db.View{
+				Name:       table,
+				Definition: viewDefinitionMap[key],
+			})
+			return nil
+		}
+		s.TableList = append(s.TableList, db.Table{
+			Name:       table,
+			Type:       tableType,
+			ColumnList: columnMap[key],
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(schemaList, func(i, j int) bool {
+		return schemaList[i].Name < schemaList[j].Name
+	})
+	return schemaList, nil
+}
+
+// queryRows queries the statement and calls f on each row.
+func (driver *Driver) queryRows(ctx context.Context, query string, args []interface{}, f func(rows *sql.Rows) error) error {
+	rows, err := driver.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return util.FormatErrorWithQuery(err, query)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := f(rows); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return util.FormatErrorWithQuery(err, query)
+	}
+	return nil
+}
+
+// Execute executes the statements one by one, where the USE statements switch the schema.
+func (driver *Driver) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Execute", driver.dbType, nil)
+	result, err := driver.execute(ctx, statement)
+	span.End(err)
+	return result, err
+}
+
+func (driver *Driver) execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	statementList, err := db.SplitStatements(statement, db.Trino)
+	if err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return driver.ExecuteStatementList(ctx, statementList)
+}
+
+// GetType returns the database type.
+func (driver *Driver) GetType() db.Type {
+	return driver.dbType
+}
+
+// ExecuteStatementList executes the statements in order without a transaction, so the statements before the failed one stay applied.
+func (driver *Driver) ExecuteStatementList(ctx context.Context, statementList []string) (db.ExecuteResult, error) {
+	var result db.ExecuteResult
+	for i, statement := range statementList {
+		if catalog, schema, ok := parseUseStatement(statement); ok {
+			if err := driver.useDatabase(catalog, schema); err != nil {
+				return result, &db.StatementError{Index: i, Statement: statement, Committed: i > 0, Err: err}
+			}
+			continue
+		}
+		sqlResult, err := driver.db.ExecContext(ctx, statement)
+		if err != nil {
+			return result, &db.StatementError{Index: i, Statement: statement, Committed: i > 0, Err: err}
+		}
+		result.Add(sqlResult)
+	}
+	return result, nil
+}
+
+// ExecuteBatch executes the statements one by one, since Trino doesn't support transactions.
+// The statements before the failing one take effect.
+func (driver *Driver) ExecuteBatch(ctx context.Context, statementList []string) error {
+	for i, statement := range statementList {
+		if _, err := driver.db.ExecContext(ctx, statement); err != nil {
+			return &db.BatchError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// Query queries a read-only statement, i.e. SELECT, WITH, SHOW, DESCRIBE, EXPLAIN or VALUES.
+// Trino doesn't support read-only transactions, so the other statements are rejected.
+func (driver *Driver) Query(ctx context.Context, statement string, limit int) ([]interface{}, error) {
+	if fields := strings.Fields(statement); len(fields) == 0 || !readStatementWords[strings.ToUpper(fields[0])] {
+		return nil, fmt.Errorf("only the read-only statements can be queried for %s", db.Trino)
+	}
+	return util.QueryWithoutTx(ctx, driver.db, statement, limit)
+}
+
+// BeginTx returns an error, since Trino doesn't support transactions.
+func (*Driver) BeginTx(ctx context.Context) (db.Tx, error) {
+	return nil, fmt.Errorf("transaction isn't supported for %s", db.Trino)
+}
+
+// migrationSchema returns the catalog and the schema of the migration history.
+func (driver *Driver) migrationSchema() (string, string, error) {
+	if v := driver.driverConfig.MigrationHistorySchema; v != "" {
+		catalog, schema := splitDatabase(v)
+		if catalog == "" || schema == "" {
+			return "", "", fmt.Errorf("the migration history schema %q should be in the form of catalog.schema", v)
+		}
+		return catalog, schema, nil
+	}
+	if driver.catalog == "" {
+		return "", "", fmt.Errorf("the catalog of the migration history is unknown, set the catalog of the connection database or DriverConfig.MigrationHistorySchema")
+	}
+	return driver.catalog, defaultMigrationSchema, nil
+}
+
+// migrationHistoryTableName returns the qualified name of the migration history table.
+func (driver *Driver) migrationHistoryTableName() (string, error) {
+	catalog, schema, err := driver.migrationSchema()
+	if err != nil {
+		return "", err
+	}
+	return qualifiedName(catalog, schema, migrationHistoryTable), nil
+}
+
+// NeedsSetupMigration returns whether it needs to setup migration.
+func (driver *Driver) NeedsSetupMigration(ctx context.Context) (bool, error) {
+	if driver.migrationSetup {
+		return false, nil
+	}
+	catalog, schema, err := driver.migrationSchema()
+	if err != nil {
+		return false, err
+	}
+	query := fmt.Sprintf("SELECT table_name FROM %s WHERE table_schema = ? AND table_name = ?", qualifiedName(catalog, informationSchema, "tables"))
+	var table string
+	if err := driver.db.QueryRowContext(ctx, query, schema, migrationHistoryTable).Scan(&table); err != nil {
+		if err == sql.ErrNoRows {
+			return true, nil
+		}
+		return false, util.FormatErrorWithQuery(err, query)
+	}
+	return false, nil
+}
+
+// SetupMigrationIfNeeded sets up migration if needed.
+// The migration schema and table are created with the default properties of the connector,
+// so create them beforehand for the other properties such as the location.
+func (driver *Driver) SetupMigrationIfNeeded(ctx context.Context) error {
+	setup, err := driver.NeedsSetupMigration(ctx)
+	if err != nil {
+		return err
+	}
+
+	if setup {
+		driver.l.Info("Bytebase migration schema not found, creating schema...",
+			zap.String("environment", driver.connectionCtx.EnvironmentName),
+			zap.String("database", driver.connectionCtx.InstanceName),
+		)
+		catalog, schema, err := driver.migrationSchema()
+		if err != nil {
+			return err
+		}
+		statementList := []string{
+			fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", qualifiedName(catalog, schema)),
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	namespace varchar,
+	id bigint,
+	created_by varchar,
+	created_ts bigint,
+	updated_by varchar,
+	updated_ts bigint,
+	release_version varchar,
+	sequence bigint,
+	source varchar,
+	type varchar,
+	status varchar,
+	version varchar,
+	description varchar,
+	statement varchar,
+	"schema" varchar,
+	schema_prev varchar,
+	execution_duration_ns bigint,
+	issue_id varchar,
+	payload varchar,
+	recorded_ns bigint
+)`, qualifiedName(catalog, schema, migrationHistoryTable)),
+		}
+		for _, statement := range statementList {
+			if _, err := driver.db.ExecContext(ctx, statement); err != nil {
+				driver.l.Error("Failed to initialize migration schema.",
+					zap.Error(err),
+					zap.String("environment", driver.connectionCtx.EnvironmentName),
+					zap.String("database", driver.connectionCtx.InstanceName),
+				)
+				return util.FormatErrorWithQuery(err, statement)
+			}
+		}
+		driver.l.Info("Successfully created migration schema.",
+			zap.String("environment", driver.connectionCtx.EnvironmentName),
+			zap.String("database", driver.connectionCtx.InstanceName),
+		)
+	}
+
+	driver.migrationSetup = true
+	return nil
+}
+
+// ExecuteMigration will execute the migration, where m.Database is in the form of catalog.schema.
+// The semantic versions and running the migration as a role aren't supported.
+func (driver *Driver) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "ExecuteMigration", driver.dbType, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := driver.executeMigration(ctx, m, statement)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+func (driver *Driver) executeMigration(ctx context.Context, m *db.MigrationInfo, statement string) (migrationHistoryID int64, updatedSchema string, resErr error) {
+	if err := driver.driverConfig.ValidateStatement(driver.dbType, statement, m); err != nil {
+		return -1, "", err
+	}
+	if m.UseSemanticVersion {
+		return -1, "", fmt.Errorf("semantic versioning isn't supported for %s", db.Trino)
+	}
+	if m.RunAsRole != "" {
+		return -1, "", fmt.Errorf("running the migration as role %q isn't supported by the driver", m.RunAsRole)
+	}
+	payload, err := util.BuildMigrationPayload(m, statement)
+	if err != nil {
+		return -1, "", err
+	}
+
+	var prevSchemaBuf bytes.Buffer
+	// Don't record schema if the schema hasn't exist yet.
+	if !m.CreateDatabase {
+		if err := driver.Dump(ctx, m.Database, &prevSchemaBuf, true /* schemaOnly */); err != nil {
+			return -1, "", err
+		}
+	}
+
+	// Phase 1 - Precheck before executing migration
+	// Phase 2 - Record migration history as PENDING
+	history, err := driver.beginMigration(ctx, m, prevSchemaBuf.String(), statement, payload)
+	if err != nil {
+		return -1, "", err
+	}
+
+	startedNs := time.Now().UnixNano()
+	defer func() {
+		if err := driver.endMigration(ctx, history, time.Now().UnixNano()-startedNs, updatedSchema, resErr); err != nil {
+			driver.l.Error("Failed to update migration history record",
+				zap.Error(err),
+				zap.Int("migration_id", history.ID),
+			)
+		}
+	}()
+
+	// Phase 3 - Executing migration
+	// Baseline migration type could has non-empty sql but will not execute, except for CreateDatabase.
+	if statement != "" && (m.Type != db.Baseline || m.CreateDatabase) {
+		// Switch to the target schema only if we're NOT creating this target schema.
+		if !m.CreateDatabase {
+			if err := driver.useDatabase(splitDatabase(m.Database)); err != nil {
+				return -1, "", err
+			}
+		}
+		if _, err := driver.execute(ctx, statement); err != nil {
+			return -1, "", err
+		}
+	}
+
+	// Phase 4 - Dump the schema after migration
+	var afterSchemaBuf bytes.Buffer
+	if err := driver.Dump(ctx, m.Database, &afterSchemaBuf, true /* schemaOnly */); err != nil {
+		return -1, "", err
+	}
+	return int64(history.ID), afterSchemaBuf.String(), nil
+}
+
+// beginMigration checks before executing migration and records the migration history with pending status.
+func (driver *Driver) beginMigration(ctx context.Context, m *db.MigrationInfo, prevSchema string, statement string, payload string) (*db.MigrationHistory, error) {
+	historyList, err := driver.findMigrationHistoryList(ctx, &db.MigrationHistoryFind{
+		Database: &m.Namespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Check if the same migration version has already been applied.
+	for _, history := range historyList {
+		if history.Version != m.Version {
+			continue
+		}
+		switch history.Status {
+		case db.Done:
+			if !m.Force {
+				return nil, common.Errorf(common.MigrationAlreadyApplied,
+					fmt.Errorf("%w, database %q has already applied version %s", db.ErrMigrationAlreadyApplied, m.Database, m.Version))
+			}
+			// The forced re-run records the applied id as pending again, and endMigration records it as DONE or FAILED.
+			history.Updater = m.Creator
+			history.UpdatedTs = time.Now().Unix()
+			history.Status = db.Pending
+			history.Statement = statement
+			history.Payload = payload
+			if err := driver.insertMigrationHistory(ctx, history); err != nil {
+				return nil, err
+			}
+			return history, nil
+		case db.Pending:
+			return nil, common.Errorf(common.MigrationPending,
+				fmt.Errorf("database %q version %s migration is already in progress", m.Database, m.Version))
+		case db.Failed:
+			return nil, common.Errorf(common.MigrationFailed,
+				fmt.Errorf("database %q version %s migration has failed, please check your database to make sure things are fine and then start a new migration using a new version ", m.Database, m.Version))
+		}
+	}
+	// Check if there is any higher version already been applied since the last baseline or branch.
+	// The history list is the most recent first.
+	largestSequence := 0
+	for _, history := range historyList {
+		if history.Sequence > largestSequence {
+			largestSequence = history.Sequence
+		}
+	}
+	for _, history := range historyList {
+		if db.CompareVersion(history.Version, m.Version) >= 0 {
+			return nil, common.Errorf(common.MigrationOutOfOrder, fmt.Errorf("database %q has already applied version %s which >= %s", m.Database, history.Version, m.Version))
+		}
+		if history.Type == db.Baseline || history.Type == db.Branch {
+			break
+		}
+	}
+
+	// The id is the creation time in nanoseconds, since Trino doesn't generate sequential ids.
+	now := time.Now()
+	history := &db.MigrationHistory{
+		ID:             int(now.UnixNano()),
+		Creator:        m.Creator,
+		CreatedTs:      now.Unix(),
+		Updater:        m.Creator,
+		UpdatedTs:      now.Unix(),
+		ReleaseVersion: m.ReleaseVersion,
+		Namespace:      m.Namespace,
+		Sequence:       largestSequence + 1,
+		Source:         m.Source,
+		Type:           m.Type,
+		Status:         db.Pending,
+		Version:        m.Version,
+		Description:    m.Description,
+		Statement:      statement,
+		Schema:         prevSchema,
+		SchemaPrev:     prevSchema,
+		IssueID:        m.IssueID,
+		Payload:        payload,
+	}
+	if err := driver.insertMigrationHistory(ctx, history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// endMigration records the migration history as DONE, or FAILED with the error message in the payload if migrationErr isn't nil.
+func (driver *Driver) endMigration(ctx context.Context, history *db.MigrationHistory, migrationDurationNs int64, updatedSchema string, migrationErr error) error {
+	history.ExecutionDurationNs = migrationDurationNs
+	history.UpdatedTs = time.Now().Unix()
+	if migrationErr == nil {
+		history.Status = db.Done
+		history.Schema = updatedSchema
+		return driver.insertMigrationHistory(ctx, history)
+	}
+	payload, err := util.SetMigrationPayloadError(history.Payload, migrationErr.Error())
+	if err != nil {
+		return err
+	}
+	history.Status = db.Failed
+	history.Payload = payload
+	return driver.insertMigrationHistory(ctx, history)
+}
+
+// insertMigrationHistory records the state of the migration history as a new row, which supersedes the previous rows of its id.
+func (driver *Driver) insertMigrationHistory(ctx context.Context, history *db.MigrationHistory) error {
+	tableName, err := driver.migrationHistoryTableName()
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (
+		namespace,
+		id,
+		created_by,
+		created_ts,
+		updated_by,
+		updated_ts,
+		release_version,
+		sequence,
+		source,
+		type,
+		status,
+		version,
+		description,
+		statement,
+		"schema",
+		schema_prev,
+		execution_duration_ns,
+		issue_id,
+		payload,
+		recorded_ns
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, tableName)
+	if _, err := driver.db.ExecContext(ctx, query,
+		history.Namespace,
+		int64(history.ID),
+		history.Creator,
+		history.CreatedTs,
+		history.Updater,
+		history.UpdatedTs,
+		history.ReleaseVersion,
+		int64(history.Sequence),
+		string(history.Source),
+		string(history.Type),
+		string(history.Status),
+		history.Version,
+		history.Description,
+		history.Statement,
+		history.Schema,
+		history.SchemaPrev,
+		history.ExecutionDurationNs,
+		history.IssueID,
+		history.Payload,
+		time.Now().UnixNano(),
+	); err != nil {
+		return util.FormatErrorWithQuery(err, query)
+	}
+	return nil
+}
+
+// Rollback will revert the applied migration version by executing its rollback statement as migration m with ExecuteMigration.
+// The reverted migration is marked as rolled back by m.Version in its payload, and it can't be rolled back again.
+func (driver *Driver) Rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	ctx, span := db.StartSpan(ctx, driver.driverConfig.Tracer, "Rollback", driver.dbType, db.MigrationSpanAttributes(m))
+	migrationHistoryID, updatedSchema, err := driver.rollback(ctx, m, version)
+	span.End(err)
+	return migrationHistoryID, updatedSchema, err
+}
+
+func (driver *Driver) rollback(ctx context.Context, m *db.MigrationInfo, version string) (int64, string, error) {
+	list, err := driver.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{
+		Database: &m.Namespace,
+		Version:  &version,
+	})
+	if err != nil {
+		return -1, "", fmt.Errorf("failed to find migration history of version %s, error %w", version, err)
+	}
+	if len(list) == 0 {
+		return -1, "", common.Errorf(common.NotFound, fmt.Errorf("database %q has never applied version %s", m.Database, version))
+	}
+	history := list[0]
+	if history.Type == db.Baseline {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s is a baseline, which can't be rolled back", m.Database, version))
+	}
+	if history.Status != db.Done {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration is %s, only the applied migration can be rolled back", m.Database, version, history.Status))
+	}
+	var miPayload db.MigrationInfoPayload
+	if history.Payload != "" {
+		if err := json.Unmarshal([]byte(history.Payload), &miPayload); err != nil {
+			return -1, "", fmt.Errorf("failed to unmarshal migration history payload of version %s, error %w", version, err)
+		}
+	}
+	if miPayload.RolledBackBy != "" {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration has already been rolled back by version %s", m.Database, version, miPayload.RolledBackBy))
+	}
+	if miPayload.RollbackStatement == "" {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration doesn't have a rollback statement", m.Database, version))
+	}
+
+	mi := *m
+	mi.Type = db.Migrate
+	mi.RollbackStatement = ""
+	if mi.Description == "" {
+		mi.Description = fmt.Sprintf("Rollback %s version %s", m.Database, version)
+	}
+	migrationHistoryID, updatedSchema, err := driver.ExecuteMigration(ctx, &mi, miPayload.RollbackStatement)
+	if err != nil {
+		return -1, "", err
+	}
+
+	miPayload.RolledBackBy = mi.Version
+	payloadBytes, err := json.Marshal(miPayload)
+	if err != nil {
+		return -1, "", fmt.Errorf("failed to marshal migration info payload, error %w", err)
+	}
+	history.Payload = string(payloadBytes)
+	if err := driver.insertMigrationHistory(ctx, history); err != nil {
+		return -1, "", fmt.Errorf("database %q version %s migration has been rolled back by version %s, but failed to mark it as rolled back, error %w", m.Database, version, mi.Version, err)
+	}
+	return migrationHistoryID, updatedSchema, nil
+}
+
+// FindMigrationHistoryList finds the migration history, most recent first.
+// Only the namespace is filtered by Trino, and the other conditions are filtered by the driver after the rows of each id are merged.
+func (driver *Driver) FindMigrationHistoryList(ctx context.Context, find *db.MigrationHistoryFind) ([]*db.MigrationHistory, error) {
+	historyList, err := driver.findMigrationHistoryList(ctx, find)
+	if err != nil {
+		return nil, err
+	}
+	var filteredList []*db.MigrationHistory
+	for _, history := range historyList {
+		if v := find.ID; v != nil && history.ID != *v {
+			continue
+		}
+		if v := find.Version; v != nil && history.Version != *v {
+			continue
+		}
+		if v := find.Source; v != nil && history.Source != *v {
+			continue
+		}
+		if v := find.Status; v != nil && history.Status != *v {
+			continue
+		}
+		if v := find.IssueID; v != nil && history.IssueID != *v {
+			continue
+		}
+		if v := find.Limit; v != nil && len(filteredList) >= *v {
+			break
+		}
+		filteredList = append(filteredList, history)
+	}
+	return filteredList, nil
+}
+
+// recordedHistory is a row of the migration history table.
+type recordedHistory struct {
+	history    *db.MigrationHistory
+	recordedNs int64
+}
+
+// findMigrationHistoryList returns the migration history of the namespace of find, or all namespaces if it's nil, most recent first.
+func (driver *Driver) findMigrationHistoryList(ctx context.Context, find *db.MigrationHistoryFind) ([]*db.MigrationHistory, error) {
+	tableName, err := driver.migrationHistoryTableName()
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`SELECT
+		id,
+		created_by,
+		created_ts,
+		updated_by,
+		updated_ts,
+		release_version,
+		namespace,
+		sequence,
+		source,
+		type,
+		status,
+		version,
+		description,
+		%s,
+		"schema",
+		schema_prev,
+		execution_duration_ns,
+		issue_id,
+		payload,
+		recorded_ns
+	FROM %s`, util.MigrationHistoryStatementColumn(find), tableName)
+	var args []interface{}
+	if v := find.Database; v != nil {
+		query += " WHERE namespace = ?"
+		args = append(args, *v)
+	}
+	var recordedList []*recordedHistory
+	if err := driver.queryRows(ctx, query, args, func(rows *sql.Rows) error {
+		var history db.MigrationHistory
+		var id, sequence, recordedNs int64
+		var source, migrationType, status string
+		if err := rows.Scan(
+			&id,
+			&history.Creator,
+			&history.CreatedTs,
+			&history.Updater,
+			&history.UpdatedTs,
+			&history.ReleaseVersion,
+			&history.Namespace,
+			&sequence,
+			&source,
+			&migrationType,
+			&status,
+			&history.Version,
+			&history.Description,
+			&history.Statement,
+			&history.Schema,
+			&history.SchemaPrev,
+			&history.ExecutionDurationNs,
+			&history.IssueID,
+			&history.Payload,
+			&recordedNs,
+		); err != nil {
+			return err
+		}
+		history.ID, history.Sequence = int(id), int(sequence)
+		history.Source, history.Type, history.Status = db.MigrationSource(source), db.MigrationType(migrationType), db.MigrationStatus(status)
+		recordedList = append(recordedList, &recordedHistory{history: &history, recordedNs: recordedNs})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	historyList := latestMigrationHistoryList(recordedList)
+	for _, history := range historyList {
+		if history.Payload == "" {
+			continue
+		}
+		var miPayload db.MigrationInfoPayload
+		if err := json.Unmarshal([]byte(history.Payload), &miPayload); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal migration history payload of version %s, error %w", history.Version, err)
+		}
+		history.ErrorMessage, history.Environment, history.Labels = miPayload.ErrorMessage, miPayload.Environment, miPayload.Labels
+		history.RolledBackBy = miPayload.RolledBackBy
+	}
+	return historyList, nil
+}
+
+// latestMigrationHistoryList returns the row recorded last of each id, most recent first.
+func latestMigrationHistoryList(recordedList []*recordedHistory) []*db.MigrationHistory {
+	// id -> the row recorded last map
+	latestMap := make(map[int]*recordedHistory)
+	for _, recorded := range recordedList {
+		if latest, ok := latestMap[recorded.history.ID]; !ok || recorded.recordedNs > latest.recordedNs {
+			latestMap[recorded.history.ID] = recorded
+		}
+	}
+	var historyList []*db.MigrationHistory
+	for _, recorded := range latestMap {
+		historyList = append(historyList, recorded.history)
+	}
+	// The id is the creation time in nanoseconds.
+	sort.Slice(historyList, func(i, j int) bool {
+		return historyList[i].ID > historyList[j].ID
+	})
+	return historyList
+}
+
+// Dump and restore
+const (
+	schemaHeaderFmt = "" +
+		"--\n" +
+		"-- Trino schema structure for `%s`\n" +
+		"--\n"
+	tableStmtFmt = "" +
+		"--\n" +
+		"-- %s structure for `%s`\n" +
+		"--\n" +
+		"%s;\n\n"
+)
+
+// Dump dumps the schema of the database in the form of catalog.schema, or all the synced schemas if database is empty.
+// The statements are the output of SHOW CREATE TABLE and SHOW CREATE VIEW, whose names are qualified by the catalog and the schema.
+// schemaOnly isn't supported yet and true by default.
+func (driver *Driver) Dump(ctx context.Context, database string, out io.Writer, schemaOnly bool) error {
+	var dumpableSchemaList []string
+	if database != "" {
+		catalog, schema := splitDatabase(database)
+		if schema == "" {
+			return fmt.Errorf("the database %q should be in the form of catalog.schema", database)
+		}
+		var exist bool
+		query := fmt.Sprintf("SELECT schema_name FROM %s WHERE schema_name = ?", qualifiedName(catalog, informationSchema, "schemata"))
+		if err := driver.queryRows(ctx, query, []interface{}{schema}, func(rows *sql.Rows) error {
+			exist = true
+			return nil
+		}); err != nil {
+			return err
+		}
+		if !exist {
+			return common.Errorf(common.NotFound, fmt.Errorf("schema %s not found", database))
+		}
+		dumpableSchemaList = []string{database}
+	} else {
+		schemaList, err := driver.syncSchema(ctx)
+		if err != nil {
+			return err
+		}
+		for _, schema := range schemaList {
+			dumpableSchemaList = append(dumpableSchemaList, schema.Name)
+		}
+	}
+
+	migrationCatalog, migrationSchema, _ := driver.migrationSchema()
+	for _, database := range dumpableSchemaList {
+		catalog, schema := splitDatabase(database)
+		// Include "CREATE SCHEMA" and "USE" if dumping multiple schemas.
+		if len(dumpableSchemaList) > 1 {
+			stmt := fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;\nUSE %s;\n\n", qualifiedName(catalog, schema), qualifiedName(catalog, schema))
+			if _, err := io.WriteString(out, fmt.Sprintf(schemaHeaderFmt, database)+stmt); err != nil {
+				return err
+			}
+		}
+
+		query := fmt.Sprintf("SELECT table_name, table_type FROM %s WHERE table_schema = ? ORDER BY table_name", qualifiedName(catalog, informationSchema, "tables"))
+		type tableInfo struct {
+			name      string
+			tableType string
+		}
+		var tableList []tableInfo
+		if err := driver.queryRows(ctx, query, []interface{}{schema}, func(rows *sql.Rows) error {
+			var table tableInfo
+			if err := rows.Scan(&table.name, &table.tableType); err != nil {
+				return err
+			}
+			tableList = append(tableList, table)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to get tables of schema %q: %s", database, err)
+		}
+		for _, table := range tableList {
+			if table.name == migrationHistoryTable && catalog == migrationCatalog && schema == migrationSchema {
+				continue
+			}
+			kind := "Table"
+			if table.tableType == "VIEW" {
+				kind = "View"
+			}
+			query := fmt.Sprintf("SHOW CREATE %s %s", strings.ToUpper(kind), qualifiedName(catalog, schema, table.name))
+			var stmt string
+			if err := driver.db.QueryRowContext(ctx, query).Scan(&stmt); err != nil {
+				return util.FormatErrorWithQuery(err, query)
+			}
+			if _, err := io.WriteString(out, fmt.Sprintf(tableStmtFmt, kind, table.name, stmt)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Restore applies the statements one by one without a transaction, so the statements before a failure stay applied.
+func (driver *Driver) Restore(ctx context.Context, sc *bufio.Scanner) error {
+	f := func(stmt string) error {
+		if _, err := driver.ExecuteStatementList(ctx, []string{stmt}); err != nil {
+			return err
+		}
+		return nil
+	}
+	return db.ApplyStatements(sc, db.Trino, f)
+}
@@ -0,0 +1,70 @@
+package trino
+
+import (
+	"testing"
+
+	"github.com/bytebase/bytebase/plugin/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUseStatement(t *testing.T) {
+	tests := []struct {
+		statement string
+		catalog   string
+		schema    string
+		ok        bool
+	}{
+		{statement: "USE s1", schema: "s1", ok: true},
+		{statement: "use Hive.S1", catalog: "hive", schema: "s1", ok: true},
+		{statement: `USE "Hive" . "S1"`, catalog: "Hive", schema: "S1", ok: true},
+		{statement: `USE hive."a""b"`, catalog: "hive", schema: `a"b`, ok: true},
+		{statement: "  USE\nhive.s1  ", catalog: "hive", schema: "s1", ok: true},
+		{statement: "USE hive.s1.t1", ok: false},
+		{statement: "SELECT * FROM users", ok: false},
+		{statement: "USER s1", ok: false},
+	}
+	for _, test := range tests {
+		catalog, schema, ok := parseUseStatement(test.statement)
+		require.Equal(t, test.ok, ok, test.statement)
+		require.Equal(t, test.catalog, catalog, test.statement)
+		require.Equal(t, test.schema, schema, test.statement)
+	}
+}
+
+func TestSplitDatabase(t *testing.T) {
+	tests := []struct {
+		database string
+		catalog  string
+		schema   string
+	}{
+		{database: "", catalog: "", schema: ""},
+		{database: "hive", catalog: "hive", schema: ""},
+		{database: "hive.sales", catalog: "hive", schema: "sales"},
+		{database: "hive.sales.2022", catalog: "hive", schema: "sales.2022"},
+	}
+	for _, test := range tests {
+		catalog, schema := splitDatabase(test.database)
+		require.Equal(t, test.catalog, catalog, test.database)
+		require.Equal(t, test.schema, schema, test.database)
+	}
+}
+
+func TestLatestMigrationHistoryList(t *testing.T) {
+	recordedList := []*recordedHistory{
+		{history: &db.MigrationHistory{ID: 1, Version: "0001", Status: db.Pending}, recordedNs: 10},
+		{history: &db.MigrationHistory{ID: 2, Version: "0002", Status: db.Failed}, recordedNs: 40},
+		{history: &db.MigrationHistory{ID: 1, Version: "0001", Status: db.Done}, recordedNs: 20},
+		{history: &db.MigrationHistory{ID: 2, Version: "0002", Status: db.Pending}, recordedNs: 30},
+		{history: &db.MigrationHistory{ID: 3, Version: "0003", Status: db.Pending}, recordedNs: 50},
+	}
+	historyList := latestMigrationHistoryList(recordedList)
+	require.Len(t, historyList, 3)
+	var statusList []db.MigrationStatus
+	var versionList []string
+	for _, history := range historyList {
+		versionList = append(versionList, history.Version)
+		statusList = append(statusList, history.Status)
+	}
+	require.Equal(t, []string{"0003", "0002", "0001"}, versionList)
+	require.Equal(t, []db.MigrationStatus{db.Pending, db.Failed, db.Done}, statusList)
+}
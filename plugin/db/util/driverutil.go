@@ -4,11 +4,16 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/blang/semver/v4"
 	"github.com/bytebase/bytebase/common"
@@ -96,6 +101,46 @@ func ApplyMultiStatements(sc *bufio.Scanner, f func(string) error) error {
 	return nil
 }
 
+// ExecuteBatch executes the statements one by one in a single transaction, and rolls back on the first failure.
+// The returned error is a *db.BatchError with the index of the failing statement.
+func ExecuteBatch(ctx context.Context, sqldb *sql.DB, statementList []string) error {
+	tx, err := sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, statement := range statementList {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return &db.BatchError{Index: i, Err: err}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ExecuteMultiStatementBatch is ExecuteBatch sending all the statements to the server in a single round trip.
+// It's only for the databases executing multiple statements in one call and rolling back the DDL statements with the transaction,
+// e.g. Postgres, SQLite and SQL Server. Since the error of the single round trip doesn't tell which statement fails,
+// the statements are executed again one by one in a new transaction by ExecuteBatch on failure to return the *db.BatchError.
+func ExecuteMultiStatementBatch(ctx context.Context, sqldb *sql.DB, statementList []string) error {
+	if len(statementList) <= 1 {
+		return ExecuteBatch(ctx, sqldb, statementList)
+	}
+	tx, err := sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, strings.Join(statementList, ";\n")); err != nil {
+		// The transaction must be rolled back before the statements are executed again.
+		tx.Rollback()
+		return ExecuteBatch(ctx, sqldb, statementList)
+	}
+	return tx.Commit()
+}
+
 // NeedsSetupMigrationSchema will return whether it's needed to setup migration schema.
 func NeedsSetupMigrationSchema(ctx context.Context, sqldb *sql.DB, query string) (bool, error) {
 	rows, err := sqldb.QueryContext(ctx, query)
@@ -114,8 +159,8 @@ func NeedsSetupMigrationSchema(ctx context.Context, sqldb *sql.DB, query string)
 // MigrationExecutor is an adapter for ExecuteMigration().
 type MigrationExecutor interface {
 	db.Driver
-	// FindLargestVersionSinceBaseline will find the largest version since last baseline or branch.
-	FindLargestVersionSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) (*string, error)
+	// FindVersionListSinceBaseline will find the stored versions since last baseline or branch.
+	FindVersionListSinceBaseline(ctx context.Context, tx *sql.Tx, namespace string) ([]string, error)
 	// FindLargestSequence will return the largest sequence number.
 	// Returns 0 if we haven't applied any migration for this namespace.
 	FindLargestSequence(ctx context.Context, tx *sql.Tx, namespace string, baseline bool) (int, error)
@@ -123,13 +168,67 @@ type MigrationExecutor interface {
 	InsertPendingHistory(ctx context.Context, tx *sql.Tx, sequence int, prevSchema string, m *db.MigrationInfo, storedVersion, statement string) (insertedID int64, err error)
 	// UpdateHistoryAsDone will update the migration record as done.
 	UpdateHistoryAsDone(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, updatedSchema string, insertedID int64) error
-	// UpdateHistoryAsFailed will update the migration record as failed.
-	UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, insertedID int64) error
+	// UpdateHistoryAsFailed will update the migration record as failed, and replace the payload with the one recording the error message.
+	UpdateHistoryAsFailed(ctx context.Context, tx *sql.Tx, migrationDurationNs int64, payload string, insertedID int64) error
+	// UpdateHistoryPayload will replace the payload of the migration record.
+	UpdateHistoryPayload(ctx context.Context, tx *sql.Tx, payload string, id int64) error
 }
 
 // ExecuteMigration will execute the database migration.
 // Returns the created migraiton history id and the updated schema on success.
-func ExecuteMigration(ctx context.Context, l *zap.Logger, executor MigrationExecutor, m *db.MigrationInfo, statement string) (migrationHistoryID int64, updatedSchema string, resErr error) {
+// If the executor implements db.MigrationLocker, the migration is executed holding the migration lock of the namespace,
+// or of the database if the namespace is empty.
+func ExecuteMigration(ctx context.Context, l *zap.Logger, executor MigrationExecutor, m *db.MigrationInfo, statement string) (int64, string, error) {
+	var migrationHistoryID int64
+	var updatedSchema string
+	if err := withMigrationLock(ctx, executor, m, func(ctx context.Context) error {
+		var err error
+		migrationHistoryID, updatedSchema, err = executeMigration(ctx, l, executor, m, statement)
+		return err
+	}); err != nil {
+		return -1, "", err
+	}
+	return migrationHistoryID, updatedSchema, nil
+}
+
+// migrationLockKey is the context key of the namespace whose migration lock is held by withMigrationLock.
+type migrationLockKey struct{}
+
+// withMigrationLock calls fn holding the migration lock of the namespace of m, or of the database if the namespace is empty,
+// if the executor implements db.MigrationLocker. The lock isn't reentrant, so the context of fn records the held lock,
+// and the nested calls for the same namespace, e.g. ExecuteMigration called by Rollback, call fn directly.
+func withMigrationLock(ctx context.Context, executor MigrationExecutor, m *db.MigrationInfo, fn func(ctx context.Context) error) error {
+	namespace := m.Namespace
+	if namespace == "" {
+		namespace = m.Database
+	}
+	locker, ok := executor.(db.MigrationLocker)
+	if !ok || ctx.Value(migrationLockKey{}) == namespace {
+		return fn(ctx)
+	}
+	return locker.WithMigrationLock(ctx, namespace, func(ctx context.Context) error {
+		return fn(context.WithValue(ctx, migrationLockKey{}, namespace))
+	})
+}
+
+func executeMigration(ctx context.Context, l *zap.Logger, executor MigrationExecutor, m *db.MigrationInfo, statement string) (migrationHistoryID int64, updatedSchema string, resErr error) {
+	if l == nil {
+		l = zap.NewNop()
+	}
+	if m.RunAsRole != "" {
+		if _, ok := executor.(db.RoleExecutor); !ok {
+			return -1, "", fmt.Errorf("running the migration as role %q isn't supported by the driver", m.RunAsRole)
+		}
+	}
+	// Record the statement checksum, the rollback statement, the role, the environment and the labels in the migration history payload.
+	payload, err := BuildMigrationPayload(m, statement)
+	if err != nil {
+		return -1, "", err
+	}
+	mi := *m
+	mi.Payload = payload
+	m = &mi
+
 	var prevSchemaBuf bytes.Buffer
 	// Don't record schema if the database hasn't exist yet.
 	if !m.CreateDatabase {
@@ -148,14 +247,28 @@ func ExecuteMigration(ctx context.Context, l *zap.Logger, executor MigrationExec
 	}
 
 	startedNs := time.Now().UnixNano()
+	var rowsAffected int64
 
 	defer func() {
-		if err := endMigration(ctx, l, executor, startedNs, insertedID, updatedSchema, resErr == nil /*isDone*/); err != nil {
+		if err := endMigration(ctx, l, executor, startedNs, insertedID, updatedSchema, m.Payload, resErr); err != nil {
 			l.Error("Failed to update migration history record",
 				zap.Error(err),
 				zap.Int64("migration_id", migrationHistoryID),
 			)
 		}
+
+		fields := []zap.Field{
+			zap.String("database", m.Database),
+			zap.String("version", m.Version),
+			zap.String("type", string(m.Type)),
+			zap.Duration("duration", time.Duration(time.Now().UnixNano()-startedNs)),
+			zap.String("creator", m.Creator),
+		}
+		if resErr == nil {
+			l.Info("Applied migration", append(fields, zap.Int64("rows_affected", rowsAffected))...)
+		} else {
+			l.Error("Failed to apply migration", append(fields, zap.String("statement", truncateStatement(statement)), zap.Error(resErr))...)
+		}
 	}()
 
 	// Phase 3 - Executing migration
@@ -169,9 +282,11 @@ func ExecuteMigration(ctx context.Context, l *zap.Logger, executor MigrationExec
 				return -1, "", err
 			}
 		}
-		if err := executor.Execute(ctx, statement); err != nil {
+		result, err := executeMigrationStatement(ctx, executor, statement, m.CreateDatabase, m.RunAsRole)
+		if err != nil {
 			return -1, "", formatError(err)
 		}
+		rowsAffected = result.RowsAffected
 	}
 
 	// Phase 4 - Dump the schema after migration
@@ -183,6 +298,307 @@ func ExecuteMigration(ctx context.Context, l *zap.Logger, executor MigrationExec
 	return insertedID, afterSchemaBuf.String(), nil
 }
 
+// executeMigrationStatement executes the migration statement.
+// If the driver implements db.StatementListExecutor, the statement is split and executed statement by statement,
+// so that a failure rolls back the whole migration for the databases with transactional DDL, and the error identifies the failed statement.
+// If the role isn't empty, the statement is executed as the role by db.RoleExecutor.
+func executeMigrationStatement(ctx context.Context, executor MigrationExecutor, statement string, createDatabase bool, role string) (db.ExecuteResult, error) {
+	if role != "" {
+		roleExecutor, ok := executor.(db.RoleExecutor)
+		if !ok {
+			return db.ExecuteResult{}, fmt.Errorf("running the migration as role %q isn't supported by the driver", role)
+		}
+		statementList, err := db.SplitStatements(statement, roleExecutor.GetType())
+		if err != nil {
+			return db.ExecuteResult{}, err
+		}
+		return roleExecutor.ExecuteStatementListAsRole(ctx, role, statementList)
+	}
+	listExecutor, ok := executor.(db.StatementListExecutor)
+	// Creating the database can't be executed in a transaction.
+	if !ok || createDatabase {
+		return executor.Execute(ctx, statement)
+	}
+	statementList, err := db.SplitStatements(statement, listExecutor.GetType())
+	if err != nil {
+		return db.ExecuteResult{}, err
+	}
+	return listExecutor.ExecuteStatementList(ctx, statementList)
+}
+
+// maxLoggedStatementLength is the maximum length in bytes of the statement in the logs.
+const maxLoggedStatementLength = 1024
+
+// truncateStatement truncates the statement to maxLoggedStatementLength without breaking a UTF-8 character.
+func truncateStatement(statement string) string {
+	if len(statement) <= maxLoggedStatementLength {
+		return statement
+	}
+	end := maxLoggedStatementLength
+	for end > 0 && !utf8.RuneStart(statement[end]) {
+		end--
+	}
+	return statement[:end] + "..."
+}
+
+// BuildMigrationPayload sets the statement checksum, the rollback statement, the role, the environment and the labels of m in its payload.
+// It's exported for the drivers recording the migration history without MigrationExecutor.
+func BuildMigrationPayload(m *db.MigrationInfo, statement string) (string, error) {
+	var miPayload db.MigrationInfoPayload
+	if m.Payload != "" {
+		if err := json.Unmarshal([]byte(m.Payload), &miPayload); err != nil {
+			return "", fmt.Errorf("failed to unmarshal migration info payload %q, error %w", m.Payload, err)
+		}
+	}
+	if statement != "" {
+		miPayload.StatementChecksum = StatementChecksum(statement)
+	}
+	if m.RollbackStatement != "" {
+		miPayload.RollbackStatement = m.RollbackStatement
+	}
+	if m.RunAsRole != "" {
+		miPayload.Role = m.RunAsRole
+	}
+	if m.Environment != "" {
+		miPayload.Environment = m.Environment
+	}
+	if len(m.Labels) > 0 {
+		miPayload.Labels = m.Labels
+	}
+	payloadBytes, err := json.Marshal(miPayload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal migration info payload, error %w", err)
+	}
+	return string(payloadBytes), nil
+}
+
+// SetMigrationPayloadError sets the error message in the migration info payload.
+func SetMigrationPayloadError(payload string, errorMessage string) (string, error) {
+	var miPayload db.MigrationInfoPayload
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &miPayload); err != nil {
+			return "", fmt.Errorf("failed to unmarshal migration info payload %q, error %w", payload, err)
+		}
+	}
+	miPayload.ErrorMessage = errorMessage
+	payloadBytes, err := json.Marshal(miPayload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal migration info payload, error %w", err)
+	}
+	return string(payloadBytes), nil
+}
+
+// StatementChecksum returns the hex encoded SHA-256 checksum of the migration statement.
+func StatementChecksum(statement string) string {
+	sum := sha256.Sum256([]byte(statement))
+	return hex.EncodeToString(sum[:])
+}
+
+// ChecksumMismatch is an applied migration version whose statement differs from the current migration file.
+type ChecksumMismatch struct {
+	Version string
+	// StoredChecksum is the checksum recorded in the migration history.
+	StoredChecksum string
+	// FileChecksum is the checksum of the current migration file content.
+	FileChecksum string
+}
+
+// VerifyMigrationChecksums re-reads the applied migration versions of the database and reports the ones whose
+// stored checksum differs from the current file content. The files map is keyed by the migration version.
+// Versions without any applied migration are ignored. For migration histories recorded before the checksum was
+// introduced, the checksum is computed from the stored statement.
+func VerifyMigrationChecksums(ctx context.Context, driver db.Driver, database string, files map[string]string) ([]ChecksumMismatch, error) {
+	versionList := make([]string, 0, len(files))
+	for version := range files {
+		versionList = append(versionList, version)
+	}
+	sort.Strings(versionList)
+
+	var mismatchList []ChecksumMismatch
+	for _, version := range versionList {
+		v := version
+		list, err := driver.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{
+			Database: &database,
+			Version:  &v,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find migration history of version %s, error %w", version, err)
+		}
+		if len(list) == 0 {
+			continue
+		}
+		history := list[0]
+		// Baseline and branch migrations don't execute the statement.
+		if history.Status != db.Done || history.Type == db.Baseline || history.Type == db.Branch {
+			continue
+		}
+		storedChecksum, err := getStoredChecksum(history)
+		if err != nil {
+			return nil, err
+		}
+		if fileChecksum := StatementChecksum(files[version]); fileChecksum != storedChecksum {
+			mismatchList = append(mismatchList, ChecksumMismatch{
+				Version:        version,
+				StoredChecksum: storedChecksum,
+				FileChecksum:   fileChecksum,
+			})
+		}
+	}
+	return mismatchList, nil
+}
+
+// GetLatestVersion returns the highest version of the applied migrations of the namespace, or empty if there's none.
+// The versions are compared by db.CompareVersion, and the pending and failed migrations are ignored.
+// So are the migrations rolled back and the ones recording the rollbacks, whose changes have been reverted.
+func GetLatestVersion(ctx context.Context, driver db.Driver, namespace string) (string, error) {
+	list, err := driver.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{
+		Database: &namespace,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find migration history of namespace %q, error %w", namespace, err)
+	}
+	rollbackVersions := make(map[string]bool)
+	for _, history := range list {
+		if history.RolledBackBy != "" {
+			rollbackVersions[history.RolledBackBy] = true
+		}
+	}
+	latest := ""
+	for _, history := range list {
+		if history.Status != db.Done || history.RolledBackBy != "" || rollbackVersions[history.Version] {
+			continue
+		}
+		if latest == "" || db.CompareVersion(history.Version, latest) > 0 {
+			latest = history.Version
+		}
+	}
+	return latest, nil
+}
+
+// FindIncompleteMigrations returns the pending migrations of all namespaces, most recent first, which are recorded as started but not finished.
+// A migration is left pending if the runner dies while executing it, and its statement may have been applied partially,
+// so the operator should check the database before retrying it with a new version.
+// A pending migration may also be still running by another runner.
+func FindIncompleteMigrations(ctx context.Context, driver db.Driver) ([]*db.MigrationHistory, error) {
+	status := db.Pending
+	list, err := driver.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{
+		Status: &status,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pending migration history, error %w", err)
+	}
+	return list, nil
+}
+
+// getStoredChecksum returns the statement checksum recorded in the migration history payload,
+// and falls back to the checksum of the stored statement.
+func getStoredChecksum(history *db.MigrationHistory) (string, error) {
+	if history.Payload != "" {
+		var miPayload db.MigrationInfoPayload
+		if err := json.Unmarshal([]byte(history.Payload), &miPayload); err != nil {
+			return "", fmt.Errorf("failed to unmarshal migration history payload of version %s, error %w", history.Version, err)
+		}
+		if miPayload.StatementChecksum != "" {
+			return miPayload.StatementChecksum, nil
+		}
+	}
+	return StatementChecksum(history.Statement), nil
+}
+
+// Rollback will revert the applied migration version by executing its rollback statement as migration m with executor.ExecuteMigration,
+// so the rollback statement is validated and traced like any other migration statement.
+// The reverted migration is marked as rolled back by m.Version in its payload, and it can't be rolled back again.
+// If the executor implements db.MigrationLocker, the migration history is checked and updated holding the migration lock,
+// so the concurrent rollbacks of the same version can't both pass the checks.
+// Returns the created migration history id and the updated schema on success.
+func Rollback(ctx context.Context, l *zap.Logger, executor MigrationExecutor, m *db.MigrationInfo, version string) (int64, string, error) {
+	var migrationHistoryID int64
+	var updatedSchema string
+	if err := withMigrationLock(ctx, executor, m, func(ctx context.Context) error {
+		var err error
+		migrationHistoryID, updatedSchema, err = rollback(ctx, executor, m, version)
+		return err
+	}); err != nil {
+		return -1, "", err
+	}
+	return migrationHistoryID, updatedSchema, nil
+}
+
+func rollback(ctx context.Context, executor MigrationExecutor, m *db.MigrationInfo, version string) (int64, string, error) {
+	list, err := executor.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{
+		Database: &m.Namespace,
+		Version:  &version,
+	})
+	if err != nil {
+		return -1, "", fmt.Errorf("failed to find migration history of version %s, error %w", version, err)
+	}
+	if len(list) == 0 {
+		return -1, "", common.Errorf(common.NotFound, fmt.Errorf("database %q has never applied version %s", m.Database, version))
+	}
+	history := list[0]
+	if history.Type == db.Baseline {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s is a baseline, which can't be rolled back", m.Database, version))
+	}
+	if history.Status != db.Done {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration is %s, only the applied migration can be rolled back", m.Database, version, history.Status))
+	}
+	var miPayload db.MigrationInfoPayload
+	if history.Payload != "" {
+		if err := json.Unmarshal([]byte(history.Payload), &miPayload); err != nil {
+			return -1, "", fmt.Errorf("failed to unmarshal migration history payload of version %s, error %w", version, err)
+		}
+	}
+	if miPayload.RolledBackBy != "" {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration has already been rolled back by version %s", m.Database, version, miPayload.RolledBackBy))
+	}
+	if miPayload.RollbackStatement == "" {
+		return -1, "", common.Errorf(common.Invalid, fmt.Errorf("database %q version %s migration doesn't have a rollback statement", m.Database, version))
+	}
+
+	mi := *m
+	mi.Type = db.Migrate
+	mi.RollbackStatement = ""
+	if mi.Description == "" {
+		mi.Description = fmt.Sprintf("Rollback %s version %s", m.Database, version)
+	}
+	migrationHistoryID, updatedSchema, err := executor.ExecuteMigration(ctx, &mi, miPayload.RollbackStatement)
+	if err != nil {
+		return -1, "", err
+	}
+
+	miPayload.RolledBackBy = mi.Version
+	if err := updateHistoryPayload(ctx, executor, &miPayload, int64(history.ID)); err != nil {
+		return -1, "", fmt.Errorf("database %q version %s migration has been rolled back by version %s, but failed to mark it as rolled back, error %w", m.Database, version, mi.Version, err)
+	}
+	return migrationHistoryID, updatedSchema, nil
+}
+
+// updateHistoryPayload replaces the payload of the migration history record.
+func updateHistoryPayload(ctx context.Context, executor MigrationExecutor, miPayload *db.MigrationInfoPayload, id int64) error {
+	payloadBytes, err := json.Marshal(miPayload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal migration info payload, error %w", err)
+	}
+	return updateHistoryPayloadString(ctx, executor, string(payloadBytes), id)
+}
+
+func updateHistoryPayloadString(ctx context.Context, executor MigrationExecutor, payload string, id int64) error {
+	sqldb, err := executor.GetDbConnection(ctx, bytebaseDatabase)
+	if err != nil {
+		return err
+	}
+	tx, err := sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := executor.UpdateHistoryPayload(ctx, tx, payload, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // beginMigration checks before executing migration and inserts a migration history record with pending status.
 func beginMigration(ctx context.Context, executor MigrationExecutor, m *db.MigrationInfo, prevSchema string, statement string) (insertedID int64, err error) {
 	// Convert verion to stored version.
@@ -200,8 +616,16 @@ func beginMigration(ctx context.Context, executor MigrationExecutor, m *db.Migra
 	} else if len(list) > 0 {
 		switch list[0].Status {
 		case db.Done:
-			return -1, common.Errorf(common.MigrationAlreadyApplied,
-				fmt.Errorf("database %q has already applied version %s", m.Database, m.Version))
+			if !m.Force {
+				return -1, common.Errorf(common.MigrationAlreadyApplied,
+					fmt.Errorf("%w, database %q has already applied version %s", db.ErrMigrationAlreadyApplied, m.Database, m.Version))
+			}
+			// The forced re-run reuses the applied record because of the unique (namespace, version) index,
+			// and endMigration updates it as DONE or FAILED again.
+			if err := updateHistoryPayloadString(ctx, executor, m.Payload, int64(list[0].ID)); err != nil {
+				return -1, err
+			}
+			return int64(list[0].ID), nil
 		case db.Pending:
 			return -1, common.Errorf(common.MigrationPending,
 				fmt.Errorf("database %q version %s migration is already in progress", m.Database, m.Version))
@@ -228,11 +652,18 @@ func beginMigration(ctx context.Context, executor MigrationExecutor, m *db.Migra
 	}
 
 	// Check if there is any higher version already been applied since the last baseline or branch.
-	if version, err := executor.FindLargestVersionSinceBaseline(ctx, tx, m.Namespace); err != nil {
+	versionList, err := executor.FindVersionListSinceBaseline(ctx, tx, m.Namespace)
+	if err != nil {
 		return -1, err
-	} else if version != nil && len(*version) > 0 && *version >= m.Version {
-		// len(*version) > 0 is used because Clickhouse will always return non-nil version with empty string.
-		return -1, common.Errorf(common.MigrationOutOfOrder, fmt.Errorf("database %q has already applied version %s which >= %s", m.Database, *version, m.Version))
+	}
+	for _, version := range versionList {
+		c, err := compareStoredVersion(version, storedVersion)
+		if err != nil {
+			return -1, err
+		}
+		if c >= 0 {
+			return -1, common.Errorf(common.MigrationOutOfOrder, fmt.Errorf("database %q has already applied version %s which >= %s", m.Database, version, storedVersion))
+		}
 	}
 
 	// Phase 2 - Record migration history as PENDING.
@@ -250,9 +681,14 @@ func beginMigration(ctx context.Context, executor MigrationExecutor, m *db.Migra
 	return insertedID, nil
 }
 
-// endMigration updates the migration history record to DONE or FAILED depending on migration is done or not.
-func endMigration(ctx context.Context, l *zap.Logger, executor MigrationExecutor, startedNs int64, migrationHistoryID int64, updatedSchema string, isDone bool) (err error) {
+// endMigration updates the migration history record to DONE, or FAILED with the error message in the payload if migrationErr isn't nil.
+func endMigration(ctx context.Context, l *zap.Logger, executor MigrationExecutor, startedNs int64, migrationHistoryID int64, updatedSchema string, payload string, migrationErr error) (err error) {
 	migrationDurationNs := time.Now().UnixNano() - startedNs
+	if migrationErr != nil {
+		if payload, err = SetMigrationPayloadError(payload, migrationErr.Error()); err != nil {
+			return err
+		}
+	}
 
 	sqldb, err := executor.GetDbConnection(ctx, bytebaseDatabase)
 	if err != nil {
@@ -264,12 +700,12 @@ func endMigration(ctx context.Context, l *zap.Logger, executor MigrationExecutor
 	}
 	defer tx.Rollback()
 
-	if isDone {
+	if migrationErr == nil {
 		// Upon success, update the migration history as 'DONE', execution_duration_ns, updated schema.
 		err = executor.UpdateHistoryAsDone(ctx, tx, migrationDurationNs, updatedSchema, migrationHistoryID)
 	} else {
-		// Otherwise, update the migration history as 'FAILED', exeuction_duration.
-		err = executor.UpdateHistoryAsFailed(ctx, tx, migrationDurationNs, migrationHistoryID)
+		// Otherwise, update the migration history as 'FAILED', exeuction_duration, payload with the error message.
+		err = executor.UpdateHistoryAsFailed(ctx, tx, migrationDurationNs, payload, migrationHistoryID)
 	}
 
 	if err != nil {
@@ -292,6 +728,61 @@ func Query(ctx context.Context, l *zap.Logger, sqldb *sql.DB, statement string,
 	}
 	defer tx.Rollback()
 
+	return queryTx(ctx, tx, statement, limit)
+}
+
+// BeginTx begins a transaction on sqldb, which implements Driver.BeginTx for the drivers based on database/sql.
+func BeginTx(ctx context.Context, sqldb *sql.DB) (db.Tx, error) {
+	tx, err := sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{tx: tx}, nil
+}
+
+// sqlTx implements db.Tx with a *sql.Tx.
+type sqlTx struct {
+	tx *sql.Tx
+}
+
+func (t *sqlTx) Execute(ctx context.Context, statement string) (db.ExecuteResult, error) {
+	var result db.ExecuteResult
+	sqlResult, err := t.tx.ExecContext(ctx, statement)
+	if err != nil {
+		return result, FormatErrorWithQuery(err, statement)
+	}
+	result.Add(sqlResult)
+	return result, nil
+}
+
+func (t *sqlTx) Query(ctx context.Context, statement string, limit int) ([]interface{}, error) {
+	return queryTx(ctx, t.tx, statement, limit)
+}
+
+func (t *sqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqlTx) Rollback() error {
+	if err := t.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return err
+	}
+	return nil
+}
+
+// QueryWithoutTx queries the statement on sqldb without a transaction, and returns the result in the format of Driver.Query.
+// It's for the databases without transactions such as Trino, so the callers should make sure the statement is read-only.
+func QueryWithoutTx(ctx context.Context, sqldb *sql.DB, statement string, limit int) ([]interface{}, error) {
+	return queryTx(ctx, sqldb, statement, limit)
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryTx executes the query in tx, and returns the result in the format of Driver.Query.
+func queryTx(ctx context.Context, tx queryer, statement string, limit int) ([]interface{}, error) {
 	rows, err := tx.QueryContext(ctx, statement)
 	if err != nil {
 		return nil, FormatErrorWithQuery(err, statement)
@@ -377,6 +868,15 @@ func Query(ctx context.Context, l *zap.Logger, sqldb *sql.DB, statement string,
 	return []interface{}{columnNames, columnTypeNames, data}, nil
 }
 
+// MigrationHistoryStatementColumn returns the statement column of the migration history query,
+// which selects the empty statement instead if find.OmitStatement is set.
+func MigrationHistoryStatementColumn(find *db.MigrationHistoryFind) string {
+	if find.OmitStatement {
+		return "'' AS statement"
+	}
+	return "statement"
+}
+
 // FindMigrationHistoryList will find the list of migration history.
 func FindMigrationHistoryList(ctx context.Context, findMigrationHistoryListQuery string, queryParams []interface{}, driver db.Driver, find *db.MigrationHistoryFind, baseQuery string) ([]*db.MigrationHistory, error) {
 	sqldb, err := driver.GetDbConnection(ctx, bytebaseDatabase)
@@ -429,6 +929,14 @@ func FindMigrationHistoryList(ctx context.Context, findMigrationHistoryListQuery
 			return nil, err
 		}
 		history.UseSemanticVersion, history.Version, history.SemanticVersionSuffix = useSemanticVersion, version, semanticVersionSuffix
+		if history.Payload != "" {
+			var miPayload db.MigrationInfoPayload
+			if err := json.Unmarshal([]byte(history.Payload), &miPayload); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal migration history payload of version %s, error %w", history.Version, err)
+			}
+			history.ErrorMessage, history.Environment, history.Labels = miPayload.ErrorMessage, miPayload.Environment, miPayload.Labels
+			history.RolledBackBy = miPayload.RolledBackBy
+		}
 		migrationHistoryList = append(migrationHistoryList, &history)
 	}
 	if err := rows.Err(); err != nil {
@@ -477,6 +985,24 @@ func ToStoredVersion(useSemanticVersion bool, version, semanticVersionSuffix str
 	return fmt.Sprintf("%04s.%04s.%04s-%s", major, minor, patch, semanticVersionSuffix), nil
 }
 
+// compareStoredVersion compares two stored versions by their versions with db.CompareVersion, and returns -1 if a < b, 0 if a == b and 1 if a > b.
+// The stored versions aren't comparable as strings, because the non-semantic versions aren't zero-padded, e.g. "1.10" > "1.2".
+// The same semantic versions are compared by their suffixes, so that baselining to the same semantic version again is in order.
+func compareStoredVersion(a, b string) (int, error) {
+	aSemantic, aVersion, aSuffix, err := fromStoredVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	bSemantic, bVersion, bSuffix, err := fromStoredVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	if c := db.CompareVersion(aVersion, bVersion); c != 0 || !aSemantic || !bSemantic {
+		return c, nil
+	}
+	return strings.Compare(aSuffix, bSuffix), nil
+}
+
 // fromStoredVersion converts stored version to semantic or non-semantic version.
 func fromStoredVersion(storedVersion string) (bool, string, string, error) {
 	if strings.HasPrefix(storedVersion, NonSemanticPrefix) {
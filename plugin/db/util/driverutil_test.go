@@ -1,8 +1,14 @@
 package util
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/bytebase/bytebase/plugin/db"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/require"
 )
 
@@ -68,3 +74,244 @@ func TestFromStoredVersion(t *testing.T) {
 		require.Equal(t, tc.wantSemanticVersionSuffix, gotSemanticVersionSuffix)
 	}
 }
+
+func TestBuildMigrationPayload(t *testing.T) {
+	type test struct {
+		m         db.MigrationInfo
+		statement string
+		want      string
+		wantErr   string
+	}
+	tests := []test{
+		{db.MigrationInfo{}, "", `{}`, ""},
+		{db.MigrationInfo{}, "CREATE TABLE t(id INT);", `{"statementChecksum":"` + StatementChecksum("CREATE TABLE t(id INT);") + `"}`, ""},
+		{db.MigrationInfo{RollbackStatement: "DROP TABLE t;"}, "", `{"rollbackStatement":"DROP TABLE t;"}`, ""},
+		{db.MigrationInfo{Payload: "{}", RollbackStatement: "DROP TABLE t;"}, "CREATE TABLE t(id INT);", `{"statementChecksum":"` + StatementChecksum("CREATE TABLE t(id INT);") + `","rollbackStatement":"DROP TABLE t;"}`, ""},
+		{db.MigrationInfo{Payload: `{"rollbackStatement":"DROP TABLE t1;"}`, RollbackStatement: "DROP TABLE t2;"}, "", `{"rollbackStatement":"DROP TABLE t2;"}`, ""},
+		{db.MigrationInfo{RunAsRole: "ddl_admin"}, "", `{"role":"ddl_admin"}`, ""},
+		{db.MigrationInfo{Environment: "prod", Labels: map[string]string{"change_request": "CR-123"}}, "", `{"environment":"prod","labels":{"change_request":"CR-123"}}`, ""},
+		{db.MigrationInfo{Payload: "hello", RollbackStatement: "DROP TABLE t;"}, "", "", "failed to unmarshal migration info payload"},
+	}
+	for _, tc := range tests {
+		got, err := BuildMigrationPayload(&tc.m, tc.statement)
+		if tc.wantErr != "" {
+			require.Contains(t, err.Error(), tc.wantErr)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tc.want, got)
+	}
+}
+
+func TestStatementChecksum(t *testing.T) {
+	require.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", StatementChecksum(""))
+	require.Equal(t, StatementChecksum("CREATE TABLE t(id INT);"), StatementChecksum("CREATE TABLE t(id INT);"))
+	require.NotEqual(t, StatementChecksum("CREATE TABLE t(id INT);"), StatementChecksum("CREATE TABLE t(id BIGINT);"))
+}
+
+func TestTruncateStatement(t *testing.T) {
+	require.Equal(t, "SELECT 1;", truncateStatement("SELECT 1;"))
+
+	long := strings.Repeat("a", maxLoggedStatementLength+10)
+	require.Equal(t, long[:maxLoggedStatementLength]+"...", truncateStatement(long))
+
+	// The multi-byte character crossing the limit is dropped as a whole.
+	multiByte := strings.Repeat("a", maxLoggedStatementLength-1) + "中文"
+	require.Equal(t, multiByte[:maxLoggedStatementLength-1]+"...", truncateStatement(multiByte))
+}
+
+// historyDriver is a fake driver returning the migration histories.
+type historyDriver struct {
+	db.Driver
+	list []*db.MigrationHistory
+}
+
+func (d *historyDriver) FindMigrationHistoryList(_ context.Context, find *db.MigrationHistoryFind) ([]*db.MigrationHistory, error) {
+	var list []*db.MigrationHistory
+	for _, history := range d.list {
+		if find.Status != nil && history.Status != *find.Status {
+			continue
+		}
+		list = append(list, history)
+	}
+	return list, nil
+}
+
+func TestGetLatestVersion(t *testing.T) {
+	ctx := context.Background()
+	driver := &historyDriver{}
+	version, err := GetLatestVersion(ctx, driver, "db1")
+	require.NoError(t, err)
+	require.Equal(t, "", version)
+
+	driver.list = []*db.MigrationHistory{
+		{Version: "1.2.9", Status: db.Done},
+		{Version: "1.2.10", Status: db.Done},
+		{Version: "1.3.0", Status: db.Failed},
+		{Version: "1.2.2", Status: db.Done},
+	}
+	version, err = GetLatestVersion(ctx, driver, "db1")
+	require.NoError(t, err)
+	require.Equal(t, "1.2.10", version)
+
+	// The rolled back migration and the migration recording the rollback are ignored.
+	driver.list[1].RolledBackBy = "1.4.0"
+	driver.list = append(driver.list, &db.MigrationHistory{Version: "1.4.0", Status: db.Done})
+	version, err = GetLatestVersion(ctx, driver, "db1")
+	require.NoError(t, err)
+	require.Equal(t, "1.2.9", version)
+}
+
+// lockingExecutor is a fake executor with a non-reentrant migration lock, which records whether the migration history
+// is read without holding the lock.
+type lockingExecutor struct {
+	MigrationExecutor
+	sqldb        *sql.DB
+	list         []*db.MigrationHistory
+	locked       bool
+	unlockedRead bool
+}
+
+func (e *lockingExecutor) WithMigrationLock(ctx context.Context, _ string, fn func(ctx context.Context) error) error {
+	if e.locked {
+		return errors.New("the migration lock is held")
+	}
+	e.locked = true
+	defer func() {
+		e.locked = false
+	}()
+	return fn(ctx)
+}
+
+func (e *lockingExecutor) FindMigrationHistoryList(_ context.Context, find *db.MigrationHistoryFind) ([]*db.MigrationHistory, error) {
+	if !e.locked {
+		e.unlockedRead = true
+	}
+	var list []*db.MigrationHistory
+	for _, history := range e.list {
+		if find.Version == nil || history.Version == *find.Version {
+			list = append(list, history)
+		}
+	}
+	return list, nil
+}
+
+// ExecuteMigration takes the migration lock as the drivers calling ExecuteMigration do.
+func (e *lockingExecutor) ExecuteMigration(ctx context.Context, m *db.MigrationInfo, statement string) (int64, string, error) {
+	if err := withMigrationLock(ctx, e, m, func(context.Context) error {
+		e.list = append(e.list, &db.MigrationHistory{ID: len(e.list) + 1, Version: m.Version, Status: db.Done, Statement: statement})
+		return nil
+	}); err != nil {
+		return -1, "", err
+	}
+	return int64(len(e.list)), "", nil
+}
+
+func (e *lockingExecutor) GetDbConnection(_ context.Context, _ string) (*sql.DB, error) {
+	return e.sqldb, nil
+}
+
+func (e *lockingExecutor) UpdateHistoryPayload(_ context.Context, _ *sql.Tx, payload string, id int64) error {
+	e.list[id-1].Payload = payload
+	return nil
+}
+
+func TestRollbackHoldsMigrationLock(t *testing.T) {
+	ctx := context.Background()
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer sqldb.Close()
+	executor := &lockingExecutor{
+		sqldb: sqldb,
+		list: []*db.MigrationHistory{
+			{ID: 1, Version: "0001", Status: db.Done, Type: db.Migrate, Payload: `{"rollbackStatement":"DROP TABLE t1"}`},
+		},
+	}
+	m := &db.MigrationInfo{Version: "0002", Namespace: "db1", Database: "db1"}
+
+	_, _, err = Rollback(ctx, nil, executor, m, "0001")
+	require.NoError(t, err)
+	require.False(t, executor.unlockedRead)
+	require.Len(t, executor.list, 2)
+	require.Equal(t, "DROP TABLE t1", executor.list[1].Statement)
+	require.Contains(t, executor.list[0].Payload, `"rolledBackBy":"0002"`)
+
+	m.Version = "0003"
+	_, _, err = Rollback(ctx, nil, executor, m, "0001")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "has already been rolled back by version 0002")
+	require.Len(t, executor.list, 2)
+}
+
+func TestFindIncompleteMigrations(t *testing.T) {
+	ctx := context.Background()
+	driver := &historyDriver{
+		list: []*db.MigrationHistory{
+			{Version: "0003", Status: db.Pending},
+			{Version: "0002", Status: db.Failed},
+			{Version: "0001", Status: db.Done},
+		},
+	}
+	list, err := FindIncompleteMigrations(ctx, driver)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, "0003", list[0].Version)
+}
+
+func TestSetMigrationPayloadError(t *testing.T) {
+	payload, err := SetMigrationPayloadError(`{"statementChecksum":"abc"}`, "syntax error")
+	require.NoError(t, err)
+	require.Equal(t, `{"statementChecksum":"abc","errorMessage":"syntax error"}`, payload)
+
+	payload, err = SetMigrationPayloadError("", "syntax error")
+	require.NoError(t, err)
+	require.Equal(t, `{"errorMessage":"syntax error"}`, payload)
+}
+
+func TestCompareStoredVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{NonSemanticPrefix + "1.2", NonSemanticPrefix + "1.10", -1},
+		{NonSemanticPrefix + "1.2.10", NonSemanticPrefix + "1.2.9", 1},
+		{NonSemanticPrefix + "20220101", NonSemanticPrefix + "20220101", 0},
+		{"0001.0002.0010-20220101000000", "0001.0002.0009-20220101000000", 1},
+		{"0001.0002.0000-20220101000000", "0001.0002.0000-20230101000000", -1},
+		{NonSemanticPrefix + "1.2.0", "0001.0010.0000-20220101000000", -1},
+	}
+	for _, tc := range tests {
+		got, err := compareStoredVersion(tc.a, tc.b)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, got, "%s vs %s", tc.a, tc.b)
+	}
+}
+
+func TestExecuteMultiStatementBatch(t *testing.T) {
+	ctx := context.Background()
+	sqldb, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer sqldb.Close()
+	// Each connection of an in-memory database has its own database.
+	sqldb.SetMaxOpenConns(1)
+
+	err = ExecuteMultiStatementBatch(ctx, sqldb, []string{
+		"CREATE TABLE t(id INTEGER PRIMARY KEY)",
+		"INSERT INTO t VALUES (1)",
+		"INSERT INTO t VALUES (2)",
+	})
+	require.NoError(t, err)
+
+	err = ExecuteMultiStatementBatch(ctx, sqldb, []string{
+		"INSERT INTO t VALUES (3)",
+		"INSERT INTO t VALUES (1)",
+		"INSERT INTO t VALUES (4)",
+	})
+	var batchErr *db.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	require.Equal(t, 1, batchErr.Index)
+
+	var count int
+	require.NoError(t, sqldb.QueryRowContext(ctx, "SELECT COUNT(*) FROM t").Scan(&count))
+	require.Equal(t, 2, count)
+}
@@ -612,6 +612,14 @@ func (s *Server) createPipelineFromIssue(ctx context.Context, issueCreate *api.I
 			m.DatabaseName = strings.ToUpper(m.DatabaseName)
 		case db.SQLite:
 			// no-op.
+		case db.SQLServer:
+			// SQL Server only supports collation at the database level.
+			if m.CharacterSet != "" {
+				return nil, echo.NewHTTPError(
+					http.StatusBadRequest,
+					fmt.Sprintf("Failed to create issue, SQL Server does not support character set, got %s\n", m.CharacterSet),
+				)
+			}
 		default:
 			if m.CharacterSet == "" {
 				return nil, echo.NewHTTPError(http.StatusBadRequest, "Failed to create issue, character set missing")
@@ -619,7 +627,7 @@ func (s *Server) createPipelineFromIssue(ctx context.Context, issueCreate *api.I
 			// For postgres, we don't explicitly specify a default since the default might be UNSET (denoted by "C").
 			// If that's the case, setting an explicit default such as "en_US.UTF-8" might fail if the instance doesn't
 			// install it.
-			if instance.Engine != db.Postgres && m.Collation == "" {
+			if instance.Engine != db.Postgres && instance.Engine != db.CockroachDB && m.Collation == "" {
 				return nil, echo.NewHTTPError(http.StatusBadRequest, "Failed to create issue, collation missing")
 			}
 		}
@@ -990,12 +998,12 @@ func getDatabaseNameAndStatement(dbType db.Type, databaseName, characterSet, col
 
 	var stmt string
 	switch dbType {
-	case db.MySQL, db.TiDB:
+	case db.MySQL, db.TiDB, db.MariaDB:
 		stmt = fmt.Sprintf("CREATE DATABASE `%s` CHARACTER SET %s COLLATE %s;", databaseName, characterSet, collation)
 		if schema != "" {
 			stmt = fmt.Sprintf("%s\nUSE `%s`;\n%s", stmt, databaseName, schema)
 		}
-	case db.Postgres:
+	case db.Postgres, db.CockroachDB:
 		if collation == "" {
 			stmt = fmt.Sprintf("CREATE DATABASE \"%s\" ENCODING %q;", databaseName, characterSet)
 		} else {
@@ -1015,6 +1023,14 @@ func getDatabaseNameAndStatement(dbType db.Type, databaseName, characterSet, col
 		if schema != "" {
 			stmt = fmt.Sprintf("%s\nUSE DATABASE %s;\n%s", stmt, databaseName, schema)
 		}
+	case db.SQLServer:
+		stmt = fmt.Sprintf("CREATE DATABASE [%s];", databaseName)
+		if collation != "" {
+			stmt = fmt.Sprintf("CREATE DATABASE [%s] COLLATE %s;", databaseName, collation)
+		}
+		if schema != "" {
+			stmt = fmt.Sprintf("%s\nUSE [%s];\n%s", stmt, databaseName, schema)
+		}
 	case db.SQLite:
 		// This is a fake CREATE DATABASE and USE statement since a single SQLite file represents a database. Engine driver will recognize it and establish a connection to create the sqlite file representing the database.
 		stmt = fmt.Sprintf("CREATE DATABASE '%s';", databaseName)
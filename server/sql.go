@@ -43,7 +43,8 @@ func (s *Server) registerSQLRoutes(g *echo.Group) {
 			password = adminPassword
 		}
 
-		db, err := db.Open(
+		resultSet := &api.SQLResultSet{}
+		if err := db.TestConnection(
 			ctx,
 			connectionInfo.Engine,
 			db.DriverConfig{Logger: s.l},
@@ -53,21 +54,12 @@ func (s *Server) registerSQLRoutes(g *echo.Group) {
 				Host:     connectionInfo.Host,
 				Port:     connectionInfo.Port,
 			},
-			db.ConnectionContext{},
-		)
-
-		resultSet := &api.SQLResultSet{}
-		if err != nil {
+		); err != nil {
 			hostPort := connectionInfo.Host
 			if connectionInfo.Port != "" {
 				hostPort += ":" + connectionInfo.Port
 			}
 			resultSet.Error = fmt.Errorf("failed to connect %q for user %q, %w", hostPort, connectionInfo.Username, err).Error()
-		} else {
-			defer db.Close(ctx)
-			if err := db.Ping(ctx); err != nil {
-				resultSet.Error = err.Error()
-			}
 		}
 
 		c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
@@ -634,8 +626,9 @@ func getLatestSchemaVersion(ctx context.Context, driver db.Driver, databaseName
 	// TODO(d): support semantic versioning.
 	limit := 1
 	history, err := driver.FindMigrationHistoryList(ctx, &db.MigrationHistoryFind{
-		Database: &databaseName,
-		Limit:    &limit,
+		Database:      &databaseName,
+		Limit:         &limit,
+		OmitStatement: true,
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to get migration history for database %q, error %v", databaseName, err)
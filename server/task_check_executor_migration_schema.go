@@ -54,6 +54,16 @@ func (exec *TaskCheckMigrationSchemaExecutor) Run(ctx context.Context, server *S
 
 	setup, err := driver.NeedsSetupMigration(ctx)
 	if err != nil {
+		if common.ErrorCode(err) == common.MigrationSchemaAccessDenied {
+			return []api.TaskCheckResult{
+				{
+					Status:  api.TaskCheckStatusError,
+					Code:    common.MigrationSchemaAccessDenied,
+					Title:   "Error",
+					Content: fmt.Sprintf("Insufficient privilege to check the migration schema for instance %q", instance.Name),
+				},
+			}, nil
+		}
 		return []api.TaskCheckResult{}, err
 	}
 
@@ -119,6 +119,9 @@ func runMigration(ctx context.Context, l *zap.Logger, server *Server, task *api.
 		mi.IssueID = strconv.Itoa(issue.ID)
 	}
 
+	// The statement has been reviewed and approved in the issue pipeline, so the destructive statements are allowed.
+	mi.AllowDestructive = true
+
 	statement = strings.TrimSpace(statement)
 	// Only baseline can have empty sql statement, which indicates empty database.
 	if mi.Type != db.Baseline && statement == "" {
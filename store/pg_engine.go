@@ -321,6 +321,7 @@ func migrate(ctx context.Context, d dbdriver.Driver, curVer *semver.Version, mod
 				Type:                  dbdriver.Migrate,
 				Description:           fmt.Sprintf("Initial migration version %s server version %s with file %s.", cutoffSchemaVersion, serverVersion, latestSchemaPath),
 				CreateDatabase:        true,
+				AllowDestructive:      true,
 			},
 			stmt,
 		); err != nil {
@@ -382,6 +383,7 @@ func migrate(ctx context.Context, d dbdriver.Driver, curVer *semver.Version, mod
 						Source:                dbdriver.LIBRARY,
 						Type:                  dbdriver.Migrate,
 						Description:           fmt.Sprintf("Migrate version %s server version %s with files %s.", pv.version, serverVersion, pv.filename),
+						AllowDestructive:      true,
 					},
 					string(buf),
 				); err != nil {
@@ -472,6 +474,7 @@ func migrateDev(ctx context.Context, d dbdriver.Driver, serverVersion, databaseN
 				Source:                dbdriver.LIBRARY,
 				Type:                  dbdriver.Migrate,
 				Description:           fmt.Sprintf("Migrate version %s server version %s with files %s.", m.version, serverVersion, m.filename),
+				AllowDestructive:      true,
 			},
 			m.statement,
 		); err != nil {